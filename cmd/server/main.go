@@ -2,24 +2,64 @@ package main
 
 import (
 	"context"
+	"errors"
+	"github.com/BinaryArchaism/mc-srv/internal/admin"
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/console"
+	"github.com/BinaryArchaism/mc-srv/internal/metrics"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/query"
+	"github.com/BinaryArchaism/mc-srv/internal/rcon"
 	"github.com/BinaryArchaism/mc-srv/internal/server"
+	"github.com/BinaryArchaism/mc-srv/internal/wasmplugin"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
 	"time"
 )
 
-func main() {
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).
+func newLogger(out io.Writer) zerolog.Logger {
+	return zerolog.New(zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}).
 		Level(zerolog.TraceLevel).
 		With().
 		Timestamp().
 		Caller().
 		Logger()
+}
+
+func main() {
+	log.Logger = newLogger(os.Stderr)
+
+	cfg, err := config.Load("config.json")
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load config")
+	}
+
+	wasmPlugins, err := wasmplugin.Load(cfg.Plugins.Dir, wasmplugin.Options{
+		MaxMemory:   cfg.Plugins.MaxMemoryMB << 20,
+		CallTimeout: time.Duration(cfg.Plugins.CallTimeoutMS) * time.Millisecond,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load plugins")
+	}
+	for _, p := range wasmPlugins {
+		server.RegisterPlugin(p)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	srv, _ := server.New()
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create server")
+	}
+	// players are saved when Run returns, the world is closed after that
+	ran := make(chan struct{})
+	go func() {
+		srv.Run(ctx)
+		close(ran)
+	}()
 	go func() {
 		err := srv.Accept(ctx)
 		if err != nil {
@@ -27,12 +67,108 @@ func main() {
 		}
 	}()
 
+	if cfg.RCON.Enabled {
+		rc, err := rcon.Listen(cfg.RCON.Address, rcon.Options{
+			Password:        cfg.RCON.Password,
+			MaxAuthFailures: cfg.RCON.MaxAuthFailures,
+			BlockDuration:   time.Duration(cfg.RCON.BlockSeconds) * time.Second,
+			IdleTimeout:     time.Duration(cfg.RCON.IdleSeconds) * time.Second,
+		}, srv.RemoteCommand)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to start rcon")
+		}
+		defer rc.Close()
+		go func() {
+			err := rc.Serve()
+			if err != nil {
+				log.Err(err).Msg("rcon stopped")
+			}
+		}()
+		log.Info().Str("address", rc.Addr().String()).Msg("rcon started")
+	}
+	if cfg.Query.Enabled {
+		q, err := query.Listen(cfg.Query.Address, srv.QueryStats)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to start query")
+		}
+		defer q.Close()
+		go func() {
+			err := q.Serve()
+			if err != nil {
+				log.Err(err).Msg("query stopped")
+			}
+		}()
+		log.Info().Str("address", q.Addr().String()).Msg("query started")
+	}
+	if cfg.Metrics.Enabled {
+		reg := metrics.NewRegistry(metrics.Sources{
+			TickStats: srv.TickStats,
+			PoolStats: protocol.Pool.Stats,
+		})
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler(reg))
+		hs := &http.Server{Addr: cfg.Metrics.Address, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		defer hs.Close()
+		go func() {
+			err := hs.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Err(err).Msg("metrics stopped")
+			}
+		}()
+		log.Info().Str("address", cfg.Metrics.Address).Msg("metrics started")
+	}
+	if cfg.Admin.Enabled {
+		handler, err := admin.NewHandler(cfg.Admin.Token, srv.Admin())
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to start admin api")
+		}
+		hs := &http.Server{Addr: cfg.Admin.Address, Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+		defer hs.Close()
+		go func() {
+			err := hs.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Err(err).Msg("admin api stopped")
+			}
+		}()
+		log.Info().Str("address", cfg.Admin.Address).Msg("admin api started")
+	}
+
+	cons, err := console.New(os.Stdin, os.Stdout, srv.CompleteCommand)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to open console")
+	}
+	log.Logger = newLogger(cons.Output(os.Stderr))
+	go func() {
+		err := cons.Run(srv.ConsoleCommand)
+		if errors.Is(err, console.ErrInterrupted) {
+			srv.Stop()
+			return
+		}
+		if err != nil {
+			log.Err(err).Msg("console stopped")
+		}
+	}()
+
 	log.Info().Msg("server started")
 	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, os.Interrupt)
-	<-osSignal
-	log.Info().Msg("server shutdown signal received")
+	select {
+	case <-osSignal:
+		log.Info().Msg("server shutdown signal received")
+	case <-srv.Done():
+		log.Info().Msg("server stop requested")
+	}
+	srv.Stop()
 	cancel()
+	<-ran
+	err = srv.World().Close()
+	if err != nil {
+		log.Err(err).Msg("failed to save world")
+	}
 	time.Sleep(1 * time.Second)
 	log.Info().Msg("server shutdown")
+	err = cons.Close()
+	if err != nil {
+		log.Err(err).Msg("failed to restore terminal")
+	}
 }
@@ -0,0 +1,205 @@
+// Package access keeps the vanilla player lists: operators, bans and the whitelist.
+package access
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TimeFormat is how ban dates are written in banned-players.json
+const TimeFormat = "2006-01-02 15:04:05 -0700"
+
+// Forever is the expiry of permanent bans
+const Forever = "forever"
+
+// Profile identifies a player
+type Profile struct {
+	UUID uuid.UUID `json:"uuid"`
+	Name string    `json:"name"`
+}
+
+func (p Profile) profile() Profile {
+	return p
+}
+
+// OfflineUUID returns the UUID vanilla gives to players of offline mode servers
+func OfflineUUID(name string) uuid.UUID {
+	id := uuid.UUID(md5.Sum([]byte("OfflinePlayer:" + name)))
+	id[6] = id[6]&0x0f | 0x30
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// Op is an entry of ops.json
+type Op struct {
+	Profile
+	Level               int  `json:"level"`
+	BypassesPlayerLimit bool `json:"bypassesPlayerLimit"`
+}
+
+// Ban is an entry of banned-players.json
+type Ban struct {
+	Profile
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// NewBan returns a permanent ban created now
+func NewBan(p Profile, source, reason string) Ban {
+	return Ban{Profile: p, Created: time.Now().Format(TimeFormat), Source: source, Expires: Forever, Reason: reason}
+}
+
+// Expired reports whether the ban no longer applies at now
+func (b Ban) Expired(now time.Time) bool {
+	if b.Expires == "" || b.Expires == Forever {
+		return false
+	}
+	expires, err := time.Parse(TimeFormat, b.Expires)
+	return err == nil && now.After(expires)
+}
+
+// WhitelistEntry is an entry of whitelist.json
+type WhitelistEntry struct {
+	Profile
+}
+
+type entry interface {
+	profile() Profile
+}
+
+// List is a player list saved as a JSON array, it is safe for concurrent use
+type List[E entry] struct {
+	path    string
+	mu      sync.RWMutex
+	entries []E
+}
+
+// Load reads the list at path, a missing file gives an empty list
+func Load[E entry](path string) (*List[E], error) {
+	l := &List[E]{path: path}
+	err := l.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload reads the list from its file again
+func (l *List[E]) Reload() error {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		data = []byte("[]")
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", l.path, err)
+	}
+	var entries []E
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", l.path, err)
+	}
+	l.mu.Lock()
+	l.entries = entries
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *List[E]) index(id uuid.UUID) int {
+	return slices.IndexFunc(l.entries, func(e E) bool { return e.profile().UUID == id })
+}
+
+// Get returns the entry of the player id
+func (l *List[E]) Get(id uuid.UUID) (E, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if i := l.index(id); i >= 0 {
+		return l.entries[i], true
+	}
+	var zero E
+	return zero, false
+}
+
+// Find returns the entry of the player name, ignoring case
+func (l *List[E]) Find(name string) (E, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, e := range l.entries {
+		if strings.EqualFold(e.profile().Name, name) {
+			return e, true
+		}
+	}
+	var zero E
+	return zero, false
+}
+
+// Add saves e, it reports false if the player is already listed
+func (l *List[E]) Add(e E) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.index(e.profile().UUID) >= 0 {
+		return false, nil
+	}
+	l.entries = append(l.entries, e)
+	return true, l.save()
+}
+
+// Remove removes the player id, it reports false if the player isn't listed
+func (l *List[E]) Remove(id uuid.UUID) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := l.index(id)
+	if i < 0 {
+		return false, nil
+	}
+	l.entries = slices.Delete(l.entries, i, i+1)
+	return true, l.save()
+}
+
+// Entries returns a copy of the list
+func (l *List[E]) Entries() []E {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Clone(l.entries)
+}
+
+// Names returns names of listed players
+func (l *List[E]) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := make([]string, len(l.entries))
+	for i, e := range l.entries {
+		res[i] = e.profile().Name
+	}
+	return res
+}
+
+func (l *List[E]) save() error {
+	entries := l.entries
+	if entries == nil {
+		entries = []E{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	err = os.Rename(tmp, l.path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	return nil
+}
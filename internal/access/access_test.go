@@ -0,0 +1,55 @@
+package access
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflineUUID(t *testing.T) {
+	// as computed by vanilla for offline players
+	require.Equal(t, uuid.MustParse("b50ad385-829d-3141-a216-7e7d7539ba7f"), OfflineUUID("Notch"))
+}
+
+func TestList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ops.json")
+	ops, err := Load[Op](path)
+	require.NoError(t, err)
+	require.Empty(t, ops.Entries())
+
+	alice := Profile{UUID: OfflineUUID("alice"), Name: "alice"}
+	added, err := ops.Add(Op{Profile: alice, Level: 4})
+	require.NoError(t, err)
+	require.True(t, added)
+	added, err = ops.Add(Op{Profile: alice, Level: 2})
+	require.NoError(t, err)
+	require.False(t, added)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"uuid":"`+alice.UUID.String()+`","name":"alice","level":4,"bypassesPlayerLimit":false}]`, string(data))
+
+	reloaded, err := Load[Op](path)
+	require.NoError(t, err)
+	op, ok := reloaded.Find("ALICE")
+	require.True(t, ok)
+	require.Equal(t, 4, op.Level)
+
+	removed, err := reloaded.Remove(alice.UUID)
+	require.NoError(t, err)
+	require.True(t, removed)
+	_, ok = reloaded.Get(alice.UUID)
+	require.False(t, ok)
+}
+
+func TestBan_Expired(t *testing.T) {
+	now := time.Now()
+	ban := NewBan(Profile{Name: "bob"}, "Server", "griefing")
+	require.False(t, ban.Expired(now))
+	ban.Expires = now.Add(-time.Hour).Format(TimeFormat)
+	require.True(t, ban.Expired(now))
+}
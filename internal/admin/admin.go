@@ -0,0 +1,205 @@
+// Package admin serves a token authenticated HTTP/JSON API for server
+// operations tooling.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxBodySize limits request bodies, they only carry short strings
+const maxBodySize = 64 << 10
+
+var (
+	ErrNoToken        = errors.New("admin api token is not set")
+	ErrPlayerNotFound = errors.New("player not found")
+	ErrAlreadyBanned  = errors.New("player is already banned")
+)
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+type Player struct {
+	Name     string   `json:"name"`
+	UUID     string   `json:"uuid"`
+	Address  string   `json:"address"`
+	PingMS   int64    `json:"ping"`
+	GameMode string   `json:"gameMode"`
+	Position Position `json:"position"`
+}
+
+type Status struct {
+	Version       string  `json:"version"`
+	Protocol      int     `json:"protocol"`
+	MOTD          string  `json:"motd"`
+	OnlinePlayers int     `json:"onlinePlayers"`
+	MaxPlayers    int     `json:"maxPlayers"`
+	TPS           float64 `json:"tps"`
+	MSPT          float64 `json:"mspt"`
+}
+
+// Backend carries out API calls, its methods are called from HTTP goroutines
+type Backend interface {
+	Players() []Player
+	// Kick disconnects an online player or returns ErrPlayerNotFound
+	Kick(name, reason string) error
+	// Ban bans an online or known player, an empty reason uses the default one
+	Ban(name, reason string) error
+	Broadcast(message string)
+	// RunCommand runs a console command and returns its output
+	RunCommand(command string) string
+	Status() Status
+}
+
+type api struct {
+	token   string
+	backend Backend
+}
+
+// NewHandler returns the API handler, requests must carry the token as
+// "Authorization: Bearer <token>"
+func NewHandler(token string, backend Backend) (http.Handler, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	a := &api{token: token, backend: backend}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", a.status)
+	mux.HandleFunc("GET /api/players", a.players)
+	mux.HandleFunc("POST /api/players/{name}/kick", a.kick)
+	mux.HandleFunc("POST /api/players/{name}/ban", a.ban)
+	mux.HandleFunc("POST /api/broadcast", a.broadcast)
+	mux.HandleFunc("POST /api/command", a.command)
+	return a.authenticate(mux), nil
+}
+
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			log.Warn().Str("client", r.RemoteAddr).Str("path", r.URL.Path).Msg("unauthorized admin api request")
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to write admin api response")
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// readBody decodes the JSON request body into v, an empty body leaves v as is
+func readBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return false
+	}
+	return true
+}
+
+func (a *api) status(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, a.backend.Status())
+}
+
+func (a *api) players(w http.ResponseWriter, _ *http.Request) {
+	players := a.backend.Players()
+	if players == nil {
+		players = []Player{}
+	}
+	writeJSON(w, http.StatusOK, players)
+}
+
+type reasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (a *api) kick(w http.ResponseWriter, r *http.Request) {
+	var req reasonRequest
+	if !readBody(w, r, &req) {
+		return
+	}
+	name := r.PathValue("name")
+	err := a.backend.Kick(name, req.Reason)
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	log.Info().Str("client", r.RemoteAddr).Str("player", name).Msg("admin api kicked player")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) ban(w http.ResponseWriter, r *http.Request) {
+	var req reasonRequest
+	if !readBody(w, r, &req) {
+		return
+	}
+	name := r.PathValue("name")
+	err := a.backend.Ban(name, req.Reason)
+	if err != nil {
+		writeError(w, errorCode(err), err)
+		return
+	}
+	log.Info().Str("client", r.RemoteAddr).Str("player", name).Msg("admin api banned player")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) broadcast(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if !readBody(w, r, &req) {
+		return
+	}
+	if req.Message == "" {
+		writeError(w, http.StatusBadRequest, errors.New("message is empty"))
+		return
+	}
+	a.backend.Broadcast(req.Message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) command(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Command string `json:"command"`
+	}
+	if !readBody(w, r, &req) {
+		return
+	}
+	if req.Command == "" {
+		writeError(w, http.StatusBadRequest, errors.New("command is empty"))
+		return
+	}
+	log.Info().Str("client", r.RemoteAddr).Str("command", req.Command).Msg("admin api command")
+	writeJSON(w, http.StatusOK, map[string]string{"output": a.backend.RunCommand(req.Command)})
+}
+
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrPlayerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyBanned):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	kicked    []string
+	banned    []string
+	broadcast []string
+}
+
+func (b *fakeBackend) Players() []Player {
+	return []Player{{Name: "alice", UUID: "id", Address: "127.0.0.1:1234", PingMS: 12, GameMode: "creative", Position: Position{X: 1, Y: 2, Z: 3}}}
+}
+
+func (b *fakeBackend) Kick(name, reason string) error {
+	if name != "alice" {
+		return ErrPlayerNotFound
+	}
+	b.kicked = append(b.kicked, name+": "+reason)
+	return nil
+}
+
+func (b *fakeBackend) Ban(name, reason string) error {
+	for _, n := range b.banned {
+		if n == name {
+			return ErrAlreadyBanned
+		}
+	}
+	b.banned = append(b.banned, name)
+	return nil
+}
+
+func (b *fakeBackend) Broadcast(message string) {
+	b.broadcast = append(b.broadcast, message)
+}
+
+func (b *fakeBackend) RunCommand(command string) string {
+	return "ran " + command
+}
+
+func (b *fakeBackend) Status() Status {
+	return Status{Version: "1.21", Protocol: 767, OnlinePlayers: 1, MaxPlayers: 20, TPS: 20}
+}
+
+func TestHandler(t *testing.T) {
+	_, err := NewHandler("", &fakeBackend{})
+	require.ErrorIs(t, err, ErrNoToken)
+
+	backend := &fakeBackend{}
+	handler, err := NewHandler("secret", backend)
+	require.NoError(t, err)
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/api/status", "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/api/status", "wrong", "").Code)
+
+	rec := do("GET", "/api/status", "secret", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.Equal(t, 767, status.Protocol)
+
+	rec = do("GET", "/api/players", "secret", "")
+	require.JSONEq(t, `[{"name":"alice","uuid":"id","address":"127.0.0.1:1234","ping":12,"gameMode":"creative","position":{"x":1,"y":2,"z":3}}]`, rec.Body.String())
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/api/players/alice/kick", "secret", `{"reason":"bye"}`).Code)
+	require.Equal(t, http.StatusNotFound, do("POST", "/api/players/bob/kick", "secret", "").Code)
+	require.Equal(t, []string{"alice: bye"}, backend.kicked)
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/api/players/bob/ban", "secret", "").Code)
+	require.Equal(t, http.StatusConflict, do("POST", "/api/players/bob/ban", "secret", "").Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/players/bob/ban", "secret", "{").Code)
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/api/broadcast", "secret", `{"message":"hi"}`).Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/broadcast", "secret", `{}`).Code)
+	require.Equal(t, []string{"hi"}, backend.broadcast)
+
+	rec = do("POST", "/api/command", "secret", `{"command":"list"}`)
+	require.JSONEq(t, `{"output":"ran list"}`, rec.Body.String())
+}
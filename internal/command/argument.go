@@ -0,0 +1,510 @@
+package command
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/google/uuid"
+)
+
+// Argument type ids of the minecraft:command_argument_type registry
+const (
+	parserBool        = 0
+	parserDouble      = 2
+	parserInteger     = 3
+	parserString      = 5
+	parserEntity      = 6
+	parserGameProfile = 7
+	parserBlockPos    = 8
+	parserVec3        = 10
+	parserItemStack   = 14
+	parserMessage     = 19
+	parserGameMode    = 41
+	parserTime        = 42
+)
+
+// Parser reads an argument value from the input
+type Parser interface {
+	Parse(r *Reader) (any, error)
+	// ID is the minecraft:command_argument_type id the client parses the argument with
+	ID() int32
+	// WriteProperties writes the parser properties of the Commands packet
+	WriteProperties(e *protocol.Encoder)
+}
+
+type boolParser struct{}
+
+// Bool parses true or false
+func Bool() Parser {
+	return boolParser{}
+}
+
+func (boolParser) Parse(r *Reader) (any, error) {
+	return r.ReadBool()
+}
+
+func (boolParser) ID() int32 {
+	return parserBool
+}
+
+func (boolParser) WriteProperties(*protocol.Encoder) {}
+
+const (
+	hasMin = 0x01
+	hasMax = 0x02
+)
+
+type integerParser struct {
+	min, max int
+}
+
+// Integer parses integers from min to max inclusive
+func Integer(min, max int) Parser {
+	return integerParser{min: min, max: max}
+}
+
+func (p integerParser) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+	v, err := r.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if v < p.min {
+		r.SetCursor(start)
+		return nil, r.Errorf("Integer must not be less than %d, found %d", p.min, v)
+	}
+	if v > p.max {
+		r.SetCursor(start)
+		return nil, r.Errorf("Integer must not be more than %d, found %d", p.max, v)
+	}
+	return v, nil
+}
+
+func (integerParser) ID() int32 {
+	return parserInteger
+}
+
+func (p integerParser) WriteProperties(e *protocol.Encoder) {
+	var flags byte
+	if p.min != math.MinInt32 {
+		flags |= hasMin
+	}
+	if p.max != math.MaxInt32 {
+		flags |= hasMax
+	}
+	e.Byte(flags)
+	if flags&hasMin != 0 {
+		e.Int(int32(p.min))
+	}
+	if flags&hasMax != 0 {
+		e.Int(int32(p.max))
+	}
+}
+
+type doubleParser struct {
+	min, max float64
+}
+
+// Double parses numbers from min to max inclusive
+func Double(min, max float64) Parser {
+	return doubleParser{min: min, max: max}
+}
+
+func (p doubleParser) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+	v, err := r.ReadDouble()
+	if err != nil {
+		return nil, err
+	}
+	if v < p.min || v > p.max {
+		r.SetCursor(start)
+		return nil, r.Errorf("Double must be between %g and %g, found %g", p.min, p.max, v)
+	}
+	return v, nil
+}
+
+func (doubleParser) ID() int32 {
+	return parserDouble
+}
+
+func (p doubleParser) WriteProperties(e *protocol.Encoder) {
+	var flags byte
+	if !math.IsInf(p.min, -1) {
+		flags |= hasMin
+	}
+	if !math.IsInf(p.max, 1) {
+		flags |= hasMax
+	}
+	e.Byte(flags)
+	if flags&hasMin != 0 {
+		e.Double(p.min)
+	}
+	if flags&hasMax != 0 {
+		e.Double(p.max)
+	}
+}
+
+// StringType is how much input a string argument takes
+type StringType int32
+
+const (
+	// SingleWord reads letters, digits and _-.+
+	SingleWord StringType = iota
+	// QuotablePhrase reads a word or a quoted string
+	QuotablePhrase
+	// GreedyPhrase reads the rest of the input
+	GreedyPhrase
+)
+
+type stringParser struct {
+	typ StringType
+}
+
+func String(typ StringType) Parser {
+	return stringParser{typ: typ}
+}
+
+func (p stringParser) Parse(r *Reader) (any, error) {
+	switch p.typ {
+	case GreedyPhrase:
+		s := r.Remaining()
+		r.SetCursor(len(r.Input()))
+		return s, nil
+	case QuotablePhrase:
+		return r.ReadString()
+	default:
+		return r.ReadUnquoted(), nil
+	}
+}
+
+func (stringParser) ID() int32 {
+	return parserString
+}
+
+func (p stringParser) WriteProperties(e *protocol.Encoder) {
+	e.VarInt(int32(p.typ))
+}
+
+type messageParser struct{}
+
+// Message reads the rest of the input as chat text
+func Message() Parser {
+	return messageParser{}
+}
+
+func (messageParser) Parse(r *Reader) (any, error) {
+	s := r.Remaining()
+	r.SetCursor(len(r.Input()))
+	return s, nil
+}
+
+func (messageParser) ID() int32 {
+	return parserMessage
+}
+
+func (messageParser) WriteProperties(*protocol.Encoder) {}
+
+// Selector is a parsed entity argument: a player name, a UUID or a target
+// selector such as @a
+type Selector struct {
+	// Type is 'p', 'r', 'a', 'e' or 's' for target selectors and 0 for names and UUIDs
+	Type byte
+	Name string
+	UUID uuid.UUID
+}
+
+// Single reports whether the selector matches at most one entity
+func (s Selector) Single() bool {
+	return s.Type != 'a' && s.Type != 'e'
+}
+
+func (s Selector) String() string {
+	if s.Type != 0 {
+		return "@" + string(s.Type)
+	}
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.UUID.String()
+}
+
+const (
+	entitySingle      = 0x01
+	entityPlayersOnly = 0x02
+)
+
+const maxPlayerNameLength = 16
+
+type entityParser struct {
+	single, playersOnly bool
+	// profile parsers accept names of offline players
+	profile bool
+}
+
+// Entity parses a target, single limits it to one entity and playersOnly to players
+func Entity(single, playersOnly bool) Parser {
+	return entityParser{single: single, playersOnly: playersOnly}
+}
+
+// GameProfile parses players that may be offline, as used by /ban and /op
+func GameProfile() Parser {
+	return entityParser{playersOnly: true, profile: true}
+}
+
+func (p entityParser) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+	if r.CanRead() && r.Peek() == '@' {
+		r.Skip()
+		if !r.CanRead() || !strings.ContainsRune("prase", rune(r.Peek())) {
+			return nil, r.Errorf("Unknown selector type '@%s'", r.ReadUntilSpace())
+		}
+		sel := Selector{Type: r.Peek()}
+		r.Skip()
+		if r.CanRead() && r.Peek() == '[' {
+			return nil, r.Errorf("Selector options are not supported")
+		}
+		if p.single && !sel.Single() {
+			r.SetCursor(start)
+			return nil, r.Errorf("Only one entity is allowed, but the provided selector allows more than one")
+		}
+		if p.playersOnly && sel.Type == 'e' {
+			r.SetCursor(start)
+			return nil, r.Errorf("Only players may be affected by this command, but the provided selector includes entities")
+		}
+		return sel, nil
+	}
+	s := r.ReadUnquoted()
+	if id, err := uuid.Parse(s); err == nil && strings.Count(s, "-") == 4 {
+		return Selector{UUID: id}, nil
+	}
+	if s == "" || len(s) > maxPlayerNameLength {
+		r.SetCursor(start)
+		return nil, r.Errorf("Invalid name or UUID")
+	}
+	return Selector{Name: s}, nil
+}
+
+func (p entityParser) ID() int32 {
+	if p.profile {
+		return parserGameProfile
+	}
+	return parserEntity
+}
+
+func (p entityParser) WriteProperties(e *protocol.Encoder) {
+	if p.profile {
+		return
+	}
+	var flags byte
+	if p.single {
+		flags |= entitySingle
+	}
+	if p.playersOnly {
+		flags |= entityPlayersOnly
+	}
+	e.Byte(flags)
+}
+
+// Coordinate is an absolute or a ~relative coordinate
+type Coordinate struct {
+	Value    float64
+	Relative bool
+}
+
+func (c Coordinate) Resolve(origin float64) float64 {
+	if c.Relative {
+		return origin + c.Value
+	}
+	return c.Value
+}
+
+// Coordinates is a parsed block_pos or vec3 argument
+type Coordinates struct {
+	X, Y, Z Coordinate
+}
+
+func (c Coordinates) Resolve(x, y, z float64) (float64, float64, float64) {
+	return c.X.Resolve(x), c.Y.Resolve(y), c.Z.Resolve(z)
+}
+
+// Block returns the block the resolved position is in
+func (c Coordinates) Block(x, y, z float64) (int, int, int) {
+	rx, ry, rz := c.Resolve(x, y, z)
+	return int(math.Floor(rx)), int(math.Floor(ry)), int(math.Floor(rz))
+}
+
+func readCoordinate(r *Reader, integer bool) (Coordinate, error) {
+	if !r.CanRead() {
+		return Coordinate{}, r.Errorf("Expected coordinate")
+	}
+	if r.Peek() == '^' {
+		return Coordinate{}, r.Errorf("Local coordinates are not supported")
+	}
+	var c Coordinate
+	if r.Peek() == '~' {
+		c.Relative = true
+		r.Skip()
+		if !r.CanRead() || r.Peek() == ' ' {
+			return c, nil
+		}
+	}
+	if integer && !c.Relative {
+		v, err := r.ReadInt()
+		c.Value = float64(v)
+		return c, err
+	}
+	v, err := r.ReadDouble()
+	c.Value = v
+	return c, err
+}
+
+type coordinatesParser struct {
+	integer bool
+}
+
+// BlockPos parses three integer coordinates, each may be ~relative
+func BlockPos() Parser {
+	return coordinatesParser{integer: true}
+}
+
+// Vec3 parses three coordinates, whole absolute x and z are moved to the block center
+func Vec3() Parser {
+	return coordinatesParser{}
+}
+
+func (p coordinatesParser) Parse(r *Reader) (any, error) {
+	var res Coordinates
+	for i, c := range [...]*Coordinate{&res.X, &res.Y, &res.Z} {
+		if i > 0 {
+			if !r.CanRead() || r.Peek() != ' ' {
+				return nil, r.Errorf("Incomplete (expected 3 coordinates)")
+			}
+			r.Skip()
+		}
+		start := r.Cursor()
+		v, err := readCoordinate(r, p.integer)
+		if err != nil {
+			return nil, err
+		}
+		if !p.integer && i != 1 && !v.Relative && !strings.Contains(r.Input()[start:r.Cursor()], ".") {
+			v.Value += 0.5
+		}
+		*c = v
+	}
+	return res, nil
+}
+
+func (p coordinatesParser) ID() int32 {
+	if p.integer {
+		return parserBlockPos
+	}
+	return parserVec3
+}
+
+func (coordinatesParser) WriteProperties(*protocol.Encoder) {}
+
+// GameModes are the names the gamemode argument accepts
+var GameModes = []string{"survival", "creative", "adventure", "spectator"}
+
+type gameModeParser struct{}
+
+// GameMode parses a game mode name
+func GameMode() Parser {
+	return gameModeParser{}
+}
+
+func (gameModeParser) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+	s := r.ReadUnquoted()
+	if !slices.Contains(GameModes, s) {
+		r.SetCursor(start)
+		return nil, r.Errorf("Unknown game mode: %s", s)
+	}
+	return s, nil
+}
+
+func (gameModeParser) ID() int32 {
+	return parserGameMode
+}
+
+func (gameModeParser) WriteProperties(*protocol.Encoder) {}
+
+// timeUnits are tick counts of the time argument suffixes
+var timeUnits = map[byte]float64{'d': 24000, 's': 20, 't': 1}
+
+type timeParser struct {
+	min int
+}
+
+// Time parses a duration in ticks, a d, s or t suffix gives days, seconds or ticks
+func Time(min int) Parser {
+	return timeParser{min: min}
+}
+
+func (p timeParser) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+	v, err := r.ReadDouble()
+	if err != nil {
+		return nil, err
+	}
+	unit := 1.0
+	if r.CanRead() && r.Peek() != ' ' {
+		u, ok := timeUnits[r.Peek()]
+		if !ok {
+			return nil, r.Errorf("Invalid unit")
+		}
+		unit = u
+		r.Skip()
+	}
+	ticks := int(math.Round(v * unit))
+	if ticks < p.min {
+		r.SetCursor(start)
+		return nil, r.Errorf("Tick count must not be less than %d, found %d", p.min, ticks)
+	}
+	return ticks, nil
+}
+
+func (timeParser) ID() int32 {
+	return parserTime
+}
+
+func (p timeParser) WriteProperties(e *protocol.Encoder) {
+	e.Int(int32(p.min))
+}
+
+type itemStackParser struct{}
+
+// ItemStack parses an item id, the namespace defaults to minecraft
+func ItemStack() Parser {
+	return itemStackParser{}
+}
+
+func isResourceChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c == '_' || c == '-' || c == '.' || c == '/' || c == ':'
+}
+
+func (itemStackParser) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+	for r.CanRead() && isResourceChar(r.Peek()) {
+		r.Skip()
+	}
+	id := r.Input()[start:r.Cursor()]
+	if id == "" {
+		return nil, r.Errorf("Expected item")
+	}
+	if r.CanRead() && r.Peek() == '[' {
+		return nil, r.Errorf("Item components are not supported")
+	}
+	if !strings.Contains(id, ":") {
+		id = "minecraft:" + id
+	}
+	return id, nil
+}
+
+func (itemStackParser) ID() int32 {
+	return parserItemStack
+}
+
+func (itemStackParser) WriteProperties(*protocol.Encoder) {}
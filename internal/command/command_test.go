@@ -0,0 +1,165 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/stretchr/testify/require"
+)
+
+type testSource struct {
+	level    int
+	messages []string
+}
+
+func (s *testSource) Name() string                 { return "test" }
+func (s *testSource) HasPermission(level int) bool { return s.level >= level }
+func (s *testSource) SendMessage(c text.Component) { s.messages = append(s.messages, c.String()) }
+func (s *testSource) Position() (x, y, z float64)  { return 10.5, 64, -3.5 }
+
+func testDispatcher(ran *[]string) *Dispatcher {
+	d := NewDispatcher()
+	d.Register(Literal("time").Then(
+		Literal("set").Then(
+			Argument("time", Integer(0, math.MaxInt32)).Executes(func(ctx *Context) error {
+				*ran = append(*ran, "set")
+				return nil
+			}),
+		),
+		Literal("query").Executes(func(ctx *Context) error {
+			*ran = append(*ran, "query")
+			return nil
+		}),
+	).Requires(LevelGameMaster))
+	d.Register(Literal("say").Then(
+		Argument("message", Message()).Executes(func(ctx *Context) error {
+			*ran = append(*ran, ctx.String("message"))
+			return nil
+		}),
+	))
+	d.Register(Literal("setblock").Then(
+		Argument("pos", BlockPos()).Executes(func(ctx *Context) error {
+			x, y, z := ctx.BlockPos("pos")
+			*ran = append(*ran, fmt.Sprint(x, y, z))
+			return nil
+		}),
+	))
+	kick := d.Register(Literal("kick").Then(
+		Argument("target", Entity(true, true)).
+			Suggests(func(ctx *Context, prefix string) []string { return []string{"alice", "bob"} }).
+			Executes(func(ctx *Context) error {
+				*ran = append(*ran, ctx.Selector("target").String())
+				return nil
+			}),
+	))
+	d.Register(Literal("k").Redirect(kick))
+	return d
+}
+
+func TestDispatcher_Execute(t *testing.T) {
+	var ran []string
+	d := testDispatcher(&ran)
+	admin := &testSource{level: LevelOwner}
+
+	require.NoError(t, d.Execute(admin, "time set 100"))
+	require.NoError(t, d.Execute(admin, "time query"))
+	require.NoError(t, d.Execute(admin, "say hello  world"))
+	require.NoError(t, d.Execute(admin, "setblock ~1 ~ 3"))
+	require.NoError(t, d.Execute(admin, "kick @s"))
+	require.NoError(t, d.Execute(admin, "k alice"))
+	require.Equal(t, []string{"set", "query", "hello  world", "11 64 3", "@s", "alice"}, ran)
+
+	var se *SyntaxError
+	err := d.Execute(admin, "time set -5")
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, "Integer must not be less than 0, found -5", se.Message)
+	require.Equal(t, 9, se.Cursor)
+
+	err = d.Execute(admin, "time")
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, "Unknown or incomplete command, see below for error", se.Message)
+
+	err = d.Execute(admin, "time foo")
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, "Incorrect argument for command", se.Message)
+	require.Equal(t, "time ", se.Context())
+
+	err = d.Execute(admin, "kick @a")
+	require.ErrorAs(t, err, &se)
+	require.Contains(t, se.Message, "Only one entity is allowed")
+
+	// commands above the permission level don't exist for the source
+	err = d.Execute(&testSource{}, "time query")
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, "Unknown command", se.Message)
+}
+
+func TestDispatcher_Suggest(t *testing.T) {
+	var ran []string
+	d := testDispatcher(&ran)
+	admin := &testSource{level: LevelOwner}
+
+	start, matches := d.Suggest(admin, "ti")
+	require.Equal(t, 0, start)
+	require.Equal(t, []string{"time"}, matches)
+
+	start, matches = d.Suggest(admin, "time ")
+	require.Equal(t, 5, start)
+	require.Equal(t, []string{"query", "set"}, matches)
+
+	start, matches = d.Suggest(admin, "kick b")
+	require.Equal(t, 5, start)
+	require.Equal(t, []string{"bob"}, matches)
+
+	_, matches = d.Suggest(&testSource{}, "t")
+	require.Empty(t, matches)
+
+	require.Equal(t, []string{"/k -> kick", "/kick <target>", "/say <message>", "/setblock <pos>"}, d.Usage(&testSource{}))
+}
+
+func TestDispatcher_Nodes(t *testing.T) {
+	var ran []string
+	d := testDispatcher(&ran)
+	nodes, root := d.Nodes(&testSource{})
+	require.Equal(t, int32(0), root)
+	// root, say, setblock, kick, k, message, pos, target
+	require.Len(t, nodes, 8)
+	require.Equal(t, "say", nodes[1].Name)
+	require.Equal(t, []int32{5}, nodes[1].Children)
+
+	target := nodes[7]
+	require.Equal(t, byte(protocol.CommandNodeArgument|protocol.CommandNodeExecutable|protocol.CommandNodeHasSuggestions), target.Flags)
+	require.Equal(t, int32(parserEntity), target.Parser)
+	require.Equal(t, []byte{entitySingle | entityPlayersOnly}, target.Properties)
+	require.Equal(t, byte(protocol.CommandNodeLiteral|protocol.CommandNodeHasRedirect), nodes[4].Flags)
+	require.Equal(t, int32(3), nodes[4].Redirect)
+
+	var buf bytes.Buffer
+	require.NoError(t, (&protocol.CommandsPacket{Nodes: nodes, Root: root}).Write(&buf))
+	p, err := protocol.ReadPacket(&buf)
+	require.NoError(t, err)
+	require.Equal(t, protocol.PlayCommandsID, p.ID)
+}
+
+func TestReader(t *testing.T) {
+	r := NewReader(`"a \"quoted\" word" rest`)
+	s, err := r.ReadString()
+	require.NoError(t, err)
+	require.Equal(t, `a "quoted" word`, s)
+	require.Equal(t, " rest", r.Remaining())
+
+	_, err = NewReader(`"unclosed`).ReadString()
+	require.Error(t, err)
+
+	v, err := Vec3().Parse(NewReader("1 2.5 ~-1"))
+	require.NoError(t, err)
+	x, y, z := v.(Coordinates).Resolve(0, 0, 10)
+	require.Equal(t, []float64{1.5, 2.5, 9}, []float64{x, y, z})
+
+	se := &SyntaxError{Message: "m", Input: "give player minecraft:stone", Cursor: 27}
+	require.Equal(t, "...raft:stone", se.Context())
+}
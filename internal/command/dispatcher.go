@@ -0,0 +1,317 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+)
+
+// askServer is the suggestion provider asking the server for completions
+const askServer = "minecraft:ask_server"
+
+// Dispatcher parses and runs commands of its tree, it is not safe for
+// concurrent use
+type Dispatcher struct {
+	root *Node
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{root: &Node{}}
+}
+
+// Register adds a root command, replacing a command of the same name
+func (d *Dispatcher) Register(n *Node) *Node {
+	for i, child := range d.root.children {
+		if child.name == n.name {
+			d.root.children[i] = n
+			return n
+		}
+	}
+	d.root.children = append(d.root.children, n)
+	return n
+}
+
+// Get returns the root command of the given name
+func (d *Dispatcher) Get(name string) *Node {
+	for _, child := range d.root.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Execute parses input, given without the leading slash, and runs it
+func (d *Dispatcher) Execute(src Source, input string) error {
+	ctx, executor, err := d.Parse(src, input)
+	if err != nil {
+		return err
+	}
+	return executor(ctx)
+}
+
+// Parse finds the command input runs, the error is a *SyntaxError
+func (d *Dispatcher) Parse(src Source, input string) (*Context, Executor, error) {
+	p := &parser{src: src, r: NewReader(input), ctx: &Context{Source: src, Input: input, args: map[string]any{}}}
+	end := p.children(d.root.children, true)
+	if end == nil {
+		return nil, nil, p.err
+	}
+	return p.ctx, end.executor, nil
+}
+
+type parser struct {
+	src Source
+	r   *Reader
+	ctx *Context
+	// err is the error that got the furthest
+	err *SyntaxError
+}
+
+func (p *parser) fail(err error) {
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		se = &SyntaxError{Message: err.Error(), Input: p.r.Input(), Cursor: p.r.Cursor()}
+	}
+	if p.err == nil || se.Cursor > p.err.Cursor {
+		p.err = se
+	}
+}
+
+// candidates returns nodes that may be parsed at the cursor, a matching
+// literal takes precedence over arguments
+func (p *parser) candidates(nodes []*Node) []*Node {
+	start := p.r.Cursor()
+	word := p.r.ReadUntilSpace()
+	p.r.SetCursor(start)
+	var res []*Node
+	for _, n := range nodes {
+		if !n.canUse(p.src) {
+			continue
+		}
+		if n.parser == nil && n.name == word {
+			return []*Node{n}
+		}
+		if n.parser != nil {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// children parses one of nodes and what follows it, it returns the node the command ends at
+func (p *parser) children(nodes []*Node, root bool) *Node {
+	start := p.r.Cursor()
+	candidates := p.candidates(nodes)
+	for _, n := range candidates {
+		p.r.SetCursor(start)
+		if n.parser == nil {
+			p.r.ReadUntilSpace()
+		} else {
+			v, err := n.parser.Parse(p.r)
+			if err != nil {
+				p.fail(err)
+				continue
+			}
+			if p.r.CanRead() && p.r.Peek() != ' ' {
+				p.fail(p.r.Errorf("Expected whitespace to end one argument, but found trailing data"))
+				continue
+			}
+			p.ctx.args[n.name] = v
+		}
+		if end := p.node(n); end != nil {
+			return end
+		}
+		if n.parser != nil {
+			// the branch failed, its arguments must not leak into the next one
+			delete(p.ctx.args, n.name)
+		}
+	}
+	p.r.SetCursor(start)
+	switch {
+	case len(candidates) > 0:
+	case root:
+		p.fail(p.r.Errorf("Unknown command"))
+	default:
+		p.fail(p.r.Errorf("Incorrect argument for command"))
+	}
+	return nil
+}
+
+// node continues parsing after n
+func (p *parser) node(n *Node) *Node {
+	if !p.r.CanRead() {
+		if n.executor == nil {
+			p.fail(p.r.Errorf("Unknown or incomplete command, see below for error"))
+			return nil
+		}
+		return n
+	}
+	p.r.Skip()
+	return p.children(n.next(), false)
+}
+
+// Suggest returns completions for the end of input and where they start
+func (d *Dispatcher) Suggest(src Source, input string) (int, []string) {
+	p := &parser{src: src, r: NewReader(input), ctx: &Context{Source: src, Input: input, args: map[string]any{}}}
+	start, matches := p.suggest(d.root.children)
+	if start < 0 {
+		return len(input), nil
+	}
+	slices.Sort(matches)
+	return start, slices.Compact(matches)
+}
+
+func (p *parser) suggest(nodes []*Node) (int, []string) {
+	start := p.r.Cursor()
+	rest := p.r.Remaining()
+	bestStart, best := -1, []string(nil)
+	add := func(s int, matches []string) {
+		if s > bestStart {
+			bestStart, best = s, matches
+		} else if s == bestStart {
+			best = append(best, matches...)
+		}
+	}
+	for _, n := range nodes {
+		if !n.canUse(p.src) {
+			continue
+		}
+		p.r.SetCursor(start)
+		if n.parser == nil {
+			word := p.r.ReadUntilSpace()
+			if word == n.name && p.r.CanRead() {
+				p.r.Skip()
+				add(p.suggest(n.next()))
+			} else if !p.r.CanRead() && strings.HasPrefix(n.name, strings.ToLower(word)) {
+				add(start, []string{n.name})
+			}
+			continue
+		}
+		v, err := n.parser.Parse(p.r)
+		if err == nil && p.r.CanRead() && p.r.Peek() == ' ' {
+			p.ctx.args[n.name] = v
+			p.r.Skip()
+			add(p.suggest(n.next()))
+			continue
+		}
+		if n.suggest != nil {
+			var matches []string
+			for _, s := range n.suggest(p.ctx, rest) {
+				if strings.HasPrefix(strings.ToLower(s), strings.ToLower(rest)) {
+					matches = append(matches, s)
+				}
+			}
+			add(start, matches)
+		}
+	}
+	return bestStart, best
+}
+
+// Nodes returns the tree visible to src as sent in the Commands packet
+func (d *Dispatcher) Nodes(src Source) ([]protocol.CommandNode, int32) {
+	index := map[*Node]int32{d.root: 0}
+	order := []*Node{d.root}
+	visit := func(n *Node) {
+		if _, ok := index[n]; !ok && n.canUse(src) {
+			index[n] = int32(len(order))
+			order = append(order, n)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		n := order[i]
+		for _, child := range n.children {
+			visit(child)
+		}
+		if n.redirect != nil {
+			visit(n.redirect)
+		}
+	}
+
+	res := make([]protocol.CommandNode, len(order))
+	for i, n := range order {
+		node := &res[i]
+		node.Name = n.name
+		switch {
+		case n == d.root:
+			node.Flags = protocol.CommandNodeRoot
+		case n.parser == nil:
+			node.Flags = protocol.CommandNodeLiteral
+		default:
+			node.Flags = protocol.CommandNodeArgument
+			node.Parser = n.parser.ID()
+			var e protocol.Encoder
+			n.parser.WriteProperties(&e)
+			node.Properties = e.Data()
+			if n.suggest != nil {
+				node.Flags |= protocol.CommandNodeHasSuggestions
+				node.Suggestions = askServer
+			}
+		}
+		if n.executor != nil {
+			node.Flags |= protocol.CommandNodeExecutable
+		}
+		if idx, ok := index[n.redirect]; ok && n.redirect != nil {
+			node.Flags |= protocol.CommandNodeHasRedirect
+			node.Redirect = idx
+		}
+		for _, child := range n.children {
+			if idx, ok := index[child]; ok {
+				node.Children = append(node.Children, idx)
+			}
+		}
+	}
+	return res, 0
+}
+
+// Usage returns a short usage line of every command src may run
+func (d *Dispatcher) Usage(src Source) []string {
+	var res []string
+	for _, n := range d.root.children {
+		if n.canUse(src) {
+			res = append(res, "/"+usage(n, src))
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+func (n *Node) usageName() string {
+	if n.parser == nil {
+		return n.name
+	}
+	return "<" + n.name + ">"
+}
+
+func usage(n *Node, src Source) string {
+	if n.redirect != nil {
+		return n.usageName() + " -> " + n.redirect.usageName()
+	}
+	var children []*Node
+	for _, child := range n.children {
+		if child.canUse(src) {
+			children = append(children, child)
+		}
+	}
+	if len(children) == 0 {
+		return n.usageName()
+	}
+	open, close := "(", ")"
+	if n.executor != nil {
+		open, close = "[", "]"
+	}
+	if len(children) == 1 {
+		inner := usage(children[0], src)
+		if n.executor != nil {
+			inner = open + inner + close
+		}
+		return n.usageName() + " " + inner
+	}
+	names := make([]string, len(children))
+	for i, child := range children {
+		names[i] = child.usageName()
+	}
+	return n.usageName() + " " + open + strings.Join(names, "|") + close
+}
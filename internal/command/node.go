@@ -0,0 +1,153 @@
+package command
+
+import (
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+)
+
+// Permission levels as in vanilla
+const (
+	LevelAll        = 0
+	LevelModerator  = 1
+	LevelGameMaster = 2
+	LevelAdmin      = 3
+	LevelOwner      = 4
+)
+
+// Source is whoever runs a command: a player, the console or a remote client
+type Source interface {
+	Name() string
+	HasPermission(level int) bool
+	SendMessage(c text.Component)
+	// Position is where relative coordinates are resolved from
+	Position() (x, y, z float64)
+}
+
+// Executor runs a command, a returned error is shown to the source
+type Executor func(ctx *Context) error
+
+// SuggestFunc returns completions of an argument, prefix is the typed part
+type SuggestFunc func(ctx *Context, prefix string) []string
+
+// Node is a literal or an argument of the command tree
+type Node struct {
+	name string
+	// parser is nil for literals
+	parser     Parser
+	children   []*Node
+	executor   Executor
+	permission int
+	redirect   *Node
+	suggest    SuggestFunc
+}
+
+// Literal creates a node matching name exactly
+func Literal(name string) *Node {
+	return &Node{name: name}
+}
+
+// Argument creates a node parsed by p and stored in the context under name
+func Argument(name string, p Parser) *Node {
+	return &Node{name: name, parser: p}
+}
+
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Then adds children to n
+func (n *Node) Then(children ...*Node) *Node {
+	n.children = append(n.children, children...)
+	return n
+}
+
+// Executes makes the command ending at n runnable
+func (n *Node) Executes(f Executor) *Node {
+	n.executor = f
+	return n
+}
+
+// Requires hides n from sources without the permission level
+func (n *Node) Requires(level int) *Node {
+	n.permission = level
+	return n
+}
+
+// Redirect continues parsing after n with the children of target
+func (n *Node) Redirect(target *Node) *Node {
+	n.redirect = target
+	return n
+}
+
+// Suggests makes the client ask the server for completions of the argument
+func (n *Node) Suggests(f SuggestFunc) *Node {
+	n.suggest = f
+	return n
+}
+
+func (n *Node) canUse(src Source) bool {
+	return n.permission == 0 || src.HasPermission(n.permission)
+}
+
+// next returns nodes that may follow n
+func (n *Node) next() []*Node {
+	if n.redirect != nil {
+		return n.redirect.children
+	}
+	return n.children
+}
+
+// Context holds parsed arguments of a command
+type Context struct {
+	Source Source
+	Input  string
+	args   map[string]any
+}
+
+// Arg returns the parsed value of the named argument
+func (c *Context) Arg(name string) any {
+	return c.args[name]
+}
+
+// Has reports whether the named argument was given
+func (c *Context) Has(name string) bool {
+	_, ok := c.args[name]
+	return ok
+}
+
+func (c *Context) Int(name string) int {
+	v, _ := c.args[name].(int)
+	return v
+}
+
+func (c *Context) Double(name string) float64 {
+	v, _ := c.args[name].(float64)
+	return v
+}
+
+func (c *Context) Bool(name string) bool {
+	v, _ := c.args[name].(bool)
+	return v
+}
+
+// String returns string, word and message arguments
+func (c *Context) String(name string) string {
+	v, _ := c.args[name].(string)
+	return v
+}
+
+func (c *Context) Selector(name string) Selector {
+	v, _ := c.args[name].(Selector)
+	return v
+}
+
+// BlockPos returns the named block position resolved against the source position
+func (c *Context) BlockPos(name string) (x, y, z int) {
+	v, _ := c.args[name].(Coordinates)
+	return v.Block(c.Source.Position())
+}
+
+// Vec3 returns the named position resolved against the source position
+func (c *Context) Vec3(name string) (x, y, z float64) {
+	v, _ := c.args[name].(Coordinates)
+	return v.Resolve(c.Source.Position())
+}
@@ -0,0 +1,188 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// contextLength is how much input before the error is shown
+const contextLength = 10
+
+// SyntaxError is a parse error at Cursor in Input
+type SyntaxError struct {
+	Message string
+	Input   string
+	Cursor  int
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Input == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s at position %d: %s<--[HERE]", e.Message, e.Cursor, e.Context())
+}
+
+// Context returns the input up to the error, shortened as in vanilla
+func (e *SyntaxError) Context() string {
+	cursor := min(e.Cursor, len(e.Input))
+	if cursor > contextLength {
+		return "..." + e.Input[cursor-contextLength:cursor]
+	}
+	return e.Input[:cursor]
+}
+
+// Reader walks the command input
+type Reader struct {
+	input  string
+	cursor int
+}
+
+func NewReader(input string) *Reader {
+	return &Reader{input: input}
+}
+
+func (r *Reader) Input() string {
+	return r.input
+}
+
+func (r *Reader) Cursor() int {
+	return r.cursor
+}
+
+func (r *Reader) SetCursor(cursor int) {
+	r.cursor = cursor
+}
+
+func (r *Reader) CanRead() bool {
+	return r.cursor < len(r.input)
+}
+
+func (r *Reader) Peek() byte {
+	return r.input[r.cursor]
+}
+
+func (r *Reader) Skip() {
+	r.cursor++
+}
+
+// Remaining returns the unread input
+func (r *Reader) Remaining() string {
+	return r.input[r.cursor:]
+}
+
+// Errorf returns a SyntaxError at the cursor
+func (r *Reader) Errorf(format string, args ...any) error {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Input: r.input, Cursor: r.cursor}
+}
+
+// ReadUntilSpace reads up to the next space or the end of input
+func (r *Reader) ReadUntilSpace() string {
+	start := r.cursor
+	for r.CanRead() && r.Peek() != ' ' {
+		r.cursor++
+	}
+	return r.input[start:r.cursor]
+}
+
+func isUnquoted(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c == '_' || c == '-' || c == '.' || c == '+'
+}
+
+// ReadUnquoted reads a word of letters, digits and _-.+
+func (r *Reader) ReadUnquoted() string {
+	start := r.cursor
+	for r.CanRead() && isUnquoted(r.Peek()) {
+		r.cursor++
+	}
+	return r.input[start:r.cursor]
+}
+
+// ReadString reads a quoted string or a word
+func (r *Reader) ReadString() (string, error) {
+	if !r.CanRead() {
+		return "", nil
+	}
+	quote := r.Peek()
+	if quote != '"' && quote != '\'' {
+		return r.ReadUnquoted(), nil
+	}
+	start := r.cursor
+	r.cursor++
+	var sb strings.Builder
+	escaped := false
+	for r.CanRead() {
+		c := r.Peek()
+		r.cursor++
+		switch {
+		case escaped:
+			if c != quote && c != '\\' {
+				r.cursor--
+				return "", r.Errorf("Invalid escape sequence '%c' in quoted string", c)
+			}
+			sb.WriteByte(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == quote:
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	r.cursor = start
+	return "", r.Errorf("Unclosed quoted string")
+}
+
+// readNumber reads characters a number may consist of
+func (r *Reader) readNumber() string {
+	start := r.cursor
+	for r.CanRead() && (r.Peek() >= '0' && r.Peek() <= '9' || r.Peek() == '.' || r.Peek() == '-') {
+		r.cursor++
+	}
+	return r.input[start:r.cursor]
+}
+
+func (r *Reader) ReadInt() (int, error) {
+	start := r.cursor
+	s := r.readNumber()
+	if s == "" {
+		return 0, r.Errorf("Expected integer")
+	}
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		r.cursor = start
+		return 0, r.Errorf("Invalid integer '%s'", s)
+	}
+	return int(v), nil
+}
+
+func (r *Reader) ReadDouble() (float64, error) {
+	start := r.cursor
+	s := r.readNumber()
+	if s == "" {
+		return 0, r.Errorf("Expected double")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		r.cursor = start
+		return 0, r.Errorf("Invalid double '%s'", s)
+	}
+	return v, nil
+}
+
+func (r *Reader) ReadBool() (bool, error) {
+	start := r.cursor
+	s := r.ReadUnquoted()
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return false, r.Errorf("Expected bool")
+	}
+	r.cursor = start
+	return false, r.Errorf("Invalid bool, expected true or false but found '%s'", s)
+}
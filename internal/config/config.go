@@ -0,0 +1,209 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+)
+
+const (
+	LevelTypeDefault = "default"
+	LevelTypeFlat    = "flat"
+	LevelTypeVoid    = "void"
+)
+
+type Config struct {
+	Address    string `json:"address"`
+	MaxPlayers int    `json:"maxPlayers"`
+	// ViewDistance caps the view distance requested by clients, in chunks
+	ViewDistance int `json:"viewDistance"`
+	// GameMode of joining players: survival, creative, adventure or spectator
+	GameMode string `json:"gameMode"`
+	// MOTD is the server description shown in the server list
+	MOTD text.Component `json:"motd"`
+	// Icon is the path of a 64x64 PNG shown in the server list, empty shows the default icon
+	Icon string `json:"icon"`
+
+	Level   Level   `json:"level"`
+	TabList TabList `json:"tabList"`
+	Chat    Chat    `json:"chat"`
+
+	SecureChat SecureChat `json:"secureChat"`
+	Access     Access     `json:"access"`
+	RCON       RCON       `json:"rcon"`
+	Query      Query      `json:"query"`
+	Metrics    Metrics    `json:"metrics"`
+	Admin      Admin      `json:"admin"`
+	Plugins    Plugins    `json:"plugins"`
+}
+
+// Plugins configures WebAssembly plugins loaded at start
+type Plugins struct {
+	// Dir holds the .wasm modules, a missing directory loads none
+	Dir string `json:"dir"`
+	// MaxMemoryMB caps the linear memory of every module
+	MaxMemoryMB int `json:"maxMemoryMB"`
+	// CallTimeoutMS caps every call into a module, modules running longer
+	// are stopped and disabled so they can't stall the tick
+	CallTimeoutMS int `json:"callTimeoutMS"`
+}
+
+// Admin is the HTTP/JSON admin API, it should only listen on trusted addresses
+type Admin struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+	// Token is required, the API doesn't start without it
+	Token string `json:"token"`
+}
+
+// Metrics is the HTTP endpoint serving Prometheus metrics at /metrics
+type Metrics struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+}
+
+// Query is the GameSpy4 UDP query listener
+type Query struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+}
+
+// RCON is the Source RCON remote console
+type RCON struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+	// Password is required, RCON doesn't start without it
+	Password string `json:"password"`
+	// MaxAuthFailures is how many wrong passwords an address may send before
+	// its connections are refused for BlockSeconds
+	MaxAuthFailures int `json:"maxAuthFailures"`
+	BlockSeconds    int `json:"blockSeconds"`
+	// IdleSeconds drops clients that send nothing for that long
+	IdleSeconds int `json:"idleSeconds"`
+}
+
+// Access locates the vanilla player lists, paths are relative to the working directory
+type Access struct {
+	OpsFile       string `json:"opsFile"`
+	BansFile      string `json:"bansFile"`
+	WhitelistFile string `json:"whitelistFile"`
+	// Whitelist lets only whitelisted players and operators join
+	Whitelist bool `json:"whitelist"`
+	// OpPermissionLevel is given to players made operators, from 1 to 4
+	OpPermissionLevel int `json:"opPermissionLevel"`
+}
+
+// SecureChat controls chat message signing
+type SecureChat struct {
+	// Enforce kicks players sending unsigned messages
+	Enforce bool `json:"enforce"`
+	// TrustedKeys are paths of PEM or DER public keys that sign profile keys
+	// of players, without them players can't sign messages
+	TrustedKeys []string `json:"trustedKeys"`
+}
+
+// Chat holds message templates, {player} is replaced with the player name and
+// {message} with the chat message
+type Chat struct {
+	Format       text.Component `json:"format"`
+	JoinMessage  text.Component `json:"joinMessage"`
+	LeaveMessage text.Component `json:"leaveMessage"`
+}
+
+// TabList is the player list header and footer. Their text may contain
+// {online}, {max}, {tps}, {mspt}, {player} and {ping} placeholders.
+type TabList struct {
+	Header text.Component `json:"header"`
+	Footer text.Component `json:"footer"`
+	// RefreshSeconds is how often header and footer are sent again to update
+	// placeholders, zero sends them on join only
+	RefreshSeconds int `json:"refreshSeconds"`
+}
+
+type Level struct {
+	// Dir is the vanilla world directory, empty keeps the world in memory only
+	Dir  string `json:"dir"`
+	Type string `json:"type"`
+	// Seed of the default level type, zero picks a random seed on startup
+	Seed int64 `json:"seed"`
+	// FlatLayers is the superflat layers string used by the flat level type,
+	// empty means the classic flat preset
+	FlatLayers string `json:"flatLayers"`
+	// PreloadRadius is the radius in chunks around spawn generated on startup
+	PreloadRadius int `json:"preloadRadius"`
+	// GenerationWorkers limits goroutines generating chunks, zero means number of CPUs
+	GenerationWorkers int `json:"generationWorkers"`
+	// DataPack is a directory holding data/<namespace>/recipe, such as the
+	// vanilla data extracted from the server jar. Its recipes replace and
+	// extend the built in ones.
+	DataPack string `json:"dataPack"`
+}
+
+func Default() Config {
+	return Config{
+		Address:      "0.0.0.0:8080",
+		MaxPlayers:   100,
+		ViewDistance: 10,
+		GameMode:     "survival",
+		MOTD:         text.Plain("Davai rabotai"),
+		Icon:         "../../resources/icon.png",
+		Level: Level{
+			Type:          LevelTypeFlat,
+			PreloadRadius: 4,
+		},
+		TabList: TabList{
+			RefreshSeconds: 5,
+		},
+		Chat: Chat{
+			Format:       text.Plain("<{player}> {message}"),
+			JoinMessage:  text.Component{Translate: "multiplayer.player.joined", With: []text.Component{text.Plain("{player}")}, Color: "yellow"},
+			LeaveMessage: text.Component{Translate: "multiplayer.player.left", With: []text.Component{text.Plain("{player}")}, Color: "yellow"},
+		},
+		Access: Access{
+			OpsFile:           "ops.json",
+			BansFile:          "banned-players.json",
+			WhitelistFile:     "whitelist.json",
+			OpPermissionLevel: 4,
+		},
+		RCON: RCON{
+			Address:         "0.0.0.0:25575",
+			MaxAuthFailures: 3,
+			BlockSeconds:    60,
+			IdleSeconds:     120,
+		},
+		Query: Query{
+			Address: "0.0.0.0:25565",
+		},
+		Metrics: Metrics{
+			Address: "127.0.0.1:9225",
+		},
+		Admin: Admin{
+			Address: "127.0.0.1:25580",
+		},
+		Plugins: Plugins{
+			Dir:           "plugins",
+			MaxMemoryMB:   16,
+			CallTimeoutMS: 20,
+		},
+	}
+}
+
+// Load reads JSON config from path on top of Default, missing file gives the default config
+func Load(path string) (Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,143 @@
+// Package console reads server commands from the standard input. On a
+// terminal it offers line editing, history and tab completion, piped input
+// is read line by line.
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupted is returned by Run when the terminal user presses Ctrl-C or Ctrl-D
+var ErrInterrupted = errors.New("console interrupted")
+
+const prompt = "> "
+
+// CompleteFunc returns completions for the end of input and where they start
+type CompleteFunc func(input string) (start int, matches []string)
+
+type Console struct {
+	in *os.File
+	// term is nil when the input or output isn't a terminal
+	term     *term.Terminal
+	state    *term.State
+	complete CompleteFunc
+}
+
+// New puts in into raw mode when both in and out are terminals, Close restores it
+func New(in, out *os.File, complete CompleteFunc) (*Console, error) {
+	c := &Console{in: in, complete: complete}
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return c, nil
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set terminal raw mode: %w", err)
+	}
+	c.state = state
+	c.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, prompt)
+	if width, height, err := term.GetSize(int(out.Fd())); err == nil {
+		_ = c.term.SetSize(width, height)
+	}
+	c.term.AutoCompleteCallback = c.autoComplete
+	return c, nil
+}
+
+// Output returns where logs must be written so they don't garble the
+// prompt, it is fallback when not on a terminal
+func (c *Console) Output(fallback io.Writer) io.Writer {
+	if c.term == nil {
+		return fallback
+	}
+	return c.term
+}
+
+// Run passes every entered line to handle until the input ends. Piped
+// input ending is not an error, on a terminal Ctrl-C and Ctrl-D give ErrInterrupted.
+func (c *Console) Run(handle func(line string)) error {
+	if c.term == nil {
+		return readLines(c.in, handle)
+	}
+	for {
+		line, err := c.term.ReadLine()
+		if errors.Is(err, term.ErrPasteIndicator) {
+			err = nil
+		}
+		if errors.Is(err, io.EOF) {
+			return ErrInterrupted
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read console: %w", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			handle(line)
+		}
+	}
+}
+
+func readLines(r io.Reader, handle func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			handle(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// Close clears the prompt and restores the terminal
+func (c *Console) Close() error {
+	if c.term == nil {
+		return nil
+	}
+	c.term.SetPrompt("")
+	_, _ = c.term.Write(nil)
+	return term.Restore(int(c.in.Fd()), c.state)
+}
+
+// autoComplete is called by the terminal for every key press with the
+// terminal locked, so listing matches is written after it returns
+func (c *Console) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || c.complete == nil {
+		return "", 0, false
+	}
+	input := strings.TrimPrefix(line[:pos], "/")
+	offset := pos - len(input)
+	start, matches := c.complete(input)
+	newLine, newPos, list := completeLine(line, pos, start+offset, matches)
+	if list {
+		go func() {
+			_, _ = c.term.Write([]byte(strings.Join(matches, "  ") + "\n"))
+		}()
+	}
+	return newLine, newPos, true
+}
+
+// completeLine fills the longest prefix shared by matches starting at
+// start, list is true when that adds nothing and matches should be shown
+func completeLine(line string, pos, start int, matches []string) (newLine string, newPos int, list bool) {
+	if len(matches) == 0 {
+		return line, pos, false
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		n := 0
+		for n < len(common) && n < len(m) && common[n] == m[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if start+len(common) <= pos {
+		return line, pos, len(matches) > 1
+	}
+	return line[:start] + common + line[pos:], start + len(common), false
+}
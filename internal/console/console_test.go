@@ -0,0 +1,38 @@
+package console
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLines(t *testing.T) {
+	var lines []string
+	err := readLines(strings.NewReader("list\n\n  say hi  \r\nstop"), func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"list", "say hi", "stop"}, lines)
+}
+
+func TestCompleteLine(t *testing.T) {
+	line, pos, list := completeLine("wea", 3, 0, []string{"weather"})
+	require.Equal(t, "weather", line)
+	require.Equal(t, 7, pos)
+	require.False(t, list)
+
+	line, pos, list = completeLine("time set n x", 10, 9, []string{"night", "noon"})
+	require.Equal(t, "time set n x", line)
+	require.Equal(t, 10, pos)
+	require.True(t, list)
+
+	line, pos, list = completeLine("op", 2, 0, []string{"op", "opt"})
+	require.Equal(t, "op", line)
+	require.Equal(t, 2, pos)
+	require.True(t, list)
+
+	line, pos, _ = completeLine("gamemode c", 10, 9, nil)
+	require.Equal(t, "gamemode c", line)
+	require.Equal(t, 10, pos)
+}
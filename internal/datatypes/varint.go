@@ -58,6 +58,9 @@ func (v *VarInt) Read(r io.ByteReader) error {
 			break
 		}
 		pos += shift
+		if pos > int32Len {
+			return ErrInvalidVarInt
+		}
 	}
 
 	*v = VarInt(res)
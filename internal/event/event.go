@@ -0,0 +1,71 @@
+// Package event dispatches typed events to handlers in priority order.
+//
+// Events are plain structs identified by their type, cancellable events
+// have a Cancelled field that handlers set and the firing code checks.
+package event
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// Priority orders handlers of an event, lower priorities run first so
+// higher ones see and may override their changes
+type Priority int
+
+const (
+	Lowest Priority = iota
+	Low
+	Normal
+	High
+	Highest
+	// Monitor handlers run last and should only observe the outcome
+	Monitor
+)
+
+// Bus is safe for concurrent use, the zero value is ready to use
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[reflect.Type][]handler
+}
+
+type handler struct {
+	priority Priority
+	fn       any
+}
+
+// Subscribe registers fn for events of type E, handlers of the same
+// priority run in the order they subscribed
+func Subscribe[E any](b *Bus, priority Priority, fn func(e *E)) {
+	typ := reflect.TypeFor[E]()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = map[reflect.Type][]handler{}
+	}
+	// handlers are copied on write so Fire can run them without the lock
+	hs := slices.Clone(b.handlers[typ])
+	i := len(hs)
+	for i > 0 && hs[i-1].priority > priority {
+		i--
+	}
+	b.handlers[typ] = slices.Insert(hs, i, handler{priority: priority, fn: fn})
+}
+
+// Fire runs handlers of E on the calling goroutine
+func Fire[E any](b *Bus, e *E) {
+	b.mu.RLock()
+	hs := b.handlers[reflect.TypeFor[E]()]
+	b.mu.RUnlock()
+	for _, h := range hs {
+		h.fn.(func(*E))(e)
+	}
+}
+
+// Has reports whether E has handlers, it lets callers skip building events nobody listens to
+func Has[E any](b *Bus) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.handlers[reflect.TypeFor[E]()]) > 0
+}
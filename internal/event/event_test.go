@@ -0,0 +1,36 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent struct {
+	Calls     []string
+	Cancelled bool
+}
+
+type otherEvent struct{}
+
+func TestBus(t *testing.T) {
+	var b Bus
+	require.False(t, Has[testEvent](&b))
+	Fire(&b, &testEvent{})
+
+	Subscribe(&b, Monitor, func(e *testEvent) { e.Calls = append(e.Calls, "monitor") })
+	Subscribe(&b, Normal, func(e *testEvent) { e.Calls = append(e.Calls, "normal 1") })
+	Subscribe(&b, Lowest, func(e *testEvent) {
+		e.Calls = append(e.Calls, "lowest")
+		e.Cancelled = true
+	})
+	Subscribe(&b, Normal, func(e *testEvent) { e.Calls = append(e.Calls, "normal 2") })
+	Subscribe(&b, Highest, func(e *testEvent) { e.Cancelled = false })
+
+	e := &testEvent{}
+	Fire(&b, e)
+	require.Equal(t, []string{"lowest", "normal 1", "normal 2", "monitor"}, e.Calls)
+	require.False(t, e.Cancelled)
+	require.True(t, Has[testEvent](&b))
+	require.False(t, Has[otherEvent](&b))
+}
@@ -0,0 +1,155 @@
+// Package metrics collects server metrics and exposes them in the Prometheus
+// text format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "mcsrv"
+
+var (
+	ConnectionsAccepted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "connections_accepted_total",
+		Help:      "Connections accepted by the listener.",
+	})
+	// ConnectionsRejected is labeled with the reason, e.g. login or handshake
+	ConnectionsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "connections_rejected_total",
+		Help:      "Connections refused before play.",
+	}, []string{"reason"})
+	Sessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sessions",
+		Help:      "Open sessions by protocol state.",
+	}, []string{"state"})
+
+	PacketsIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_received_total",
+		Help:      "Packets received by protocol state and packet ID.",
+	}, []string{"state", "id"})
+	PacketsOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_sent_total",
+		Help:      "Packets sent by protocol state and packet ID.",
+	}, []string{"state", "id"})
+	BytesIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_received_total",
+		Help:      "Bytes received by protocol state and packet ID, including framing.",
+	}, []string{"state", "id"})
+	BytesOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_sent_total",
+		Help:      "Bytes sent by protocol state and packet ID, including framing.",
+	}, []string{"state", "id"})
+
+	// ChunkCompressionRatio is compressed to uncompressed size of chunks written to region files
+	ChunkCompressionRatio = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "chunk_compression_ratio",
+		Help:      "Compressed to uncompressed size of stored chunks.",
+		Buckets:   prometheus.LinearBuckets(0.05, 0.05, 20),
+	})
+	ChunkGenerateSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "chunk_generate_seconds",
+		Help:      "Time to generate a chunk.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+	ChunkLoadSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "chunk_load_seconds",
+		Help:      "Time to read a chunk from storage.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+)
+
+// Sources are read on every scrape
+type Sources struct {
+	// TickStats returns ticks per second and milliseconds per tick
+	TickStats func() (tps, mspt float64)
+	// PoolStats returns objects in use by pool bucket size, -1 counts allocations
+	// too large for any bucket
+	PoolStats func() map[int]int32
+}
+
+// NewRegistry registers the server metrics, the Go runtime metrics, which
+// include the goroutine count, and metrics read from src
+func NewRegistry(src Sources) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ConnectionsAccepted, ConnectionsRejected, Sessions,
+		PacketsIn, PacketsOut, BytesIn, BytesOut,
+		ChunkCompressionRatio, ChunkGenerateSeconds, ChunkLoadSeconds,
+	)
+	if src.TickStats != nil {
+		reg.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "tps",
+				Help:      "Ticks per second averaged over the last ticks.",
+			}, func() float64 {
+				tps, _ := src.TickStats()
+				return tps
+			}),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "mspt",
+				Help:      "Milliseconds per tick averaged over the last ticks.",
+			}, func() float64 {
+				_, mspt := src.TickStats()
+				return mspt
+			}),
+		)
+	}
+	if src.PoolStats != nil {
+		reg.MustRegister(poolCollector(src.PoolStats))
+	}
+	return reg
+}
+
+// Handler serves metrics of reg
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
+
+var (
+	poolInUseDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bytespool", "in_use"),
+		"Buffers taken from a bytes pool bucket and not returned.",
+		[]string{"bucket"}, nil,
+	)
+	poolOverflowDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bytespool", "overflow_total"),
+		"Buffers allocated because they were larger than every bucket.",
+		nil, nil,
+	)
+)
+
+type poolCollector func() map[int]int32
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolInUseDesc
+	ch <- poolOverflowDesc
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for size, n := range c() {
+		if size < 0 {
+			ch <- prometheus.MustNewConstMetric(poolOverflowDesc, prometheus.CounterValue, float64(n))
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(poolInUseDesc, prometheus.GaugeValue, float64(n), strconv.Itoa(size))
+	}
+}
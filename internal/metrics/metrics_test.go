@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(Sources{
+		TickStats: func() (float64, float64) { return 19.5, 12 },
+		PoolStats: func() map[int]int32 { return map[int]int32{1024: 3, -1: 7} },
+	})
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP mcsrv_bytespool_in_use Buffers taken from a bytes pool bucket and not returned.
+# TYPE mcsrv_bytespool_in_use gauge
+mcsrv_bytespool_in_use{bucket="1024"} 3
+# HELP mcsrv_bytespool_overflow_total Buffers allocated because they were larger than every bucket.
+# TYPE mcsrv_bytespool_overflow_total counter
+mcsrv_bytespool_overflow_total 7
+# HELP mcsrv_mspt Milliseconds per tick averaged over the last ticks.
+# TYPE mcsrv_mspt gauge
+mcsrv_mspt 12
+# HELP mcsrv_tps Ticks per second averaged over the last ticks.
+# TYPE mcsrv_tps gauge
+mcsrv_tps 19.5
+`), "mcsrv_bytespool_in_use", "mcsrv_bytespool_overflow_total", "mcsrv_mspt", "mcsrv_tps")
+	require.NoError(t, err)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	require.Contains(t, names, "go_goroutines")
+}
@@ -0,0 +1,284 @@
+package nbt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// sizedReader is a reader that knows how many bytes are left, such as bytes.Reader
+type sizedReader interface {
+	Len() int
+}
+
+// maxPrealloc caps the elements allocated ahead of reading them, sizes of
+// streamed input can't be checked and may be made up
+const maxPrealloc = 1024
+
+// minPayloadSize is the fewest bytes a payload of every tag type takes
+var minPayloadSize = [...]int64{
+	TagByte: 1, TagShort: 2, TagInt: 4, TagLong: 8, TagFloat: 4, TagDouble: 8,
+	TagByteArray: 4, TagString: 2, TagList: 5, TagCompound: 1, TagIntArray: 4, TagLongArray: 4,
+}
+
+type decoder struct {
+	r   byteReader
+	buf [8]byte
+}
+
+// newDecoder reads r directly if it is a byte reader, so that nothing past the
+// root compound is consumed when NBT is embedded in a packet
+func newDecoder(r io.Reader) decoder {
+	if br, ok := r.(byteReader); ok {
+		return decoder{r: br}
+	}
+	return decoder{r: bufio.NewReader(r)}
+}
+
+// Read decodes a named root compound as stored in region and level files
+func Read(r io.Reader) (string, Compound, error) {
+	d := newDecoder(r)
+	t, err := d.readType()
+	if err != nil {
+		return "", nil, err
+	}
+	if t != TagCompound {
+		return "", nil, ErrInvalidRoot
+	}
+	name, err := d.readString()
+	if err != nil {
+		return "", nil, err
+	}
+	c, err := d.readCompound(0)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, c, nil
+}
+
+// ReadNetwork decodes a nameless root compound used by the protocol since 1.20.2
+func ReadNetwork(r io.Reader) (Compound, error) {
+	d := newDecoder(r)
+	t, err := d.readType()
+	if err != nil {
+		return nil, err
+	}
+	if t == TagEnd {
+		return nil, nil
+	}
+	if t != TagCompound {
+		return nil, ErrInvalidRoot
+	}
+	return d.readCompound(0)
+}
+
+// ReadNetworkTag decodes a nameless root tag of any type, text components
+// are sent as strings when they are plain text
+func ReadNetworkTag(r io.Reader) (any, error) {
+	d := newDecoder(r)
+	t, err := d.readType()
+	if err != nil {
+		return nil, err
+	}
+	if t == TagEnd {
+		return nil, nil
+	}
+	return d.readPayload(t, 0)
+}
+
+func (d *decoder) readType() (TagType, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return TagEnd, err
+	}
+	if b > byte(TagLongArray) {
+		return TagEnd, fmt.Errorf("%w: %d", ErrInvalidTag, b)
+	}
+	return TagType(b), nil
+}
+
+func (d *decoder) readN(n int) ([]byte, error) {
+	_, err := io.ReadFull(d.r, d.buf[:n])
+	return d.buf[:n], err
+}
+
+func (d *decoder) readString() (string, error) {
+	b, err := d.readN(2)
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, binary.BigEndian.Uint16(b))
+	_, err = io.ReadFull(d.r, data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readSize reads the length of an array or list whose elements take at
+// least elem bytes, lengths longer than the input left are rejected
+func (d *decoder) readSize(elem int64) (int, error) {
+	b, err := d.readN(4)
+	if err != nil {
+		return 0, err
+	}
+	n := int32(binary.BigEndian.Uint32(b))
+	if n < 0 {
+		return 0, ErrNegativeSize
+	}
+	if r, ok := d.r.(sizedReader); ok && int64(n)*elem > int64(r.Len()) {
+		return 0, fmt.Errorf("%w: %d", ErrSizeTooLarge, n)
+	}
+	return int(n), nil
+}
+
+func (d *decoder) readCompound(depth int) (Compound, error) {
+	if depth > maxDepth {
+		return nil, ErrDepthLimit
+	}
+	c := Compound{}
+	for {
+		t, err := d.readType()
+		if err != nil {
+			return nil, err
+		}
+		if t == TagEnd {
+			return c, nil
+		}
+		name, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.readPayload(t, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		c[name] = v
+	}
+}
+
+func (d *decoder) readPayload(t TagType, depth int) (any, error) {
+	switch t {
+	case TagByte:
+		b, err := d.r.ReadByte()
+		return int8(b), err
+	case TagShort:
+		b, err := d.readN(2)
+		if err != nil {
+			return nil, err
+		}
+		return int16(binary.BigEndian.Uint16(b)), nil
+	case TagInt:
+		b, err := d.readN(4)
+		if err != nil {
+			return nil, err
+		}
+		return int32(binary.BigEndian.Uint32(b)), nil
+	case TagLong:
+		b, err := d.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case TagFloat:
+		b, err := d.readN(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case TagDouble:
+		b, err := d.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case TagByteArray:
+		n, err := d.readSize(1)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, 0, min(n, maxPrealloc))
+		for len(data) < n {
+			step := min(n-len(data), max(len(data), maxPrealloc))
+			data = append(data, make([]byte, step)...)
+			_, err = io.ReadFull(d.r, data[len(data)-step:])
+			if err != nil {
+				return nil, err
+			}
+		}
+		res := make([]int8, n)
+		for i, b := range data {
+			res[i] = int8(b)
+		}
+		return res, nil
+	case TagString:
+		return d.readString()
+	case TagList:
+		return d.readList(depth)
+	case TagCompound:
+		return d.readCompound(depth)
+	case TagIntArray:
+		n, err := d.readSize(4)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]int32, 0, min(n, maxPrealloc))
+		for range n {
+			b, err := d.readN(4)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, int32(binary.BigEndian.Uint32(b)))
+		}
+		return res, nil
+	case TagLongArray:
+		n, err := d.readSize(8)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]int64, 0, min(n, maxPrealloc))
+		for range n {
+			b, err := d.readN(8)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, int64(binary.BigEndian.Uint64(b)))
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrInvalidTag, t)
+}
+
+func (d *decoder) readList(depth int) (List, error) {
+	if depth > maxDepth {
+		return List{}, ErrDepthLimit
+	}
+	t, err := d.readType()
+	if err != nil {
+		return List{}, err
+	}
+	n, err := d.readSize(minPayloadSize[t])
+	if err != nil {
+		return List{}, err
+	}
+	l := List{Type: t}
+	if t == TagEnd || n == 0 {
+		return l, nil
+	}
+	l.Items = make([]any, 0, min(n, maxPrealloc))
+	for range n {
+		v, err := d.readPayload(t, depth+1)
+		if err != nil {
+			return List{}, err
+		}
+		l.Items = append(l.Items, v)
+	}
+	return l, nil
+}
@@ -0,0 +1,215 @@
+package nbt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+type encoder struct {
+	w   *bufio.Writer
+	buf [8]byte
+}
+
+// Write encodes c as a named root compound as stored in region and level files
+func Write(w io.Writer, name string, c Compound) error {
+	e := encoder{w: bufio.NewWriter(w)}
+	err := e.w.WriteByte(byte(TagCompound))
+	if err != nil {
+		return err
+	}
+	err = e.writeString(name)
+	if err != nil {
+		return err
+	}
+	err = e.writeCompound(c)
+	if err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// WriteNetwork encodes c as a nameless root compound used by the protocol since 1.20.2,
+// nil compound is written as a single TAG_End
+func WriteNetwork(w io.Writer, c Compound) error {
+	e := encoder{w: bufio.NewWriter(w)}
+	if c == nil {
+		err := e.w.WriteByte(byte(TagEnd))
+		if err != nil {
+			return err
+		}
+		return e.w.Flush()
+	}
+	err := e.w.WriteByte(byte(TagCompound))
+	if err != nil {
+		return err
+	}
+	err = e.writeCompound(c)
+	if err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *encoder) writeString(s string) error {
+	if len(s) > math.MaxUint16 {
+		return fmt.Errorf("%w: string too long", ErrUnsupported)
+	}
+	binary.BigEndian.PutUint16(e.buf[:2], uint16(len(s)))
+	_, err := e.w.Write(e.buf[:2])
+	if err != nil {
+		return err
+	}
+	_, err = e.w.WriteString(s)
+	return err
+}
+
+func (e *encoder) writeInt(v int32) error {
+	binary.BigEndian.PutUint32(e.buf[:4], uint32(v))
+	_, err := e.w.Write(e.buf[:4])
+	return err
+}
+
+func (e *encoder) writeLong(v int64) error {
+	binary.BigEndian.PutUint64(e.buf[:8], uint64(v))
+	_, err := e.w.Write(e.buf[:8])
+	return err
+}
+
+func (e *encoder) writeCompound(c Compound) error {
+	// keys are sorted so that equal compounds always produce equal bytes
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := c[k]
+		t, err := TypeOf(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		err = e.w.WriteByte(byte(t))
+		if err != nil {
+			return err
+		}
+		err = e.writeString(k)
+		if err != nil {
+			return err
+		}
+		err = e.writePayload(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return e.w.WriteByte(byte(TagEnd))
+}
+
+func (e *encoder) writePayload(v any) error {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return e.w.WriteByte(1)
+		}
+		return e.w.WriteByte(0)
+	case int8:
+		return e.w.WriteByte(byte(v))
+	case int16:
+		binary.BigEndian.PutUint16(e.buf[:2], uint16(v))
+		_, err := e.w.Write(e.buf[:2])
+		return err
+	case int32:
+		return e.writeInt(v)
+	case int64:
+		return e.writeLong(v)
+	case float32:
+		return e.writeInt(int32(math.Float32bits(v)))
+	case float64:
+		return e.writeLong(int64(math.Float64bits(v)))
+	case []int8:
+		err := e.writeInt(int32(len(v)))
+		if err != nil {
+			return err
+		}
+		for _, b := range v {
+			err = e.w.WriteByte(byte(b))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []byte:
+		err := e.writeInt(int32(len(v)))
+		if err != nil {
+			return err
+		}
+		_, err = e.w.Write(v)
+		return err
+	case string:
+		return e.writeString(v)
+	case List:
+		return e.writeList(v)
+	case Compound:
+		return e.writeCompound(v)
+	case map[string]any:
+		return e.writeCompound(v)
+	case []int32:
+		err := e.writeInt(int32(len(v)))
+		if err != nil {
+			return err
+		}
+		for _, i := range v {
+			err = e.writeInt(i)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []int64:
+		err := e.writeInt(int32(len(v)))
+		if err != nil {
+			return err
+		}
+		for _, l := range v {
+			err = e.writeLong(l)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %T", ErrUnsupported, v)
+}
+
+func (e *encoder) writeList(l List) error {
+	t := l.Type
+	if len(l.Items) == 0 {
+		t = TagEnd
+	}
+	err := e.w.WriteByte(byte(t))
+	if err != nil {
+		return err
+	}
+	err = e.writeInt(int32(len(l.Items)))
+	if err != nil {
+		return err
+	}
+	for _, it := range l.Items {
+		itType, err := TypeOf(it)
+		if err != nil {
+			return err
+		}
+		if itType != t {
+			return fmt.Errorf("%w: list of %d contains %d", ErrInvalidTag, t, itType)
+		}
+		err = e.writePayload(it)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
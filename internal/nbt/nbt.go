@@ -0,0 +1,203 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidTag   = errors.New("invalid nbt tag")
+	ErrUnsupported  = errors.New("unsupported nbt value")
+	ErrDepthLimit   = errors.New("nbt nesting too deep")
+	ErrInvalidRoot  = errors.New("nbt root is not a compound")
+	ErrNegativeSize = errors.New("negative nbt array size")
+	ErrSizeTooLarge = errors.New("nbt array size exceeds input")
+)
+
+// TagType is the one byte id that precedes every named tag and list payload
+type TagType byte
+
+const (
+	TagEnd TagType = iota
+	TagByte
+	TagShort
+	TagInt
+	TagLong
+	TagFloat
+	TagDouble
+	TagByteArray
+	TagString
+	TagList
+	TagCompound
+	TagIntArray
+	TagLongArray
+)
+
+// maxDepth mirrors the vanilla limit for nested lists and compounds
+const maxDepth = 512
+
+// Compound is the in-memory form of TAG_Compound.
+// Values are int8, int16, int32, int64, float32, float64, []int8, string,
+// List, Compound, []int32 or []int64.
+type Compound map[string]any
+
+// List is the in-memory form of TAG_List, Type is the type of every element
+type List struct {
+	Type  TagType
+	Items []any
+}
+
+func NewList(t TagType, items ...any) List {
+	return List{Type: t, Items: items}
+}
+
+func (c Compound) Has(key string) bool {
+	_, ok := c[key]
+	return ok
+}
+
+func (c Compound) Byte(key string) int8 {
+	v, _ := c[key].(int8)
+	return v
+}
+
+func (c Compound) Bool(key string) bool {
+	return c.Byte(key) != 0
+}
+
+func (c Compound) Short(key string) int16 {
+	v, _ := c[key].(int16)
+	return v
+}
+
+// Int returns the value under key widened from any integer tag type
+func (c Compound) Int(key string) int32 {
+	switch v := c[key].(type) {
+	case int8:
+		return int32(v)
+	case int16:
+		return int32(v)
+	case int32:
+		return v
+	case int64:
+		return int32(v)
+	}
+	return 0
+}
+
+func (c Compound) Long(key string) int64 {
+	switch v := c[key].(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	}
+	return 0
+}
+
+func (c Compound) Float(key string) float32 {
+	switch v := c[key].(type) {
+	case float32:
+		return v
+	case float64:
+		return float32(v)
+	}
+	return 0
+}
+
+func (c Compound) Double(key string) float64 {
+	switch v := c[key].(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func (c Compound) String(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c Compound) Compound(key string) Compound {
+	v, _ := c[key].(Compound)
+	return v
+}
+
+func (c Compound) List(key string) List {
+	v, _ := c[key].(List)
+	return v
+}
+
+func (c Compound) ByteArray(key string) []int8 {
+	v, _ := c[key].([]int8)
+	return v
+}
+
+func (c Compound) IntArray(key string) []int32 {
+	v, _ := c[key].([]int32)
+	return v
+}
+
+func (c Compound) LongArray(key string) []int64 {
+	v, _ := c[key].([]int64)
+	return v
+}
+
+// Compounds returns list items that are compounds, other items are skipped
+func (l List) Compounds() []Compound {
+	res := make([]Compound, 0, len(l.Items))
+	for _, it := range l.Items {
+		if c, ok := it.(Compound); ok {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Strings returns list items that are strings, other items are skipped
+func (l List) Strings() []string {
+	res := make([]string, 0, len(l.Items))
+	for _, it := range l.Items {
+		if s, ok := it.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// TypeOf reports the tag type used to encode v
+func TypeOf(v any) (TagType, error) {
+	switch v.(type) {
+	case int8, bool:
+		return TagByte, nil
+	case int16:
+		return TagShort, nil
+	case int32:
+		return TagInt, nil
+	case int64:
+		return TagLong, nil
+	case float32:
+		return TagFloat, nil
+	case float64:
+		return TagDouble, nil
+	case []int8, []byte:
+		return TagByteArray, nil
+	case string:
+		return TagString, nil
+	case List:
+		return TagList, nil
+	case Compound, map[string]any:
+		return TagCompound, nil
+	case []int32:
+		return TagIntArray, nil
+	case []int64:
+		return TagLongArray, nil
+	}
+	return TagEnd, fmt.Errorf("%w: %T", ErrUnsupported, v)
+}
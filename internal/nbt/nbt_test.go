@@ -0,0 +1,98 @@
+package nbt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c := Compound{
+		"byte":      int8(-3),
+		"short":     int16(1234),
+		"int":       int32(-123456),
+		"long":      int64(1 << 40),
+		"float":     float32(1.5),
+		"double":    2.25,
+		"bytes":     []int8{1, -1, 2},
+		"string":    "hello",
+		"ints":      []int32{1, 2, 3},
+		"longs":     []int64{-1, 0, 1},
+		"list":      NewList(TagString, "a", "b"),
+		"emptyList": NewList(TagEnd),
+		"nested": Compound{
+			"list": NewList(TagCompound, Compound{"x": int32(1)}, Compound{"x": int32(2)}),
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, "root", c)
+	require.NoError(t, err)
+
+	name, res, err := Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "root", name)
+	require.Equal(t, c, res)
+	require.Equal(t, int32(2), res.Compound("nested").List("list").Compounds()[1].Int("x"))
+}
+
+func TestWrite_Bytes(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, "hello world", Compound{"name": "Bananrama"})
+	require.NoError(t, err)
+
+	// example from the NBT specification
+	exp := []byte{
+		0x0a, 0x00, 0x0b, 'h', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd',
+		0x08, 0x00, 0x04, 'n', 'a', 'm', 'e',
+		0x00, 0x09, 'B', 'a', 'n', 'a', 'n', 'r', 'a', 'm', 'a',
+		0x00,
+	}
+	require.Equal(t, exp, buf.Bytes())
+}
+
+func TestNetwork(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := WriteNetwork(buf, Compound{"a": int8(1)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x0a, 0x01, 0x00, 0x01, 'a', 0x01, 0x00}, buf.Bytes())
+
+	res, err := ReadNetwork(buf)
+	require.NoError(t, err)
+	require.Equal(t, Compound{"a": int8(1)}, res)
+
+	buf.Reset()
+	err = WriteNetwork(buf, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00}, buf.Bytes())
+}
+
+func TestRead_Invalid(t *testing.T) {
+	_, _, err := Read(bytes.NewReader([]byte{0x08, 0x00, 0x00}))
+	require.ErrorIs(t, err, ErrInvalidRoot)
+
+	_, _, err = Read(bytes.NewReader([]byte{0x0a, 0x00, 0x00, 0x0f}))
+	require.ErrorIs(t, err, ErrInvalidTag)
+
+	err = Write(bytes.NewBuffer(nil), "", Compound{"x": 1})
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestRead_SizeLargerThanInput(t *testing.T) {
+	huge := []byte{0x7f, 0xff, 0xff, 0xff}
+	for _, payload := range [][]byte{
+		append([]byte{byte(TagByteArray)}, huge...),
+		append([]byte{byte(TagIntArray)}, huge...),
+		append([]byte{byte(TagLongArray)}, append(huge, 1, 2, 3, 4, 5, 6, 7, 8)...),
+		append([]byte{byte(TagList), byte(TagLong)}, huge...),
+	} {
+		_, err := ReadNetworkTag(bytes.NewReader(payload))
+		require.ErrorIs(t, err, ErrSizeTooLarge)
+
+		// streamed input has no known size, reading stops when it runs out
+		_, err = ReadNetworkTag(io.MultiReader(bytes.NewReader(payload)))
+		require.Error(t, err)
+	}
+}
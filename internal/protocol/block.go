@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"io"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+// Clientbound block packet IDs
+const (
+	PlayAcknowledgeBlockChangeID = 0x05
+	PlayBlockDestroyStageID      = 0x06
+	PlayBlockActionID            = 0x08
+	PlayBlockUpdateID            = 0x09
+	PlayWorldEventID             = 0x28
+)
+
+// Serverbound block packet IDs
+const (
+	PlayPlayerActionID = 0x24
+	PlayUseItemOnID    = 0x38
+)
+
+// Player Action statuses
+const (
+	ActionStartDigging    = 0
+	ActionCancelDigging   = 1
+	ActionFinishDigging   = 2
+	ActionDropItemStack   = 3
+	ActionDropItem        = 4
+	ActionReleaseUseItem  = 5
+	ActionSwapItemInHands = 6
+)
+
+// BlockActionChestViewers tells chests how many players look inside to open the lid
+const BlockActionChestViewers = 1
+
+// Block faces
+const (
+	FaceBottom = 0
+	FaceTop    = 1
+	FaceNorth  = 2
+	FaceSouth  = 3
+	FaceWest   = 4
+	FaceEast   = 5
+)
+
+// WorldEventBlockBreak plays block break particles and sound, its data is the block state id
+const WorldEventBlockBreak = 2001
+
+type PlayerActionPacket struct {
+	Status   int32
+	Location datatypes.Position
+	Face     byte
+	Sequence int32
+}
+
+func (p *PlayerActionPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Status = d.VarInt()
+	p.Location = d.Position()
+	p.Face = d.Byte()
+	p.Sequence = d.VarInt()
+	return d.Err()
+}
+
+// UseItemOnPacket is sent when the player right clicks a block, the cursor
+// is the position clicked within the block
+type UseItemOnPacket struct {
+	Hand                      int32
+	Location                  datatypes.Position
+	Face                      int32
+	CursorX, CursorY, CursorZ float32
+	InsideBlock               bool
+	Sequence                  int32
+}
+
+func (p *UseItemOnPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Hand = d.VarInt()
+	p.Location = d.Position()
+	p.Face = d.VarInt()
+	p.CursorX = d.Float()
+	p.CursorY = d.Float()
+	p.CursorZ = d.Float()
+	p.InsideBlock = d.Bool()
+	p.Sequence = d.VarInt()
+	return d.Err()
+}
+
+// AcknowledgeBlockChangePacket tells the client changes it predicted up to
+// Sequence are confirmed or corrected
+type AcknowledgeBlockChangePacket struct {
+	Sequence int32
+}
+
+func (p *AcknowledgeBlockChangePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.Sequence)
+	return WritePacket(w, PlayAcknowledgeBlockChangeID, e.Data())
+}
+
+type BlockUpdatePacket struct {
+	Location datatypes.Position
+	Block    world.BlockState
+}
+
+func (p *BlockUpdatePacket) Write(w io.Writer) error {
+	id, _ := registry.BlockStateID(p.Block)
+	var e Encoder
+	e.Position(p.Location)
+	e.VarInt(id)
+	return WritePacket(w, PlayBlockUpdateID, e.Data())
+}
+
+// BlockDestroyStagePacket shows the cracks of a block being broken by
+// EntityID, stages above 9 remove them
+type BlockDestroyStagePacket struct {
+	EntityID int32
+	Location datatypes.Position
+	Stage    byte
+}
+
+func (p *BlockDestroyStagePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.EntityID)
+	e.Position(p.Location)
+	e.Byte(p.Stage)
+	return WritePacket(w, PlayBlockDestroyStageID, e.Data())
+}
+
+type WorldEventPacket struct {
+	Event    int32
+	Location datatypes.Position
+	Data     int32
+	// Global plays the event regardless of distance
+	Global bool
+}
+
+func (p *WorldEventPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Int(p.Event)
+	e.Position(p.Location)
+	e.Int(p.Data)
+	e.Bool(p.Global)
+	return WritePacket(w, PlayWorldEventID, e.Data())
+}
+
+// BlockActionPacket triggers a block animation such as a chest lid opening
+type BlockActionPacket struct {
+	Location datatypes.Position
+	Action   byte
+	Param    byte
+	// BlockID is the block registry id, not a state id
+	BlockID int32
+}
+
+func (p *BlockActionPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Position(p.Location)
+	e.Byte(p.Action)
+	e.Byte(p.Param)
+	e.VarInt(p.BlockID)
+	return WritePacket(w, PlayBlockActionID, e.Data())
+}
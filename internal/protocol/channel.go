@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	ConfigPluginMessageID = 0x01
+	PlayPluginMessageID   = 0x19
+
+	ConfigServerClientInformationID = 0x00
+	ConfigServerPluginMessageID     = 0x02
+	ConfigServerFinishAckID         = 0x03
+	ConfigServerKnownPacksID        = 0x07
+	PlayServerPluginMessageID       = 0x12
+)
+
+const (
+	// MaxServerboundPayload and MaxClientboundPayload are the vanilla custom payload limits
+	MaxServerboundPayload = 32767
+	MaxClientboundPayload = 1 << 20
+	// maxIdentifierLength is the longest channel name
+	maxIdentifierLength = 32767
+)
+
+// PluginMessagePacket carries a custom payload on a named channel, Config
+// selects the configuration packet ID instead of the play one
+type PluginMessagePacket struct {
+	Channel string
+	Data    []byte
+	Config  bool
+}
+
+func (p *PluginMessagePacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Channel = d.String(maxIdentifierLength)
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if d.Remaining() > MaxServerboundPayload {
+		return fmt.Errorf("%w: payload of %d bytes", ErrPacketTooLarge, d.Remaining())
+	}
+	p.Data = d.Rest()
+	return d.Err()
+}
+
+func (p *PluginMessagePacket) Write(w io.Writer) error {
+	if len(p.Data) > MaxClientboundPayload {
+		return fmt.Errorf("%w: payload of %d bytes", ErrPacketTooLarge, len(p.Data))
+	}
+	var e Encoder
+	e.String(p.Channel)
+	e.Raw(p.Data)
+	id := int32(PlayPluginMessageID)
+	if p.Config {
+		id = ConfigPluginMessageID
+	}
+	return WritePacket(w, id, e.Data())
+}
@@ -0,0 +1,155 @@
+package protocol
+
+import (
+	"io"
+
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/google/uuid"
+)
+
+const (
+	PlayPlayerChatID = 0x39
+	PlaySystemChatID = 0x6C
+
+	PlayChatAckID           = 0x03
+	PlayChatMessageID       = 0x06
+	PlayChatSessionUpdateID = 0x07
+)
+
+// ChatTypeChat is the registry id of minecraft:chat
+const ChatTypeChat = 0
+
+// filterPassThrough is the filter mask of messages shown as they are
+const filterPassThrough = 0
+
+const (
+	// MaxChatLength is the longest chat message a client may send, in characters
+	MaxChatLength = 256
+	// MessageSignatureSize is the size of a chat message signature
+	MessageSignatureSize = 256
+	// ackBitsetSize holds 20 bits of acknowledged messages
+	ackBitsetSize = 3
+	// maxPreviousMessages is the number of last seen messages in Player Chat
+	maxPreviousMessages = 20
+	maxPublicKeySize    = 512
+	maxKeySignatureSize = 4096
+)
+
+// ChatMessagePacket is a chat message sent by the player
+type ChatMessagePacket struct {
+	Message   string
+	Timestamp int64
+	Salt      int64
+	// Signature is nil for unsigned messages
+	Signature    []byte
+	MessageCount int32
+	Acknowledged [ackBitsetSize]byte
+}
+
+func (p *ChatMessagePacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Message = d.String(MaxChatLength)
+	p.Timestamp = d.Long()
+	p.Salt = d.Long()
+	p.Signature = nil
+	if d.Bool() {
+		p.Signature = d.Bytes(MessageSignatureSize)
+	}
+	p.MessageCount = d.VarInt()
+	copy(p.Acknowledged[:], d.Bytes(ackBitsetSize))
+	return d.Err()
+}
+
+// SystemChatPacket shows a message in chat, or above the hotbar when Overlay is set
+type SystemChatPacket struct {
+	Content text.Component
+	Overlay bool
+}
+
+func (p *SystemChatPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.NBT(p.Content.NBT())
+	e.Bool(p.Overlay)
+	return WritePacket(w, PlaySystemChatID, e.Data())
+}
+
+// ChatSessionUpdatePacket carries the profile public key the player signs messages with
+type ChatSessionUpdatePacket struct {
+	SessionID uuid.UUID
+	// ExpiresAt is in unix milliseconds
+	ExpiresAt    int64
+	PublicKey    []byte
+	KeySignature []byte
+}
+
+func (p *ChatSessionUpdatePacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.SessionID = d.UUID()
+	p.ExpiresAt = d.Long()
+	p.PublicKey = d.ByteArray(maxPublicKeySize)
+	p.KeySignature = d.ByteArray(maxKeySignatureSize)
+	return d.Err()
+}
+
+// ChatAckPacket acknowledges messages without sending one
+type ChatAckPacket struct {
+	MessageCount int32
+}
+
+func (p *ChatAckPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.MessageCount = d.VarInt()
+	return d.Err()
+}
+
+// PlayerChatPacket is a signed player message, the client checks the
+// signature with the key from the sender's chat session
+type PlayerChatPacket struct {
+	Sender uuid.UUID
+	// Index is the position of the message in the chain of the sender
+	Index     int32
+	Signature []byte
+	Message   string
+	// Timestamp is in unix milliseconds
+	Timestamp int64
+	Salt      int64
+	// PreviousMessages holds signatures of messages the sender acknowledged
+	PreviousMessages [][]byte
+	// UnsignedContent is shown instead of Message when set
+	UnsignedContent *text.Component
+	ChatType        int32
+	SenderName      text.Component
+	TargetName      *text.Component
+}
+
+func (p *PlayerChatPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.UUID(p.Sender)
+	e.VarInt(p.Index)
+	e.Bool(p.Signature != nil)
+	if p.Signature != nil {
+		e.Raw(p.Signature)
+	}
+	e.String(p.Message)
+	e.Long(p.Timestamp)
+	e.Long(p.Salt)
+	e.VarInt(int32(min(len(p.PreviousMessages), maxPreviousMessages)))
+	for _, sig := range p.PreviousMessages[:min(len(p.PreviousMessages), maxPreviousMessages)] {
+		// zero id means the full signature follows
+		e.VarInt(0)
+		e.Raw(sig)
+	}
+	e.Bool(p.UnsignedContent != nil)
+	if p.UnsignedContent != nil {
+		e.NBT(p.UnsignedContent.NBT())
+	}
+	e.VarInt(filterPassThrough)
+	// the chat type is a registry id offset by one, zero is an inline definition
+	e.VarInt(p.ChatType + 1)
+	e.NBT(p.SenderName.NBT())
+	e.Bool(p.TargetName != nil)
+	if p.TargetName != nil {
+		e.NBT(p.TargetName.NBT())
+	}
+	return WritePacket(w, PlayPlayerChatID, e.Data())
+}
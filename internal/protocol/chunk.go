@@ -1,4 +1,274 @@
 package protocol
 
+import (
+	"io"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+const (
+	maxIndirectBlockBits = 8
+	minIndirectBlockBits = 4
+	maxIndirectBiomeBits = 3
+)
+
+var fullSkyLight = func() []byte {
+	b := make([]byte, world.LightLength)
+	for i := range b {
+		b[i] = 0xFF
+	}
+	return b
+}()
+
+// ChunkPacket is Chunk Data and Update Light, it carries a whole chunk column
+// together with its light
 type ChunkPacket struct {
+	X, Z int32
+
+	Heightmaps    nbt.Compound
+	Data          []byte
+	BlockEntities []ChunkBlockEntity
+
+	// Light masks have a bit per section plus one section below and one above the world
+	SkyLightMask        []int64
+	BlockLightMask      []int64
+	EmptySkyLightMask   []int64
+	EmptyBlockLightMask []int64
+	SkyLight            [][]byte
+	BlockLight          [][]byte
+}
+
+// ChunkBlockEntity is a block entity the client renders, such as a chest
+type ChunkBlockEntity struct {
+	Pos  world.BlockPos
+	Type int32
+	// Data is what the client needs of the block entity, chests need none
+	Data nbt.Compound
+}
+
+type sectionSnapshot struct {
+	nonAir       int
+	palette      []world.BlockState
+	blocks       []uint16
+	biomePalette []string
+	biomes       []uint16
+	blockLight   []byte
+	skyLight     []byte
+}
+
+// NewChunkPacket encodes chunk c. Sections without stored sky light are lit
+// from above down to the highest block of every column.
+func NewChunkPacket(c *world.Chunk) *ChunkPacket {
+	c.Lock()
+	sections := make([]sectionSnapshot, len(c.Sections))
+	for i, s := range c.Sections {
+		// BlockData compacts the palette, so the chunk is locked for writing
+		sections[i].palette, sections[i].blocks = s.BlockData()
+		sections[i].biomePalette, sections[i].biomes = s.BiomeData()
+		sections[i].nonAir = s.NonAirCount()
+		sections[i].blockLight = append([]byte(nil), s.BlockLight...)
+		sections[i].skyLight = append([]byte(nil), s.SkyLight...)
+	}
+	p := &ChunkPacket{X: c.X, Z: c.Z}
+	for pos, data := range c.BlockEntities {
+		typ, ok := registry.BlockEntityTypeID(data.String("id"))
+		if !ok {
+			continue
+		}
+		p.BlockEntities = append(p.BlockEntities, ChunkBlockEntity{Pos: pos, Type: typ, Data: nbt.Compound{}})
+	}
+	c.Unlock()
+
+	heights := heightmap(sections)
+	packed := world.PackIndexes(heights[:], world.BitsFor(len(sections)*world.SectionWidth+1, 1))
+	p.Heightmaps = nbt.Compound{
+		"MOTION_BLOCKING": packed,
+		"WORLD_SURFACE":   packed,
+	}
+
+	var e Encoder
+	for _, s := range sections {
+		e.Short(int16(s.nonAir))
+		writeBlockStates(&e, s.palette, s.blocks)
+		writeBiomes(&e, s.biomePalette, s.biomes)
+	}
+	p.Data = e.Data()
+
+	count := len(sections) + 2
+	p.SkyLightMask = make([]int64, (count+63)/64)
+	p.BlockLightMask = make([]int64, (count+63)/64)
+	p.EmptySkyLightMask = make([]int64, (count+63)/64)
+	p.EmptyBlockLightMask = make([]int64, (count+63)/64)
+	for i := range count {
+		sky := fullSkyLight
+		var block []byte
+		switch {
+		case i == 0:
+			sky = nil
+		case i <= len(sections):
+			s := sections[i-1]
+			sky = s.skyLight
+			if len(sky) != world.LightLength {
+				sky = computeSkyLight(heights, i-1)
+			}
+			if len(s.blockLight) == world.LightLength {
+				block = s.blockLight
+			}
+		}
+		if sky == nil {
+			p.EmptySkyLightMask[i/64] |= 1 << (i % 64)
+		} else {
+			p.SkyLightMask[i/64] |= 1 << (i % 64)
+			p.SkyLight = append(p.SkyLight, sky)
+		}
+		if block == nil {
+			p.EmptyBlockLightMask[i/64] |= 1 << (i % 64)
+		} else {
+			p.BlockLightMask[i/64] |= 1 << (i % 64)
+			p.BlockLight = append(p.BlockLight, block)
+		}
+	}
+	return p
+}
+
+// heightmap returns per column number of blocks from the bottom of the world
+// up to and including the highest non air block, in ZX order
+func heightmap(sections []sectionSnapshot) [world.SectionWidth * world.SectionWidth]uint16 {
+	var res [world.SectionWidth * world.SectionWidth]uint16
+	found := 0
+	for i := len(sections) - 1; i >= 0 && found < len(res); i-- {
+		s := sections[i]
+		if s.nonAir == 0 {
+			continue
+		}
+		for column := range res {
+			if res[column] != 0 {
+				continue
+			}
+			for y := world.SectionWidth - 1; y >= 0; y-- {
+				if !s.palette[s.blocks[y<<8|column]].IsAir() {
+					res[column] = uint16(i*world.SectionWidth + y + 1)
+					found++
+					break
+				}
+			}
+		}
+	}
+	return res
+}
+
+// computeSkyLight returns full light above the heightmap and darkness below it,
+// nil means the whole section is dark
+func computeSkyLight(heights [world.SectionWidth * world.SectionWidth]uint16, section int) []byte {
+	bottom := section * world.SectionWidth
+	lit, dark := true, true
+	for _, h := range heights {
+		lit = lit && int(h) <= bottom
+		dark = dark && int(h) >= bottom+world.SectionWidth
+	}
+	if lit {
+		return fullSkyLight
+	}
+	if dark {
+		return nil
+	}
+	res := make([]byte, world.LightLength)
+	for column, h := range heights {
+		x, z := column&0xF, column>>4
+		for y := max(int(h)-bottom, 0); y < world.SectionWidth; y++ {
+			world.SetLight(res, x, y, z, 15)
+		}
+	}
+	return res
+}
+
+func writeBlockStates(e *Encoder, palette []world.BlockState, blocks []uint16) {
+	ids := make([]int32, len(palette))
+	for i, b := range palette {
+		ids[i], _ = registry.BlockStateID(b)
+	}
+	if len(ids) == 1 {
+		e.Byte(0)
+		e.VarInt(ids[0])
+		e.VarInt(0)
+		return
+	}
+	bits := world.BitsFor(len(ids), minIndirectBlockBits)
+	if bits > maxIndirectBlockBits {
+		direct := make([]uint16, len(blocks))
+		for i, v := range blocks {
+			direct[i] = uint16(ids[v])
+		}
+		bits = world.BitsFor(int(registry.StateCount()), 1)
+		e.Byte(byte(bits))
+		e.Longs(world.PackIndexes(direct, bits))
+		return
+	}
+	e.Byte(byte(bits))
+	e.VarInt(int32(len(ids)))
+	for _, id := range ids {
+		e.VarInt(id)
+	}
+	e.Longs(world.PackIndexes(blocks, bits))
+}
+
+func writeBiomes(e *Encoder, palette []string, biomes []uint16) {
+	ids := make([]int32, len(palette))
+	for i, b := range palette {
+		ids[i], _ = registry.BiomeID(b)
+	}
+	if len(ids) == 1 {
+		e.Byte(0)
+		e.VarInt(ids[0])
+		e.VarInt(0)
+		return
+	}
+	bits := world.BitsFor(len(ids), 1)
+	if bits > maxIndirectBiomeBits {
+		direct := make([]uint16, len(biomes))
+		for i, v := range biomes {
+			direct[i] = uint16(ids[v])
+		}
+		bits = world.BitsFor(len(registry.Biomes), 1)
+		e.Byte(byte(bits))
+		e.Longs(world.PackIndexes(direct, bits))
+		return
+	}
+	e.Byte(byte(bits))
+	e.VarInt(int32(len(ids)))
+	for _, id := range ids {
+		e.VarInt(id)
+	}
+	e.Longs(world.PackIndexes(biomes, bits))
+}
+
+func (p *ChunkPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Int(p.X)
+	e.Int(p.Z)
+	e.NBT(p.Heightmaps)
+	e.ByteArray(p.Data)
+	e.VarInt(int32(len(p.BlockEntities)))
+	for _, be := range p.BlockEntities {
+		e.Byte(byte((be.Pos.X&0xF)<<4 | be.Pos.Z&0xF))
+		e.Short(int16(be.Pos.Y))
+		e.VarInt(be.Type)
+		e.NBT(be.Data)
+	}
+
+	e.Longs(p.SkyLightMask)
+	e.Longs(p.BlockLightMask)
+	e.Longs(p.EmptySkyLightMask)
+	e.Longs(p.EmptyBlockLightMask)
+	e.VarInt(int32(len(p.SkyLight)))
+	for _, l := range p.SkyLight {
+		e.ByteArray(l)
+	}
+	e.VarInt(int32(len(p.BlockLight)))
+	for _, l := range p.BlockLight {
+		e.ByteArray(l)
+	}
+	return WritePacket(w, PlayChunkDataID, e.Data())
 }
@@ -0,0 +1,178 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWritePacket(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, (&SetCenterChunkPacket{X: -3, Z: 300}).Write(&buf))
+	require.NoError(t, (&ChunkBatchStartPacket{}).Write(&buf))
+
+	r := bufio.NewReader(&buf)
+	p, err := ReadPacket(r)
+	require.NoError(t, err)
+	require.Equal(t, PlaySetCenterChunkID, p.ID)
+	d := NewDecoder(p.Data)
+	require.Equal(t, int32(-3), d.VarInt())
+	require.Equal(t, int32(300), d.VarInt())
+	require.NoError(t, d.Err())
+	require.Zero(t, d.Remaining())
+
+	p, err = ReadPacket(r)
+	require.NoError(t, err)
+	require.Equal(t, PlayChunkBatchStartID, p.ID)
+	require.Empty(t, p.Data)
+
+	_, err = ReadPacket(bufio.NewReader(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0x7F})))
+	require.ErrorIs(t, err, ErrPacketTooLarge)
+}
+
+func TestDecoder_Limits(t *testing.T) {
+	var e Encoder
+	e.String("hello world")
+	d := NewDecoder(e.Data())
+	require.Empty(t, d.String(5))
+	require.ErrorIs(t, d.Err(), ErrStringTooLong)
+
+	d = NewDecoder([]byte{0x01})
+	d.Int()
+	require.Error(t, d.Err())
+}
+
+func readContainer(t *testing.T, d *Decoder, entries int, directBits int) []int32 {
+	t.Helper()
+	bits := int(d.Byte())
+	var palette []int32
+	switch {
+	case bits == 0:
+		v := d.VarInt()
+		require.Zero(t, d.VarInt())
+		res := make([]int32, entries)
+		for i := range res {
+			res[i] = v
+		}
+		return res
+	case bits == directBits:
+	default:
+		palette = make([]int32, d.VarInt())
+		for i := range palette {
+			palette[i] = d.VarInt()
+		}
+	}
+	data := make([]int64, d.VarInt())
+	for i := range data {
+		data[i] = d.Long()
+	}
+	idx := make([]uint16, entries)
+	require.True(t, world.UnpackIndexes(data, bits, idx))
+	res := make([]int32, entries)
+	for i, v := range idx {
+		res[i] = int32(v)
+		if palette != nil {
+			res[i] = palette[v]
+		}
+	}
+	return res
+}
+
+func TestComputeSkyLight(t *testing.T) {
+	var heights [256]uint16
+	heights[0] = 18
+	light := computeSkyLight(heights, 1)
+	require.Equal(t, byte(0), world.Light(light, 0, 1, 0))
+	require.Equal(t, byte(15), world.Light(light, 0, 2, 0))
+	require.Equal(t, byte(15), world.Light(light, 1, 0, 0))
+	require.Equal(t, fullSkyLight, computeSkyLight(heights, 2))
+	for i := range heights {
+		heights[i] = 16
+	}
+	require.Nil(t, computeSkyLight(heights, 0))
+}
+
+func TestNewChunkPacket(t *testing.T) {
+	c := world.NewChunk(2, -1, world.OverworldMinY, world.OverworldHeight)
+	for x := range 16 {
+		for z := range 16 {
+			c.SetBlock(x, -64, z, world.Bedrock)
+			c.SetBlock(x, -63, z, world.Grass)
+		}
+	}
+	c.SetBlock(3, 10, 4, world.Stone)
+	// every block of the section differs, so the direct palette is used
+	id := int32(0)
+	for i := range world.SectionVolume {
+		b, _ := registry.BlockState(id)
+		for b.IsAir() {
+			id++
+			b, _ = registry.BlockState(id)
+		}
+		c.SetBlock(i&0xF, 32+i>>8, i>>4&0xF, b)
+		id++
+	}
+	c.Sections[1].SetBiome(1, 2, 3, "minecraft:desert")
+	chest := world.BlockPos{X: 35, Y: 10, Z: -12}
+	c.SetBlockEntity(chest, nbt.Compound{"id": "minecraft:chest", "Items": nbt.NewList(nbt.TagCompound)})
+	c.SetBlockEntity(world.BlockPos{X: 36, Y: 10, Z: -12}, nbt.Compound{"id": "minecraft:unknown"})
+
+	p := NewChunkPacket(c)
+	require.Equal(t, int32(2), p.X)
+	require.Equal(t, int32(-1), p.Z)
+	require.Equal(t, []ChunkBlockEntity{{Pos: chest, Type: 1, Data: nbt.Compound{}}}, p.BlockEntities)
+
+	heights := make([]uint16, 256)
+	require.True(t, world.UnpackIndexes(p.Heightmaps["WORLD_SURFACE"].([]int64), 9, heights))
+	require.Equal(t, uint16(32+15-world.OverworldMinY+1), heights[0])
+
+	d := NewDecoder(p.Data)
+	grass, _ := registry.BlockStateID(world.Grass)
+	bedrock, _ := registry.BlockStateID(world.Bedrock)
+	plains, _ := registry.BiomeID(world.DefaultBiome)
+	desert, _ := registry.BiomeID("minecraft:desert")
+	for i := range c.Sections {
+		require.Equal(t, int16(c.Sections[i].NonAirCount()), d.Short())
+		blocks := readContainer(t, d, world.SectionVolume, 15)
+		biomes := readContainer(t, d, world.BiomeVolume, 6)
+		switch i {
+		case 0:
+			require.Equal(t, bedrock, blocks[0])
+			require.Equal(t, grass, blocks[1<<8|5<<4|5])
+			require.Equal(t, int32(0), blocks[2<<8])
+		case 6:
+			b, _ := registry.BlockState(blocks[0])
+			require.Equal(t, c.Block(0, 32, 0), b)
+		case 1:
+			require.Equal(t, desert, biomes[3<<2|2<<4|1])
+		}
+		if i != 1 {
+			require.Equal(t, plains, biomes[0])
+		}
+	}
+	require.NoError(t, d.Err())
+	require.Zero(t, d.Remaining())
+
+	// one section below the world and sections up to the filled one are dark
+	require.Len(t, p.SkyLight, 26-8)
+	require.Equal(t, int64(0xFF), p.EmptySkyLightMask[0])
+	require.Equal(t, int64(1<<26-1), p.EmptyBlockLightMask[0])
+	require.Equal(t, fullSkyLight, p.SkyLight[0])
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	frame, err := ReadPacket(bufio.NewReader(&buf))
+	require.NoError(t, err)
+	require.Equal(t, PlayChunkDataID, frame.ID)
+	d = NewDecoder(frame.Data)
+	require.Equal(t, int32(2), d.Int())
+	require.Equal(t, int32(-1), d.Int())
+	heightmaps, err := nbt.ReadNetwork(bytes.NewReader(d.Rest()))
+	require.NoError(t, err)
+	require.Equal(t, p.Heightmaps, heightmaps)
+}
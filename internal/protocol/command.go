@@ -0,0 +1,177 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+)
+
+const (
+	PlayCommandSuggestionsID = 0x10
+	PlayCommandsID           = 0x11
+
+	PlayChatCommandID               = 0x04
+	PlaySignedChatCommandID         = 0x05
+	PlayCommandSuggestionsRequestID = 0x0B
+)
+
+// Command node flags
+const (
+	CommandNodeRoot     = 0x00
+	CommandNodeLiteral  = 0x01
+	CommandNodeArgument = 0x02
+
+	CommandNodeExecutable     = 0x04
+	CommandNodeHasRedirect    = 0x08
+	CommandNodeHasSuggestions = 0x10
+)
+
+const (
+	// maxCommandRequestLength is the longest text a client asks suggestions for
+	maxCommandRequestLength = 32500
+	maxArgumentNameLength   = 16
+	maxSignedArguments      = 8
+)
+
+// CommandNode is a node of the command graph sent to the client
+type CommandNode struct {
+	Flags    byte
+	Children []int32
+	Redirect int32
+	// Name is set for literal and argument nodes
+	Name string
+	// Parser is the minecraft:command_argument_type id of argument nodes
+	Parser     int32
+	Properties []byte
+	// Suggestions is the suggestion provider, minecraft:ask_server makes
+	// the client send Command Suggestions Request
+	Suggestions string
+}
+
+// CommandsPacket declares the commands the client completes and highlights
+type CommandsPacket struct {
+	Nodes []CommandNode
+	Root  int32
+}
+
+func (p *CommandsPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(int32(len(p.Nodes)))
+	for _, n := range p.Nodes {
+		e.Byte(n.Flags)
+		e.VarInt(int32(len(n.Children)))
+		for _, child := range n.Children {
+			e.VarInt(child)
+		}
+		if n.Flags&CommandNodeHasRedirect != 0 {
+			e.VarInt(n.Redirect)
+		}
+		if n.Flags&0x03 != CommandNodeRoot {
+			e.String(n.Name)
+		}
+		if n.Flags&0x03 == CommandNodeArgument {
+			e.VarInt(n.Parser)
+			e.Raw(n.Properties)
+			if n.Flags&CommandNodeHasSuggestions != 0 {
+				e.String(n.Suggestions)
+			}
+		}
+	}
+	e.VarInt(p.Root)
+	return WritePacket(w, PlayCommandsID, e.Data())
+}
+
+// ChatCommandPacket is a command typed by the player, without the leading slash
+type ChatCommandPacket struct {
+	Command string
+}
+
+func (p *ChatCommandPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Command = d.String(MaxChatLength)
+	return d.Err()
+}
+
+// ArgumentSignature is the signature of a message argument of a command
+type ArgumentSignature struct {
+	Name      string
+	Signature []byte
+}
+
+// SignedChatCommandPacket is a command with signed message arguments
+type SignedChatCommandPacket struct {
+	Command   string
+	Timestamp int64
+	Salt      int64
+	Arguments []ArgumentSignature
+	// MessageCount and Acknowledged update the last seen messages as in ChatMessagePacket
+	MessageCount int32
+	Acknowledged [ackBitsetSize]byte
+}
+
+func (p *SignedChatCommandPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Command = d.String(MaxChatLength)
+	p.Timestamp = d.Long()
+	p.Salt = d.Long()
+	n := d.VarInt()
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if n < 0 || n > maxSignedArguments {
+		return fmt.Errorf("%w: %d", ErrInvalidLength, n)
+	}
+	p.Arguments = make([]ArgumentSignature, n)
+	for i := range p.Arguments {
+		p.Arguments[i].Name = d.String(maxArgumentNameLength)
+		p.Arguments[i].Signature = d.Bytes(MessageSignatureSize)
+	}
+	p.MessageCount = d.VarInt()
+	copy(p.Acknowledged[:], d.Bytes(ackBitsetSize))
+	return d.Err()
+}
+
+// CommandSuggestionsRequestPacket asks completions for Text, which starts with a slash
+type CommandSuggestionsRequestPacket struct {
+	TransactionID int32
+	Text          string
+}
+
+func (p *CommandSuggestionsRequestPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.TransactionID = d.VarInt()
+	p.Text = d.String(maxCommandRequestLength)
+	return d.Err()
+}
+
+// Suggestion is a completion with an optional tooltip
+type Suggestion struct {
+	Match   string
+	Tooltip *text.Component
+}
+
+// CommandSuggestionsPacket answers a suggestions request, the matches replace
+// Length characters of the request text from Start
+type CommandSuggestionsPacket struct {
+	TransactionID int32
+	Start         int32
+	Length        int32
+	Matches       []Suggestion
+}
+
+func (p *CommandSuggestionsPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.TransactionID)
+	e.VarInt(p.Start)
+	e.VarInt(p.Length)
+	e.VarInt(int32(len(p.Matches)))
+	for _, m := range p.Matches {
+		e.String(m.Match)
+		e.Bool(m.Tooltip != nil)
+		if m.Tooltip != nil {
+			e.NBT(m.Tooltip.NBT())
+		}
+	}
+	return WritePacket(w, PlayCommandSuggestionsID, e.Data())
+}
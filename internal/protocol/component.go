@@ -0,0 +1,497 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+)
+
+// Data component types of Minecraft 1.21 in registry order
+const (
+	ComponentCustomData = iota
+	ComponentMaxStackSize
+	ComponentMaxDamage
+	ComponentDamage
+	ComponentUnbreakable
+	ComponentCustomName
+	ComponentItemName
+	ComponentLore
+	ComponentRarity
+	ComponentEnchantments
+	ComponentCanPlaceOn
+	ComponentCanBreak
+	ComponentAttributeModifiers
+	ComponentCustomModelData
+	ComponentHideAdditionalTooltip
+	ComponentHideTooltip
+	ComponentRepairCost
+	ComponentCreativeSlotLock
+	ComponentEnchantmentGlintOverride
+	ComponentIntangibleProjectile
+	ComponentFood
+	ComponentFireResistant
+	ComponentTool
+	ComponentStoredEnchantments
+	ComponentDyedColor
+	ComponentMapColor
+	ComponentMapID
+	ComponentMapDecorations
+	ComponentMapPostProcessing
+	ComponentChargedProjectiles
+	ComponentBundleContents
+	ComponentPotionContents
+	ComponentSuspiciousStewEffects
+	ComponentWritableBookContent
+	ComponentWrittenBookContent
+	ComponentTrim
+	ComponentDebugStickState
+	ComponentEntityData
+	ComponentBucketEntityData
+	ComponentBlockEntityData
+	ComponentInstrument
+	ComponentOminousBottleAmplifier
+	ComponentJukeboxPlayable
+	ComponentRecipes
+	ComponentLodestoneTracker
+	ComponentFireworkExplosion
+	ComponentFireworks
+	ComponentProfile
+	ComponentNoteBlockSound
+	ComponentBannerPatterns
+	ComponentBaseColor
+	ComponentPotDecorations
+	ComponentContainer
+	ComponentBlockState
+	ComponentBees
+	ComponentLock
+	ComponentContainerLoot
+)
+
+const (
+	// maxSlotDepth limits item stacks nested in containers and bundles
+	maxSlotDepth = 16
+	// maxBookPageLength is the longest writable book page
+	maxBookPageLength = 1024
+)
+
+var ErrUnknownComponent = errors.New("unknown data component")
+
+// Components is a data component patch: components an item stack has on
+// top of or instead of the defaults of its item. Added components are kept
+// encoded, so ones the server doesn't interpret pass through unchanged.
+type Components struct {
+	Added   map[int32][]byte
+	Removed map[int32]bool
+}
+
+func (c Components) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+func (c Components) Equal(o Components) bool {
+	return maps.EqualFunc(c.Added, o.Added, bytes.Equal) && maps.Equal(c.Removed, o.Removed)
+}
+
+func (c Components) Clone() Components {
+	return Components{Added: maps.Clone(c.Added), Removed: maps.Clone(c.Removed)}
+}
+
+// Get returns the encoded value of component typ
+func (c Components) Get(typ int32) ([]byte, bool) {
+	data, ok := c.Added[typ]
+	return data, ok
+}
+
+// Set adds component typ with its encoded value
+func (c *Components) Set(typ int32, data []byte) {
+	if c.Added == nil {
+		c.Added = map[int32][]byte{}
+	}
+	c.Added[typ] = data
+	delete(c.Removed, typ)
+}
+
+// Remove removes component typ from the item defaults
+func (c *Components) Remove(typ int32) {
+	if c.Removed == nil {
+		c.Removed = map[int32]bool{}
+	}
+	c.Removed[typ] = true
+	delete(c.Added, typ)
+}
+
+// VarInt returns a component holding a single VarInt, such as damage
+func (c Components) VarInt(typ int32) (int32, bool) {
+	data, ok := c.Added[typ]
+	if !ok {
+		return 0, false
+	}
+	d := NewDecoder(data)
+	v := d.VarInt()
+	return v, d.Err() == nil
+}
+
+func (c *Components) SetVarInt(typ int32, v int32) {
+	var e Encoder
+	e.VarInt(v)
+	c.Set(typ, e.Data())
+}
+
+// SetText sets a text component such as the custom name
+func (c *Components) SetText(typ int32, t text.Component) {
+	var e Encoder
+	e.NBT(t.NBT())
+	c.Set(typ, e.Data())
+}
+
+// Enchantment is an enchantment registry id and its level
+type Enchantment struct {
+	ID    int32
+	Level int32
+}
+
+// Enchantments returns the enchantments or stored enchantments component
+func (c Components) Enchantments(typ int32) []Enchantment {
+	data, ok := c.Added[typ]
+	if !ok {
+		return nil
+	}
+	d := NewDecoder(data)
+	var res []Enchantment
+	d.repeat(func() {
+		res = append(res, Enchantment{ID: d.VarInt(), Level: d.VarInt()})
+	})
+	if d.Err() != nil {
+		return nil
+	}
+	return res
+}
+
+// SetEnchantments sets the enchantments shown in the tooltip
+func (c *Components) SetEnchantments(typ int32, enchantments []Enchantment) {
+	var e Encoder
+	e.VarInt(int32(len(enchantments)))
+	for _, ench := range enchantments {
+		e.VarInt(ench.ID)
+		e.VarInt(ench.Level)
+	}
+	e.Bool(true)
+	c.Set(typ, e.Data())
+}
+
+// Components writes a component patch, components are sorted by type
+func (e *Encoder) Components(c Components) {
+	e.VarInt(int32(len(c.Added)))
+	e.VarInt(int32(len(c.Removed)))
+	for _, typ := range slices.Sorted(maps.Keys(c.Added)) {
+		e.VarInt(typ)
+		e.Raw(c.Added[typ])
+	}
+	for _, typ := range slices.Sorted(maps.Keys(c.Removed)) {
+		e.VarInt(typ)
+	}
+}
+
+// Components reads a component patch
+func (d *Decoder) Components() Components {
+	var c Components
+	added := d.count()
+	removed := d.count()
+	for range added {
+		typ := d.VarInt()
+		start := d.offset()
+		d.component(typ)
+		if d.err != nil {
+			return Components{}
+		}
+		c.Set(typ, bytes.Clone(d.data[start:d.offset()]))
+	}
+	for range removed {
+		c.Remove(d.VarInt())
+	}
+	return c
+}
+
+// count reads a collection size, every element takes at least a byte
+func (d *Decoder) count() int32 {
+	n := d.VarInt()
+	if d.err == nil && (n < 0 || int(n) > d.Remaining()) {
+		d.fail(fmt.Errorf("%w: %d", ErrInvalidLength, n))
+	}
+	if d.err != nil {
+		return 0
+	}
+	return n
+}
+
+// repeat reads a collection calling fn for every element
+func (d *Decoder) repeat(fn func()) {
+	for range d.count() {
+		if d.err != nil {
+			return
+		}
+		fn()
+	}
+}
+
+// optional calls fn when a present flag is set
+func (d *Decoder) optional(fn func()) {
+	if d.Bool() {
+		fn()
+	}
+}
+
+func (d *Decoder) anyNBT() {
+	if d.err != nil {
+		return
+	}
+	_, err := nbt.ReadNetworkTag(d.r)
+	if err != nil {
+		d.fail(err)
+	}
+}
+
+func (d *Decoder) identifier() {
+	d.String(maxIdentifierLength)
+}
+
+// holder reads a registry reference, id zero is followed by an inline value
+func (d *Decoder) holder(direct func()) {
+	if d.VarInt() == 0 {
+		direct()
+	}
+}
+
+// idSet reads a tag name or a list of registry ids
+func (d *Decoder) idSet() {
+	n := d.VarInt()
+	if n == 0 {
+		d.identifier()
+		return
+	}
+	if n < 0 || int(n-1) > d.Remaining() {
+		d.fail(fmt.Errorf("%w: %d", ErrInvalidLength, n))
+		return
+	}
+	for range n - 1 {
+		d.VarInt()
+	}
+}
+
+func (d *Decoder) soundEvent() {
+	d.identifier()
+	d.optional(func() { d.Float() })
+}
+
+func (d *Decoder) effectDetails() {
+	d.VarInt()
+	d.VarInt()
+	d.Bool()
+	d.Bool()
+	d.Bool()
+	d.optional(d.effectDetails)
+}
+
+func (d *Decoder) effect() {
+	d.VarInt()
+	d.effectDetails()
+}
+
+func (d *Decoder) blockPredicate() {
+	d.optional(d.idSet)
+	d.optional(func() {
+		d.repeat(func() {
+			d.String(maxIdentifierLength)
+			if d.Bool() {
+				d.String(maxIdentifierLength)
+				return
+			}
+			d.optional(func() { d.String(maxIdentifierLength) })
+			d.optional(func() { d.String(maxIdentifierLength) })
+		})
+	})
+	d.optional(d.anyNBT)
+}
+
+func (d *Decoder) fireworkExplosion() {
+	d.VarInt()
+	d.repeat(func() { d.Int() })
+	d.repeat(func() { d.Int() })
+	d.Bool()
+	d.Bool()
+}
+
+func (d *Decoder) slot() {
+	d.Slot()
+}
+
+// component reads the value of component type typ. Types that are never
+// sent to clients are rejected.
+func (d *Decoder) component(typ int32) {
+	switch typ {
+	case ComponentCustomData, ComponentCustomName, ComponentItemName, ComponentMapDecorations,
+		ComponentEntityData, ComponentBucketEntityData, ComponentBlockEntityData:
+		d.anyNBT()
+	case ComponentMaxStackSize, ComponentMaxDamage, ComponentDamage, ComponentRarity,
+		ComponentCustomModelData, ComponentRepairCost, ComponentMapID, ComponentMapPostProcessing,
+		ComponentOminousBottleAmplifier, ComponentBaseColor:
+		d.VarInt()
+	case ComponentUnbreakable, ComponentEnchantmentGlintOverride:
+		d.Bool()
+	case ComponentHideAdditionalTooltip, ComponentHideTooltip, ComponentCreativeSlotLock, ComponentFireResistant:
+	case ComponentLore:
+		d.repeat(d.anyNBT)
+	case ComponentEnchantments, ComponentStoredEnchantments:
+		d.repeat(func() {
+			d.VarInt()
+			d.VarInt()
+		})
+		d.Bool()
+	case ComponentCanPlaceOn, ComponentCanBreak:
+		d.repeat(d.blockPredicate)
+		d.Bool()
+	case ComponentAttributeModifiers:
+		d.repeat(func() {
+			d.VarInt()
+			d.identifier()
+			d.Double()
+			d.VarInt()
+			d.VarInt()
+		})
+		d.Bool()
+	case ComponentFood:
+		d.VarInt()
+		d.Float()
+		d.Bool()
+		d.Float()
+		d.optional(d.slot)
+		d.repeat(func() {
+			d.effect()
+			d.Float()
+		})
+	case ComponentTool:
+		d.repeat(func() {
+			d.idSet()
+			d.optional(func() { d.Float() })
+			d.optional(func() { d.Bool() })
+		})
+		d.Float()
+		d.VarInt()
+	case ComponentDyedColor:
+		d.Int()
+		d.Bool()
+	case ComponentMapColor:
+		d.Int()
+	case ComponentChargedProjectiles, ComponentBundleContents, ComponentContainer:
+		d.repeat(d.slot)
+	case ComponentPotionContents:
+		d.optional(func() { d.VarInt() })
+		d.optional(func() { d.Int() })
+		d.repeat(d.effect)
+	case ComponentSuspiciousStewEffects:
+		d.repeat(func() {
+			d.VarInt()
+			d.VarInt()
+		})
+	case ComponentWritableBookContent:
+		d.repeat(func() {
+			d.String(maxBookPageLength)
+			d.optional(func() { d.String(maxBookPageLength) })
+		})
+	case ComponentWrittenBookContent:
+		d.String(32)
+		d.optional(func() { d.String(32) })
+		d.String(maxIdentifierLength)
+		d.VarInt()
+		d.repeat(func() {
+			d.anyNBT()
+			d.optional(d.anyNBT)
+		})
+		d.Bool()
+	case ComponentTrim:
+		d.holder(func() {
+			d.String(maxIdentifierLength)
+			d.VarInt()
+			d.Float()
+			d.repeat(func() {
+				d.VarInt()
+				d.String(maxIdentifierLength)
+			})
+			d.anyNBT()
+		})
+		d.holder(func() {
+			d.identifier()
+			d.VarInt()
+			d.anyNBT()
+			d.Bool()
+		})
+		d.Bool()
+	case ComponentInstrument:
+		d.holder(func() {
+			d.holder(d.soundEvent)
+			d.VarInt()
+			d.Float()
+		})
+	case ComponentJukeboxPlayable:
+		if d.Bool() {
+			d.holder(func() {
+				d.holder(d.soundEvent)
+				d.anyNBT()
+				d.Float()
+				d.VarInt()
+			})
+		} else {
+			d.identifier()
+		}
+		d.Bool()
+	case ComponentLodestoneTracker:
+		d.optional(func() {
+			d.identifier()
+			d.Long()
+		})
+		d.Bool()
+	case ComponentFireworkExplosion:
+		d.fireworkExplosion()
+	case ComponentFireworks:
+		d.VarInt()
+		d.repeat(d.fireworkExplosion)
+	case ComponentProfile:
+		d.optional(func() { d.String(16) })
+		d.optional(func() { d.UUID() })
+		d.repeat(func() {
+			d.String(64)
+			d.String(maxIdentifierLength)
+			d.optional(func() { d.String(maxBookPageLength) })
+		})
+	case ComponentNoteBlockSound:
+		d.identifier()
+	case ComponentBannerPatterns:
+		d.repeat(func() {
+			d.holder(func() {
+				d.identifier()
+				d.String(maxIdentifierLength)
+			})
+			d.VarInt()
+		})
+	case ComponentPotDecorations:
+		d.repeat(func() { d.VarInt() })
+	case ComponentBlockState:
+		d.repeat(func() {
+			d.String(maxIdentifierLength)
+			d.String(maxIdentifierLength)
+		})
+	case ComponentBees:
+		d.repeat(func() {
+			d.anyNBT()
+			d.VarInt()
+			d.VarInt()
+		})
+	default:
+		d.fail(fmt.Errorf("%w: %d", ErrUnknownComponent, typ))
+	}
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"io"
+
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+)
+
+const (
+	PlayContainerPropertyID = 0x14
+	PlayOpenScreenID        = 0x33
+)
+
+// Menu types of Open Screen
+const (
+	MenuGeneric9x1 = 0
+	MenuGeneric9x3 = 2
+	MenuGeneric9x6 = 5
+	MenuCrafting   = 12
+	MenuFurnace    = 14
+)
+
+// Furnace window properties
+const (
+	FurnaceLitTime = iota
+	FurnaceLitDuration
+	FurnaceCookingProgress
+	FurnaceCookingTotalTime
+)
+
+// OpenScreenPacket opens a window, slots are sent afterwards with Set Container Content
+type OpenScreenPacket struct {
+	WindowID int32
+	Menu     int32
+	Title    text.Component
+}
+
+func (p *OpenScreenPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.WindowID)
+	e.VarInt(p.Menu)
+	e.NBT(p.Title.NBT())
+	return WritePacket(w, PlayOpenScreenID, e.Data())
+}
+
+// ContainerPropertyPacket updates a window property such as furnace progress
+type ContainerPropertyPacket struct {
+	WindowID byte
+	Property int16
+	Value    int16
+}
+
+func (p *ContainerPropertyPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.WindowID)
+	e.Short(p.Property)
+	e.Short(p.Value)
+	return WritePacket(w, PlayContainerPropertyID, e.Data())
+}
@@ -0,0 +1,335 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/google/uuid"
+)
+
+// MaxPacketSize is the largest packet length the vanilla client accepts
+const MaxPacketSize = 2097151
+
+var (
+	ErrPacketTooLarge = errors.New("packet too large")
+	ErrStringTooLong  = errors.New("string too long")
+	ErrInvalidLength  = errors.New("invalid length")
+)
+
+// ReadPacket reads a single length prefixed packet, Data holds the packet body without ID
+func ReadPacket(r datatypes.BytesReader) (PacketWithData, error) {
+	var p PacketWithData
+	var length datatypes.VarInt
+	err := length.Read(r)
+	if err != nil {
+		return p, err
+	}
+	if length <= 0 || length > MaxPacketSize {
+		return p, fmt.Errorf("%w: %d", ErrPacketTooLarge, length)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return p, err
+	}
+	var id datatypes.VarInt
+	buf := bytes.NewReader(data)
+	err = id.Read(buf)
+	if err != nil {
+		return p, err
+	}
+	p.Length = int(length)
+	p.ID = int(id)
+	p.Data = data[len(data)-buf.Len():]
+	return p, nil
+}
+
+// WritePacket frames body with packet length and ID and writes it with a single Write call
+func WritePacket(w io.Writer, id int32, body []byte) error {
+	var head Encoder
+	head.VarInt(id)
+	length := head.Len() + len(body)
+	if length > MaxPacketSize {
+		return fmt.Errorf("%w: %d", ErrPacketTooLarge, length)
+	}
+
+	var res Encoder
+	res.buf.Grow(length + 5)
+	res.VarInt(int32(length))
+	res.Raw(head.Data())
+	res.Raw(body)
+	_, err := w.Write(res.Data())
+	return err
+}
+
+// Encoder builds packet bodies out of protocol data types
+type Encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *Encoder) Data() []byte {
+	return e.buf.Bytes()
+}
+
+func (e *Encoder) Len() int {
+	return e.buf.Len()
+}
+
+func (e *Encoder) Raw(b []byte) {
+	e.buf.Write(b)
+}
+
+func (e *Encoder) VarInt(v int32) {
+	vi := datatypes.VarInt(v)
+	_ = vi.Write(&e.buf)
+}
+
+func (e *Encoder) VarLong(v int64) {
+	u := uint64(v)
+	for u >= 0x80 {
+		e.buf.WriteByte(byte(u) | 0x80)
+		u >>= 7
+	}
+	e.buf.WriteByte(byte(u))
+}
+
+func (e *Encoder) Bool(v bool) {
+	e.buf.WriteByte(datatypes.WriteBoolean(datatypes.Boolean(v)))
+}
+
+func (e *Encoder) Byte(v byte) {
+	e.buf.WriteByte(v)
+}
+
+func (e *Encoder) Short(v int16) {
+	e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(v)))
+}
+
+func (e *Encoder) Int(v int32) {
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(v)))
+}
+
+func (e *Encoder) Long(v int64) {
+	e.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(v)))
+}
+
+func (e *Encoder) Float(v float32) {
+	e.Int(int32(math.Float32bits(v)))
+}
+
+func (e *Encoder) Double(v float64) {
+	e.Long(int64(math.Float64bits(v)))
+}
+
+func (e *Encoder) String(s string) {
+	e.VarInt(int32(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *Encoder) UUID(u uuid.UUID) {
+	e.buf.Write(u[:])
+}
+
+func (e *Encoder) Position(p datatypes.Position) {
+	e.Long(datatypes.WritePosition(p))
+}
+
+// ByteArray writes b prefixed with its length
+func (e *Encoder) ByteArray(b []byte) {
+	e.VarInt(int32(len(b)))
+	e.buf.Write(b)
+}
+
+// Longs writes a length prefixed long array, it is also the BitSet encoding
+func (e *Encoder) Longs(v []int64) {
+	e.VarInt(int32(len(v)))
+	for _, l := range v {
+		e.Long(l)
+	}
+}
+
+// NBT writes c as nameless network NBT, nil writes the end tag
+func (e *Encoder) NBT(c nbt.Compound) {
+	_ = nbt.WriteNetwork(&e.buf, c)
+}
+
+// Decoder reads packet bodies. The first error is kept and returned by Err,
+// after it every read returns zero values.
+type Decoder struct {
+	data  []byte
+	r     *bytes.Reader
+	err   error
+	depth int
+}
+
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data, r: bytes.NewReader(data)}
+}
+
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+func (d *Decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// Remaining returns the number of unread bytes
+func (d *Decoder) Remaining() int {
+	return d.r.Len()
+}
+
+// offset is the number of bytes read so far
+func (d *Decoder) offset() int {
+	return len(d.data) - d.r.Len()
+}
+
+func (d *Decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > d.r.Len() {
+		d.fail(io.ErrUnexpectedEOF)
+		return nil
+	}
+	b := make([]byte, n)
+	_, _ = d.r.Read(b)
+	return b
+}
+
+func (d *Decoder) VarInt() int32 {
+	if d.err != nil {
+		return 0
+	}
+	var v datatypes.VarInt
+	err := v.Read(d.r)
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	return int32(v)
+}
+
+func (d *Decoder) VarLong() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var res uint64
+	for shift := 0; shift < 70; shift += 7 {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			d.fail(io.ErrUnexpectedEOF)
+			return 0
+		}
+		res |= uint64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return int64(res)
+		}
+	}
+	d.fail(datatypes.ErrInvalidVarInt)
+	return 0
+}
+
+func (d *Decoder) Byte() byte {
+	b := d.read(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *Decoder) Bool() bool {
+	return d.Byte() == 0x01
+}
+
+func (d *Decoder) Short() int16 {
+	b := d.read(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (d *Decoder) Int() int32 {
+	b := d.read(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *Decoder) Long() int64 {
+	b := d.read(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *Decoder) Float() float32 {
+	return math.Float32frombits(uint32(d.Int()))
+}
+
+func (d *Decoder) Double() float64 {
+	return math.Float64frombits(uint64(d.Long()))
+}
+
+// String reads a string of at most maxLen characters
+func (d *Decoder) String(maxLen int) string {
+	n := d.VarInt()
+	if d.err != nil {
+		return ""
+	}
+	if n < 0 || int(n) > maxLen*3 {
+		d.fail(fmt.Errorf("%w: %d bytes", ErrStringTooLong, n))
+		return ""
+	}
+	b := d.read(int(n))
+	if utf8.RuneCount(b) > maxLen {
+		d.fail(fmt.Errorf("%w: %d characters", ErrStringTooLong, utf8.RuneCount(b)))
+		return ""
+	}
+	return string(b)
+}
+
+func (d *Decoder) UUID() uuid.UUID {
+	var u uuid.UUID
+	copy(u[:], d.read(16))
+	return u
+}
+
+func (d *Decoder) Position() datatypes.Position {
+	return datatypes.ReadPosition(d.Long())
+}
+
+// ByteArray reads a length prefixed byte array of at most maxLen bytes
+func (d *Decoder) ByteArray(maxLen int) []byte {
+	n := d.VarInt()
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || int(n) > maxLen {
+		d.fail(fmt.Errorf("%w: %d", ErrInvalidLength, n))
+		return nil
+	}
+	return d.read(int(n))
+}
+
+// Bytes reads exactly n bytes
+func (d *Decoder) Bytes(n int) []byte {
+	return d.read(n)
+}
+
+// Rest returns all unread bytes
+func (d *Decoder) Rest() []byte {
+	return d.read(d.r.Len())
+}
@@ -0,0 +1,246 @@
+package protocol
+
+import (
+	"io"
+	"math"
+
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/google/uuid"
+)
+
+// Clientbound entity packet IDs
+const (
+	PlayAddEntityID        = 0x01
+	PlayMoveEntityPosID    = 0x2E
+	PlayMoveEntityPosRotID = 0x2F
+	PlayMoveEntityRotID    = 0x30
+	PlayPlayerInfoRemoveID = 0x3D
+	PlayPlayerInfoUpdateID = 0x3E
+	PlayRemoveEntitiesID   = 0x42
+	PlayRotateHeadID       = 0x48
+	PlayTeleportEntityID   = 0x70
+)
+
+// EntityTypePlayer is the minecraft:player entity type id
+const EntityTypePlayer = 128
+
+// Player Info Update actions
+const (
+	PlayerInfoAddPlayer         = 0x01
+	PlayerInfoInitializeChat    = 0x02
+	PlayerInfoUpdateGameMode    = 0x04
+	PlayerInfoUpdateListed      = 0x08
+	PlayerInfoUpdateLatency     = 0x10
+	PlayerInfoUpdateDisplayName = 0x20
+)
+
+// Angle converts degrees to the protocol angle, a 256th of a turn
+func Angle(degrees float32) byte {
+	return byte(int32(math.Floor(float64(degrees) * 256 / 360)))
+}
+
+// DeltaScale converts a position to the fixed point units used by relative
+// entity moves
+const DeltaScale = 4096
+
+type AddEntityPacket struct {
+	EntityID                        int32
+	UUID                            uuid.UUID
+	Type                            int32
+	X, Y, Z                         float64
+	Pitch, Yaw, HeadYaw             byte
+	Data                            int32
+	VelocityX, VelocityY, VelocityZ int16
+}
+
+func (p *AddEntityPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.EntityID)
+	e.UUID(p.UUID)
+	e.VarInt(p.Type)
+	e.Double(p.X)
+	e.Double(p.Y)
+	e.Double(p.Z)
+	e.Byte(p.Pitch)
+	e.Byte(p.Yaw)
+	e.Byte(p.HeadYaw)
+	e.VarInt(p.Data)
+	e.Short(p.VelocityX)
+	e.Short(p.VelocityY)
+	e.Short(p.VelocityZ)
+	return WritePacket(w, PlayAddEntityID, e.Data())
+}
+
+// MoveEntityPacket is a relative move of less than 8 blocks per axis.
+// HasPosition and HasRotation select between Update Entity Position, Update
+// Entity Position and Rotation and Update Entity Rotation.
+type MoveEntityPacket struct {
+	EntityID               int32
+	DeltaX, DeltaY, DeltaZ int16
+	Yaw, Pitch             byte
+	OnGround               bool
+	HasPosition            bool
+	HasRotation            bool
+}
+
+func (p *MoveEntityPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.EntityID)
+	if p.HasPosition {
+		e.Short(p.DeltaX)
+		e.Short(p.DeltaY)
+		e.Short(p.DeltaZ)
+	}
+	if p.HasRotation {
+		e.Byte(p.Yaw)
+		e.Byte(p.Pitch)
+	}
+	e.Bool(p.OnGround)
+	id := int32(PlayMoveEntityRotID)
+	switch {
+	case p.HasPosition && p.HasRotation:
+		id = PlayMoveEntityPosRotID
+	case p.HasPosition:
+		id = PlayMoveEntityPosID
+	}
+	return WritePacket(w, id, e.Data())
+}
+
+type TeleportEntityPacket struct {
+	EntityID   int32
+	X, Y, Z    float64
+	Yaw, Pitch byte
+	OnGround   bool
+}
+
+func (p *TeleportEntityPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.EntityID)
+	e.Double(p.X)
+	e.Double(p.Y)
+	e.Double(p.Z)
+	e.Byte(p.Yaw)
+	e.Byte(p.Pitch)
+	e.Bool(p.OnGround)
+	return WritePacket(w, PlayTeleportEntityID, e.Data())
+}
+
+type RotateHeadPacket struct {
+	EntityID int32
+	HeadYaw  byte
+}
+
+func (p *RotateHeadPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.EntityID)
+	e.Byte(p.HeadYaw)
+	return WritePacket(w, PlayRotateHeadID, e.Data())
+}
+
+type RemoveEntitiesPacket struct {
+	EntityIDs []int32
+}
+
+func (p *RemoveEntitiesPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(int32(len(p.EntityIDs)))
+	for _, id := range p.EntityIDs {
+		e.VarInt(id)
+	}
+	return WritePacket(w, PlayRemoveEntitiesID, e.Data())
+}
+
+// PlayerProperty is a signed game profile property, textures holds the skin
+type PlayerProperty struct {
+	Name      string
+	Value     string
+	Signature string
+}
+
+// ChatSession is the profile public key other players check messages of the player with
+type ChatSession struct {
+	ID uuid.UUID
+	// ExpiresAt is in unix milliseconds
+	ExpiresAt    int64
+	PublicKey    []byte
+	KeySignature []byte
+}
+
+// PlayerInfoEntry holds the fields written for the actions of the packet
+type PlayerInfoEntry struct {
+	UUID       uuid.UUID
+	Name       string
+	Properties []PlayerProperty
+	// ChatSession is nil when the player doesn't sign messages
+	ChatSession *ChatSession
+	GameMode    int32
+	Listed      bool
+	Latency     int32
+	// DisplayName replaces the name in the tab list when it isn't nil
+	DisplayName *text.Component
+}
+
+type PlayerInfoUpdatePacket struct {
+	Actions byte
+	Entries []PlayerInfoEntry
+}
+
+func (p *PlayerInfoUpdatePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.Actions)
+	e.VarInt(int32(len(p.Entries)))
+	for _, entry := range p.Entries {
+		e.UUID(entry.UUID)
+		if p.Actions&PlayerInfoAddPlayer != 0 {
+			e.String(entry.Name)
+			e.VarInt(int32(len(entry.Properties)))
+			for _, prop := range entry.Properties {
+				e.String(prop.Name)
+				e.String(prop.Value)
+				e.Bool(prop.Signature != "")
+				if prop.Signature != "" {
+					e.String(prop.Signature)
+				}
+			}
+		}
+		if p.Actions&PlayerInfoInitializeChat != 0 {
+			session := entry.ChatSession
+			e.Bool(session != nil)
+			if session != nil {
+				e.UUID(session.ID)
+				e.Long(session.ExpiresAt)
+				e.ByteArray(session.PublicKey)
+				e.ByteArray(session.KeySignature)
+			}
+		}
+		if p.Actions&PlayerInfoUpdateGameMode != 0 {
+			e.VarInt(entry.GameMode)
+		}
+		if p.Actions&PlayerInfoUpdateListed != 0 {
+			e.Bool(entry.Listed)
+		}
+		if p.Actions&PlayerInfoUpdateLatency != 0 {
+			e.VarInt(entry.Latency)
+		}
+		if p.Actions&PlayerInfoUpdateDisplayName != 0 {
+			e.Bool(entry.DisplayName != nil)
+			if entry.DisplayName != nil {
+				e.NBT(entry.DisplayName.NBT())
+			}
+		}
+	}
+	return WritePacket(w, PlayPlayerInfoUpdateID, e.Data())
+}
+
+type PlayerInfoRemovePacket struct {
+	UUIDs []uuid.UUID
+}
+
+func (p *PlayerInfoRemovePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(int32(len(p.UUIDs)))
+	for _, u := range p.UUIDs {
+		e.UUID(u)
+	}
+	return WritePacket(w, PlayPlayerInfoRemoveID, e.Data())
+}
@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	PlayCloseContainerID      = 0x12
+	PlaySetContainerContentID = 0x13
+	PlaySetContainerSlotID    = 0x15
+
+	PlayServerClickContainerID  = 0x0E
+	PlayServerCloseContainerID  = 0x0F
+	PlayServerSetHeldItemID     = 0x2F
+	PlayServerSetCreativeSlotID = 0x32
+)
+
+// Click container modes
+const (
+	ClickPickup = iota
+	ClickQuickMove
+	ClickSwap
+	ClickClone
+	ClickThrow
+	ClickDrag
+	ClickPickupAll
+)
+
+const (
+	// CarriedWindow and CarriedSlot address the stack held by the cursor
+	CarriedWindow = 0xFF
+	CarriedSlot   = -1
+	// maxChangedSlots is the most slots a click may report as changed
+	maxChangedSlots = 128
+)
+
+// PlayerInventoryWindow is the window id of the player inventory
+const PlayerInventoryWindow = 0
+
+// Slot is an item stack as sent over the network, a zero Count is an empty slot
+type Slot struct {
+	Count      int32
+	ItemID     int32
+	Components Components
+}
+
+func (e *Encoder) Slot(s Slot) {
+	e.VarInt(s.Count)
+	if s.Count <= 0 {
+		return
+	}
+	e.VarInt(s.ItemID)
+	e.Components(s.Components)
+}
+
+func (d *Decoder) Slot() Slot {
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxSlotDepth {
+		d.fail(fmt.Errorf("%w: item stacks nested too deep", ErrInvalidLength))
+		return Slot{}
+	}
+	count := d.VarInt()
+	if count <= 0 || d.err != nil {
+		return Slot{}
+	}
+	s := Slot{Count: count, ItemID: d.VarInt()}
+	s.Components = d.Components()
+	return s
+}
+
+// SetContainerSlotPacket changes a single slot of a window
+type SetContainerSlotPacket struct {
+	WindowID byte
+	StateID  int32
+	Slot     int16
+	Item     Slot
+}
+
+func (p *SetContainerSlotPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.WindowID)
+	e.VarInt(p.StateID)
+	e.Short(p.Slot)
+	e.Slot(p.Item)
+	return WritePacket(w, PlaySetContainerSlotID, e.Data())
+}
+
+// SetContainerContentPacket replaces every slot of a window and the carried item
+type SetContainerContentPacket struct {
+	WindowID byte
+	StateID  int32
+	Slots    []Slot
+	Carried  Slot
+}
+
+func (p *SetContainerContentPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.WindowID)
+	e.VarInt(p.StateID)
+	e.VarInt(int32(len(p.Slots)))
+	for _, s := range p.Slots {
+		e.Slot(s)
+	}
+	e.Slot(p.Carried)
+	return WritePacket(w, PlaySetContainerContentID, e.Data())
+}
+
+// ChangedSlot is a slot the client predicted a click to change
+type ChangedSlot struct {
+	Slot int16
+	Item Slot
+}
+
+// ClickContainerPacket is a click in a window. Slot is -999 for clicks
+// outside of the window.
+type ClickContainerPacket struct {
+	WindowID byte
+	StateID  int32
+	Slot     int16
+	Button   byte
+	Mode     int32
+	Changed  []ChangedSlot
+	Carried  Slot
+}
+
+func (p *ClickContainerPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.WindowID = d.Byte()
+	p.StateID = d.VarInt()
+	p.Slot = d.Short()
+	p.Button = d.Byte()
+	p.Mode = d.VarInt()
+	n := d.count()
+	if n > maxChangedSlots {
+		return fmt.Errorf("%w: %d changed slots", ErrInvalidLength, n)
+	}
+	p.Changed = make([]ChangedSlot, 0, n)
+	for range n {
+		p.Changed = append(p.Changed, ChangedSlot{Slot: d.Short(), Item: d.Slot()})
+	}
+	p.Carried = d.Slot()
+	return d.Err()
+}
+
+// CloseContainerPacket closes a window, it is sent in both directions
+type CloseContainerPacket struct {
+	WindowID byte
+}
+
+func (p *CloseContainerPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.WindowID = d.Byte()
+	return d.Err()
+}
+
+func (p *CloseContainerPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.WindowID)
+	return WritePacket(w, PlayCloseContainerID, e.Data())
+}
+
+// SetCreativeSlotPacket sets a player inventory slot in creative mode,
+// a negative slot drops the item
+type SetCreativeSlotPacket struct {
+	Slot int16
+	Item Slot
+}
+
+func (p *SetCreativeSlotPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Slot = d.Short()
+	p.Item = d.Slot()
+	return d.Err()
+}
@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlot_Components(t *testing.T) {
+	var c Components
+	c.SetVarInt(ComponentDamage, 12)
+	c.SetText(ComponentCustomName, text.Plain("Sword"))
+	c.SetEnchantments(ComponentEnchantments, []Enchantment{{ID: 3, Level: 2}})
+	c.Set(ComponentHideTooltip, nil)
+	c.Remove(ComponentMaxStackSize)
+
+	var inner Components
+	inner.SetVarInt(ComponentRepairCost, 1)
+	var e Encoder
+	e.VarInt(1)
+	e.Slot(Slot{Count: 2, ItemID: 7, Components: inner})
+	c.Set(ComponentContainer, e.Data())
+
+	s := Slot{Count: 1, ItemID: 800, Components: c}
+	e = Encoder{}
+	e.Slot(s)
+	e.Slot(Slot{})
+	d := NewDecoder(e.Data())
+	got := d.Slot()
+	require.NoError(t, d.Err())
+	require.Equal(t, s.Count, got.Count)
+	require.Equal(t, s.ItemID, got.ItemID)
+	require.True(t, s.Components.Equal(got.Components))
+	require.Equal(t, Slot{}, d.Slot())
+	require.Zero(t, d.Remaining())
+
+	damage, ok := got.Components.VarInt(ComponentDamage)
+	require.True(t, ok)
+	require.Equal(t, int32(12), damage)
+	require.Equal(t, []Enchantment{{ID: 3, Level: 2}}, got.Components.Enchantments(ComponentEnchantments))
+
+	// components never sent to clients are rejected
+	e = Encoder{}
+	e.VarInt(1)
+	e.VarInt(1)
+	e.VarInt(1)
+	e.VarInt(0)
+	e.VarInt(ComponentLock)
+	d = NewDecoder(e.Data())
+	d.Slot()
+	require.ErrorIs(t, d.Err(), ErrUnknownComponent)
+
+	// nested containers are limited
+	e = Encoder{}
+	for range maxSlotDepth + 1 {
+		e.VarInt(1)
+		e.VarInt(1)
+		e.VarInt(1)
+		e.VarInt(0)
+		e.VarInt(ComponentContainer)
+		e.VarInt(1)
+	}
+	d = NewDecoder(e.Data())
+	d.Slot()
+	require.ErrorIs(t, d.Err(), ErrInvalidLength)
+	require.ErrorContains(t, d.Err(), "nested")
+
+	// nbt sizes longer than the packet are rejected before allocating
+	e = Encoder{}
+	e.VarInt(1)
+	e.VarInt(1)
+	e.VarInt(1)
+	e.VarInt(0)
+	e.VarInt(ComponentCustomData)
+	e.Raw([]byte{byte(nbt.TagLongArray), 0x7f, 0xff, 0xff, 0xff})
+	d = NewDecoder(e.Data())
+	d.Slot()
+	require.ErrorIs(t, d.Err(), nbt.ErrSizeTooLarge)
+}
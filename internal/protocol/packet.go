@@ -2,15 +2,14 @@ package protocol
 
 import (
 	"bytes"
-	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
 
 	"github.com/BinaryArchaism/mc-srv/internal/countingbuffer"
 	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
 	"github.com/google/uuid"
 )
 
@@ -67,92 +66,51 @@ func (p *HandshakePacket) Read(r io.Reader) error {
 	return nil
 }
 
-type StatusResponsePacket struct {
-	Length datatypes.VarInt
-	ID     datatypes.VarInt
+// Version of the game the server speaks
+const (
+	VersionName     = "1.21"
+	ProtocolVersion = 767
+)
 
-	JSONResponse datatypes.String
+const StatusResponseID = 0x00
+
+type StatusResponsePacket struct {
+	Response JSONResponse
 }
 
 type JSONResponse struct {
-	Version struct {
-		Name     string `json:"name"`
-		Protocol int    `json:"protocol"`
-	} `json:"version"`
-	Players struct {
-		Max    int `json:"max"`
-		Online int `json:"online"`
-		Sample []struct {
-			Name string `json:"name"`
-			Id   string `json:"id"`
-		} `json:"sample"`
-	} `json:"players"`
-	Description struct {
-		Text string `json:"text"`
-	} `json:"description"`
-	Favicon            string `json:"favicon"`
+	Version     StatusVersion  `json:"version"`
+	Players     StatusPlayers  `json:"players"`
+	Description text.Component `json:"description"`
+	// Favicon is a PNG data URI, empty shows the default icon
+	Favicon            string `json:"favicon,omitempty"`
 	EnforcesSecureChat bool   `json:"enforcesSecureChat"`
 }
 
-func (p *StatusResponsePacket) Write(w io.Writer) error {
-	// todo must do it once on startup
-	data, err := os.ReadFile("../../resources/icon.png")
-	if err != nil {
-		return err
-	}
-	encoded := base64.StdEncoding.EncodeToString(data)
-
-	js := JSONResponse{
-		Version: struct {
-			Name     string `json:"name"`
-			Protocol int    `json:"protocol"`
-		}{
-			Name:     "1.21",
-			Protocol: 767,
-		},
-		Players: struct {
-			Max    int `json:"max"`
-			Online int `json:"online"`
-			Sample []struct {
-				Name string `json:"name"`
-				Id   string `json:"id"`
-			} `json:"sample"`
-		}{
-			Max:    100,
-			Online: 10,
-			Sample: nil,
-		},
-		Description: struct {
-			Text string `json:"text"`
-		}{
-			"Davai rabotai",
-		},
-		Favicon:            "data:image/png;base64," + encoded,
-		EnforcesSecureChat: false,
-	}
-	b, err := json.Marshal(js)
-	if err != nil {
-		return err
-	}
-	str := datatypes.FromString(string(b))
-
-	strBytes := datatypes.WriteString(str)
+type StatusVersion struct {
+	Name     string `json:"name"`
+	Protocol int    `json:"protocol"`
+}
 
-	s := StatusResponsePacket{
-		Length: datatypes.VarInt(len(strBytes) + 1),
-		ID:     0x00,
-	}
+type StatusPlayers struct {
+	Max    int            `json:"max"`
+	Online int            `json:"online"`
+	Sample []StatusPlayer `json:"sample,omitempty"`
+}
 
-	res := make([]byte, 0, 3+len(b)+1)
-	res = append(res, datatypes.WriteVarInt(s.Length)...)
-	res = append(res, datatypes.WriteVarInt(s.ID)...)
-	res = append(res, strBytes...)
+type StatusPlayer struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
 
-	_, err = w.Write(res)
+func (p *StatusResponsePacket) Write(w io.Writer) error {
+	b, err := json.Marshal(p.Response)
 	if err != nil {
 		return err
 	}
-	return nil
+	var e Encoder
+	e.String(string(b))
+	return WritePacket(w, StatusResponseID, e.Data())
 }
 
 type LoginPacket struct {
@@ -192,6 +150,8 @@ func (p *LoginPacket) Read(r io.Reader) error {
 	return nil
 }
 
+const LoginDisconnectID = 0x00
+
 type DisconnectPacket struct {
 	Length int32
 	ID     int32
@@ -230,6 +190,21 @@ func (p *DisconnectPacket) Write(w io.Writer) error {
 	return nil
 }
 
+// LoginDisconnectPacket refuses a player during login
+type LoginDisconnectPacket struct {
+	Reason text.Component
+}
+
+func (p *LoginDisconnectPacket) Write(w io.Writer) error {
+	reason, err := json.Marshal(p.Reason)
+	if err != nil {
+		return err
+	}
+	var e Encoder
+	e.String(string(reason))
+	return WritePacket(w, LoginDisconnectID, e.Data())
+}
+
 type Packet struct {
 	Length int
 	ID     int
@@ -526,69 +501,6 @@ func (p *LoginPlugin) Read(r io.Reader) error {
 	return nil
 }
 
-type ServerboundPluginPacket struct {
-	Packet
-
-	Channel datatypes.String
-	Data    []byte
-}
-
-func (p *ServerboundPluginPacket) Read(r io.Reader) error {
-	poolBytes := Pool.GetN(SmallObjectSize)
-	defer Pool.Put(poolBytes)
-
-	n, err := r.Read(poolBytes[:cap(poolBytes)])
-	if err != nil {
-		return err
-	}
-
-	buf := countingbuffer.New(poolBytes)
-
-	p.Packet.Length, err = datatypes.BinaryReadVarInt(buf)
-	if err != nil {
-		return err
-	}
-
-	p.Packet.ID, err = datatypes.BinaryReadVarInt(buf)
-	if err != nil {
-		return err
-	}
-
-	p.Channel = datatypes.ReadStringReader(buf)
-	p.Data = buf.Bytes()[:n-buf.ReadCount()]
-	return nil
-}
-
-func (p *ServerboundPluginPacket) Write(w io.Writer) error {
-	poolBytes := Pool.GetN(SmallObjectSize)
-	defer Pool.Put(poolBytes)
-
-	buf := countingbuffer.New(poolBytes)
-	buf.Reset()
-
-	p.ID = 0x01
-
-	_, err := buf.Write(datatypes.BinaryWriteVarInt(p.ID))
-	if err != nil {
-		return err
-	}
-	_, err = buf.Write(datatypes.WriteString(p.Channel))
-	if err != nil {
-		return err
-	}
-
-	_, err = w.Write(datatypes.BinaryWriteVarInt(buf.WriteCount()))
-	if err != nil {
-		return err
-	}
-
-	_, err = buf.WriteTo(w)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 type ClientInformationPacket struct {
 	Packet
 
@@ -850,11 +762,19 @@ func (p *LoginPlayPacket) Write(w io.Writer) error {
 	buf := countingbuffer.New(poolBytes)
 	buf.Reset()
 
-	p.ID = 0x2B
+	p.ID = PlayLoginID
+
+	_, err := buf.Write(datatypes.BinaryWriteVarInt(p.ID))
+	if err != nil {
+		return err
+	}
 
-	binary.BigEndian.PutUint32(buf.Next(4), uint32(p.EntityID))
+	err = binary.Write(buf, binary.BigEndian, p.EntityID)
+	if err != nil {
+		return err
+	}
 
-	err := buf.WriteByte(datatypes.WriteBoolean(p.IsHardcore))
+	err = buf.WriteByte(datatypes.WriteBoolean(p.IsHardcore))
 	if err != nil {
 		return err
 	}
@@ -911,7 +831,10 @@ func (p *LoginPlayPacket) Write(w io.Writer) error {
 		return err
 	}
 
-	binary.BigEndian.PutUint64(buf.Next(8), uint64(p.HashedSeed))
+	err = binary.Write(buf, binary.BigEndian, p.HashedSeed)
+	if err != nil {
+		return err
+	}
 
 	err = buf.WriteByte(p.GameMode)
 	if err != nil {
@@ -944,7 +867,10 @@ func (p *LoginPlayPacket) Write(w io.Writer) error {
 			return err
 		}
 
-		binary.LittleEndian.PutUint64(buf.Next(8), uint64(datatypes.WritePosition(p.DeathLocation)))
+		err = binary.Write(buf, binary.BigEndian, datatypes.WritePosition(p.DeathLocation))
+		if err != nil {
+			return err
+		}
 	}
 
 	_, err = buf.Write(datatypes.BinaryWriteVarInt(int(p.PortalCooldown)))
@@ -0,0 +1,360 @@
+package protocol
+
+import (
+	"io"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+)
+
+// Clientbound play packet IDs
+const (
+	PlayChunkBatchFinishedID        = 0x0C
+	PlayChunkBatchStartID           = 0x0D
+	PlayDisconnectID                = 0x1D
+	PlayEntityEventID               = 0x1F
+	PlayUnloadChunkID               = 0x21
+	PlayGameEventID                 = 0x22
+	PlayKeepAliveID                 = 0x26
+	PlayChunkDataID                 = 0x27
+	PlayLoginID                     = 0x2B
+	PlayPlayerAbilitiesID           = 0x38
+	PlaySynchronizePlayerPositionID = 0x40
+	PlaySetHeldItemID               = 0x53
+	PlaySetCenterChunkID            = 0x54
+	PlaySetDefaultSpawnPositionID   = 0x56
+	PlaySetExperienceID             = 0x5C
+	PlaySetHealthID                 = 0x5D
+	PlaySetTimeID                   = 0x64
+	PlayTabListID                   = 0x6D
+)
+
+// Serverbound play packet IDs
+const (
+	PlayConfirmTeleportationID = 0x00
+	PlayChunkBatchReceivedID   = 0x08
+	PlayClientInformationID    = 0x0A
+	PlayServerKeepAliveID      = 0x18
+	PlayMovePlayerPosID        = 0x1A
+	PlayMovePlayerPosRotID     = 0x1B
+	PlayMovePlayerRotID        = 0x1C
+	PlayMovePlayerStatusOnlyID = 0x1D
+	PlayPlayerCommandID        = 0x25
+)
+
+// Game Event types
+const (
+	GameEventBeginRaining          = 1
+	GameEventEndRaining            = 2
+	GameEventChangeGameMode        = 3
+	GameEventRainLevelChange       = 7
+	GameEventThunderLevelChange    = 8
+	GameEventStartWaitingForChunks = 13
+)
+
+// Player Command actions
+const (
+	CommandStartSneaking = 0
+	CommandStopSneaking  = 1
+)
+
+// EntityEventOpLevel is the Entity Event status telling a player its
+// permission level, levels 0 to 4 are added to it
+const EntityEventOpLevel = 24
+
+// Player Abilities flags
+const (
+	AbilityInvulnerable = 0x01
+	AbilityFlying       = 0x02
+	AbilityAllowFlying  = 0x04
+	AbilityInstantBreak = 0x08
+)
+
+type SetCenterChunkPacket struct {
+	X, Z int32
+}
+
+func (p *SetCenterChunkPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.X)
+	e.VarInt(p.Z)
+	return WritePacket(w, PlaySetCenterChunkID, e.Data())
+}
+
+type UnloadChunkPacket struct {
+	X, Z int32
+}
+
+func (p *UnloadChunkPacket) Write(w io.Writer) error {
+	var e Encoder
+	// Z goes first
+	e.Int(p.Z)
+	e.Int(p.X)
+	return WritePacket(w, PlayUnloadChunkID, e.Data())
+}
+
+type ChunkBatchStartPacket struct{}
+
+func (p *ChunkBatchStartPacket) Write(w io.Writer) error {
+	return WritePacket(w, PlayChunkBatchStartID, nil)
+}
+
+type ChunkBatchFinishedPacket struct {
+	BatchSize int32
+}
+
+func (p *ChunkBatchFinishedPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.VarInt(p.BatchSize)
+	return WritePacket(w, PlayChunkBatchFinishedID, e.Data())
+}
+
+type ChunkBatchReceivedPacket struct {
+	ChunksPerTick float32
+}
+
+func (p *ChunkBatchReceivedPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.ChunksPerTick = d.Float()
+	return d.Err()
+}
+
+// Decode reads client information sent in play state
+func (p *ClientInformationPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Locale = datatypes.FromString(d.String(16))
+	p.ViewDistance = d.Byte()
+	p.ChatMode = int(d.VarInt())
+	p.ChatColors = datatypes.Boolean(d.Bool())
+	p.DisplayedSkinParts = d.Byte()
+	p.MainHand = int(d.VarInt())
+	p.EnableTextFiltering = datatypes.Boolean(d.Bool())
+	p.AllowServerListings = datatypes.Boolean(d.Bool())
+	return d.Err()
+}
+
+type SetDefaultSpawnPositionPacket struct {
+	Location datatypes.Position
+	Angle    float32
+}
+
+func (p *SetDefaultSpawnPositionPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Position(p.Location)
+	e.Float(p.Angle)
+	return WritePacket(w, PlaySetDefaultSpawnPositionID, e.Data())
+}
+
+// SynchronizePlayerPositionPacket teleports the player, the client answers
+// with Confirm Teleportation carrying TeleportID
+type SynchronizePlayerPositionPacket struct {
+	X, Y, Z    float64
+	Yaw, Pitch float32
+	// Flags make the matching fields relative, zero means all absolute
+	Flags      byte
+	TeleportID int32
+}
+
+func (p *SynchronizePlayerPositionPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Double(p.X)
+	e.Double(p.Y)
+	e.Double(p.Z)
+	e.Float(p.Yaw)
+	e.Float(p.Pitch)
+	e.Byte(p.Flags)
+	e.VarInt(p.TeleportID)
+	return WritePacket(w, PlaySynchronizePlayerPositionID, e.Data())
+}
+
+type ConfirmTeleportationPacket struct {
+	TeleportID int32
+}
+
+func (p *ConfirmTeleportationPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.TeleportID = d.VarInt()
+	return d.Err()
+}
+
+// MovePlayerPacket is any of Set Player Position, Set Player Position and
+// Rotation, Set Player Rotation and Set Player On Ground
+type MovePlayerPacket struct {
+	X, Y, Z     float64
+	Yaw, Pitch  float32
+	OnGround    bool
+	HasPosition bool
+	HasRotation bool
+}
+
+// Decode reads the movement packet with the given id
+func (p *MovePlayerPacket) Decode(id int, data []byte) error {
+	d := NewDecoder(data)
+	p.HasPosition = id == PlayMovePlayerPosID || id == PlayMovePlayerPosRotID
+	p.HasRotation = id == PlayMovePlayerRotID || id == PlayMovePlayerPosRotID
+	if p.HasPosition {
+		p.X = d.Double()
+		p.Y = d.Double()
+		p.Z = d.Double()
+	}
+	if p.HasRotation {
+		p.Yaw = d.Float()
+		p.Pitch = d.Float()
+	}
+	p.OnGround = d.Bool()
+	return d.Err()
+}
+
+type GameEventPacket struct {
+	Event byte
+	Value float32
+}
+
+func (p *GameEventPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.Event)
+	e.Float(p.Value)
+	return WritePacket(w, PlayGameEventID, e.Data())
+}
+
+// EntityEventPacket triggers an entity status effect on the client
+type EntityEventPacket struct {
+	EntityID int32
+	Status   byte
+}
+
+func (p *EntityEventPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Int(p.EntityID)
+	e.Byte(p.Status)
+	return WritePacket(w, PlayEntityEventID, e.Data())
+}
+
+type PlayerAbilitiesPacket struct {
+	Flags       byte
+	FlyingSpeed float32
+	// FOVModifier is the walking speed
+	FOVModifier float32
+}
+
+func (p *PlayerAbilitiesPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Byte(p.Flags)
+	e.Float(p.FlyingSpeed)
+	e.Float(p.FOVModifier)
+	return WritePacket(w, PlayPlayerAbilitiesID, e.Data())
+}
+
+// SetHealthPacket updates the health and food bars of the player
+type SetHealthPacket struct {
+	Health     float32
+	Food       int32
+	Saturation float32
+}
+
+func (p *SetHealthPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Float(p.Health)
+	e.VarInt(p.Food)
+	e.Float(p.Saturation)
+	return WritePacket(w, PlaySetHealthID, e.Data())
+}
+
+// SetExperiencePacket updates the experience bar, Progress is between 0 and 1
+type SetExperiencePacket struct {
+	Progress float32
+	Level    int32
+	Total    int32
+}
+
+func (p *SetExperiencePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Float(p.Progress)
+	e.VarInt(p.Level)
+	e.VarInt(p.Total)
+	return WritePacket(w, PlaySetExperienceID, e.Data())
+}
+
+type SetHeldItemPacket struct {
+	Slot byte
+}
+
+func (p *SetHeldItemPacket) Write(w io.Writer) error {
+	return WritePacket(w, PlaySetHeldItemID, []byte{p.Slot})
+}
+
+// Decode reads the slot the client selected, it is sent as a short
+func (p *SetHeldItemPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.Slot = byte(d.Short())
+	return d.Err()
+}
+
+// PlayerCommandPacket reports sneaking, sprinting and similar player actions
+type PlayerCommandPacket struct {
+	EntityID  int32
+	Action    int32
+	JumpBoost int32
+}
+
+func (p *PlayerCommandPacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.EntityID = d.VarInt()
+	p.Action = d.VarInt()
+	p.JumpBoost = d.VarInt()
+	return d.Err()
+}
+
+// KeepAlivePacket is sent by the server and echoed back by the client
+type KeepAlivePacket struct {
+	ID int64
+}
+
+func (p *KeepAlivePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Long(p.ID)
+	return WritePacket(w, PlayKeepAliveID, e.Data())
+}
+
+func (p *KeepAlivePacket) Decode(data []byte) error {
+	d := NewDecoder(data)
+	p.ID = d.Long()
+	return d.Err()
+}
+
+// PlayDisconnectPacket kicks the player in play state
+type PlayDisconnectPacket struct {
+	Reason string
+}
+
+func (p *PlayDisconnectPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.NBT(nbt.Compound{"text": p.Reason})
+	return WritePacket(w, PlayDisconnectID, e.Data())
+}
+
+type SetTimePacket struct {
+	WorldAge int64
+	// TimeOfDay is negative when the daylight cycle is stopped
+	TimeOfDay int64
+}
+
+func (p *SetTimePacket) Write(w io.Writer) error {
+	var e Encoder
+	e.Long(p.WorldAge)
+	e.Long(p.TimeOfDay)
+	return WritePacket(w, PlaySetTimeID, e.Data())
+}
+
+// TabListPacket sets the header and footer of the player list
+type TabListPacket struct {
+	Header, Footer text.Component
+}
+
+func (p *TabListPacket) Write(w io.Writer) error {
+	var e Encoder
+	e.NBT(p.Header.NBT())
+	e.NBT(p.Footer.NBT())
+	return WritePacket(w, PlayTabListID, e.Data())
+}
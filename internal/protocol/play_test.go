@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMovePlayerPacket_Decode(t *testing.T) {
+	var e Encoder
+	e.Double(1.5)
+	e.Double(64)
+	e.Double(-2.25)
+	e.Float(90)
+	e.Float(-10)
+	e.Bool(true)
+
+	var p MovePlayerPacket
+	require.NoError(t, p.Decode(PlayMovePlayerPosRotID, e.Data()))
+	require.Equal(t, MovePlayerPacket{
+		X: 1.5, Y: 64, Z: -2.25, Yaw: 90, Pitch: -10,
+		OnGround: true, HasPosition: true, HasRotation: true,
+	}, p)
+
+	e = Encoder{}
+	e.Float(45)
+	e.Float(0)
+	e.Bool(false)
+	p = MovePlayerPacket{}
+	require.NoError(t, p.Decode(PlayMovePlayerRotID, e.Data()))
+	require.Equal(t, MovePlayerPacket{Yaw: 45, HasRotation: true}, p)
+
+	p = MovePlayerPacket{}
+	require.NoError(t, p.Decode(PlayMovePlayerStatusOnlyID, []byte{1}))
+	require.Equal(t, MovePlayerPacket{OnGround: true}, p)
+
+	require.Error(t, p.Decode(PlayMovePlayerPosID, []byte{1}))
+}
@@ -0,0 +1,265 @@
+// Package query implements the GameSpy4 UDP query protocol used by server
+// lists to read the server status and player list.
+package query
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Request types
+const (
+	TypeStat      = 0x00
+	TypeHandshake = 0x09
+)
+
+const (
+	magic = 0xFEFD
+	// sessionIDMask drops bits clients may not use in session IDs
+	sessionIDMask = 0x0F0F0F0F
+	// TokenLifetime is how long a challenge token is accepted, tokens are
+	// dropped on a timer and clients have to handshake again
+	TokenLifetime    = 30 * time.Second
+	maxRequestLength = 1460
+)
+
+var (
+	// fullStatPadding and playersPadding are constant parts of the full stat response
+	fullStatPadding = []byte("splitnum\x00\x80\x00")
+	playersPadding  = []byte("\x01player_\x00\x00")
+)
+
+var ErrInvalidRequest = errors.New("invalid query request")
+
+// Stats are reported to query clients
+type Stats struct {
+	MOTD     string
+	GameType string
+	GameID   string
+	Version  string
+	// Plugins is the server software and its plugins, as in "mc-srv: a; b"
+	Plugins    string
+	Map        string
+	NumPlayers int
+	MaxPlayers int
+	HostPort   int
+	HostIP     string
+	Players    []string
+}
+
+// Provider returns current stats, it is called from the query goroutine
+type Provider func() Stats
+
+type Request struct {
+	Type      byte
+	SessionID int32
+	Token     int32
+	// Full is set for stat requests asking for the full stat
+	Full bool
+}
+
+func ParseRequest(data []byte) (Request, error) {
+	if len(data) < 7 || binary.BigEndian.Uint16(data) != magic {
+		return Request{}, ErrInvalidRequest
+	}
+	req := Request{
+		Type:      data[2],
+		SessionID: int32(binary.BigEndian.Uint32(data[3:])) & sessionIDMask,
+	}
+	switch req.Type {
+	case TypeHandshake:
+	case TypeStat:
+		if len(data) < 11 {
+			return Request{}, fmt.Errorf("%w: missing challenge token", ErrInvalidRequest)
+		}
+		req.Token = int32(binary.BigEndian.Uint32(data[7:]))
+		req.Full = len(data) >= 15
+	default:
+		return Request{}, fmt.Errorf("%w: type %d", ErrInvalidRequest, req.Type)
+	}
+	return req, nil
+}
+
+func responseHeader(typ byte, sessionID int32) *bytes.Buffer {
+	var buf bytes.Buffer
+	buf.WriteByte(typ)
+	_ = binary.Write(&buf, binary.BigEndian, sessionID)
+	return &buf
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.WriteByte(0)
+}
+
+// HandshakeResponse returns the challenge token the client must send back
+func HandshakeResponse(sessionID, token int32) []byte {
+	buf := responseHeader(TypeHandshake, sessionID)
+	writeString(buf, strconv.Itoa(int(token)))
+	return buf.Bytes()
+}
+
+func BasicStatResponse(sessionID int32, st Stats) []byte {
+	buf := responseHeader(TypeStat, sessionID)
+	writeString(buf, st.MOTD)
+	writeString(buf, st.GameType)
+	writeString(buf, st.Map)
+	writeString(buf, strconv.Itoa(st.NumPlayers))
+	writeString(buf, strconv.Itoa(st.MaxPlayers))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(st.HostPort))
+	writeString(buf, st.HostIP)
+	return buf.Bytes()
+}
+
+func FullStatResponse(sessionID int32, st Stats) []byte {
+	buf := responseHeader(TypeStat, sessionID)
+	buf.Write(fullStatPadding)
+	for _, kv := range [...][2]string{
+		{"hostname", st.MOTD},
+		{"gametype", st.GameType},
+		{"game_id", st.GameID},
+		{"version", st.Version},
+		{"plugins", st.Plugins},
+		{"map", st.Map},
+		{"numplayers", strconv.Itoa(st.NumPlayers)},
+		{"maxplayers", strconv.Itoa(st.MaxPlayers)},
+		{"hostport", strconv.Itoa(st.HostPort)},
+		{"hostip", st.HostIP},
+	} {
+		writeString(buf, kv[0])
+		writeString(buf, kv[1])
+	}
+	buf.WriteByte(0)
+	buf.Write(playersPadding)
+	for _, name := range st.Players {
+		writeString(buf, name)
+	}
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+type challenge struct {
+	token   int32
+	created time.Time
+}
+
+type Server struct {
+	conn     net.PacketConn
+	provider Provider
+	done     chan struct{}
+
+	mu         sync.Mutex
+	challenges map[string]challenge
+}
+
+// Listen opens the UDP socket, Serve answers the requests
+func Listen(address string, provider Provider) (*Server, error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for query: %w", err)
+	}
+	return &Server{
+		conn:       conn,
+		provider:   provider,
+		done:       make(chan struct{}),
+		challenges: map[string]challenge{},
+	}, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Serve answers requests until Close is called
+func (s *Server) Serve() error {
+	go s.rotateTokens()
+	buf := make([]byte, maxRequestLength)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read query request: %w", err)
+		}
+		res, err := s.handle(buf[:n], addr)
+		if err != nil {
+			log.Debug().Err(err).Str("client", addr.String()).Msg("rejected query request")
+			continue
+		}
+		_, err = s.conn.WriteTo(res, addr)
+		if err != nil {
+			log.Debug().Err(err).Str("client", addr.String()).Msg("failed to write query response")
+		}
+	}
+}
+
+func (s *Server) Close() error {
+	close(s.done)
+	return s.conn.Close()
+}
+
+func (s *Server) handle(data []byte, addr net.Addr) ([]byte, error) {
+	req, err := ParseRequest(data)
+	if err != nil {
+		return nil, err
+	}
+	if req.Type == TypeHandshake {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		s.challenges[addr.String()] = challenge{token: token, created: time.Now()}
+		s.mu.Unlock()
+		return HandshakeResponse(req.SessionID, token), nil
+	}
+	s.mu.Lock()
+	c, ok := s.challenges[addr.String()]
+	s.mu.Unlock()
+	if !ok || c.token != req.Token || time.Since(c.created) > TokenLifetime {
+		return nil, fmt.Errorf("%w: wrong challenge token", ErrInvalidRequest)
+	}
+	if req.Full {
+		return FullStatResponse(req.SessionID, s.provider()), nil
+	}
+	return BasicStatResponse(req.SessionID, s.provider()), nil
+}
+
+func newToken() (int32, error) {
+	var b [4]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate challenge token: %w", err)
+	}
+	return int32(binary.BigEndian.Uint32(b[:]) & 0x7FFFFFFF), nil
+}
+
+// rotateTokens drops expired challenge tokens
+func (s *Server) rotateTokens() {
+	ticker := time.NewTicker(TokenLifetime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for addr, c := range s.challenges {
+				if now.Sub(c.created) > TokenLifetime {
+					delete(s.challenges, addr)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testStats = Stats{
+	MOTD:       "A server",
+	GameType:   "SMP",
+	GameID:     "MINECRAFT",
+	Version:    "1.21",
+	Map:        "world",
+	NumPlayers: 2,
+	MaxPlayers: 20,
+	HostPort:   25565,
+	HostIP:     "127.0.0.1",
+	Players:    []string{"alice", "bob"},
+}
+
+func request(typ byte, sessionID int32, extra ...int32) []byte {
+	buf := []byte{0xFE, 0xFD, typ}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(sessionID))
+	for _, v := range extra {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(v))
+	}
+	return buf
+}
+
+func TestParseRequest(t *testing.T) {
+	req, err := ParseRequest(request(TypeStat, 0x7F7F7F7F, 42, 0))
+	require.NoError(t, err)
+	require.Equal(t, Request{Type: TypeStat, SessionID: 0x0F0F0F0F, Token: 42, Full: true}, req)
+
+	_, err = ParseRequest([]byte{0xFE, 0xFC, 9, 0, 0, 0, 1})
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = ParseRequest(request(TypeStat, 1))
+	require.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestBasicStatResponse(t *testing.T) {
+	res := BasicStatResponse(1, testStats)
+	require.Equal(t, append([]byte("\x00\x00\x00\x00\x01A server\x00SMP\x00world\x002\x0020\x00\xdd\x63"), "127.0.0.1\x00"...), res)
+}
+
+func TestServer(t *testing.T) {
+	srv, err := Listen("127.0.0.1:0", func() Stats { return testStats })
+	require.NoError(t, err)
+	go srv.Serve()
+	defer srv.Close()
+
+	conn, err := net.Dial("udp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	buf := make([]byte, 1024)
+	exchange := func(req []byte) []byte {
+		_, err := conn.Write(req)
+		require.NoError(t, err)
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		return buf[:n]
+	}
+
+	res := exchange(request(TypeHandshake, 1))
+	require.Equal(t, []byte{TypeHandshake, 0, 0, 0, 1}, res[:5])
+	token, err := strconv.Atoi(string(bytes.TrimSuffix(res[5:], []byte{0})))
+	require.NoError(t, err)
+
+	res = exchange(request(TypeStat, 1, int32(token), 0))
+	require.Equal(t, FullStatResponse(1, testStats), res)
+	require.True(t, bytes.HasSuffix(res, []byte("\x01player_\x00\x00alice\x00bob\x00\x00")))
+	require.True(t, bytes.Contains(res, []byte("numplayers\x002\x00")))
+
+	// a wrong token is not answered, the next request still is
+	_, err = conn.Write(request(TypeStat, 1, int32(token)+1))
+	require.NoError(t, err)
+	require.Equal(t, BasicStatResponse(1, testStats), exchange(request(TypeStat, 1, int32(token))))
+}
@@ -0,0 +1,89 @@
+package rcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Packet types, a command and an auth response share the same value
+const (
+	TypeResponseValue = 0
+	TypeExecCommand   = 2
+	TypeAuthResponse  = 2
+	TypeAuth          = 3
+)
+
+const (
+	// packetHeaderLength is the request ID, the type and the two null bytes ending the body
+	packetHeaderLength = 10
+	// maxPacketLength limits packets sent by clients
+	maxPacketLength = 4096 + packetHeaderLength
+	// maxResponseBody is how much of a response fits in one packet, longer
+	// responses are split into several packets with the same request ID
+	maxResponseBody = 4096
+)
+
+var ErrInvalidPacket = errors.New("invalid rcon packet")
+
+// Packet is a request or a response, both are little endian with a length prefix
+type Packet struct {
+	ID   int32
+	Type int32
+	Body string
+}
+
+func ReadPacket(r io.Reader) (Packet, error) {
+	var length int32
+	err := binary.Read(r, binary.LittleEndian, &length)
+	if err != nil {
+		return Packet{}, err
+	}
+	if length < packetHeaderLength || length > maxPacketLength {
+		return Packet{}, fmt.Errorf("%w: length %d", ErrInvalidPacket, length)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return Packet{}, fmt.Errorf("failed to read rcon packet: %w", err)
+	}
+	body := data[8 : length-2]
+	if i := bytes.IndexByte(body, 0); i >= 0 {
+		body = body[:i]
+	}
+	return Packet{
+		ID:   int32(binary.LittleEndian.Uint32(data[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(data[4:8])),
+		Body: string(body),
+	}, nil
+}
+
+func (p Packet) Write(w io.Writer) error {
+	buf := make([]byte, 4, 4+packetHeaderLength+len(p.Body))
+	binary.LittleEndian.PutUint32(buf, uint32(packetHeaderLength+len(p.Body)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.ID))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.Type))
+	buf = append(buf, p.Body...)
+	buf = append(buf, 0, 0)
+	_, err := w.Write(buf)
+	return err
+}
+
+// splitResponse cuts body into parts that fit a packet without breaking UTF-8 sequences
+func splitResponse(body string) []string {
+	if len(body) <= maxResponseBody {
+		return []string{body}
+	}
+	var parts []string
+	for len(body) > maxResponseBody {
+		n := maxResponseBody
+		for n > 0 && body[n]&0xC0 == 0x80 {
+			n--
+		}
+		parts = append(parts, body[:n])
+		body = body[n:]
+	}
+	return append(parts, body)
+}
@@ -0,0 +1,263 @@
+// Package rcon implements the Source RCON protocol for remote administration.
+package rcon
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Handler runs a command and returns its output
+type Handler func(command string) string
+
+// Options configure authentication of an RCON server
+type Options struct {
+	Password string
+	// MaxAuthFailures wrong passwords from one address block it for BlockDuration
+	MaxAuthFailures int
+	BlockDuration   time.Duration
+	// IdleTimeout drops clients that send nothing for that long,
+	// DefaultIdleTimeout is used when it is not positive
+	IdleTimeout time.Duration
+}
+
+const DefaultIdleTimeout = 2 * time.Minute
+
+var ErrNoPassword = errors.New("rcon password is not set")
+
+type Server struct {
+	listener net.Listener
+	password string
+	handler  Handler
+	limiter  *authLimiter
+	idle     time.Duration
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// Listen starts listening on address, Serve accepts the clients
+func Listen(address string, opts Options, handler Handler) (*Server, error) {
+	if opts.Password == "" {
+		return nil, ErrNoPassword
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for rcon: %w", err)
+	}
+	return &Server{
+		listener: listener,
+		password: opts.Password,
+		handler:  handler,
+		limiter:  newAuthLimiter(opts.MaxAuthFailures, opts.BlockDuration),
+		idle:     opts.IdleTimeout,
+		conns:    map[net.Conn]struct{}{},
+	}, nil
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve accepts clients until Close is called
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to accept rcon client: %w", err)
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// Close stops accepting clients and disconnects connected ones
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	return err
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if s.limiter.blocked(host) {
+		log.Debug().Str("client", addr).Msg("refused blocked rcon client")
+		return
+	}
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	authenticated := false
+	for {
+		err = conn.SetReadDeadline(time.Now().Add(s.idle))
+		if err != nil {
+			return
+		}
+		p, err := ReadPacket(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Debug().Err(err).Str("client", addr).Msg("rcon client dropped")
+			}
+			return
+		}
+		switch p.Type {
+		case TypeAuth:
+			if subtle.ConstantTimeCompare([]byte(p.Body), []byte(s.password)) == 1 {
+				authenticated = true
+				s.limiter.succeeded(host)
+				log.Info().Str("client", addr).Msg("rcon client authenticated")
+				err = Packet{ID: p.ID, Type: TypeAuthResponse}.Write(w)
+				break
+			}
+			authenticated = false
+			err = Packet{ID: -1, Type: TypeAuthResponse}.Write(w)
+			if s.limiter.failed(host) {
+				log.Warn().Str("client", addr).Msg("rcon client blocked after failed logins")
+				_ = w.Flush()
+				return
+			}
+		case TypeExecCommand:
+			if !authenticated {
+				err = Packet{ID: -1, Type: TypeAuthResponse}.Write(w)
+				break
+			}
+			log.Info().Str("client", addr).Str("command", p.Body).Msg("rcon command")
+			for _, part := range splitResponse(s.handler(p.Body)) {
+				err = Packet{ID: p.ID, Type: TypeResponseValue, Body: part}.Write(w)
+				if err != nil {
+					break
+				}
+			}
+		case TypeResponseValue:
+			// clients send an empty response after a command and wait for it
+			// to come back to know a split response has ended
+			err = Packet{ID: p.ID, Type: TypeResponseValue}.Write(w)
+		default:
+			err = Packet{ID: p.ID, Type: TypeResponseValue, Body: fmt.Sprintf("Unknown request %x", p.Type)}.Write(w)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("client", addr).Msg("failed to write rcon response")
+			return
+		}
+	}
+}
+
+// authLimiter counts wrong passwords per address and blocks addresses that
+// send too many of them. Failures are forgotten once the block duration
+// passed since the first one.
+type authLimiter struct {
+	maxFailures int
+	block       time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*authFailures
+	// pruned is when expired failures were last removed
+	pruned time.Time
+}
+
+type authFailures struct {
+	count        int
+	since        time.Time
+	blockedUntil time.Time
+}
+
+// expired reports whether f no longer counts at now
+func (l *authLimiter) expired(f *authFailures, now time.Time) bool {
+	if f.blockedUntil.IsZero() {
+		return now.Sub(f.since) >= l.block
+	}
+	return !now.Before(f.blockedUntil)
+}
+
+func newAuthLimiter(maxFailures int, block time.Duration) *authLimiter {
+	return &authLimiter{maxFailures: maxFailures, block: block, now: time.Now, failures: map[string]*authFailures{}}
+}
+
+func (l *authLimiter) blocked(host string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.failures[host]
+	if !ok || f.blockedUntil.IsZero() {
+		return false
+	}
+	if l.now().Before(f.blockedUntil) {
+		return true
+	}
+	delete(l.failures, host)
+	return false
+}
+
+// failed records a wrong password and reports whether host is now blocked
+func (l *authLimiter) failed(host string) bool {
+	if l.maxFailures <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.pruned) >= l.block {
+		for h, f := range l.failures {
+			if l.expired(f, now) {
+				delete(l.failures, h)
+			}
+		}
+		l.pruned = now
+	}
+	f, ok := l.failures[host]
+	if !ok || l.expired(f, now) {
+		f = &authFailures{since: now}
+		l.failures[host] = f
+	}
+	f.count++
+	if f.count < l.maxFailures {
+		return false
+	}
+	f.blockedUntil = now.Add(l.block)
+	return true
+}
+
+func (l *authLimiter) succeeded(host string) {
+	l.mu.Lock()
+	delete(l.failures, host)
+	l.mu.Unlock()
+}
@@ -0,0 +1,127 @@
+package rcon
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacket(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Packet{ID: 7, Type: TypeExecCommand, Body: "list"}.Write(&buf))
+	require.Equal(t, []byte{14, 0, 0, 0, 7, 0, 0, 0, 2, 0, 0, 0, 'l', 'i', 's', 't', 0, 0}, buf.Bytes())
+	p, err := ReadPacket(&buf)
+	require.NoError(t, err)
+	require.Equal(t, Packet{ID: 7, Type: TypeExecCommand, Body: "list"}, p)
+
+	_, err = ReadPacket(bytes.NewReader([]byte{0xFF, 0xFF, 0, 0}))
+	require.ErrorIs(t, err, ErrInvalidPacket)
+}
+
+func TestSplitResponse(t *testing.T) {
+	require.Equal(t, []string{""}, splitResponse(""))
+	long := strings.Repeat("a", maxResponseBody-1) + "é" + "b"
+	parts := splitResponse(long)
+	require.Equal(t, []string{strings.Repeat("a", maxResponseBody-1), "éb"}, parts)
+}
+
+func exchange(t *testing.T, conn net.Conn, p Packet) Packet {
+	t.Helper()
+	require.NoError(t, p.Write(conn))
+	res, err := ReadPacket(conn)
+	require.NoError(t, err)
+	return res
+}
+
+func TestServer(t *testing.T) {
+	srv, err := Listen("127.0.0.1:0", Options{Password: "secret", MaxAuthFailures: 2, BlockDuration: time.Minute}, func(cmd string) string {
+		if cmd == "long" {
+			return strings.Repeat("x", maxResponseBody+10)
+		}
+		return "ran " + cmd
+	})
+	require.NoError(t, err)
+	go srv.Serve()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, Packet{ID: -1, Type: TypeAuthResponse}, exchange(t, conn, Packet{ID: 1, Type: TypeExecCommand, Body: "list"}))
+	require.Equal(t, Packet{ID: 2, Type: TypeAuthResponse}, exchange(t, conn, Packet{ID: 2, Type: TypeAuth, Body: "secret"}))
+	require.Equal(t, Packet{ID: 3, Type: TypeResponseValue, Body: "ran list"}, exchange(t, conn, Packet{ID: 3, Type: TypeExecCommand, Body: "list"}))
+
+	require.NoError(t, Packet{ID: 4, Type: TypeExecCommand, Body: "long"}.Write(conn))
+	require.NoError(t, Packet{ID: 5, Type: TypeResponseValue}.Write(conn))
+	var body string
+	for {
+		p, err := ReadPacket(conn)
+		require.NoError(t, err)
+		if p.ID == 5 {
+			break
+		}
+		require.Equal(t, int32(4), p.ID)
+		body += p.Body
+	}
+	require.Len(t, body, maxResponseBody+10)
+
+	// wrong passwords get the address blocked
+	bad, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer bad.Close()
+	require.Equal(t, int32(-1), exchange(t, bad, Packet{ID: 1, Type: TypeAuth, Body: "guess"}).ID)
+	require.Equal(t, int32(-1), exchange(t, bad, Packet{ID: 2, Type: TypeAuth, Body: "guess"}).ID)
+	_, err = ReadPacket(bad)
+	require.Error(t, err)
+
+	blocked, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer blocked.Close()
+	require.NoError(t, Packet{ID: 1, Type: TypeAuth, Body: "secret"}.Write(blocked))
+	_, err = ReadPacket(blocked)
+	require.Error(t, err)
+}
+
+func TestAuthLimiter(t *testing.T) {
+	now := time.Now()
+	l := newAuthLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+	require.False(t, l.failed("a"))
+	l.succeeded("a")
+	require.False(t, l.failed("a"))
+	require.True(t, l.failed("a"))
+	require.True(t, l.blocked("a"))
+	require.False(t, l.blocked("b"))
+	now = now.Add(time.Minute)
+	require.False(t, l.blocked("a"))
+	require.False(t, l.failed("a"))
+
+	// failures far apart don't add up and old ones are dropped
+	now = now.Add(time.Minute)
+	require.False(t, l.failed("a"))
+	require.False(t, l.failed("b"))
+	now = now.Add(2 * time.Minute)
+	require.False(t, l.failed("c"))
+	require.Len(t, l.failures, 1)
+	require.False(t, l.failed("a"))
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	srv, err := Listen("127.0.0.1:0", Options{Password: "secret", IdleTimeout: 50 * time.Millisecond}, func(string) string { return "" })
+	require.NoError(t, err)
+	go srv.Serve()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = ReadPacket(conn)
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,106 @@
+package recipe
+
+import (
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+)
+
+// fuels are the vanilla furnace burn times in ticks, names starting with #
+// are item tags
+var fuels = map[string]int{
+	"minecraft:lava_bucket":             20000,
+	"minecraft:coal_block":              16000,
+	"minecraft:dried_kelp_block":        4001,
+	"minecraft:blaze_rod":               2400,
+	"minecraft:coal":                    1600,
+	"minecraft:charcoal":                1600,
+	"#minecraft:boats":                  1200,
+	"#minecraft:hanging_signs":          800,
+	"#minecraft:logs_that_burn":         300,
+	"#minecraft:bamboo_blocks":          300,
+	"#minecraft:planks":                 300,
+	"#minecraft:wooden_stairs":          300,
+	"#minecraft:wooden_trapdoors":       300,
+	"#minecraft:wooden_pressure_plates": 300,
+	"#minecraft:wooden_fences":          300,
+	"#minecraft:fence_gates":            300,
+	"#minecraft:banners":                300,
+	"minecraft:mangrove_roots":          300,
+	"minecraft:note_block":              300,
+	"minecraft:bookshelf":               300,
+	"minecraft:chiseled_bookshelf":      300,
+	"minecraft:lectern":                 300,
+	"minecraft:jukebox":                 300,
+	"minecraft:chest":                   300,
+	"minecraft:trapped_chest":           300,
+	"minecraft:crafting_table":          300,
+	"minecraft:daylight_detector":       300,
+	"minecraft:bow":                     300,
+	"minecraft:fishing_rod":             300,
+	"minecraft:ladder":                  300,
+	"minecraft:crossbow":                300,
+	"minecraft:barrel":                  300,
+	"minecraft:cartography_table":       300,
+	"minecraft:fletching_table":         300,
+	"minecraft:smithing_table":          300,
+	"minecraft:loom":                    300,
+	"minecraft:composter":               300,
+	"#minecraft:signs":                  200,
+	"#minecraft:wooden_doors":           200,
+	"minecraft:wooden_shovel":           200,
+	"minecraft:wooden_sword":            200,
+	"minecraft:wooden_hoe":              200,
+	"minecraft:wooden_axe":              200,
+	"minecraft:wooden_pickaxe":          200,
+	"#minecraft:wooden_slabs":           150,
+	"#minecraft:wool":                   100,
+	"#minecraft:wooden_buttons":         100,
+	"#minecraft:saplings":               100,
+	"minecraft:stick":                   100,
+	"minecraft:bowl":                    100,
+	"minecraft:dead_bush":               100,
+	"minecraft:azalea":                  100,
+	"minecraft:flowering_azalea":        100,
+	"#minecraft:wool_carpets":           67,
+	"minecraft:bamboo":                  50,
+	"minecraft:scaffolding":             50,
+}
+
+var fuelTimes = map[string]int{}
+
+func init() {
+	// items keep their own time over the time of tags containing them
+	for name, ticks := range fuels {
+		if name[0] != '#' {
+			fuelTimes[name] = ticks
+		}
+	}
+	for name, ticks := range fuels {
+		if name[0] != '#' {
+			continue
+		}
+		for _, item := range registry.ItemTag(name[1:]) {
+			if _, ok := fuelTimes[item]; !ok {
+				fuelTimes[item] = ticks
+			}
+		}
+	}
+}
+
+// FuelTime returns how many ticks item burns in a furnace, zero if it isn't a fuel
+func FuelTime(item string) int {
+	return fuelTimes[item]
+}
+
+// remainders are items left in the grid or fuel slot when item is used up
+var remainders = map[string]string{
+	"minecraft:milk_bucket":   "minecraft:bucket",
+	"minecraft:water_bucket":  "minecraft:bucket",
+	"minecraft:lava_bucket":   "minecraft:bucket",
+	"minecraft:honey_bottle":  "minecraft:glass_bottle",
+	"minecraft:dragon_breath": "minecraft:glass_bottle",
+}
+
+// Remainder returns the item left behind when item is used, empty for none
+func Remainder(item string) string {
+	return remainders[item]
+}
@@ -0,0 +1,398 @@
+// Package recipe loads crafting and smelting recipes in the vanilla data
+// pack format and matches crafting grids against them.
+package recipe
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+)
+
+// data holds the built in subset of the vanilla recipes
+//
+//go:embed data
+var data embed.FS
+
+var ErrInvalidRecipe = errors.New("invalid recipe")
+
+const (
+	TypeShaped    = "minecraft:crafting_shaped"
+	TypeShapeless = "minecraft:crafting_shapeless"
+	TypeSmelting  = "minecraft:smelting"
+
+	// DefaultCookingTime is the smelting time in ticks of recipes without one
+	DefaultCookingTime = 200
+)
+
+// Ingredient lists the items accepted by one slot of a recipe
+type Ingredient []string
+
+func (i Ingredient) Matches(item string) bool {
+	return slices.Contains(i, item)
+}
+
+type Result struct {
+	Item  string
+	Count int
+}
+
+// Shaped is a crafting recipe with a fixed layout, it also matches mirrored.
+// Pattern is Width*Height ingredients row by row, nil for empty slots.
+type Shaped struct {
+	ID      string
+	Width   int
+	Height  int
+	Pattern []Ingredient
+	Result  Result
+}
+
+// Shapeless is a crafting recipe whose ingredients may be anywhere in the grid
+type Shapeless struct {
+	ID          string
+	Ingredients []Ingredient
+	Result      Result
+}
+
+type Smelting struct {
+	ID         string
+	Ingredient Ingredient
+	Result     Result
+	Experience float32
+	// CookingTime is in ticks
+	CookingTime int
+}
+
+// Book holds the recipes the server knows
+type Book struct {
+	shaped    []Shaped
+	shapeless []Shapeless
+	smelting  []Smelting
+}
+
+var vanilla = sync.OnceValue(func() *Book {
+	b, err := Load()
+	if err != nil {
+		panic("recipe: " + err.Error())
+	}
+	return b
+})
+
+// Vanilla returns the built in recipes
+func Vanilla() *Book {
+	return vanilla()
+}
+
+type recipeJSON struct {
+	Type        string                     `json:"type"`
+	Key         map[string]json.RawMessage `json:"key"`
+	Pattern     []string                   `json:"pattern"`
+	Ingredients []json.RawMessage          `json:"ingredients"`
+	Ingredient  json.RawMessage            `json:"ingredient"`
+	Result      resultJSON                 `json:"result"`
+	Experience  float32                    `json:"experience"`
+	CookingTime int                        `json:"cookingtime"`
+}
+
+type resultJSON struct {
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+}
+
+type ingredientJSON struct {
+	Item string `json:"item"`
+	Tag  string `json:"tag"`
+}
+
+// Load reads data/<namespace>/recipe/*.json from the built in recipes and
+// then from every data pack in packs, recipes with the same id replace
+// earlier ones. Recipe types other than shaped, shapeless and smelting are
+// skipped, as are recipes using items or tags the server doesn't know.
+func Load(packs ...fs.FS) (*Book, error) {
+	recipes := map[string]recipeJSON{}
+	for _, pack := range append([]fs.FS{data}, packs...) {
+		files, err := fs.Glob(pack, "data/*/recipe/*.json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipes: %w", err)
+		}
+		for _, file := range files {
+			raw, err := fs.ReadFile(pack, file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read recipe: %w", err)
+			}
+			var r recipeJSON
+			err = json.Unmarshal(raw, &r)
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: %w", ErrInvalidRecipe, file, err)
+			}
+			namespace := strings.Split(file, "/")[1]
+			id := namespace + ":" + strings.TrimSuffix(path.Base(file), ".json")
+			recipes[id] = r
+		}
+	}
+
+	b := &Book{}
+	for _, id := range slices.Sorted(maps.Keys(recipes)) {
+		err := b.add(id, recipes[id])
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrInvalidRecipe, id, err)
+		}
+	}
+	return b, nil
+}
+
+var errUnknownItem = errors.New("unknown item")
+
+func (b *Book) add(id string, r recipeJSON) error {
+	if r.Type != TypeShaped && r.Type != TypeShapeless && r.Type != TypeSmelting {
+		return nil
+	}
+	result, err := parseResult(r.Result)
+	if errors.Is(err, errUnknownItem) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch r.Type {
+	case TypeShaped:
+		s, err := parseShaped(r)
+		if errors.Is(err, errUnknownItem) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.ID, s.Result = id, result
+		b.shaped = append(b.shaped, s)
+	case TypeShapeless:
+		s := Shapeless{ID: id, Result: result}
+		if len(r.Ingredients) == 0 || len(r.Ingredients) > 9 {
+			return fmt.Errorf("%d ingredients", len(r.Ingredients))
+		}
+		for _, raw := range r.Ingredients {
+			ing, err := parseIngredient(raw)
+			if errors.Is(err, errUnknownItem) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			s.Ingredients = append(s.Ingredients, ing)
+		}
+		b.shapeless = append(b.shapeless, s)
+	case TypeSmelting:
+		ing, err := parseIngredient(r.Ingredient)
+		if errors.Is(err, errUnknownItem) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cookingTime := r.CookingTime
+		if cookingTime <= 0 {
+			cookingTime = DefaultCookingTime
+		}
+		b.smelting = append(b.smelting, Smelting{
+			ID:          id,
+			Ingredient:  ing,
+			Result:      result,
+			Experience:  r.Experience,
+			CookingTime: cookingTime,
+		})
+	}
+	return nil
+}
+
+func parseResult(r resultJSON) (Result, error) {
+	if r.ID == "" {
+		return Result{}, errors.New("missing result")
+	}
+	if _, ok := registry.ItemID(r.ID); !ok {
+		return Result{}, errUnknownItem
+	}
+	count := r.Count
+	if count <= 0 {
+		count = 1
+	}
+	return Result{Item: r.ID, Count: count}, nil
+}
+
+func parseShaped(r recipeJSON) (Shaped, error) {
+	height := len(r.Pattern)
+	if height == 0 || height > 3 {
+		return Shaped{}, fmt.Errorf("%d pattern rows", height)
+	}
+	width := len(r.Pattern[0])
+	if width == 0 || width > 3 {
+		return Shaped{}, fmt.Errorf("%d pattern columns", width)
+	}
+	keys := map[rune]Ingredient{}
+	for k, raw := range r.Key {
+		if len([]rune(k)) != 1 || k == " " {
+			return Shaped{}, fmt.Errorf("invalid key %q", k)
+		}
+		ing, err := parseIngredient(raw)
+		if err != nil {
+			return Shaped{}, err
+		}
+		keys[[]rune(k)[0]] = ing
+	}
+	s := Shaped{Width: width, Height: height}
+	for _, row := range r.Pattern {
+		if len(row) != width {
+			return Shaped{}, errors.New("pattern rows differ in width")
+		}
+		for _, c := range row {
+			if c == ' ' {
+				s.Pattern = append(s.Pattern, nil)
+				continue
+			}
+			ing, ok := keys[c]
+			if !ok {
+				return Shaped{}, fmt.Errorf("undefined key %q", c)
+			}
+			s.Pattern = append(s.Pattern, ing)
+		}
+	}
+	return s, nil
+}
+
+// parseIngredient reads an item or tag object, a list of them or, as data
+// packs since 1.21.2 write it, an item id or #tag string
+func parseIngredient(raw json.RawMessage) (Ingredient, error) {
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		var res Ingredient
+		for _, elem := range list {
+			ing, err := parseIngredient(elem)
+			if err != nil && !errors.Is(err, errUnknownItem) {
+				return nil, err
+			}
+			res = append(res, ing...)
+		}
+		if len(res) == 0 {
+			return nil, errUnknownItem
+		}
+		return res, nil
+	}
+
+	var obj ingredientJSON
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if tag, ok := strings.CutPrefix(s, "#"); ok {
+			obj.Tag = tag
+		} else {
+			obj.Item = s
+		}
+	} else if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case obj.Item != "":
+		if _, ok := registry.ItemID(obj.Item); !ok {
+			return nil, errUnknownItem
+		}
+		return Ingredient{obj.Item}, nil
+	case obj.Tag != "":
+		items := registry.ItemTag(obj.Tag)
+		if len(items) == 0 {
+			return nil, errUnknownItem
+		}
+		return Ingredient(slices.Clone(items)), nil
+	}
+	return nil, errors.New("empty ingredient")
+}
+
+// Craft returns the result of a crafting grid width slots wide, empty slots
+// are empty strings
+func (b *Book) Craft(grid []string, width int) (Result, bool) {
+	minX, minY, maxX, maxY := width, len(grid), -1, -1
+	var items []string
+	for i, item := range grid {
+		if item == "" {
+			continue
+		}
+		x, y := i%width, i/width
+		minX, maxX = min(minX, x), max(maxX, x)
+		minY, maxY = min(minY, y), max(maxY, y)
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return Result{}, false
+	}
+
+	w, h := maxX-minX+1, maxY-minY+1
+	at := func(x, y int) string {
+		return grid[(minY+y)*width+minX+x]
+	}
+	for _, r := range b.shaped {
+		if r.Width == w && r.Height == h && (r.matches(at, false) || r.matches(at, true)) {
+			return r.Result, true
+		}
+	}
+	for _, r := range b.shapeless {
+		if len(r.Ingredients) == len(items) && matchShapeless(r.Ingredients, items) {
+			return r.Result, true
+		}
+	}
+	return Result{}, false
+}
+
+func (r Shaped) matches(at func(x, y int) string, mirrored bool) bool {
+	for y := range r.Height {
+		for x := range r.Width {
+			px := x
+			if mirrored {
+				px = r.Width - 1 - x
+			}
+			ing := r.Pattern[y*r.Width+px]
+			item := at(x, y)
+			if ing == nil && item != "" || ing != nil && !ing.Matches(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchShapeless pairs every item with a different ingredient
+func matchShapeless(ingredients []Ingredient, items []string) bool {
+	if len(items) == 0 {
+		return true
+	}
+	for i, ing := range ingredients {
+		if !ing.Matches(items[0]) {
+			continue
+		}
+		rest := slices.Delete(slices.Clone(ingredients), i, i+1)
+		if matchShapeless(rest, items[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Smelt returns the smelting recipe of item
+func (b *Book) Smelt(item string) (Smelting, bool) {
+	for _, r := range b.smelting {
+		if r.Ingredient.Matches(item) {
+			return r, true
+		}
+	}
+	return Smelting{}, false
+}
+
+// Len returns the number of loaded recipes
+func (b *Book) Len() int {
+	return len(b.shaped) + len(b.shapeless) + len(b.smelting)
+}
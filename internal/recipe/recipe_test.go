@@ -0,0 +1,104 @@
+package recipe
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVanilla_Craft(t *testing.T) {
+	b := Vanilla()
+	testCases := []struct {
+		name  string
+		grid  []string
+		width int
+		exp   Result
+	}{
+		{
+			name:  "planks from any log in the 2x2 grid",
+			grid:  []string{"", "", "", "minecraft:stripped_birch_log"},
+			width: 2,
+			exp:   Result{Item: "minecraft:birch_planks", Count: 4},
+		},
+		{
+			name:  "sticks anywhere in the grid",
+			grid:  []string{"", "", "", "", "minecraft:oak_planks", "", "", "minecraft:spruce_planks", ""},
+			width: 3,
+			exp:   Result{Item: "minecraft:stick", Count: 4},
+		},
+		{
+			name: "mirrored axe",
+			grid: []string{
+				"minecraft:cobblestone", "minecraft:cobblestone", "",
+				"minecraft:stick", "minecraft:cobblestone", "",
+				"minecraft:stick", "", "",
+			},
+			width: 3,
+			exp:   Result{Item: "minecraft:stone_axe", Count: 1},
+		},
+		{
+			name:  "shapeless",
+			grid:  []string{"minecraft:leather", "minecraft:paper", "minecraft:paper", "minecraft:paper"},
+			width: 2,
+			exp:   Result{Item: "minecraft:book", Count: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, ok := b.Craft(tc.grid, tc.width)
+			require.True(t, ok)
+			require.Equal(t, tc.exp, res)
+		})
+	}
+
+	_, ok := b.Craft([]string{"minecraft:stick", "", "", "minecraft:stick"}, 2)
+	require.False(t, ok)
+	_, ok = b.Craft(make([]string, 9), 3)
+	require.False(t, ok)
+}
+
+func TestVanilla_Smelt(t *testing.T) {
+	r, ok := Vanilla().Smelt("minecraft:raw_iron")
+	require.True(t, ok)
+	require.Equal(t, Result{Item: "minecraft:iron_ingot", Count: 1}, r.Result)
+	require.Equal(t, 200, r.CookingTime)
+	_, ok = Vanilla().Smelt("minecraft:dirt")
+	require.False(t, ok)
+
+	require.Equal(t, 1600, FuelTime("minecraft:coal"))
+	require.Equal(t, 300, FuelTime("minecraft:oak_planks"))
+	require.Equal(t, 150, FuelTime("minecraft:oak_slab"))
+	require.Zero(t, FuelTime("minecraft:stone"))
+}
+
+func TestLoad_DataPack(t *testing.T) {
+	pack := fstest.MapFS{
+		// replaces the built in torch recipe
+		"data/minecraft/recipe/torch.json": {Data: []byte(`{
+			"type": "minecraft:crafting_shapeless",
+			"ingredients": ["minecraft:stick", "#minecraft:coals"],
+			"result": {"id": "minecraft:torch", "count": 8}
+		}`)},
+		"data/custom/recipe/future.json": {Data: []byte(`{
+			"type": "minecraft:crafting_shapeless",
+			"ingredients": [{"item": "minecraft:not_an_item"}],
+			"result": {"id": "minecraft:stone"}
+		}`)},
+		"data/custom/recipe/special.json": {Data: []byte(`{"type": "minecraft:crafting_special_mapcloning"}`)},
+	}
+	b, err := Load(pack)
+	require.NoError(t, err)
+	require.Equal(t, Vanilla().Len(), b.Len())
+	res, ok := b.Craft([]string{"minecraft:charcoal", "minecraft:stick"}, 2)
+	require.True(t, ok)
+	require.Equal(t, Result{Item: "minecraft:torch", Count: 8}, res)
+
+	pack["data/custom/recipe/broken.json"] = &fstest.MapFile{Data: []byte(`{
+		"type": "minecraft:crafting_shaped",
+		"pattern": ["#"],
+		"result": {"id": "minecraft:stone"}
+	}`)}
+	_, err = Load(pack)
+	require.ErrorIs(t, err, ErrInvalidRecipe)
+}
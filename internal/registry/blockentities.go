@@ -0,0 +1,26 @@
+package registry
+
+// blockEntityTypes are the block entity types of Minecraft 1.21 in registry order
+var blockEntityTypes = []string{
+	"furnace", "chest", "trapped_chest", "ender_chest", "jukebox", "dispenser", "dropper", "sign",
+	"hanging_sign", "mob_spawner", "piston", "brewing_stand", "enchanting_table", "end_portal",
+	"beacon", "skull", "daylight_detector", "hopper", "comparator", "banner", "structure_block",
+	"end_gateway", "command_block", "shulker_box", "bed", "conduit", "barrel", "smoker",
+	"blast_furnace", "lectern", "bell", "jigsaw", "campfire", "beehive", "sculk_sensor",
+	"calibrated_sculk_sensor", "sculk_catalyst", "sculk_shrieker", "chiseled_bookshelf",
+	"brushable_block", "decorated_pot", "crafter", "trial_spawner", "vault",
+}
+
+var blockEntityTypeIDs = func() map[string]int32 {
+	res := make(map[string]int32, len(blockEntityTypes))
+	for i, name := range blockEntityTypes {
+		res["minecraft:"+name] = int32(i)
+	}
+	return res
+}()
+
+// BlockEntityTypeID returns the registry id of a block entity id such as minecraft:chest
+func BlockEntityTypeID(name string) (int32, bool) {
+	id, ok := blockEntityTypeIDs[name]
+	return id, ok
+}
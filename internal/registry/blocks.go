@@ -0,0 +1,203 @@
+package registry
+
+import (
+	_ "embed"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+//go:embed blocks.txt
+var blocksTable string
+
+//go:embed solid.txt
+var solidTable string
+
+//go:embed hardness.txt
+var hardnessTable string
+
+type blockProperty struct {
+	name   string
+	values []string
+}
+
+type blockInfo struct {
+	name  string
+	id    int32
+	first int32
+	// defaults holds value indexes of the default state per property
+	defaults   []int
+	properties []blockProperty
+	// solid blocks are full cubes in every state
+	solid  bool
+	mining Mining
+}
+
+func (b *blockInfo) count() int32 {
+	n := int32(1)
+	for _, p := range b.properties {
+		n *= int32(len(p.values))
+	}
+	return n
+}
+
+var (
+	blocksByName map[string]*blockInfo
+	// blocksByID is ordered by the first state id
+	blocksByID []*blockInfo
+	stateCount int32
+)
+
+func init() {
+	blocksByName = map[string]*blockInfo{}
+	for _, line := range strings.Split(blocksTable, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			panic("registry: invalid block line " + line)
+		}
+		first, err1 := strconv.Atoi(fields[1])
+		def, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			panic("registry: invalid block line " + line)
+		}
+		b := &blockInfo{name: "minecraft:" + fields[0], id: int32(len(blocksByID)), first: int32(first)}
+		for _, f := range fields[3:] {
+			name, values, _ := strings.Cut(f, "=")
+			b.properties = append(b.properties, blockProperty{name: name, values: strings.Split(values, ",")})
+		}
+		b.defaults = make([]int, len(b.properties))
+		rest := def - first
+		for i := len(b.properties) - 1; i >= 0; i-- {
+			n := len(b.properties[i].values)
+			b.defaults[i] = rest % n
+			rest /= n
+		}
+		blocksByName[b.name] = b
+		blocksByID = append(blocksByID, b)
+		stateCount = b.first + b.count()
+	}
+	for _, line := range strings.Split(solidTable, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		b, ok := blocksByName["minecraft:"+line]
+		if !ok {
+			panic("registry: unknown solid block " + line)
+		}
+		b.solid = true
+	}
+	for _, line := range strings.Split(hardnessTable, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		b, ok := blocksByName["minecraft:"+fields[0]]
+		if !ok {
+			panic("registry: unknown block " + line)
+		}
+		hardness, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			panic("registry: invalid hardness line " + line)
+		}
+		b.mining.Hardness = hardness
+		if len(fields) > 2 {
+			b.mining.Tool = fields[2]
+		}
+		if len(fields) > 3 {
+			b.mining.Tier, ok = tiers[fields[3]]
+			if !ok {
+				panic("registry: invalid hardness line " + line)
+			}
+		}
+	}
+}
+
+// StateCount is the number of block states, it defines bits of the direct palette
+func StateCount() int32 {
+	return stateCount
+}
+
+// BlockStateID returns the network id of block state b. Properties missing
+// from b take their default values, unknown blocks are reported with false
+// and map to air.
+func BlockStateID(b world.BlockState) (int32, bool) {
+	info, ok := blocksByName[b.Name]
+	if !ok {
+		return 0, false
+	}
+	if len(info.properties) == 0 {
+		return info.first, true
+	}
+	props := b.PropertyMap()
+	var idx int32
+	for i, p := range info.properties {
+		v := info.defaults[i]
+		if s, ok := props[p.name]; ok {
+			for j, value := range p.values {
+				if value == s {
+					v = j
+					break
+				}
+			}
+		}
+		idx = idx*int32(len(p.values)) + int32(v)
+	}
+	return info.first + idx, true
+}
+
+// BlockID returns the registry id of block name, used by packets naming a
+// block rather than a state
+func BlockID(name string) (int32, bool) {
+	info, ok := blocksByName[name]
+	if !ok {
+		return 0, false
+	}
+	return info.id, true
+}
+
+// BlockState is the inverse of BlockStateID
+func BlockState(id int32) (world.BlockState, bool) {
+	if id < 0 || id >= stateCount {
+		return world.Air, false
+	}
+	i := sort.Search(len(blocksByID), func(i int) bool {
+		return blocksByID[i].first > id
+	}) - 1
+	info := blocksByID[i]
+	if len(info.properties) == 0 {
+		return world.BlockState{Name: info.name}, true
+	}
+	props := make(map[string]string, len(info.properties))
+	rest := int(id - info.first)
+	for j := len(info.properties) - 1; j >= 0; j-- {
+		p := info.properties[j]
+		props[p.name] = p.values[rest%len(p.values)]
+		rest /= len(p.values)
+	}
+	return world.NewBlockState(info.name, props), true
+}
+
+// DefaultBlockState returns the default state of block name
+func DefaultBlockState(name string) (world.BlockState, bool) {
+	info, ok := blocksByName[name]
+	if !ok {
+		return world.Air, false
+	}
+	var idx int32
+	for i, p := range info.properties {
+		idx = idx*int32(len(p.values)) + int32(info.defaults[i])
+	}
+	return BlockState(info.first + idx)
+}
+
+// Solid reports whether block name is a full cube in every state, unknown
+// blocks are not solid
+func Solid(name string) bool {
+	info, ok := blocksByName[name]
+	return ok && info.solid
+}
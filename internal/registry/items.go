@@ -0,0 +1,69 @@
+package registry
+
+import (
+	_ "embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed items.txt
+var itemsTable string
+
+type itemInfo struct {
+	name     string
+	maxStack int
+}
+
+var (
+	itemsByName map[string]int32
+	// itemsByID is indexed by the network id
+	itemsByID []itemInfo
+)
+
+func init() {
+	itemsByName = map[string]int32{}
+	for _, line := range strings.Split(itemsTable, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, stack, ok := strings.Cut(line, " ")
+		maxStack, err := strconv.Atoi(stack)
+		if !ok || err != nil {
+			panic("registry: invalid item line " + line)
+		}
+		itemsByName["minecraft:"+name] = int32(len(itemsByID))
+		itemsByID = append(itemsByID, itemInfo{name: "minecraft:" + name, maxStack: maxStack})
+	}
+}
+
+// ItemID returns the network id of item name
+func ItemID(name string) (int32, bool) {
+	id, ok := itemsByName[name]
+	return id, ok
+}
+
+// ItemName is the inverse of ItemID
+func ItemName(id int32) (string, bool) {
+	if id < 0 || int(id) >= len(itemsByID) {
+		return "", false
+	}
+	return itemsByID[id].name, true
+}
+
+// MaxStackSize returns how many of item name fit in a slot, unknown items give zero
+func MaxStackSize(name string) int {
+	id, ok := itemsByName[name]
+	if !ok {
+		return 0
+	}
+	return itemsByID[id].maxStack
+}
+
+// Items returns names of all items in network id order
+func Items() []string {
+	res := make([]string, len(itemsByID))
+	for i, item := range itemsByID {
+		res[i] = item.name
+	}
+	return res
+}
@@ -0,0 +1,77 @@
+package registry
+
+import "strings"
+
+// Tool tiers, a tool harvests blocks that need its tier or a lower one
+const (
+	TierNone = iota
+	TierWooden
+	TierStone
+	TierIron
+	TierDiamond
+	TierNetherite
+)
+
+var tiers = map[string]int{
+	"wooden":    TierWooden,
+	"stone":     TierStone,
+	"iron":      TierIron,
+	"diamond":   TierDiamond,
+	"netherite": TierNetherite,
+}
+
+// Mining describes how a block is broken
+type Mining struct {
+	// Hardness is negative for blocks players can't break
+	Hardness float64
+	// Tool is the tool kind breaking the block faster: pickaxe, axe, shovel, hoe or sword
+	Tool string
+	// Tier is the lowest tool tier the block drops for, TierNone if it drops without a tool
+	Tier int
+}
+
+// BlockMining returns how block name is broken, unknown blocks are unbreakable
+func BlockMining(name string) Mining {
+	info, ok := blocksByName[name]
+	if !ok {
+		return Mining{Hardness: -1}
+	}
+	return info.mining
+}
+
+// Tool is a mining tool item
+type Tool struct {
+	Kind  string
+	Tier  int
+	Speed float64
+}
+
+var toolMaterials = map[string]Tool{
+	"wooden":    {Tier: TierWooden, Speed: 2},
+	"stone":     {Tier: TierStone, Speed: 4},
+	"iron":      {Tier: TierIron, Speed: 6},
+	"diamond":   {Tier: TierDiamond, Speed: 8},
+	"netherite": {Tier: TierNetherite, Speed: 9},
+	"golden":    {Tier: TierWooden, Speed: 12},
+}
+
+// ItemTool returns the tool item name is, swords only cut cobwebs faster
+func ItemTool(name string) (Tool, bool) {
+	material, kind, ok := strings.Cut(strings.TrimPrefix(name, "minecraft:"), "_")
+	if !ok {
+		return Tool{}, false
+	}
+	tool, ok := toolMaterials[material]
+	if !ok {
+		return Tool{}, false
+	}
+	switch kind {
+	case "pickaxe", "axe", "shovel", "hoe":
+	case "sword":
+		tool.Speed = 15
+	default:
+		return Tool{}, false
+	}
+	tool.Kind = kind
+	return tool, true
+}
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockStateID(t *testing.T) {
+	testCases := []struct {
+		block world.BlockState
+		exp   int32
+	}{
+		{block: world.Air, exp: 0},
+		{block: world.Stone, exp: 1},
+		{block: world.Grass, exp: 9},
+		{block: world.BlockState{Name: "minecraft:grass_block"}, exp: 9},
+		{block: world.Dirt, exp: 10},
+		{block: world.Bedrock, exp: 79},
+		{block: world.Water, exp: 80},
+		{block: world.OakLog, exp: 131},
+		{block: world.OakLeaves, exp: 264},
+		{block: world.ParseBlockState("minecraft:chest[facing=north,type=single,waterlogged=true]"), exp: 2954},
+		{block: world.BlockState{Name: "minecraft:chest"}, exp: 2955},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.block.String(), func(t *testing.T) {
+			id, ok := BlockStateID(tc.block)
+			require.True(t, ok)
+			require.Equal(t, tc.exp, id)
+		})
+	}
+
+	id, ok := BlockStateID(world.BlockState{Name: "minecraft:unknown"})
+	require.False(t, ok)
+	require.Zero(t, id)
+	require.Equal(t, int32(26684), StateCount())
+}
+
+func TestBlockState_Roundtrip(t *testing.T) {
+	for id := int32(0); id < StateCount(); id++ {
+		b, ok := BlockState(id)
+		require.True(t, ok)
+		res, ok := BlockStateID(b)
+		require.True(t, ok)
+		require.Equal(t, id, res, b.String())
+	}
+	_, ok := BlockState(StateCount())
+	require.False(t, ok)
+
+	b, ok := DefaultBlockState("minecraft:oak_log")
+	require.True(t, ok)
+	require.Equal(t, world.OakLog, b)
+}
+
+func TestSolid(t *testing.T) {
+	for _, name := range []string{"minecraft:stone", "minecraft:dirt", "minecraft:grass_block", "minecraft:bedrock", "minecraft:oak_log"} {
+		require.True(t, Solid(name), name)
+	}
+	for _, name := range []string{"minecraft:air", "minecraft:water", "minecraft:short_grass", "minecraft:oak_slab", "minecraft:torch", "minecraft:unknown"} {
+		require.False(t, Solid(name), name)
+	}
+}
+
+func TestBlockMining(t *testing.T) {
+	require.Equal(t, Mining{Hardness: 1.5, Tool: "pickaxe", Tier: TierWooden}, BlockMining("minecraft:stone"))
+	require.Equal(t, Mining{Hardness: 4.5, Tool: "pickaxe", Tier: TierIron}, BlockMining("minecraft:deepslate_diamond_ore"))
+	require.Equal(t, Mining{Hardness: 2, Tool: "axe"}, BlockMining("minecraft:oak_stairs"))
+	require.Equal(t, Mining{}, BlockMining("minecraft:short_grass"))
+	require.Negative(t, BlockMining("minecraft:bedrock").Hardness)
+	require.Negative(t, BlockMining("minecraft:unknown").Hardness)
+
+	tool, ok := ItemTool("minecraft:golden_pickaxe")
+	require.True(t, ok)
+	require.Equal(t, Tool{Kind: "pickaxe", Tier: TierWooden, Speed: 12}, tool)
+	_, ok = ItemTool("minecraft:stone_bricks")
+	require.False(t, ok)
+}
+
+func TestBiomeID(t *testing.T) {
+	require.Len(t, Biomes, 64)
+	id, ok := BiomeID(world.DefaultBiome)
+	require.True(t, ok)
+	require.Equal(t, int32(39), id)
+	id, ok = BiomeID("minecraft:the_void")
+	require.True(t, ok)
+	require.Equal(t, int32(56), id)
+	id, ok = BiomeID("custom:biome")
+	require.False(t, ok)
+	require.Equal(t, int32(39), id)
+}
+
+func TestItemID(t *testing.T) {
+	id, ok := ItemID("minecraft:stone")
+	require.True(t, ok)
+	require.Equal(t, int32(1), id)
+	id, ok = ItemID("minecraft:diamond_sword")
+	require.True(t, ok)
+	name, ok := ItemName(id)
+	require.True(t, ok)
+	require.Equal(t, "minecraft:diamond_sword", name)
+	require.Equal(t, 1, MaxStackSize(name))
+	require.Equal(t, 16, MaxStackSize("minecraft:ender_pearl"))
+
+	_, ok = ItemID("minecraft:unknown")
+	require.False(t, ok)
+	require.Len(t, Items(), 1333)
+}
+
+func TestBlockID(t *testing.T) {
+	id, ok := BlockID("minecraft:air")
+	require.True(t, ok)
+	require.Zero(t, id)
+	id, ok = BlockID("minecraft:stone")
+	require.True(t, ok)
+	require.Equal(t, int32(1), id)
+	_, ok = BlockID("minecraft:unknown")
+	require.False(t, ok)
+
+	id, ok = BlockEntityTypeID("minecraft:barrel")
+	require.True(t, ok)
+	require.Equal(t, int32(26), id)
+	require.Equal(t, []string{"minecraft:oak_planks", "minecraft:spruce_planks"}, ItemTag("minecraft:planks")[:2])
+}
@@ -0,0 +1,34 @@
+package registry
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed itemtags.txt
+var itemTagsTable string
+
+var itemTags map[string][]string
+
+func init() {
+	itemTags = map[string][]string{}
+	for _, line := range strings.Split(itemTagsTable, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			panic("registry: invalid item tag line " + line)
+		}
+		items := make([]string, 0, len(fields)-1)
+		for _, item := range fields[1:] {
+			items = append(items, "minecraft:"+item)
+		}
+		itemTags["minecraft:"+fields[0]] = items
+	}
+}
+
+// ItemTag returns the items of the vanilla item tag name, nil for unknown tags
+func ItemTag(name string) []string {
+	return itemTags[name]
+}
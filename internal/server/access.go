@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/google/uuid"
+)
+
+var ErrLoginRejected = errors.New("login rejected")
+
+// accessLists are the player lists the server checks on login
+type accessLists struct {
+	ops       *access.List[access.Op]
+	bans      *access.List[access.Ban]
+	whitelist *access.List[access.WhitelistEntry]
+}
+
+func loadAccessLists(cfg config.Access) (accessLists, error) {
+	ops, err := access.Load[access.Op](cfg.OpsFile)
+	if err != nil {
+		return accessLists{}, fmt.Errorf("failed to load operators: %w", err)
+	}
+	bans, err := access.Load[access.Ban](cfg.BansFile)
+	if err != nil {
+		return accessLists{}, fmt.Errorf("failed to load bans: %w", err)
+	}
+	whitelist, err := access.Load[access.WhitelistEntry](cfg.WhitelistFile)
+	if err != nil {
+		return accessLists{}, fmt.Errorf("failed to load whitelist: %w", err)
+	}
+	return accessLists{ops: ops, bans: bans, whitelist: whitelist}, nil
+}
+
+func banMessage(ban access.Ban) string {
+	msg := "You are banned from this server."
+	if ban.Reason != "" {
+		msg += "\nReason: " + ban.Reason
+	}
+	return msg
+}
+
+// checkLogin returns why the player can't join, it is safe for concurrent use
+func (s *Server) checkLogin(p access.Profile) (text.Component, bool) {
+	if ban, ok := s.bans.Get(p.UUID); ok && !ban.Expired(time.Now()) {
+		return text.Plain(banMessage(ban)), false
+	}
+	if s.whitelistEnabled.Load() {
+		_, listed := s.whitelist.Get(p.UUID)
+		_, op := s.ops.Get(p.UUID)
+		if !listed && !op {
+			return text.Plain("You are not white-listed on this server!"), false
+		}
+	}
+	return text.Component{}, true
+}
+
+// opLevel returns the permission level of the player id
+func (s *Server) opLevel(id uuid.UUID) int {
+	op, ok := s.ops.Get(id)
+	if !ok {
+		return 0
+	}
+	return op.Level
+}
+
+func (s *Session) profile() access.Profile {
+	return access.Profile{UUID: s.player.UUID, Name: s.player.Name}
+}
+
+// SetWhitelist turns the whitelist on or off until the server restarts
+func (s *Server) SetWhitelist(enabled bool) {
+	s.whitelistEnabled.Store(enabled)
+}
+
+// sendPermissionLevel tells the client its permission level, it unlocks the
+// debug and game mode switcher keys
+func (s *Session) sendPermissionLevel() {
+	s.queue(&protocol.EntityEventPacket{
+		EntityID: s.player.EntityID,
+		Status:   byte(protocol.EntityEventOpLevel + min(max(s.player.PermissionLevel, 0), 4)),
+	})
+}
+
+// SetPermissionLevel changes the commands the player may run, it must be called from the tick goroutine
+func (s *Session) SetPermissionLevel(level int) {
+	s.player.PermissionLevel = level
+	s.sendPermissionLevel()
+	s.sendCommands()
+}
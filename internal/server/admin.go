@@ -0,0 +1,154 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/admin"
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/rs/zerolog/log"
+)
+
+// adminSource names bans issued through the admin API
+const adminSource = "Admin API"
+
+var ErrServerStopped = errors.New("server stopped")
+
+// call runs fn on the next tick and waits for it, it returns false when the
+// server stopped first and results of fn must not be read
+func (s *Server) call(fn func()) bool {
+	done := make(chan struct{})
+	s.Submit(func() {
+		fn()
+		close(done)
+	})
+	select {
+	case <-done:
+		return true
+	case <-s.Done():
+		return false
+	}
+}
+
+// adminBackend carries out admin API calls on the tick goroutine
+type adminBackend struct {
+	server *Server
+}
+
+// Admin returns the backend of the admin API
+func (s *Server) Admin() admin.Backend {
+	return adminBackend{server: s}
+}
+
+func (s *Session) remoteAddr() string {
+	if c, ok := s.UserConn.(net.Conn); ok {
+		return c.RemoteAddr().String()
+	}
+	return ""
+}
+
+func (b adminBackend) Players() []admin.Player {
+	var res []admin.Player
+	ok := b.server.call(func() {
+		for _, p := range b.server.players() {
+			res = append(res, admin.Player{
+				Name:     p.player.Name,
+				UUID:     p.player.UUID.String(),
+				Address:  p.remoteAddr(),
+				PingMS:   p.latency.Milliseconds(),
+				GameMode: p.player.GameMode.String(),
+				Position: admin.Position{X: p.player.X, Y: p.player.Y, Z: p.player.Z},
+			})
+		}
+	})
+	if !ok {
+		return nil
+	}
+	return res
+}
+
+func (b adminBackend) Kick(name, reason string) error {
+	if reason == "" {
+		reason = defaultKickReason
+	}
+	var err error
+	ok := b.server.call(func() {
+		var players []*Session
+		players, err = b.server.selectPlayers(b.server.Console(), command.Selector{Name: name})
+		if err != nil {
+			err = fmt.Errorf("%w: %s", admin.ErrPlayerNotFound, name)
+			return
+		}
+		for _, p := range players {
+			p.Kick(reason)
+			log.Info().Str("player", p.player.Name).Str("reason", reason).Msg("kicked by admin api")
+		}
+	})
+	if !ok {
+		return ErrServerStopped
+	}
+	return err
+}
+
+func (b adminBackend) Ban(name, reason string) error {
+	if reason == "" {
+		reason = defaultBanReason
+	}
+	var err error
+	ok := b.server.call(func() {
+		var profiles []access.Profile
+		profiles, err = b.server.selectProfiles(b.server.Console(), command.Selector{Name: name})
+		if err != nil {
+			err = fmt.Errorf("%w: %s", admin.ErrPlayerNotFound, name)
+			return
+		}
+		for _, p := range profiles {
+			ban := access.NewBan(p, adminSource, reason)
+			var added bool
+			added, err = b.server.bans.Add(ban)
+			if err != nil {
+				return
+			}
+			if !added {
+				err = fmt.Errorf("%w: %s", admin.ErrAlreadyBanned, p.Name)
+				return
+			}
+			log.Info().Str("player", p.Name).Str("reason", reason).Msg("banned by admin api")
+			if session := b.server.playerByUUID(p.UUID); session != nil {
+				session.Kick(banMessage(ban))
+			}
+		}
+	})
+	if !ok {
+		return ErrServerStopped
+	}
+	return err
+}
+
+func (b adminBackend) Broadcast(message string) {
+	b.server.Submit(func() {
+		b.server.BroadcastMessage(text.Plain("[Server] " + message))
+	})
+}
+
+func (b adminBackend) RunCommand(input string) string {
+	return b.server.RemoteCommand(input)
+}
+
+func (b adminBackend) Status() admin.Status {
+	st := b.server.StatusInfo()
+	tps, mspt := b.server.TickStats()
+	return admin.Status{
+		Version:       protocol.VersionName,
+		Protocol:      protocol.ProtocolVersion,
+		MOTD:          st.MOTD.String(),
+		OnlinePlayers: len(st.Players),
+		MaxPlayers:    st.MaxPlayers,
+		TPS:           tps,
+		MSPT:          mspt,
+	}
+}
@@ -0,0 +1,482 @@
+package server
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	playerEyeHeight = 1.62
+	playerHeight    = 1.8
+	// survivalReach and creativeReach are the vanilla block interaction ranges,
+	// the server allows one more block as vanilla does
+	survivalReach = 4.5
+	creativeReach = 5
+	// digTolerance is the share of the dig time a client must wait before
+	// finishing, it absorbs latency the same as vanilla
+	digTolerance = 0.7
+	// noDestroyStage removes block cracks
+	noDestroyStage = 0xFF
+)
+
+// replaceableBlocks are replaced by placed blocks instead of being built against
+var replaceableBlocks = map[string]bool{
+	"minecraft:air":            true,
+	"minecraft:cave_air":       true,
+	"minecraft:void_air":       true,
+	"minecraft:water":          true,
+	"minecraft:lava":           true,
+	"minecraft:bubble_column":  true,
+	"minecraft:short_grass":    true,
+	"minecraft:fern":           true,
+	"minecraft:dead_bush":      true,
+	"minecraft:seagrass":       true,
+	"minecraft:vine":           true,
+	"minecraft:glow_lichen":    true,
+	"minecraft:fire":           true,
+	"minecraft:soul_fire":      true,
+	"minecraft:crimson_roots":  true,
+	"minecraft:warped_roots":   true,
+	"minecraft:nether_sprouts": true,
+	"minecraft:structure_void": true,
+	"minecraft:light":          true,
+}
+
+func replaceable(b world.BlockState) bool {
+	if b.Name == "minecraft:snow" {
+		return b.Property("layers") == "1"
+	}
+	return replaceableBlocks[b.Name]
+}
+
+// hasCollision reports whether entities can't stand inside b, shapes other
+// than full cubes are guessed from block names
+func hasCollision(b world.BlockState) bool {
+	if isSolid(b) {
+		return true
+	}
+	for _, suffix := range [...]string{"_slab", "_stairs", "_wall", "_fence", "_fence_gate"} {
+		if strings.HasSuffix(b.Name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// digState is the block a survival player is breaking
+type digState struct {
+	active  bool
+	pos     world.BlockPos
+	started int64
+	stage   byte
+}
+
+func toBlockPos(p datatypes.Position) world.BlockPos {
+	return world.BlockPos{X: p.X, Y: p.Y, Z: p.Z}
+}
+
+func toPosition(p world.BlockPos) datatypes.Position {
+	return datatypes.Position{X: p.X, Y: p.Y, Z: p.Z}
+}
+
+// faceOffset returns the block next to pos on face
+func faceOffset(pos world.BlockPos, face int32) world.BlockPos {
+	switch face {
+	case protocol.FaceBottom:
+		pos.Y--
+	case protocol.FaceTop:
+		pos.Y++
+	case protocol.FaceNorth:
+		pos.Z--
+	case protocol.FaceSouth:
+		pos.Z++
+	case protocol.FaceWest:
+		pos.X--
+	case protocol.FaceEast:
+		pos.X++
+	}
+	return pos
+}
+
+// digProgress returns the share of a block broken per tick, one or more
+// breaks it instantly. It follows vanilla without enchantments and effects.
+func digProgress(m registry.Mining, held ItemStack, onGround bool) float64 {
+	if m.Hardness < 0 {
+		return 0
+	}
+	if m.Hardness == 0 {
+		return 1
+	}
+	tool, isTool := registry.ItemTool(held.Item)
+	correct := isTool && m.Tool != "" && tool.Kind == m.Tool
+	speed := 1.0
+	if correct {
+		speed = tool.Speed
+	}
+	if !onGround {
+		speed /= 5
+	}
+	if m.Tier == registry.TierNone || correct && tool.Tier >= m.Tier {
+		return speed / m.Hardness / 30
+	}
+	return speed / m.Hardness / 100
+}
+
+// canReach reports whether pos is within interaction range of the player eyes
+func (s *Session) canReach(pos world.BlockPos) bool {
+	p := s.player
+	reach := survivalReach + 1.0
+	if p.GameMode == Creative {
+		reach = creativeReach + 1.0
+	}
+	dist := func(v float64, block int) float64 {
+		return v - min(max(v, float64(block)), float64(block+1))
+	}
+	dx, dy, dz := dist(p.X, pos.X), dist(p.Y+playerEyeHeight, pos.Y), dist(p.Z, pos.Z)
+	return dx*dx+dy*dy+dz*dz <= reach*reach
+}
+
+// canBuild reports whether the game mode lets the player change blocks
+func (s *Session) canBuild() bool {
+	return s.player.GameMode == Survival || s.player.GameMode == Creative
+}
+
+// sendBlock tells the client the actual block at pos, undoing its prediction
+func (s *Session) sendBlock(pos world.BlockPos) {
+	b, ok := s.server.world.LoadedBlock(pos.X, pos.Y, pos.Z)
+	if !ok {
+		return
+	}
+	s.queue(&protocol.BlockUpdatePacket{Location: toPosition(pos), Block: b})
+}
+
+// seesBlock reports whether the client was sent the chunk of pos
+func (s *Session) seesBlock(pos world.BlockPos) bool {
+	return s.chunks.Has(world.ChunkPosOf(pos.X, pos.Z))
+}
+
+// broadcastBlock sends a block change to every player having its chunk
+func (s *Server) broadcastBlock(pos world.BlockPos, b world.BlockState) {
+	for session := range s.sessions {
+		if session.seesBlock(pos) {
+			session.queue(&protocol.BlockUpdatePacket{Location: toPosition(pos), Block: b})
+		}
+	}
+}
+
+// broadcastDestroyStage shows cracks of a block s is breaking to other players
+func (s *Session) broadcastDestroyStage(pos world.BlockPos, stage byte) {
+	for session := range s.server.sessions {
+		if session != s && session.seesBlock(pos) {
+			session.queue(&protocol.BlockDestroyStagePacket{EntityID: s.player.EntityID, Location: toPosition(pos), Stage: stage})
+		}
+	}
+}
+
+// SetBlock changes a block and sends it to players, it must be called from the tick goroutine
+func (s *Server) SetBlock(pos world.BlockPos, b world.BlockState) error {
+	prev, err := s.world.SetBlock(pos.X, pos.Y, pos.Z, b)
+	if err != nil {
+		return err
+	}
+	s.blockChanged(pos, prev, b)
+	s.broadcastBlock(pos, b)
+	return nil
+}
+
+// handlePlayerAction runs on the tick goroutine, only digging is handled
+func (s *Session) handlePlayerAction(action protocol.PlayerActionPacket) {
+	switch action.Status {
+	case protocol.ActionStartDigging, protocol.ActionCancelDigging, protocol.ActionFinishDigging:
+		s.handleDigging(action)
+		s.queue(&protocol.AcknowledgeBlockChangePacket{Sequence: action.Sequence})
+	default:
+		log.Trace().Int32("status", action.Status).Str("player", s.player.Name).Msg("unhandled player action")
+	}
+}
+
+func (s *Session) handleDigging(action protocol.PlayerActionPacket) {
+	pos := toBlockPos(action.Location)
+	if action.Status == protocol.ActionCancelDigging {
+		s.stopDigging()
+		return
+	}
+	block, loaded := s.server.world.LoadedBlock(pos.X, pos.Y, pos.Z)
+	if !loaded || !s.canBuild() || !s.canReach(pos) || block.IsAir() {
+		s.stopDigging()
+		s.sendBlock(pos)
+		return
+	}
+
+	if s.player.GameMode == Creative {
+		if tool, ok := registry.ItemTool(s.player.MainHand().Item); ok && tool.Kind == "sword" {
+			// swords can't break blocks in creative
+			s.sendBlock(pos)
+			return
+		}
+		s.breakBlock(pos, block)
+		return
+	}
+
+	progress := digProgress(registry.BlockMining(block.Name), *s.player.MainHand(), s.player.OnGround)
+	switch action.Status {
+	case protocol.ActionStartDigging:
+		s.stopDigging()
+		if progress >= 1 {
+			s.breakBlock(pos, block)
+			return
+		}
+		if progress <= 0 {
+			s.sendBlock(pos)
+			return
+		}
+		s.dig = digState{active: true, pos: pos, started: s.server.CurrentTick(), stage: noDestroyStage}
+
+	case protocol.ActionFinishDigging:
+		dig := s.dig
+		s.stopDigging()
+		ticks := s.server.CurrentTick() - dig.started + 1
+		if !dig.active || dig.pos != pos || float64(ticks)*progress < digTolerance {
+			log.Warn().Str("player", s.player.Name).Int("x", pos.X).Int("y", pos.Y).Int("z", pos.Z).
+				Msg("rejected block break")
+			s.sendBlock(pos)
+			return
+		}
+		s.breakBlock(pos, block)
+	}
+}
+
+// stopDigging forgets the block being broken and removes its cracks
+func (s *Session) stopDigging() {
+	if s.dig.active && s.dig.stage != noDestroyStage {
+		s.broadcastDestroyStage(s.dig.pos, noDestroyStage)
+	}
+	s.dig = digState{}
+}
+
+// tickDigging shows other players how far the block being broken is
+func (s *Session) tickDigging(tick int64) {
+	if !s.dig.active {
+		return
+	}
+	block := s.blockAt(s.dig.pos.X, s.dig.pos.Y, s.dig.pos.Z)
+	progress := digProgress(registry.BlockMining(block.Name), *s.player.MainHand(), s.player.OnGround)
+	stage := byte(min(float64(tick-s.dig.started)*progress*10, 9))
+	if stage != s.dig.stage {
+		s.dig.stage = stage
+		s.broadcastDestroyStage(s.dig.pos, stage)
+	}
+}
+
+// breakBlock removes block at pos after plugins agreed
+func (s *Session) breakBlock(pos world.BlockPos, block world.BlockState) {
+	e := &BlockBreakEvent{Player: s, Pos: pos, Block: block}
+	event.Fire(&s.server.events, e)
+	if e.Cancelled {
+		s.sendBlock(pos)
+		return
+	}
+	err := s.server.SetBlock(pos, world.Air)
+	if err != nil {
+		log.Err(err).Str("player", s.player.Name).Msg("failed to break block")
+		s.sendBlock(pos)
+		return
+	}
+	id, _ := registry.BlockStateID(block)
+	for session := range s.server.sessions {
+		if session != s && session.seesBlock(pos) {
+			session.queue(&protocol.WorldEventPacket{Event: protocol.WorldEventBlockBreak, Location: toPosition(pos), Data: id})
+		}
+	}
+}
+
+// horizontalFacing is the direction the player looks at on the horizontal plane
+func horizontalFacing(yaw float32) string {
+	switch int(math.Floor(float64(yaw)/90+0.5)) & 3 {
+	case 0:
+		return "south"
+	case 1:
+		return "west"
+	case 2:
+		return "north"
+	default:
+		return "east"
+	}
+}
+
+var oppositeFacing = map[string]string{
+	"north": "south", "south": "north", "east": "west", "west": "east", "up": "down", "down": "up",
+}
+
+// lookingFacing is the direction the player looks at the most
+func lookingFacing(yaw, pitch float32) string {
+	if pitch < -45 {
+		return "up"
+	}
+	if pitch > 45 {
+		return "down"
+	}
+	return horizontalFacing(yaw)
+}
+
+// placementState orients b placed against face at the cursor height by a
+// player looking at yaw and pitch
+func placementState(b world.BlockState, face int32, cursorY float32, yaw, pitch float32) world.BlockState {
+	props := b.PropertyMap()
+	if _, ok := props["axis"]; ok {
+		switch face {
+		case protocol.FaceBottom, protocol.FaceTop:
+			b = b.WithProperty("axis", "y")
+		case protocol.FaceNorth, protocol.FaceSouth:
+			b = b.WithProperty("axis", "z")
+		default:
+			b = b.WithProperty("axis", "x")
+		}
+	}
+	if _, ok := props["facing"]; ok {
+		facing := oppositeFacing[horizontalFacing(yaw)]
+		switch {
+		case strings.HasSuffix(b.Name, "_stairs") || strings.HasSuffix(b.Name, "_fence_gate"):
+			facing = horizontalFacing(yaw)
+		case slices.Contains([]string{"minecraft:piston", "minecraft:sticky_piston", "minecraft:dispenser", "minecraft:dropper"}, b.Name):
+			facing = oppositeFacing[lookingFacing(yaw, pitch)]
+		case b.Name == "minecraft:observer":
+			facing = lookingFacing(yaw, pitch)
+		}
+		b = b.WithProperty("facing", facing)
+	}
+	top := face == protocol.FaceBottom || face != protocol.FaceTop && cursorY > 0.5
+	if half := props["half"]; half == "top" || half == "bottom" {
+		b = b.WithProperty("half", map[bool]string{true: "top", false: "bottom"}[top])
+	}
+	if strings.HasSuffix(b.Name, "_slab") {
+		b = b.WithProperty("type", map[bool]string{true: "top", false: "bottom"}[top])
+	}
+	return b
+}
+
+// multiBlock reports whether b is one part of a block taking several
+// positions, such as doors and beds, these can't be placed yet
+func multiBlock(b world.BlockState) bool {
+	props := b.PropertyMap()
+	_, part := props["part"]
+	return part || props["half"] == "lower" || props["half"] == "upper"
+}
+
+// occupied reports whether a player stands in block pos
+func (s *Server) occupied(pos world.BlockPos) bool {
+	block := aabb{
+		minX: float64(pos.X), minY: float64(pos.Y), minZ: float64(pos.Z),
+		maxX: float64(pos.X + 1), maxY: float64(pos.Y + 1), maxZ: float64(pos.Z + 1),
+	}
+	for session := range s.sessions {
+		p := session.player
+		if p.GameMode == Spectator {
+			continue
+		}
+		box := playerBox(p.X, p.Y, p.Z, playerHeight)
+		if box.minX < block.maxX && box.maxX > block.minX &&
+			box.minY < block.maxY && box.maxY > block.minY &&
+			box.minZ < block.maxZ && box.maxZ > block.minZ {
+			return true
+		}
+	}
+	return false
+}
+
+// handleUseItemOn opens the clicked block or places the held block on the
+// tick goroutine, sneaking players with items in hand always place
+func (s *Session) handleUseItemOn(use protocol.UseItemOnPacket) {
+	defer s.queue(&protocol.AcknowledgeBlockChangePacket{Sequence: use.Sequence})
+
+	clicked := toBlockPos(use.Location)
+	if use.Face < protocol.FaceBottom || use.Face > protocol.FaceEast {
+		s.sendBlock(clicked)
+		return
+	}
+	target := faceOffset(clicked, use.Face)
+	slot := s.player.mainHandSlot()
+	if use.Hand == 1 {
+		slot = offHandSlot
+	}
+	item := &s.player.Inventory.Slots[slot]
+
+	w := s.server.world
+	clickedBlock, loaded := w.LoadedBlock(clicked.X, clicked.Y, clicked.Z)
+	handsEmpty := s.player.MainHand().IsEmpty() && s.player.OffHand().IsEmpty()
+	if loaded && s.canReach(clicked) && (!s.sneaking || handsEmpty) && s.useBlock(clicked, clickedBlock) {
+		return
+	}
+
+	state, ok := registry.DefaultBlockState(item.Item)
+	if item.IsEmpty() || !ok || state.IsAir() {
+		// using items is not supported
+		return
+	}
+	reject := func() {
+		s.sendBlock(clicked)
+		s.sendBlock(target)
+	}
+	if !loaded || !s.canBuild() || !s.canReach(clicked) || multiBlock(state) {
+		reject()
+		return
+	}
+	cursorY := use.CursorY - float32(math.Floor(float64(use.CursorY)))
+	state = placementState(state, use.Face, cursorY, s.player.Yaw, s.player.Pitch)
+
+	completesSlab := false
+	switch {
+	case clickedBlock.Name == state.Name && clickedBlock.Property("type") != "double" &&
+		strings.HasSuffix(state.Name, "_slab") &&
+		(clickedBlock.Property("type") == "bottom" && use.Face == protocol.FaceTop ||
+			clickedBlock.Property("type") == "top" && use.Face == protocol.FaceBottom):
+		// completes the clicked slab
+		target = clicked
+		state = clickedBlock.WithProperty("type", "double")
+		completesSlab = true
+	case replaceable(clickedBlock):
+		target = clicked
+	}
+
+	targetBlock, loaded := w.LoadedBlock(target.X, target.Y, target.Z)
+	if !loaded || target.Y < w.MinY || target.Y >= w.MinY+w.Height {
+		reject()
+		return
+	}
+	if !completesSlab && !replaceable(targetBlock) {
+		reject()
+		return
+	}
+	if hasCollision(state) && s.server.occupied(target) {
+		reject()
+		return
+	}
+
+	e := &BlockPlaceEvent{Player: s, Pos: target, Block: state}
+	event.Fire(&s.server.events, e)
+	if e.Cancelled {
+		reject()
+		return
+	}
+	err := s.server.SetBlock(target, e.Block)
+	if err != nil {
+		log.Err(err).Str("player", s.player.Name).Msg("failed to place block")
+		reject()
+		return
+	}
+	if s.player.GameMode != Creative {
+		item.Count--
+		if item.Count == 0 {
+			*item = ItemStack{}
+		}
+		s.syncWindow()
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigProgress(t *testing.T) {
+	ticks := func(block string, held string, onGround bool) float64 {
+		return 1 / digProgress(registry.BlockMining(block), ItemStack{Item: held, Count: 1}, onGround)
+	}
+	require.InDelta(t, 150, ticks("minecraft:stone", "", true), 1e-9)
+	require.InDelta(t, 22.5, ticks("minecraft:stone", "minecraft:wooden_pickaxe", true), 1e-9)
+	require.InDelta(t, 15, ticks("minecraft:dirt", "", true), 1e-9)
+	require.InDelta(t, 75, ticks("minecraft:dirt", "", false), 1e-9)
+	// a wooden pickaxe mines iron ore faster but doesn't harvest it
+	require.InDelta(t, 150, ticks("minecraft:iron_ore", "minecraft:wooden_pickaxe", true), 1e-9)
+	require.Equal(t, 1.0, digProgress(registry.BlockMining("minecraft:torch"), ItemStack{}, true))
+	require.Zero(t, digProgress(registry.BlockMining("minecraft:bedrock"), ItemStack{}, true))
+}
+
+func defaultState(t *testing.T, name string) world.BlockState {
+	t.Helper()
+	b, ok := registry.DefaultBlockState(name)
+	require.True(t, ok)
+	return b
+}
+
+func TestPlacementState(t *testing.T) {
+	log := defaultState(t, "minecraft:oak_log")
+	require.Equal(t, "x", placementState(log, protocol.FaceEast, 0.5, 0, 0).Property("axis"))
+	furnace := defaultState(t, "minecraft:furnace")
+	// a player looking north gets the furnace front facing them
+	require.Equal(t, "south", placementState(furnace, protocol.FaceTop, 1, 180, 0).Property("facing"))
+	stairs := defaultState(t, "minecraft:oak_stairs")
+	placed := placementState(stairs, protocol.FaceNorth, 0.7, 180, 0)
+	require.Equal(t, "north", placed.Property("facing"))
+	require.Equal(t, "top", placed.Property("half"))
+	slab := defaultState(t, "minecraft:stone_slab")
+	require.Equal(t, "bottom", placementState(slab, protocol.FaceTop, 1, 0, 0).Property("type"))
+}
+
+func blockAt(t *testing.T, srv *Server, x, y, z int) string {
+	t.Helper()
+	b, err := srv.world.Block(x, y, z)
+	require.NoError(t, err)
+	return b.Name
+}
+
+func TestPlaceAndBreakBlocks(t *testing.T) {
+	srv := newTestServer(t)
+	_, err := srv.world.Chunk(0, 0)
+	require.NoError(t, err)
+	require.NoError(t, srv.SetBlock(world.BlockPos{X: 0, Y: 64, Z: 0}, world.NewBlockState("minecraft:stone", nil)))
+
+	a := addTestPlayer(srv, 1, "alice")
+	a.player.X, a.player.Y, a.player.Z, a.player.OnGround = 2.5, 65, 0.5, true
+	a.player.Inventory.Slots[hotbarSlotStart] = ItemStack{Item: "minecraft:stone", Count: 2}
+	b := addTestPlayer(srv, 2, "bob")
+	b.player.X, b.player.Y, b.player.Z = 0.5, 66, 2.5
+	a.chunks.sent[world.ChunkPos{}] = struct{}{}
+	b.chunks.sent[world.ChunkPos{}] = struct{}{}
+	queuedIDs(t, a)
+	queuedIDs(t, b)
+
+	top := datatypes.Position{X: 0, Y: 64, Z: 0}
+	a.handleUseItemOn(protocol.UseItemOnPacket{Location: top, Face: protocol.FaceTop, CursorY: 1, Sequence: 1})
+	require.Equal(t, "minecraft:stone", blockAt(t, srv, 0, 65, 0))
+	require.Equal(t, 1, a.player.Inventory.Slots[hotbarSlotStart].Count)
+	require.Equal(t, []int{protocol.PlayBlockUpdateID, protocol.PlaySetContainerSlotID, protocol.PlayAcknowledgeBlockChangeID}, queuedIDs(t, a))
+	require.Equal(t, []int{protocol.PlayBlockUpdateID}, queuedIDs(t, b))
+
+	// bob stands where the block would go
+	b.player.Y = 65
+	b.player.Z = 1.2
+	a.handleUseItemOn(protocol.UseItemOnPacket{Location: datatypes.Position{X: 0, Y: 65, Z: 0}, Face: protocol.FaceSouth, CursorY: 0.5, Sequence: 2})
+	require.Equal(t, "minecraft:air", blockAt(t, srv, 0, 65, 1))
+	require.Equal(t, 1, a.player.Inventory.Slots[hotbarSlotStart].Count)
+
+	// faces outside the six sides don't replace the clicked block
+	queuedIDs(t, a)
+	a.handleUseItemOn(protocol.UseItemOnPacket{Location: top, Face: 9, CursorY: 1, Sequence: 3})
+	require.Equal(t, "minecraft:stone", blockAt(t, srv, 0, 64, 0))
+	require.Equal(t, 1, a.player.Inventory.Slots[hotbarSlotStart].Count)
+	require.Equal(t, []int{protocol.PlayBlockUpdateID, protocol.PlayAcknowledgeBlockChangeID}, queuedIDs(t, a))
+
+	// breaking stone by hand takes 150 ticks
+	pos := datatypes.Position{X: 0, Y: 65, Z: 0}
+	a.handlePlayerAction(protocol.PlayerActionPacket{Status: protocol.ActionStartDigging, Location: pos, Sequence: 3})
+	a.handlePlayerAction(protocol.PlayerActionPacket{Status: protocol.ActionFinishDigging, Location: pos, Sequence: 4})
+	require.Equal(t, "minecraft:stone", blockAt(t, srv, 0, 65, 0))
+	queuedIDs(t, b)
+
+	a.handlePlayerAction(protocol.PlayerActionPacket{Status: protocol.ActionStartDigging, Location: pos, Sequence: 5})
+	srv.currentTick.Add(110)
+	a.tickDigging(srv.CurrentTick())
+	require.Equal(t, []int{protocol.PlayBlockDestroyStageID}, queuedIDs(t, b))
+	a.handlePlayerAction(protocol.PlayerActionPacket{Status: protocol.ActionFinishDigging, Location: pos, Sequence: 6})
+	require.Equal(t, "minecraft:air", blockAt(t, srv, 0, 65, 0))
+	require.Equal(t, []int{protocol.PlayBlockDestroyStageID, protocol.PlayBlockUpdateID, protocol.PlayWorldEventID}, queuedIDs(t, b))
+
+	// plugins may keep blocks
+	event.Subscribe(&srv.events, event.Normal, func(e *BlockBreakEvent) {
+		e.Cancelled = e.Block.Name == "minecraft:stone"
+	})
+	a.player.GameMode = Creative
+	a.handlePlayerAction(protocol.PlayerActionPacket{Status: protocol.ActionStartDigging, Location: top, Sequence: 7})
+	require.Equal(t, "minecraft:stone", blockAt(t, srv, 0, 64, 0))
+	require.NoError(t, srv.SetBlock(world.BlockPos{X: 0, Y: 64, Z: 0}, world.NewBlockState("minecraft:dirt", nil)))
+	a.handlePlayerAction(protocol.PlayerActionPacket{Status: protocol.ActionStartDigging, Location: top, Sequence: 8})
+	require.Equal(t, "minecraft:air", blockAt(t, srv, 0, 64, 0))
+}
+
+func TestPlaceSlabs(t *testing.T) {
+	srv := newTestServer(t)
+	_, err := srv.world.Chunk(0, 0)
+	require.NoError(t, err)
+	require.NoError(t, srv.SetBlock(world.BlockPos{X: 0, Y: 64, Z: 0}, world.NewBlockState("minecraft:stone", nil)))
+	a := addTestPlayer(srv, 1, "alice")
+	a.player.X, a.player.Y, a.player.Z = 2.5, 65, 0.5
+	a.player.GameMode = Creative
+	a.player.Inventory.Slots[hotbarSlotStart] = ItemStack{Item: "minecraft:stone_slab", Count: 1}
+
+	a.handleUseItemOn(protocol.UseItemOnPacket{Location: datatypes.Position{X: 0, Y: 64, Z: 0}, Face: protocol.FaceTop, CursorY: 1})
+	b, err := srv.world.Block(0, 65, 0)
+	require.NoError(t, err)
+	require.Equal(t, "bottom", b.Property("type"))
+	a.handleUseItemOn(protocol.UseItemOnPacket{Location: datatypes.Position{X: 0, Y: 65, Z: 0}, Face: protocol.FaceTop, CursorY: 65.5})
+	b, err = srv.world.Block(0, 65, 0)
+	require.NoError(t, err)
+	require.Equal(t, "double", b.Property("type"))
+	require.Equal(t, "minecraft:air", blockAt(t, srv, 0, 66, 0))
+	require.Equal(t, 1, a.player.Inventory.Slots[hotbarSlotStart].Count)
+}
@@ -0,0 +1,709 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Failures of built-in commands, they are shown to the source as is
+var (
+	errNoPlayer       = errors.New("No player was found")
+	errEntityRequired = errors.New("An entity is required to run this command here")
+	errUnknownPlayer  = errors.New("That player does not exist")
+)
+
+const (
+	defaultKickReason = "Kicked by an operator"
+	defaultBanReason  = "Banned by an operator."
+	// maxGiveStacks limits /give to this many full stacks, as vanilla does
+	maxGiveStacks = 100
+)
+
+// namedTimes are the day times /time set accepts by name
+var namedTimes = []struct {
+	name string
+	time int64
+}{
+	{"day", 1000},
+	{"noon", 6000},
+	{"night", 13000},
+	{"midnight", 18000},
+}
+
+var weatherFeedback = map[world.Weather]string{
+	world.Clear:   "clear",
+	world.Rain:    "rain",
+	world.Thunder: "rain & thunder",
+}
+
+func (s *Server) registerBuiltinCommands() {
+	for _, n := range []*command.Node{
+		s.helpCommand(),
+		s.teleportCommand(),
+		s.gameModeCommand(),
+		s.kickCommand(),
+		s.banCommand(),
+		s.pardonCommand(),
+		s.whitelistCommand(),
+		s.opCommand(),
+		s.deopCommand(),
+		s.sayCommand(),
+		s.listCommand(),
+		s.timeCommand(),
+		s.weatherCommand(),
+		s.giveCommand(),
+		s.stopCommand(),
+		s.seedCommand(),
+	} {
+		s.commands.Register(n)
+	}
+	s.commands.Register(command.Literal("tp").Requires(command.LevelGameMaster).Redirect(s.commands.Get("teleport")))
+}
+
+// sendFeedback reports a successful command to src and, as vanilla does, to
+// other operators and the log
+func (s *Server) sendFeedback(src command.Source, msg string) {
+	src.SendMessage(text.Plain(msg))
+	if _, ok := src.(console); !ok {
+		log.Info().Str("source", src.Name()).Msg(msg)
+	}
+	notice := text.Plain("[" + src.Name() + ": " + msg + "]")
+	notice.Color, notice.Italic = "gray", true
+	for session := range s.sessions {
+		if session != src && session.HasPermission(command.LevelGameMaster) {
+			session.SendMessage(notice)
+		}
+	}
+}
+
+// players returns players in play sorted by name
+func (s *Server) players() []*Session {
+	res := make([]*Session, 0, len(s.sessions))
+	for session := range s.sessions {
+		res = append(res, session)
+	}
+	slices.SortFunc(res, func(a, b *Session) int {
+		return strings.Compare(a.player.Name, b.player.Name)
+	})
+	return res
+}
+
+func (s *Server) playerNames() []string {
+	players := s.players()
+	res := make([]string, len(players))
+	for i, p := range players {
+		res[i] = p.player.Name
+	}
+	return res
+}
+
+// suggestPlayers completes player names, suggestions may be asked for from any goroutine
+func (s *Server) suggestPlayers(*command.Context, string) []string {
+	players := s.onlinePlayers()
+	res := make([]string, len(players))
+	for i, p := range players {
+		res[i] = p.Name
+	}
+	return res
+}
+
+// playerArgument is an entity argument limited to players
+func (s *Server) playerArgument(name string, single bool) *command.Node {
+	return command.Argument(name, command.Entity(single, true)).Suggests(s.suggestPlayers)
+}
+
+// selectPlayers returns players in play matched by sel
+func (s *Server) selectPlayers(src command.Source, sel command.Selector) ([]*Session, error) {
+	players := s.players()
+	var res []*Session
+	switch sel.Type {
+	case 's':
+		self, ok := src.(*Session)
+		if !ok {
+			return nil, errEntityRequired
+		}
+		res = append(res, self)
+	case 'a', 'e':
+		res = players
+	case 'r':
+		if len(players) > 0 {
+			res = append(res, players[rand.IntN(len(players))])
+		}
+	case 'p':
+		x, y, z := src.Position()
+		nearest := math.Inf(1)
+		for _, p := range players {
+			dx, dy, dz := p.player.X-x, p.player.Y-y, p.player.Z-z
+			if d := dx*dx + dy*dy + dz*dz; d < nearest {
+				nearest = d
+				res = []*Session{p}
+			}
+		}
+	default:
+		for _, p := range players {
+			if sel.Name != "" && strings.EqualFold(p.player.Name, sel.Name) || sel.Name == "" && p.player.UUID == sel.UUID {
+				res = append(res, p)
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, errNoPlayer
+	}
+	return res, nil
+}
+
+// selfOr selects the named argument when given and the source otherwise
+func (s *Server) selfOr(ctx *command.Context, name string) ([]*Session, error) {
+	sel := command.Selector{Type: 's'}
+	if ctx.Has(name) {
+		sel = ctx.Selector(name)
+	}
+	return s.selectPlayers(ctx.Source, sel)
+}
+
+// selectProfiles resolves a game profile argument. Offline players are
+// looked up in the player lists, unknown names get the offline mode UUID.
+func (s *Server) selectProfiles(src command.Source, sel command.Selector) ([]access.Profile, error) {
+	players, err := s.selectPlayers(src, sel)
+	if err == nil {
+		res := make([]access.Profile, len(players))
+		for i, p := range players {
+			res[i] = p.profile()
+		}
+		return res, nil
+	}
+	if sel.Type != 0 {
+		return nil, err
+	}
+	if p, ok := s.knownProfile(sel); ok {
+		return []access.Profile{p}, nil
+	}
+	if sel.Name == "" {
+		return nil, errUnknownPlayer
+	}
+	return []access.Profile{{UUID: access.OfflineUUID(sel.Name), Name: sel.Name}}, nil
+}
+
+func (s *Server) knownProfile(sel command.Selector) (access.Profile, bool) {
+	if sel.Name != "" {
+		if op, ok := s.ops.Find(sel.Name); ok {
+			return op.Profile, true
+		}
+		if ban, ok := s.bans.Find(sel.Name); ok {
+			return ban.Profile, true
+		}
+		if e, ok := s.whitelist.Find(sel.Name); ok {
+			return e.Profile, true
+		}
+		return access.Profile{}, false
+	}
+	if op, ok := s.ops.Get(sel.UUID); ok {
+		return op.Profile, true
+	}
+	if ban, ok := s.bans.Get(sel.UUID); ok {
+		return ban.Profile, true
+	}
+	if e, ok := s.whitelist.Get(sel.UUID); ok {
+		return e.Profile, true
+	}
+	return access.Profile{}, false
+}
+
+// playerByUUID returns the player in play with id or nil
+func (s *Server) playerByUUID(id uuid.UUID) *Session {
+	for session := range s.sessions {
+		if session.player.UUID == id {
+			return session
+		}
+	}
+	return nil
+}
+
+// PlayerByName returns the player in play with name, ignoring case, or nil.
+// It must be called from the tick goroutine.
+func (s *Server) PlayerByName(name string) *Session {
+	for session := range s.sessions {
+		if strings.EqualFold(session.player.Name, name) {
+			return session
+		}
+	}
+	return nil
+}
+
+// describePlayers names a single player and counts several
+func describePlayers(players []*Session) string {
+	if len(players) == 1 {
+		return players[0].player.Name
+	}
+	return fmt.Sprintf("%d players", len(players))
+}
+
+func (s *Server) helpCommand() *command.Node {
+	return command.Literal("help").Executes(func(ctx *command.Context) error {
+		for _, line := range s.commands.Usage(ctx.Source) {
+			ctx.Source.SendMessage(text.Plain(line))
+		}
+		return nil
+	})
+}
+
+func (s *Server) teleportCommand() *command.Node {
+	return command.Literal("teleport").Requires(command.LevelGameMaster).Then(
+		command.Argument("location", command.Vec3()).Executes(s.runTeleport),
+		s.playerArgument("destination", true).Executes(s.runTeleport),
+		s.playerArgument("targets", false).Then(
+			command.Argument("location", command.Vec3()).Executes(s.runTeleport),
+			s.playerArgument("destination", true).Executes(s.runTeleport),
+		),
+	)
+}
+
+func (s *Server) runTeleport(ctx *command.Context) error {
+	targets, err := s.selfOr(ctx, "targets")
+	if err != nil {
+		return err
+	}
+	var x, y, z float64
+	var to string
+	if ctx.Has("location") {
+		x, y, z = ctx.Vec3("location")
+		to = fmt.Sprintf("%f, %f, %f", x, y, z)
+	} else {
+		destination, err := s.selectPlayers(ctx.Source, ctx.Selector("destination"))
+		if err != nil {
+			return err
+		}
+		x, y, z = destination[0].Position()
+		to = destination[0].player.Name
+	}
+	for _, p := range targets {
+		p.Teleport(x, y, z)
+	}
+	s.sendFeedback(ctx.Source, fmt.Sprintf("Teleported %s to %s", describePlayers(targets), to))
+	return nil
+}
+
+// gameModeTitle is how feedback messages name a game mode
+func gameModeTitle(mode GameMode) string {
+	name := mode.String()
+	return strings.ToUpper(name[:1]) + name[1:] + " Mode"
+}
+
+func (s *Server) gameModeCommand() *command.Node {
+	return command.Literal("gamemode").Requires(command.LevelGameMaster).Then(
+		command.Argument("gamemode", command.GameMode()).Executes(s.runGameMode).Then(
+			s.playerArgument("target", false).Executes(s.runGameMode),
+		),
+	)
+}
+
+func (s *Server) runGameMode(ctx *command.Context) error {
+	mode, _ := ParseGameMode(ctx.String("gamemode"))
+	targets, err := s.selfOr(ctx, "target")
+	if err != nil {
+		return err
+	}
+	for _, p := range targets {
+		if p.player.GameMode == mode {
+			continue
+		}
+		p.SetGameMode(mode)
+		if p == ctx.Source {
+			s.sendFeedback(ctx.Source, "Set own game mode to "+gameModeTitle(mode))
+			continue
+		}
+		p.SendMessage(text.Plain("Your game mode has been updated to " + gameModeTitle(mode)))
+		s.sendFeedback(ctx.Source, fmt.Sprintf("Set %s's game mode to %s", p.player.Name, gameModeTitle(mode)))
+	}
+	return nil
+}
+
+func (s *Server) kickCommand() *command.Node {
+	return command.Literal("kick").Requires(command.LevelAdmin).Then(
+		s.playerArgument("targets", false).Executes(s.runKick).Then(
+			command.Argument("reason", command.Message()).Executes(s.runKick),
+		),
+	)
+}
+
+func (s *Server) runKick(ctx *command.Context) error {
+	targets, err := s.selectPlayers(ctx.Source, ctx.Selector("targets"))
+	if err != nil {
+		return err
+	}
+	reason := defaultKickReason
+	if ctx.Has("reason") {
+		reason = ctx.String("reason")
+	}
+	for _, p := range targets {
+		p.Kick(reason)
+		s.sendFeedback(ctx.Source, fmt.Sprintf("Kicked %s: %s", p.player.Name, reason))
+	}
+	return nil
+}
+
+// profileArgument is a game profile argument completed with names from suggest
+func profileArgument(name string, suggest command.SuggestFunc) *command.Node {
+	return command.Argument(name, command.GameProfile()).Suggests(suggest)
+}
+
+func (s *Server) banCommand() *command.Node {
+	return command.Literal("ban").Requires(command.LevelAdmin).Then(
+		profileArgument("targets", s.suggestPlayers).Executes(s.runBan).Then(
+			command.Argument("reason", command.Message()).Executes(s.runBan),
+		),
+	)
+}
+
+func (s *Server) runBan(ctx *command.Context) error {
+	profiles, err := s.selectProfiles(ctx.Source, ctx.Selector("targets"))
+	if err != nil {
+		return err
+	}
+	reason := defaultBanReason
+	if ctx.Has("reason") {
+		reason = ctx.String("reason")
+	}
+	banned := 0
+	for _, p := range profiles {
+		ban := access.NewBan(p, ctx.Source.Name(), reason)
+		added, err := s.bans.Add(ban)
+		if err != nil {
+			return err
+		}
+		if !added {
+			continue
+		}
+		banned++
+		s.sendFeedback(ctx.Source, fmt.Sprintf("Banned %s: %s", p.Name, reason))
+		if session := s.playerByUUID(p.UUID); session != nil {
+			session.Kick(banMessage(ban))
+		}
+	}
+	if banned == 0 {
+		return errors.New("Nothing changed. The player is already banned")
+	}
+	return nil
+}
+
+func (s *Server) pardonCommand() *command.Node {
+	return command.Literal("pardon").Requires(command.LevelAdmin).Then(
+		profileArgument("targets", func(*command.Context, string) []string {
+			return s.bans.Names()
+		}).Executes(func(ctx *command.Context) error {
+			profiles, err := s.selectProfiles(ctx.Source, ctx.Selector("targets"))
+			if err != nil {
+				return err
+			}
+			pardoned := 0
+			for _, p := range profiles {
+				removed, err := s.bans.Remove(p.UUID)
+				if err != nil {
+					return err
+				}
+				if removed {
+					pardoned++
+					s.sendFeedback(ctx.Source, "Unbanned "+p.Name)
+				}
+			}
+			if pardoned == 0 {
+				return errors.New("Nothing changed. The player isn't banned")
+			}
+			return nil
+		}),
+	)
+}
+
+func (s *Server) whitelistCommand() *command.Node {
+	toggle := func(enabled bool, state string) command.Executor {
+		return func(ctx *command.Context) error {
+			if s.whitelistEnabled.Load() == enabled {
+				return errors.New("Whitelist is already turned " + state)
+			}
+			s.SetWhitelist(enabled)
+			s.sendFeedback(ctx.Source, "Whitelist is now turned "+state)
+			return nil
+		}
+	}
+	return command.Literal("whitelist").Requires(command.LevelAdmin).Then(
+		command.Literal("on").Executes(toggle(true, "on")),
+		command.Literal("off").Executes(toggle(false, "off")),
+		command.Literal("list").Executes(func(ctx *command.Context) error {
+			names := s.whitelist.Names()
+			if len(names) == 0 {
+				ctx.Source.SendMessage(text.Plain("There are no whitelisted players"))
+				return nil
+			}
+			ctx.Source.SendMessage(text.Plain(fmt.Sprintf("There are %d whitelisted player(s): %s", len(names), strings.Join(names, ", "))))
+			return nil
+		}),
+		command.Literal("add").Then(
+			profileArgument("targets", s.suggestPlayers).Executes(func(ctx *command.Context) error {
+				profiles, err := s.selectProfiles(ctx.Source, ctx.Selector("targets"))
+				if err != nil {
+					return err
+				}
+				added := 0
+				for _, p := range profiles {
+					ok, err := s.whitelist.Add(access.WhitelistEntry{Profile: p})
+					if err != nil {
+						return err
+					}
+					if ok {
+						added++
+						s.sendFeedback(ctx.Source, fmt.Sprintf("Added %s to the whitelist", p.Name))
+					}
+				}
+				if added == 0 {
+					return errors.New("Player is already whitelisted")
+				}
+				return nil
+			}),
+		),
+		command.Literal("remove").Then(
+			profileArgument("targets", func(*command.Context, string) []string {
+				return s.whitelist.Names()
+			}).Executes(func(ctx *command.Context) error {
+				profiles, err := s.selectProfiles(ctx.Source, ctx.Selector("targets"))
+				if err != nil {
+					return err
+				}
+				removed := 0
+				for _, p := range profiles {
+					ok, err := s.whitelist.Remove(p.UUID)
+					if err != nil {
+						return err
+					}
+					if ok {
+						removed++
+						s.sendFeedback(ctx.Source, fmt.Sprintf("Removed %s from the whitelist", p.Name))
+					}
+				}
+				if removed == 0 {
+					return errors.New("Player is not whitelisted")
+				}
+				return nil
+			}),
+		),
+		command.Literal("reload").Executes(func(ctx *command.Context) error {
+			err := s.whitelist.Reload()
+			if err != nil {
+				return err
+			}
+			s.sendFeedback(ctx.Source, "Reloaded the whitelist")
+			return nil
+		}),
+	)
+}
+
+func (s *Server) opCommand() *command.Node {
+	return command.Literal("op").Requires(command.LevelAdmin).Then(
+		profileArgument("targets", func(*command.Context, string) []string {
+			var res []string
+			for _, name := range s.suggestPlayers(nil, "") {
+				if _, ok := s.ops.Find(name); !ok {
+					res = append(res, name)
+				}
+			}
+			return res
+		}).Executes(func(ctx *command.Context) error {
+			profiles, err := s.selectProfiles(ctx.Source, ctx.Selector("targets"))
+			if err != nil {
+				return err
+			}
+			level := s.cfg.Access.OpPermissionLevel
+			opped := 0
+			for _, p := range profiles {
+				added, err := s.ops.Add(access.Op{Profile: p, Level: level})
+				if err != nil {
+					return err
+				}
+				if !added {
+					continue
+				}
+				opped++
+				if session := s.playerByUUID(p.UUID); session != nil {
+					session.SetPermissionLevel(level)
+				}
+				s.sendFeedback(ctx.Source, fmt.Sprintf("Made %s a server operator", p.Name))
+			}
+			if opped == 0 {
+				return errors.New("Nothing changed. The player already is an operator")
+			}
+			return nil
+		}),
+	)
+}
+
+func (s *Server) deopCommand() *command.Node {
+	return command.Literal("deop").Requires(command.LevelAdmin).Then(
+		profileArgument("targets", func(*command.Context, string) []string {
+			return s.ops.Names()
+		}).Executes(func(ctx *command.Context) error {
+			profiles, err := s.selectProfiles(ctx.Source, ctx.Selector("targets"))
+			if err != nil {
+				return err
+			}
+			deopped := 0
+			for _, p := range profiles {
+				removed, err := s.ops.Remove(p.UUID)
+				if err != nil {
+					return err
+				}
+				if !removed {
+					continue
+				}
+				deopped++
+				if session := s.playerByUUID(p.UUID); session != nil {
+					session.SetPermissionLevel(command.LevelAll)
+				}
+				s.sendFeedback(ctx.Source, fmt.Sprintf("Made %s no longer a server operator", p.Name))
+			}
+			if deopped == 0 {
+				return errors.New("Nothing changed. The player is not an operator")
+			}
+			return nil
+		}),
+	)
+}
+
+func (s *Server) sayCommand() *command.Node {
+	return command.Literal("say").Requires(command.LevelGameMaster).Then(
+		command.Argument("message", command.Message()).Executes(func(ctx *command.Context) error {
+			s.BroadcastMessage(text.Plain("[" + ctx.Source.Name() + "] " + ctx.String("message")))
+			return nil
+		}),
+	)
+}
+
+func (s *Server) listCommand() *command.Node {
+	return command.Literal("list").Executes(func(ctx *command.Context) error {
+		names := s.playerNames()
+		ctx.Source.SendMessage(text.Plain(fmt.Sprintf("There are %d of a max of %d players online: %s",
+			len(names), s.cfg.MaxPlayers, strings.Join(names, ", "))))
+		return nil
+	})
+}
+
+// setDayTime changes the time of day and tells players right away
+func (s *Server) setDayTime(src command.Source, t int64) {
+	s.world.SetDayTime(t)
+	for session := range s.sessions {
+		session.sendTime()
+	}
+	s.sendFeedback(src, fmt.Sprintf("Set the time to %d", t%world.DayLength))
+}
+
+func (s *Server) timeCommand() *command.Node {
+	set := command.Literal("set")
+	for _, named := range namedTimes {
+		set.Then(command.Literal(named.name).Executes(func(ctx *command.Context) error {
+			s.setDayTime(ctx.Source, named.time)
+			return nil
+		}))
+	}
+	set.Then(command.Argument("time", command.Time(0)).Executes(func(ctx *command.Context) error {
+		s.setDayTime(ctx.Source, int64(ctx.Int("time")))
+		return nil
+	}))
+
+	query := func(name string, value func() int64) *command.Node {
+		return command.Literal(name).Executes(func(ctx *command.Context) error {
+			ctx.Source.SendMessage(text.Plain(fmt.Sprintf("The time is %d", value())))
+			return nil
+		})
+	}
+	return command.Literal("time").Requires(command.LevelGameMaster).Then(
+		set,
+		command.Literal("add").Then(
+			command.Argument("time", command.Time(0)).Executes(func(ctx *command.Context) error {
+				s.setDayTime(ctx.Source, s.world.DayTime()+int64(ctx.Int("time")))
+				return nil
+			}),
+		),
+		command.Literal("query").Then(
+			query("daytime", func() int64 { return s.world.DayTime() % world.DayLength }),
+			query("gametime", s.world.Time),
+			query("day", func() int64 { return s.world.DayTime() / world.DayLength }),
+		),
+	)
+}
+
+func (s *Server) weatherCommand() *command.Node {
+	n := command.Literal("weather").Requires(command.LevelGameMaster)
+	for _, weather := range []world.Weather{world.Clear, world.Rain, world.Thunder} {
+		run := func(ctx *command.Context) error {
+			s.world.SetWeather(weather, int64(ctx.Int("duration")))
+			s.sendFeedback(ctx.Source, "Set the weather to "+weatherFeedback[weather])
+			return nil
+		}
+		n.Then(command.Literal(weather.String()).Executes(run).Then(
+			command.Argument("duration", command.Time(1)).Executes(run),
+		))
+	}
+	return n
+}
+
+func (s *Server) giveCommand() *command.Node {
+	return command.Literal("give").Requires(command.LevelGameMaster).Then(
+		s.playerArgument("targets", false).Then(
+			command.Argument("item", command.ItemStack()).Executes(s.runGive).Then(
+				command.Argument("count", command.Integer(1, math.MaxInt32)).Executes(s.runGive),
+			),
+		),
+	)
+}
+
+func (s *Server) runGive(ctx *command.Context) error {
+	item := ctx.String("item")
+	maxStack := registry.MaxStackSize(item)
+	if maxStack == 0 {
+		return fmt.Errorf("Unknown item '%s'", item)
+	}
+	name := strings.TrimPrefix(item, "minecraft:")
+	count := 1
+	if ctx.Has("count") {
+		count = ctx.Int("count")
+	}
+	if count > maxStack*maxGiveStacks {
+		return fmt.Errorf("Can't give more than %d of [%s]", maxStack*maxGiveStacks, name)
+	}
+	targets, err := s.selectPlayers(ctx.Source, ctx.Selector("targets"))
+	if err != nil {
+		return err
+	}
+	for _, p := range targets {
+		if left := p.Give(ItemStack{Item: item, Count: count}); left > 0 {
+			log.Debug().Str("player", p.player.Name).Str("item", item).Int("count", left).Msg("inventory full, items lost")
+		}
+	}
+	s.sendFeedback(ctx.Source, fmt.Sprintf("Gave %d [%s] to %s", count, name, describePlayers(targets)))
+	return nil
+}
+
+func (s *Server) stopCommand() *command.Node {
+	return command.Literal("stop").Requires(command.LevelOwner).Executes(func(ctx *command.Context) error {
+		s.sendFeedback(ctx.Source, "Stopping the server")
+		s.Stop()
+		return nil
+	})
+}
+
+func (s *Server) seedCommand() *command.Node {
+	return command.Literal("seed").Requires(command.LevelGameMaster).Executes(func(ctx *command.Context) error {
+		ctx.Source.SendMessage(text.Plain(fmt.Sprintf("Seed: [%d]", s.world.Seed)))
+		return nil
+	})
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	brandChannel      = "minecraft:brand"
+	registerChannel   = "minecraft:register"
+	unregisterChannel = "minecraft:unregister"
+	// maxClientChannels is how many channels a client may register, as in vanilla
+	maxClientChannels = 128
+)
+
+var (
+	ErrInvalidChannel    = errors.New("invalid channel name")
+	ErrChannelRegistered = errors.New("channel already registered")
+	ErrTooManyChannels   = errors.New("too many channels")
+	ErrChannelNotOpen    = errors.New("channel not registered by client")
+)
+
+// ChannelHandler receives custom payloads on a channel, it is called from the tick goroutine
+type ChannelHandler func(s *Session, data []byte)
+
+// validChannel reports whether name is a namespaced identifier
+func validChannel(name string) bool {
+	namespace, path, ok := strings.Cut(name, ":")
+	if !ok || namespace == "" || path == "" {
+		return false
+	}
+	valid := func(s, extra string) bool {
+		for _, c := range s {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("_-."+extra, c)) {
+				return false
+			}
+		}
+		return true
+	}
+	return valid(namespace, "") && valid(path, "/")
+}
+
+// RegisterChannel routes custom payloads sent by clients on channel to h,
+// it is safe for concurrent use
+func (s *Server) RegisterChannel(channel string, h ChannelHandler) error {
+	if !validChannel(channel) || strings.HasPrefix(channel, "minecraft:") {
+		return fmt.Errorf("%w: %q", ErrInvalidChannel, channel)
+	}
+	s.channelsMu.Lock()
+	defer s.channelsMu.Unlock()
+	if _, ok := s.channels[channel]; ok {
+		return fmt.Errorf("%w: %s", ErrChannelRegistered, channel)
+	}
+	if s.channels == nil {
+		s.channels = make(map[string]ChannelHandler)
+	}
+	s.channels[channel] = h
+	return nil
+}
+
+// Channels returns the registered channels sorted by name
+func (s *Server) Channels() []string {
+	s.channelsMu.RLock()
+	defer s.channelsMu.RUnlock()
+	return slices.Sorted(maps.Keys(s.channels))
+}
+
+func (s *Server) channelHandler(channel string) ChannelHandler {
+	s.channelsMu.RLock()
+	defer s.channelsMu.RUnlock()
+	return s.channels[channel]
+}
+
+// Brand returns the client brand, it is empty until the client sends one
+func (s *Session) Brand() string {
+	return s.clientBrand
+}
+
+// sendChannels tells the client our brand and the channels we listen on
+func (s *Session) sendChannels() error {
+	var brand protocol.Encoder
+	brand.String(serverBrand)
+	err := s.send(&protocol.PluginMessagePacket{Channel: brandChannel, Data: brand.Data(), Config: true})
+	if err != nil {
+		return fmt.Errorf("failed to write brand packet: %w", err)
+	}
+	channels := s.server.Channels()
+	if len(channels) == 0 {
+		return nil
+	}
+	err = s.send(&protocol.PluginMessagePacket{
+		Channel: registerChannel,
+		Data:    []byte(strings.Join(channels, "\x00")),
+		Config:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write register packet: %w", err)
+	}
+	return nil
+}
+
+// handlePluginMessage must be called from the tick goroutine, an error means
+// the client broke the protocol and is to be disconnected
+func (s *Session) handlePluginMessage(p protocol.PluginMessagePacket) error {
+	switch p.Channel {
+	case brandChannel:
+		d := protocol.NewDecoder(p.Data)
+		brand := d.String(protocol.MaxServerboundPayload)
+		if d.Err() != nil {
+			return fmt.Errorf("failed to decode client brand: %w", d.Err())
+		}
+		s.clientBrand = brand
+		log.Debug().Str("player", s.player.Name).Str("brand", brand).Msg("client brand")
+
+	case registerChannel:
+		for name := range bytes.SplitSeq(p.Data, []byte{0}) {
+			if len(name) == 0 {
+				continue
+			}
+			if s.clientChannels == nil {
+				s.clientChannels = make(map[string]struct{})
+			}
+			s.clientChannels[string(name)] = struct{}{}
+			if len(s.clientChannels) > maxClientChannels {
+				return fmt.Errorf("%w: %d", ErrTooManyChannels, len(s.clientChannels))
+			}
+		}
+
+	case unregisterChannel:
+		for name := range bytes.SplitSeq(p.Data, []byte{0}) {
+			delete(s.clientChannels, string(name))
+		}
+
+	default:
+		h := s.server.channelHandler(p.Channel)
+		if h == nil {
+			log.Trace().Str("player", s.player.Name).Str("channel", p.Channel).Msg("unhandled plugin message")
+			return nil
+		}
+		h(s, p.Data)
+	}
+	return nil
+}
+
+// SendPluginMessage sends data on channel, the client must have registered
+// it unless it is a minecraft one. It must be called from the tick goroutine.
+func (s *Session) SendPluginMessage(channel string, data []byte) error {
+	if len(data) > protocol.MaxClientboundPayload {
+		return fmt.Errorf("%w: payload of %d bytes", protocol.ErrPacketTooLarge, len(data))
+	}
+	if _, ok := s.clientChannels[channel]; !ok && !strings.HasPrefix(channel, "minecraft:") {
+		return fmt.Errorf("%w: %s", ErrChannelNotOpen, channel)
+	}
+	if s.outCh == nil {
+		// not in the tick yet
+		return s.send(&protocol.PluginMessagePacket{Channel: channel, Data: data, Config: true})
+	}
+	s.queue(&protocol.PluginMessagePacket{Channel: channel, Data: data})
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterChannel(t *testing.T) {
+	srv := newTestServer(t)
+	h := func(*Session, []byte) {}
+	require.NoError(t, srv.RegisterChannel("example:sync/state", h))
+	require.ErrorIs(t, srv.RegisterChannel("example:sync/state", h), ErrChannelRegistered)
+	for _, name := range []string{"nonamespace", "example:", "Example:upper", "minecraft:brand", "a:b c"} {
+		require.ErrorIs(t, srv.RegisterChannel(name, h), ErrInvalidChannel, name)
+	}
+	require.Equal(t, []string{"example:sync/state"}, srv.Channels())
+}
+
+func TestHandlePluginMessage(t *testing.T) {
+	srv := newTestServer(t)
+	a := addTestPlayer(srv, 1, "alice")
+
+	var brand protocol.Encoder
+	brand.String("vanilla")
+	require.NoError(t, a.handlePluginMessage(protocol.PluginMessagePacket{Channel: brandChannel, Data: brand.Data()}))
+	require.Equal(t, "vanilla", a.Brand())
+
+	var got []byte
+	var sendErr error
+	require.NoError(t, srv.RegisterChannel("example:echo", func(s *Session, data []byte) {
+		got = data
+		sendErr = s.SendPluginMessage("example:echo", data)
+	}))
+
+	// the client has not opened the channel yet
+	require.NoError(t, a.handlePluginMessage(protocol.PluginMessagePacket{Channel: "example:echo", Data: []byte("hi")}))
+	require.Equal(t, []byte("hi"), got)
+	require.ErrorIs(t, sendErr, ErrChannelNotOpen)
+	require.Zero(t, a.outbound.Len())
+
+	require.NoError(t, a.handlePluginMessage(protocol.PluginMessagePacket{Channel: registerChannel, Data: []byte("example:echo\x00other:x")}))
+	require.NoError(t, a.handlePluginMessage(protocol.PluginMessagePacket{Channel: "example:echo", Data: []byte("hey")}))
+	require.NoError(t, sendErr)
+	p, err := protocol.ReadPacket(bytes.NewReader(a.outbound.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, protocol.PlayPluginMessageID, p.ID)
+	d := protocol.NewDecoder(p.Data)
+	require.Equal(t, "example:echo", d.String(64))
+	require.Equal(t, []byte("hey"), d.Rest())
+
+	require.NoError(t, a.handlePluginMessage(protocol.PluginMessagePacket{Channel: unregisterChannel, Data: []byte("example:echo")}))
+	require.ErrorIs(t, a.SendPluginMessage("example:echo", nil), ErrChannelNotOpen)
+	require.ErrorIs(t, a.SendPluginMessage("minecraft:brand", make([]byte, protocol.MaxClientboundPayload+1)), protocol.ErrPacketTooLarge)
+
+	names := make([]string, maxClientChannels+1)
+	for i := range names {
+		names[i] = "spam:c" + strings.Repeat("x", i)
+	}
+	err = a.handlePluginMessage(protocol.PluginMessagePacket{Channel: registerChannel, Data: []byte(strings.Join(names, "\x00"))})
+	require.ErrorIs(t, err, ErrTooManyChannels)
+}
+
+func TestPluginMessageDecode(t *testing.T) {
+	var e protocol.Encoder
+	e.String("example:big")
+	e.Raw(make([]byte, protocol.MaxServerboundPayload+1))
+	var p protocol.PluginMessagePacket
+	require.ErrorIs(t, p.Decode(e.Data()), protocol.ErrPacketTooLarge)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// a message adds chatSpamIncrement and every tick takes one away, players
+	// going over chatSpamLimit are kicked, as in vanilla
+	chatSpamIncrement = 20
+	chatSpamLimit     = 200
+)
+
+// BroadcastMessage shows a system message to every player, it must be called from the tick goroutine
+func (s *Server) BroadcastMessage(c text.Component) {
+	log.Info().Str("message", c.String()).Msg("chat")
+	s.broadcast(&protocol.SystemChatPacket{Content: c})
+}
+
+// SendMessage shows a system message to the player
+func (s *Session) SendMessage(c text.Component) {
+	s.queue(&protocol.SystemChatPacket{Content: c})
+}
+
+// validChatMessage rejects control characters and the legacy formatting sign
+func validChatMessage(msg string) bool {
+	for _, r := range msg {
+		if r < ' ' || r == 0x7F || r == '§' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatMessage fills a chat template
+func formatMessage(format text.Component, player, message string) text.Component {
+	return format.Replace("{player}", player).Replace("{message}", message)
+}
+
+func (s *Session) handleChat(p protocol.ChatMessagePacket) {
+	s.chatSpam += chatSpamIncrement
+	if s.chatSpam > chatSpamLimit {
+		s.Kick("Kicked for spamming")
+		return
+	}
+	if !validChatMessage(p.Message) {
+		s.Kick("Illegal characters in chat")
+		return
+	}
+	lastSeen, err := s.lastSeen.ApplyUpdate(int(p.MessageCount), p.Acknowledged[:])
+	if err != nil {
+		log.Warn().Err(err).Str("player", s.player.Name).Msg("rejected chat message")
+		s.Kick("Chat message validation failure")
+		return
+	}
+	signed, err := s.verifyChat(&p, lastSeen)
+	if err != nil {
+		log.Warn().Err(err).Str("player", s.player.Name).Msg("rejected chat message")
+		s.Kick("Chat message validation failure")
+		return
+	}
+	if signed == nil && s.server.cfg.SecureChat.Enforce {
+		s.Kick("Received chat packet with missing or invalid signature")
+		return
+	}
+
+	msg := strings.Join(strings.Fields(p.Message), " ")
+	if msg == "" {
+		return
+	}
+	e := &ChatEvent{Player: s, Message: msg, Format: s.server.cfg.Chat.Format}
+	event.Fire(&s.server.events, e)
+	if e.Cancelled {
+		return
+	}
+	if signed != nil && e.Message == msg {
+		s.server.broadcastSigned(s, signed, p.Signature, e.Format)
+		return
+	}
+	s.server.BroadcastMessage(formatMessage(e.Format, s.player.Name, e.Message))
+}
+
+func (s *Session) tickChat() {
+	if s.chatSpam > 0 {
+		s.chatSpam--
+	}
+}
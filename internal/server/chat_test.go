@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/stretchr/testify/require"
+)
+
+// queuedMessages returns texts of system chat messages queued to s and clears its queue
+func queuedMessages(t *testing.T, s *Session) []string {
+	t.Helper()
+	r := bytes.NewReader(bytes.Clone(s.outbound.Bytes()))
+	s.outbound.Reset()
+	var res []string
+	for r.Len() > 0 {
+		p, err := protocol.ReadPacket(r)
+		require.NoError(t, err)
+		if p.ID != protocol.PlaySystemChatID {
+			continue
+		}
+		c, err := nbt.ReadNetwork(bytes.NewReader(p.Data))
+		require.NoError(t, err)
+		res = append(res, c.String("text"))
+	}
+	return res
+}
+
+func TestHandleChat(t *testing.T) {
+	srv := &Server{cfg: config.Default(), sessions: map[*Session]struct{}{}}
+	a := newTestSession(1, 0, 0)
+	a.server = srv
+	a.player.Name = "alice"
+	srv.sessions[a] = struct{}{}
+
+	a.handleChat(protocol.ChatMessagePacket{Message: "  hello   world "})
+	require.Equal(t, []string{"<alice> hello world"}, queuedMessages(t, a))
+
+	event.Subscribe(srv.Events(), event.Normal, func(e *ChatEvent) {
+		if e.Message == "secret" {
+			e.Cancelled = true
+		}
+		e.Format = text.Plain("{player}: {message}")
+	})
+	a.handleChat(protocol.ChatMessagePacket{Message: "secret"})
+	a.handleChat(protocol.ChatMessagePacket{Message: "hi {player}"})
+	require.Equal(t, []string{"alice: hi {player}"}, queuedMessages(t, a))
+}
+
+func TestHandleChat_Limits(t *testing.T) {
+	srv := &Server{cfg: config.Default(), sessions: map[*Session]struct{}{}}
+	a := newTestSession(1, 0, 0)
+	a.server = srv
+	a.outCh = make(chan []byte, 1)
+	srv.sessions[a] = struct{}{}
+
+	for range chatSpamLimit / chatSpamIncrement {
+		a.handleChat(protocol.ChatMessagePacket{Message: "spam"})
+		a.tickChat()
+	}
+	require.False(t, a.closing)
+	a.handleChat(protocol.ChatMessagePacket{Message: "spam"})
+	a.handleChat(protocol.ChatMessagePacket{Message: "spam"})
+	require.True(t, a.closing)
+
+	b := newTestSession(2, 0, 0)
+	b.server = srv
+	b.outCh = make(chan []byte, 1)
+	b.handleChat(protocol.ChatMessagePacket{Message: "bad §c color"})
+	require.True(t, b.closing)
+	require.True(t, validChatMessage("ünïcödé ok"))
+	require.False(t, validChatMessage("tab\there"))
+}
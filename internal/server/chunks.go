@@ -0,0 +1,254 @@
+package server
+
+import (
+	"math"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	minViewDistance = 2
+	maxViewDistance = 32
+
+	// batching follows the vanilla chunk sender
+	startChunksPerTick = 9
+	minChunksPerTick   = 0.01
+	maxChunksPerTick   = 64
+	maxUnackedBatches  = 10
+
+	// chunkUnloadInterval is how often chunks nobody sees are unloaded, in ticks
+	chunkUnloadInterval = 10 * TicksPerSecond
+)
+
+// chunkStreamer tracks which chunks a client has and decides what to send
+// next. Chunks are sent in batches, one per tick at most, sized by the rate
+// the client reports when it acknowledges a batch. It is used from the tick
+// goroutine only.
+type chunkStreamer struct {
+	center       world.ChunkPos
+	viewDistance int
+
+	// sent holds chunks the client has or that are queued to be sent
+	sent  map[world.ChunkPos]struct{}
+	queue []world.ChunkPos
+
+	chunksPerTick float64
+	quota         float64
+	unacked       int
+	maxUnacked    int
+}
+
+func newChunkStreamer(center world.ChunkPos, viewDistance int) *chunkStreamer {
+	cs := &chunkStreamer{
+		center:        center,
+		viewDistance:  clampViewDistance(viewDistance),
+		sent:          map[world.ChunkPos]struct{}{},
+		chunksPerTick: startChunksPerTick,
+		maxUnacked:    1,
+	}
+	cs.enqueue()
+	return cs
+}
+
+func clampViewDistance(d int) int {
+	return min(max(d, minViewDistance), maxViewDistance)
+}
+
+// inViewDistance reports whether pos is visible from center, it is the
+// vanilla cylindrical check with one chunk of slack
+func inViewDistance(center, pos world.ChunkPos, viewDistance int) bool {
+	dx := max(0, abs(int(pos.X-center.X))-1)
+	dz := max(0, abs(int(pos.Z-center.Z))-1)
+	return dx*dx+dz*dz < viewDistance*viewDistance
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// spiral returns chunks within radius of center walking rings outwards
+func spiral(center world.ChunkPos, radius int) []world.ChunkPos {
+	res := make([]world.ChunkPos, 0, (2*radius+1)*(2*radius+1))
+	res = append(res, center)
+	for r := int32(1); r <= int32(radius); r++ {
+		// start at the north west corner and walk clockwise
+		x, z := center.X-r, center.Z-r
+		for _, step := range [4][2]int32{{1, 0}, {0, 1}, {-1, 0}, {0, -1}} {
+			for range 2 * r {
+				res = append(res, world.ChunkPos{X: x, Z: z})
+				x, z = x+step[0], z+step[1]
+			}
+		}
+	}
+	return res
+}
+
+// enqueue queues every visible chunk the client doesn't have yet
+func (cs *chunkStreamer) enqueue() {
+	cs.queue = cs.queue[:0]
+	for _, pos := range spiral(cs.center, cs.viewDistance+1) {
+		if !inViewDistance(cs.center, pos, cs.viewDistance) {
+			continue
+		}
+		if _, ok := cs.sent[pos]; ok {
+			continue
+		}
+		cs.queue = append(cs.queue, pos)
+	}
+}
+
+// forget drops chunks out of view and returns them so the client can unload them
+func (cs *chunkStreamer) forget() []world.ChunkPos {
+	var res []world.ChunkPos
+	for pos := range cs.sent {
+		if !inViewDistance(cs.center, pos, cs.viewDistance) {
+			delete(cs.sent, pos)
+			res = append(res, pos)
+		}
+	}
+	return res
+}
+
+// SetCenter moves the player to chunk pos and returns chunks to unload
+func (cs *chunkStreamer) SetCenter(pos world.ChunkPos) []world.ChunkPos {
+	if pos == cs.center {
+		return nil
+	}
+	cs.center = pos
+	unload := cs.forget()
+	cs.enqueue()
+	return unload
+}
+
+// SetViewDistance changes the radius and returns chunks to unload
+func (cs *chunkStreamer) SetViewDistance(d int) []world.ChunkPos {
+	d = clampViewDistance(d)
+	if d == cs.viewDistance {
+		return nil
+	}
+	cs.viewDistance = d
+	unload := cs.forget()
+	cs.enqueue()
+	return unload
+}
+
+// Acknowledge handles Chunk Batch Received with the rate the client wants
+func (cs *chunkStreamer) Acknowledge(chunksPerTick float32) {
+	cs.unacked = max(cs.unacked-1, 0)
+	rate := float64(chunksPerTick)
+	if math.IsNaN(rate) {
+		rate = minChunksPerTick
+	}
+	cs.chunksPerTick = min(max(rate, minChunksPerTick), maxChunksPerTick)
+	if cs.unacked == 0 {
+		cs.quota = 1
+	}
+	cs.maxUnacked = maxUnackedBatches
+}
+
+// NextBatch is called once per tick and returns chunks to send now, they are
+// considered sent right away. Chunks that are not ready yet keep their place
+// in the queue.
+func (cs *chunkStreamer) NextBatch(ready func(pos world.ChunkPos) bool) []world.ChunkPos {
+	if cs.unacked >= cs.maxUnacked || len(cs.queue) == 0 {
+		return nil
+	}
+	cs.quota = min(cs.quota+cs.chunksPerTick, max(1, cs.chunksPerTick))
+	if cs.quota < 1 {
+		return nil
+	}
+	n := int(cs.quota)
+	var batch []world.ChunkPos
+	rest := cs.queue[:0]
+	for _, pos := range cs.queue {
+		if len(batch) < n && ready(pos) {
+			batch = append(batch, pos)
+			cs.sent[pos] = struct{}{}
+			continue
+		}
+		rest = append(rest, pos)
+	}
+	cs.queue = rest
+	if len(batch) == 0 {
+		return nil
+	}
+	cs.unacked++
+	cs.quota -= float64(len(batch))
+	return batch
+}
+
+// Upcoming returns up to n chunks that will be sent next
+func (cs *chunkStreamer) Upcoming(n int) []world.ChunkPos {
+	return cs.queue[:min(n, len(cs.queue))]
+}
+
+// Has reports whether chunk pos was sent to the client, chunks still queued
+// are sent as they are when their turn comes
+func (cs *chunkStreamer) Has(pos world.ChunkPos) bool {
+	_, ok := cs.sent[pos]
+	return ok
+}
+
+func (cs *chunkStreamer) Center() world.ChunkPos {
+	return cs.center
+}
+
+func (cs *chunkStreamer) ViewDistance() int {
+	return cs.viewDistance
+}
+
+// unloadChunks saves and drops loaded chunks out of view of every player,
+// the spawn chunks preloaded at start stay loaded
+func (s *Server) unloadChunks() {
+	spawn := world.ChunkPosOf(s.world.Spawn().X, s.world.Spawn().Z)
+	radius := s.cfg.Level.PreloadRadius
+	for _, pos := range s.world.LoadedChunks() {
+		if abs(int(pos.X-spawn.X)) <= radius && abs(int(pos.Z-spawn.Z)) <= radius || s.chunkInView(pos) {
+			continue
+		}
+		s.unloadContainers(pos)
+		err := s.world.Unload(pos.X, pos.Z)
+		if err != nil {
+			log.Err(err).Int32("x", pos.X).Int32("z", pos.Z).Msg("failed to save unloaded chunk")
+		}
+	}
+}
+
+// chunkInView reports whether any player sees the chunk at pos
+func (s *Server) chunkInView(pos world.ChunkPos) bool {
+	for session := range s.sessions {
+		if session.chunks != nil && inViewDistance(session.chunks.Center(), pos, session.chunks.viewDistance) {
+			return true
+		}
+	}
+	return false
+}
+
+// unloadContainers writes containers in use of the chunk at pos to their
+// block entities and stops ticking them
+func (s *Server) unloadContainers(pos world.ChunkPos) {
+	for p, c := range s.containers {
+		if world.ChunkPosOf(p.X, p.Z) != pos {
+			continue
+		}
+		if c.dirty {
+			s.saveContainer(c)
+		}
+		delete(s.containers, p)
+		for viewer := range c.viewers {
+			viewer.forceCloseWindow()
+		}
+	}
+}
+
+// saveWorld writes the chunks changed since they were last saved
+func (s *Server) saveWorld() {
+	err := s.world.Save()
+	if err != nil {
+		log.Err(err).Msg("failed to save world")
+	}
+}
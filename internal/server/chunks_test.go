@@ -0,0 +1,107 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/BinaryArchaism/mc-srv/internal/world/anvil"
+	"github.com/BinaryArchaism/mc-srv/internal/world/generator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpiral(t *testing.T) {
+	center := world.ChunkPos{X: 3, Z: -2}
+	res := spiral(center, 2)
+	require.Len(t, res, 25)
+	require.Equal(t, center, res[0])
+
+	seen := map[world.ChunkPos]bool{}
+	for i, pos := range res {
+		require.False(t, seen[pos], "duplicate %v", pos)
+		seen[pos] = true
+		ring := max(abs(int(pos.X-center.X)), abs(int(pos.Z-center.Z)))
+		if i > 0 {
+			prev := res[i-1]
+			prevRing := max(abs(int(prev.X-center.X)), abs(int(prev.Z-center.Z)))
+			require.GreaterOrEqual(t, ring, prevRing)
+		}
+	}
+}
+
+func TestChunkStreamer(t *testing.T) {
+	ready := func(world.ChunkPos) bool { return true }
+	cs := newChunkStreamer(world.ChunkPos{}, 2)
+	total := len(cs.queue)
+	require.Equal(t, world.ChunkPos{}, cs.queue[0])
+
+	// the first batch is limited by the start rate and waits for acknowledgement
+	batch := cs.NextBatch(ready)
+	require.Len(t, batch, startChunksPerTick)
+	require.Nil(t, cs.NextBatch(ready))
+
+	cs.Acknowledge(2.5)
+	require.Len(t, cs.NextBatch(ready), 2)
+	require.Len(t, cs.NextBatch(ready), 2)
+	require.Len(t, cs.NextBatch(ready), 2)
+
+	for len(cs.queue) > 0 {
+		require.NotEmpty(t, cs.NextBatch(ready))
+	}
+	require.Len(t, cs.sent, total)
+	require.Empty(t, cs.Upcoming(10))
+
+	unload := cs.SetCenter(world.ChunkPos{X: 10})
+	require.Len(t, unload, total)
+	for _, pos := range unload {
+		require.False(t, inViewDistance(world.ChunkPos{X: 10}, pos, 2))
+	}
+	require.Len(t, cs.queue, total)
+
+	// chunks that are not loaded yet are skipped but stay queued
+	skip := cs.Upcoming(1)[0]
+	batch = cs.NextBatch(func(pos world.ChunkPos) bool { return pos != skip })
+	require.NotContains(t, batch, skip)
+	require.Equal(t, skip, cs.Upcoming(1)[0])
+
+	require.Empty(t, cs.SetViewDistance(5))
+	require.Greater(t, len(cs.queue), total)
+	require.Empty(t, cs.SetViewDistance(100))
+	require.Equal(t, maxViewDistance, cs.ViewDistance())
+	cs.SetViewDistance(0)
+	require.Equal(t, minViewDistance, cs.ViewDistance())
+}
+
+func TestUnloadChunks(t *testing.T) {
+	srv := newTestServer(t)
+	srv.world = world.New(world.Options{
+		Seed:      42,
+		Generator: generator.NewVoid(),
+		Storage:   anvil.NewStorage(t.TempDir(), world.OverworldMinY, world.OverworldHeight),
+	})
+	s := addTestPlayer(srv, 1, "alice")
+	s.chunks = newChunkStreamer(world.ChunkPos{X: 40}, 2)
+	far := world.BlockPos{X: 20 * 16, Y: 64, Z: 20 * 16}
+	_, err := srv.world.Chunk(20, 20)
+	require.NoError(t, err)
+	require.NoError(t, srv.SetBlock(far, world.NewBlockState("minecraft:chest", nil)))
+	c := srv.blockContainer(far, containerKinds["minecraft:chest"])
+	c.items[0] = stack("diamond", 3)
+	c.dirty = true
+
+	// chunks in view and the spawn chunks stay, others are saved and dropped
+	_, err = srv.world.Chunk(41, 0)
+	require.NoError(t, err)
+	_, err = srv.world.Chunk(1, 1)
+	require.NoError(t, err)
+	srv.unloadChunks()
+	require.NotNil(t, srv.world.LoadedChunk(41, 0))
+	require.NotNil(t, srv.world.LoadedChunk(1, 1))
+	require.Nil(t, srv.world.LoadedChunk(20, 20))
+	require.Empty(t, srv.containers)
+
+	chunk, err := srv.world.Chunk(20, 20)
+	require.NoError(t, err)
+	items := chunk.BlockEntity(far).List("Items").Compounds()
+	require.Len(t, items, 1)
+	require.Equal(t, "minecraft:diamond", items[0].String("id"))
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/rs/zerolog/log"
+)
+
+// Drag click stages and kinds, the button of a drag click holds both
+const (
+	dragStart = iota
+	dragAdd
+	dragEnd
+)
+
+const (
+	dragSplit = iota
+	dragOne
+	dragClone
+)
+
+// maxQuickCrafts stops shift clicking a crafting result that never runs out
+const maxQuickCrafts = 64
+
+// dragState collects the slots of a drag click until the mouse is released
+type dragState struct {
+	active bool
+	kind   int
+	slots  []int
+}
+
+// click applies a click on slot of window w, slot is checked by the caller
+func (s *Session) click(w *window, slot, button int, mode int32) {
+	if mode != protocol.ClickDrag {
+		s.drag = dragState{}
+	}
+	switch mode {
+	case protocol.ClickPickup:
+		if button > 1 {
+			return
+		}
+		s.clickPickup(w, slot, button == 0)
+	case protocol.ClickQuickMove:
+		if slot != outsideSlot {
+			s.quickMove(w, slot)
+		}
+	case protocol.ClickSwap:
+		s.clickSwap(w, slot, button)
+	case protocol.ClickClone:
+		stack := stackAt(w, slot)
+		if s.player.GameMode == Creative && s.carried.IsEmpty() && stack != nil && !stack.IsEmpty() {
+			s.carried = stack.withCount(stack.MaxStack())
+		}
+	case protocol.ClickThrow:
+		// dropping items is not supported, the client is resynced
+	case protocol.ClickDrag:
+		s.clickDrag(w, slot, button)
+	case protocol.ClickPickupAll:
+		if slot != outsideSlot && button <= 1 {
+			s.pickupAll(w, button == 1)
+		}
+	default:
+		log.Warn().Str("player", s.player.Name).Int32("mode", mode).Msg("invalid click mode")
+	}
+}
+
+func stackAt(w *window, slot int) *ItemStack {
+	slots := w.menu.slots()
+	if slot < 0 || slot >= len(slots) {
+		return nil
+	}
+	return slots[slot]
+}
+
+// clickPickup picks up, places or swaps stacks with the cursor. A right
+// click takes half a stack or places a single item.
+func (s *Session) clickPickup(w *window, slot int, left bool) {
+	stack := stackAt(w, slot)
+	if stack == nil {
+		// dropping the carried stack outside of the window is not supported
+		return
+	}
+	m := w.menu
+	carried := &s.carried
+	if m.result(slot) {
+		s.takeResult(w, slot)
+		return
+	}
+	switch {
+	case stack.IsEmpty() && carried.IsEmpty():
+	case stack.IsEmpty():
+		if !m.mayPlace(slot, *carried) {
+			return
+		}
+		n := min(carried.Count, m.slotLimit(slot, *carried))
+		if !left {
+			n = 1
+		}
+		*stack = carried.withCount(n)
+		carried.Count -= n
+	case carried.IsEmpty():
+		n := stack.Count
+		if !left {
+			n = (n + 1) / 2
+		}
+		*carried = stack.withCount(n)
+		stack.Count -= n
+	case stack.Stacks(*carried):
+		if !m.mayPlace(slot, *carried) {
+			// takes from an output slot into the cursor
+			n := min(stack.Count, carried.MaxStack()-carried.Count)
+			if n > 0 {
+				carried.Count += n
+				stack.Count -= n
+			}
+			break
+		}
+		n := carried.Count
+		if !left {
+			n = 1
+		}
+		n = min(n, m.slotLimit(slot, *carried)-stack.Count)
+		if n > 0 {
+			stack.Count += n
+			carried.Count -= n
+		}
+	case m.mayPlace(slot, *carried) && carried.Count <= m.slotLimit(slot, *carried):
+		*stack, *carried = *carried, *stack
+	}
+	clearEmpty(stack)
+	clearEmpty(carried)
+}
+
+// takeResult moves a whole crafting result into the cursor
+func (s *Session) takeResult(w *window, slot int) {
+	stack := stackAt(w, slot)
+	carried := &s.carried
+	if stack.IsEmpty() {
+		return
+	}
+	switch {
+	case carried.IsEmpty():
+		*carried = *stack
+	case carried.Stacks(*stack) && carried.Count+stack.Count <= carried.MaxStack():
+		carried.Count += stack.Count
+	default:
+		return
+	}
+	*stack = ItemStack{}
+	w.menu.taken(slot)
+}
+
+func clearEmpty(stack *ItemStack) {
+	if stack.IsEmpty() {
+		*stack = ItemStack{}
+	}
+}
+
+// moveTo moves as much of stack as fits into slots of w, first onto equal
+// stacks and then into empty slots
+func moveTo(w *window, stack *ItemStack, slots []int) {
+	m := w.menu
+	all := m.slots()
+	for _, i := range slots {
+		slot := all[i]
+		if stack.IsEmpty() {
+			break
+		}
+		if slot.IsEmpty() || !slot.Stacks(*stack) || !m.mayPlace(i, *stack) {
+			continue
+		}
+		n := min(stack.Count, m.slotLimit(i, *stack)-slot.Count)
+		if n > 0 {
+			slot.Count += n
+			stack.Count -= n
+		}
+	}
+	for _, i := range slots {
+		slot := all[i]
+		if stack.IsEmpty() {
+			break
+		}
+		if !slot.IsEmpty() || !m.mayPlace(i, *stack) {
+			continue
+		}
+		n := min(stack.Count, m.slotLimit(i, *stack))
+		*slot = stack.withCount(n)
+		stack.Count -= n
+	}
+	clearEmpty(stack)
+}
+
+// fits reports whether the whole stack can be moved into slots of w
+func fits(w *window, stack ItemStack, slots []int) bool {
+	m := w.menu
+	all := m.slots()
+	room := 0
+	for _, i := range slots {
+		slot := all[i]
+		switch {
+		case !m.mayPlace(i, stack):
+		case slot.IsEmpty():
+			room += m.slotLimit(i, stack)
+		case slot.Stacks(stack):
+			room += max(m.slotLimit(i, stack)-slot.Count, 0)
+		}
+		if room >= stack.Count {
+			return true
+		}
+	}
+	return false
+}
+
+func slotRange(start, end int) []int {
+	res := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		res = append(res, i)
+	}
+	return res
+}
+
+// quickMove is a shift click, the menu decides where the stack goes. A
+// crafting result is crafted again for as long as its items fit.
+func (s *Session) quickMove(w *window, slot int) {
+	stack := stackAt(w, slot)
+	if stack == nil || stack.IsEmpty() {
+		return
+	}
+	m := w.menu
+	if !m.result(slot) {
+		moveTo(w, stack, m.quickMove(slot, *stack))
+		return
+	}
+	item := stack.Item
+	for range maxQuickCrafts {
+		if stack.IsEmpty() || stack.Item != item {
+			return
+		}
+		targets := m.quickMove(slot, *stack)
+		if !fits(w, *stack, targets) {
+			return
+		}
+		moveTo(w, stack, targets)
+		m.taken(slot)
+		m.changed()
+	}
+}
+
+// clickSwap swaps a slot with a hotbar slot or the off hand of the player
+func (s *Session) clickSwap(w *window, slot, button int) {
+	inv := &s.player.Inventory
+	var other *ItemStack
+	switch {
+	case button >= 0 && button < hotbarSize:
+		other = &inv.Slots[hotbarSlotStart+button]
+	case button == offHandSwapButton:
+		other = &inv.Slots[offHandSlot]
+	default:
+		return
+	}
+	stack := stackAt(w, slot)
+	if stack == nil || stack == other {
+		return
+	}
+	m := w.menu
+	if m.result(slot) {
+		if other.IsEmpty() && !stack.IsEmpty() {
+			*other, *stack = *stack, ItemStack{}
+			m.taken(slot)
+		}
+		return
+	}
+	switch {
+	case stack.IsEmpty() && other.IsEmpty():
+	case other.IsEmpty():
+		*other, *stack = *stack, ItemStack{}
+	case stack.IsEmpty():
+		if !m.mayPlace(slot, *other) {
+			return
+		}
+		n := min(other.Count, m.slotLimit(slot, *other))
+		*stack = other.withCount(n)
+		other.Count -= n
+		clearEmpty(other)
+	case m.mayPlace(slot, *other) && other.Count <= m.slotLimit(slot, *other):
+		*stack, *other = *other, *stack
+	}
+}
+
+// clickDrag handles the stages of dragging the carried stack over slots
+func (s *Session) clickDrag(w *window, slot, button int) {
+	stage, kind := button&3, button>>2&3
+	drag := &s.drag
+	switch stage {
+	case dragStart:
+		*drag = dragState{}
+		if s.carried.IsEmpty() || kind > dragClone || kind == dragClone && s.player.GameMode != Creative {
+			return
+		}
+		*drag = dragState{active: true, kind: kind}
+	case dragAdd:
+		stack := stackAt(w, slot)
+		if !drag.active || kind != drag.kind || stack == nil || slices.Contains(drag.slots, slot) {
+			return
+		}
+		if !w.menu.mayPlace(slot, s.carried) || !stack.IsEmpty() && !stack.Stacks(s.carried) {
+			return
+		}
+		if kind != dragClone && len(drag.slots) >= s.carried.Count {
+			return
+		}
+		drag.slots = append(drag.slots, slot)
+	case dragEnd:
+		if !drag.active || kind != drag.kind {
+			*drag = dragState{}
+			return
+		}
+		slots := drag.slots
+		*drag = dragState{}
+		if len(slots) == 1 && kind != dragClone {
+			s.clickPickup(w, slots[0], kind == dragSplit)
+			return
+		}
+		s.spread(w, slots, kind)
+	default:
+		*drag = dragState{}
+	}
+}
+
+// spread places the carried stack over the dragged slots
+func (s *Session) spread(w *window, slots []int, kind int) {
+	carried := &s.carried
+	if carried.IsEmpty() || len(slots) == 0 {
+		return
+	}
+	per := 1
+	switch kind {
+	case dragSplit:
+		per = carried.Count / len(slots)
+	case dragClone:
+		per = carried.MaxStack()
+	}
+	template := *carried
+	all := w.menu.slots()
+	for _, i := range slots {
+		stack := all[i]
+		if kind != dragClone && carried.IsEmpty() {
+			break
+		}
+		if !stack.IsEmpty() && !stack.Stacks(template) {
+			continue
+		}
+		n := min(per, w.menu.slotLimit(i, template)-stack.Count)
+		if kind != dragClone {
+			n = min(n, carried.Count)
+		}
+		if n <= 0 {
+			continue
+		}
+		if stack.IsEmpty() {
+			*stack = template.withCount(n)
+		} else {
+			stack.Count += n
+		}
+		if kind != dragClone {
+			carried.Count -= n
+		}
+	}
+	clearEmpty(carried)
+}
+
+// pickupAll is a double click collecting equal stacks into the cursor,
+// partial stacks are taken before full ones
+func (s *Session) pickupAll(w *window, backwards bool) {
+	carried := &s.carried
+	if carried.IsEmpty() {
+		return
+	}
+	all := w.menu.slots()
+	order := slotRange(0, len(all))
+	if backwards {
+		slices.Reverse(order)
+	}
+	maxStack := carried.MaxStack()
+	for _, full := range []bool{false, true} {
+		for _, i := range order {
+			stack := all[i]
+			if carried.Count >= maxStack {
+				return
+			}
+			if w.menu.result(i) || stack.IsEmpty() || !stack.Stacks(*carried) ||
+				(stack.Count >= stack.MaxStack()) != full {
+				continue
+			}
+			n := min(stack.Count, maxStack-carried.Count)
+			stack.Count -= n
+			carried.Count += n
+			clearEmpty(stack)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func stack(item string, count int) ItemStack {
+	return ItemStack{Item: "minecraft:" + item, Count: count}
+}
+
+func TestClickContainer(t *testing.T) {
+	s := newTestSession(1, 0, 0)
+	w := s.inventoryWindow()
+	inv := &s.player.Inventory
+	inv.Slots[mainSlotsStart] = stack("stone", 10)
+
+	// right click takes half, left click places all
+	s.click(w, mainSlotsStart, 1, protocol.ClickPickup)
+	require.Equal(t, stack("stone", 5), s.carried)
+	require.Equal(t, 5, inv.Slots[mainSlotsStart].Count)
+	s.click(w, mainSlotsStart+1, 0, protocol.ClickPickup)
+	require.True(t, s.carried.IsEmpty())
+	require.Equal(t, stack("stone", 5), inv.Slots[mainSlotsStart+1])
+
+	// armor slots take matching equipment only, one at a time
+	s.carried = stack("iron_helmet", 1)
+	s.click(w, chestSlot, 0, protocol.ClickPickup)
+	require.Equal(t, stack("iron_helmet", 1), s.carried)
+	s.click(w, headSlot, 0, protocol.ClickPickup)
+	require.Equal(t, stack("iron_helmet", 1), inv.Slots[headSlot])
+	require.True(t, s.carried.IsEmpty())
+
+	// shift clicks move main inventory to the hotbar and equipment back on
+	s.click(w, mainSlotsStart, 0, protocol.ClickQuickMove)
+	require.Equal(t, stack("stone", 5), inv.Slots[hotbarSlotStart])
+	require.True(t, inv.Slots[mainSlotsStart].IsEmpty())
+	s.click(w, headSlot, 0, protocol.ClickQuickMove)
+	require.Equal(t, stack("iron_helmet", 1), inv.Slots[mainSlotsStart])
+	s.click(w, mainSlotsStart, 0, protocol.ClickQuickMove)
+	require.Equal(t, stack("iron_helmet", 1), inv.Slots[headSlot])
+
+	// number keys swap with the hotbar, 40 with the off hand
+	s.click(w, mainSlotsStart+1, 2, protocol.ClickSwap)
+	require.Equal(t, stack("stone", 5), inv.Slots[hotbarSlotStart+2])
+	s.click(w, hotbarSlotStart+2, offHandSwapButton, protocol.ClickSwap)
+	require.Equal(t, stack("stone", 5), inv.Slots[offHandSlot])
+
+	// double click collects equal stacks
+	inv.Slots[mainSlotsStart+3] = stack("stone", 20)
+	s.click(w, offHandSlot, 0, protocol.ClickPickup)
+	s.click(w, offHandSlot, 0, protocol.ClickPickupAll)
+	require.Equal(t, stack("stone", 30), s.carried)
+	require.True(t, inv.Slots[mainSlotsStart+3].IsEmpty())
+
+	// closing the inventory returns the carried stack
+	s.handleCloseContainer(protocol.CloseContainerPacket{})
+	require.True(t, s.carried.IsEmpty())
+	require.Equal(t, stack("stone", 30), inv.Slots[hotbarSlotStart])
+}
+
+func TestClickContainer_Drag(t *testing.T) {
+	s := newTestSession(1, 0, 0)
+	w := s.inventoryWindow()
+	inv := &s.player.Inventory
+	drag := func(kind int, slots ...int) {
+		s.click(w, outsideSlot, kind<<2|dragStart, protocol.ClickDrag)
+		for _, slot := range slots {
+			s.click(w, slot, kind<<2|dragAdd, protocol.ClickDrag)
+		}
+		s.click(w, outsideSlot, kind<<2|dragEnd, protocol.ClickDrag)
+	}
+
+	s.carried = stack("dirt", 10)
+	inv.Slots[mainSlotsStart+1] = stack("dirt", 62)
+	drag(dragSplit, mainSlotsStart, mainSlotsStart+1, mainSlotsStart+2)
+	require.Equal(t, 3, inv.Slots[mainSlotsStart].Count)
+	require.Equal(t, 64, inv.Slots[mainSlotsStart+1].Count)
+	require.Equal(t, 3, inv.Slots[mainSlotsStart+2].Count)
+	require.Equal(t, 2, s.carried.Count)
+
+	drag(dragOne, mainSlotsStart, mainSlotsStart+3)
+	require.Equal(t, 4, inv.Slots[mainSlotsStart].Count)
+	require.Equal(t, 1, inv.Slots[mainSlotsStart+3].Count)
+	require.True(t, s.carried.IsEmpty())
+
+	// cloning drags are creative only
+	s.carried = stack("dirt", 1)
+	drag(dragClone, mainSlotsStart+4, mainSlotsStart+5)
+	require.True(t, inv.Slots[mainSlotsStart+4].IsEmpty())
+	s.player.GameMode = Creative
+	drag(dragClone, mainSlotsStart+4, mainSlotsStart+5)
+	require.Equal(t, stack("dirt", 64), inv.Slots[mainSlotsStart+4])
+	require.Equal(t, stack("dirt", 64), inv.Slots[mainSlotsStart+5])
+	require.Equal(t, stack("dirt", 1), s.carried)
+}
+
+func TestHandleClickContainer_Sync(t *testing.T) {
+	s := addTestPlayer(newTestServer(t), 1, "alice")
+	w := s.inventoryWindow()
+	s.player.Inventory.Slots[mainSlotsStart] = stack("stone", 4)
+	s.sendWindow(w)
+	queuedIDs(t, s)
+
+	// a correct prediction needs no updates
+	stone := stack("stone", 4).protocol()
+	s.handleClickContainer(protocol.ClickContainerPacket{
+		StateID: w.stateID,
+		Slot:    mainSlotsStart,
+		Mode:    protocol.ClickPickup,
+		Changed: []protocol.ChangedSlot{{Slot: mainSlotsStart}},
+		Carried: stone,
+	})
+	require.Empty(t, queuedIDs(t, s))
+
+	// a wrong prediction is corrected slot by slot
+	s.handleClickContainer(protocol.ClickContainerPacket{
+		StateID: w.stateID,
+		Slot:    mainSlotsStart + 1,
+		Mode:    protocol.ClickPickup,
+		Changed: []protocol.ChangedSlot{{Slot: mainSlotsStart + 1, Item: stack("stone", 3).protocol()}},
+		Carried: stack("stone", 1).protocol(),
+	})
+	require.Equal(t, []int{protocol.PlaySetContainerSlotID, protocol.PlaySetContainerSlotID}, queuedIDs(t, s))
+	require.Equal(t, stack("stone", 4), s.player.Inventory.Slots[mainSlotsStart+1])
+
+	// a stale state id resyncs everything
+	s.handleClickContainer(protocol.ClickContainerPacket{StateID: w.stateID - 1, Slot: outsideSlot})
+	require.Equal(t, []int{protocol.PlaySetContainerContentID}, queuedIDs(t, s))
+
+	// creative players set slots directly
+	s.handleSetCreativeSlot(protocol.SetCreativeSlotPacket{Slot: headSlot, Item: stone})
+	require.Equal(t, []int{protocol.PlaySetContainerContentID}, queuedIDs(t, s))
+	s.player.GameMode = Creative
+	s.handleSetCreativeSlot(protocol.SetCreativeSlotPacket{Slot: headSlot, Item: stone})
+	require.Equal(t, stack("stone", 4), s.player.Inventory.Slots[headSlot])
+	require.Empty(t, queuedIDs(t, s))
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/rs/zerolog/log"
+)
+
+// Commands returns the command tree, commands must be registered before Run
+func (s *Server) Commands() *command.Dispatcher {
+	return s.commands
+}
+
+// console runs commands typed into the server console with every permission
+type console struct {
+	server *Server
+}
+
+// Console returns the source of commands run by the server itself
+func (s *Server) Console() command.Source {
+	return console{server: s}
+}
+
+func (console) Name() string {
+	return "Server"
+}
+
+func (console) HasPermission(int) bool {
+	return true
+}
+
+func (console) SendMessage(c text.Component) {
+	log.Info().Msg(c.String())
+}
+
+// Position is the world spawn
+func (c console) Position() (x, y, z float64) {
+	spawn := c.server.world.Spawn()
+	return float64(spawn.X) + 0.5, float64(spawn.Y), float64(spawn.Z) + 0.5
+}
+
+// rconSource runs commands for an RCON client and collects their output
+type rconSource struct {
+	console
+	out *strings.Builder
+}
+
+func (rconSource) Name() string {
+	return "Rcon"
+}
+
+func (r rconSource) SendMessage(c text.Component) {
+	if r.out.Len() > 0 {
+		r.out.WriteByte('\n')
+	}
+	r.out.WriteString(c.String())
+}
+
+// RemoteCommand runs input on the next tick with every permission and
+// returns what the command replied, it blocks until then
+func (s *Server) RemoteCommand(input string) string {
+	src := rconSource{console: console{server: s}, out: &strings.Builder{}}
+	if !s.call(func() { s.RunCommand(src, strings.TrimPrefix(input, "/")) }) {
+		return ""
+	}
+	return src.out.String()
+}
+
+// ConsoleCommand queues line, with or without the leading slash, to run as
+// the console on the next tick
+func (s *Server) ConsoleCommand(line string) {
+	input := strings.TrimPrefix(line, "/")
+	s.Submit(func() {
+		s.RunCommand(s.Console(), input)
+	})
+}
+
+// CompleteCommand returns console completions for the end of input and where
+// they start, it is safe to call from any goroutine once commands are registered
+func (s *Server) CompleteCommand(input string) (int, []string) {
+	return s.commands.Suggest(s.Console(), input)
+}
+
+// RunCommand executes input, given without the leading slash, as src and
+// reports failures to it. It must be called from the tick goroutine.
+func (s *Server) RunCommand(src command.Source, input string) {
+	e := &CommandEvent{Source: src, Command: input}
+	event.Fire(&s.events, e)
+	if e.Cancelled {
+		return
+	}
+	err := s.commands.Execute(src, e.Command)
+	if err == nil {
+		return
+	}
+	var se *command.SyntaxError
+	if !errors.As(err, &se) {
+		src.SendMessage(text.Colored(err.Error(), "red"))
+		return
+	}
+	src.SendMessage(text.Colored(se.Message, "red"))
+	if se.Input == "" {
+		return
+	}
+	here := text.Colored("<--[HERE]", "red")
+	here.Italic = true
+	rest := text.Colored(se.Input[min(se.Cursor, len(se.Input)):], "red")
+	rest.Underlined = true
+	src.SendMessage(text.Colored(se.Context(), "gray").Append(rest, here))
+}
+
+func (s *Session) Name() string {
+	return s.player.Name
+}
+
+func (s *Session) HasPermission(level int) bool {
+	return s.player.PermissionLevel >= level
+}
+
+func (s *Session) Position() (x, y, z float64) {
+	return s.player.X, s.player.Y, s.player.Z
+}
+
+// sendCommands sends the commands the player may run, it must be sent again
+// when the permission level changes
+func (s *Session) sendCommands() {
+	nodes, root := s.server.commands.Nodes(s)
+	s.queue(&protocol.CommandsPacket{Nodes: nodes, Root: root})
+}
+
+func (s *Session) handleCommand(cmd string) {
+	s.chatSpam += chatSpamIncrement
+	if s.chatSpam > chatSpamLimit && !s.HasPermission(command.LevelGameMaster) {
+		s.Kick("Kicked for spamming")
+		return
+	}
+	if !validChatMessage(cmd) {
+		s.Kick("Illegal characters in chat")
+		return
+	}
+	log.Info().Str("player", s.player.Name).Str("command", cmd).Msg("issued server command")
+	s.server.RunCommand(s, cmd)
+}
+
+func (s *Session) handleSignedCommand(p protocol.SignedChatCommandPacket) {
+	_, err := s.lastSeen.ApplyUpdate(int(p.MessageCount), p.Acknowledged[:])
+	if err != nil {
+		log.Warn().Err(err).Str("player", s.player.Name).Msg("rejected chat command")
+		s.Kick("Chat message validation failure")
+		return
+	}
+	s.handleCommand(p.Command)
+}
+
+func (s *Session) handleCommandSuggestions(p protocol.CommandSuggestionsRequestPacket) {
+	input := strings.TrimPrefix(p.Text, "/")
+	offset := len(p.Text) - len(input)
+	start, matches := s.server.commands.Suggest(s, input)
+	res := &protocol.CommandSuggestionsPacket{
+		TransactionID: p.TransactionID,
+		Start:         int32(start + offset),
+		Length:        int32(len(input) - start),
+		Matches:       make([]protocol.Suggestion, len(matches)),
+	}
+	for i, m := range matches {
+		res.Matches[i].Match = m
+	}
+	s.queue(res)
+}
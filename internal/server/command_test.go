@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/recipe"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/BinaryArchaism/mc-srv/internal/world/generator"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer returns a server with built-in commands and player lists in a temporary directory
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Access.OpsFile = filepath.Join(dir, "ops.json")
+	cfg.Access.BansFile = filepath.Join(dir, "banned-players.json")
+	cfg.Access.WhitelistFile = filepath.Join(dir, "whitelist.json")
+	lists, err := loadAccessLists(cfg.Access)
+	require.NoError(t, err)
+	srv := &Server{
+		cfg:        cfg,
+		world:      world.New(world.Options{Seed: 42, Generator: generator.NewVoid()}),
+		sessions:   map[*Session]struct{}{},
+		commands:   command.NewDispatcher(),
+		ops:        lists.ops,
+		bans:       lists.bans,
+		whitelist:  lists.whitelist,
+		stopped:    make(chan struct{}),
+		recipes:    recipe.Vanilla(),
+		containers: map[world.BlockPos]*blockContainer{},
+	}
+	srv.registerBuiltinCommands()
+	return srv
+}
+
+func addTestPlayer(srv *Server, id int32, name string) *Session {
+	s := newTestSession(id, 0, 0)
+	s.server = srv
+	s.player.Name = name
+	s.player.UUID = access.OfflineUUID(name)
+	s.outCh = make(chan []byte, 1)
+	srv.sessions[s] = struct{}{}
+	return s
+}
+
+func TestHandleCommand(t *testing.T) {
+	srv := newTestServer(t)
+	a := addTestPlayer(srv, 1, "alice")
+
+	a.handleCommand("help")
+	require.Equal(t, []string{"/help", "/list"}, queuedMessages(t, a))
+
+	a.handleCommand("op alice")
+	messages := queuedMessages(t, a)
+	require.Len(t, messages, 2)
+	require.Equal(t, "Unknown command", messages[0])
+
+	a.player.PermissionLevel = command.LevelOwner
+	a.handleCommand("help")
+	require.Contains(t, queuedMessages(t, a), "/stop")
+
+	a.handleCommandSuggestions(protocol.CommandSuggestionsRequestPacket{TransactionID: 7, Text: "/he"})
+	r := bytes.NewReader(a.outbound.Bytes())
+	p, err := protocol.ReadPacket(r)
+	require.NoError(t, err)
+	require.Equal(t, protocol.PlayCommandSuggestionsID, p.ID)
+	d := protocol.NewDecoder(p.Data)
+	require.Equal(t, []int32{7, 1, 2, 1}, []int32{d.VarInt(), d.VarInt(), d.VarInt(), d.VarInt()})
+	require.Equal(t, "help", d.String(16))
+}
+
+func TestBuiltinCommands(t *testing.T) {
+	srv := newTestServer(t)
+	a := addTestPlayer(srv, 1, "alice")
+	a.player.PermissionLevel = command.LevelOwner
+	b := addTestPlayer(srv, 2, "bob")
+
+	srv.RunCommand(a, "op bob")
+	require.Equal(t, []string{"Made bob a server operator"}, queuedMessages(t, a))
+	require.Equal(t, command.LevelOwner, b.player.PermissionLevel)
+	require.Equal(t, []string{"[alice: Made bob a server operator]"}, queuedMessages(t, b))
+	srv.RunCommand(a, "op bob")
+	require.Equal(t, []string{"Nothing changed. The player already is an operator"}, queuedMessages(t, a))
+	srv.RunCommand(a, "deop bob")
+	require.Equal(t, []string{"Made bob no longer a server operator"}, queuedMessages(t, a))
+	require.Equal(t, command.LevelAll, b.player.PermissionLevel)
+	queuedMessages(t, b)
+
+	srv.RunCommand(a, "gamemode creative bob")
+	require.Equal(t, Creative, b.player.GameMode)
+	require.Equal(t, []string{"Set bob's game mode to Creative Mode"}, queuedMessages(t, a))
+	require.Equal(t, []string{"Your game mode has been updated to Creative Mode"}, queuedMessages(t, b))
+
+	srv.RunCommand(srv.Console(), "gamemode survival bob")
+	require.Equal(t, Survival, b.player.GameMode)
+	require.Equal(t, []string{"[Server: Set bob's game mode to Survival Mode]"}, queuedMessages(t, a))
+
+	srv.RunCommand(a, "tp bob 10 64 -5")
+	require.Equal(t, []float64{10.5, 64, -4.5}, []float64{b.player.X, b.player.Y, b.player.Z})
+	require.Equal(t, []string{"Teleported bob to 10.500000, 64.000000, -4.500000"}, queuedMessages(t, a))
+	srv.RunCommand(a, "teleport bob")
+	require.Equal(t, []float64{10.5, 64, -4.5}, []float64{a.player.X, a.player.Y, a.player.Z})
+	require.Equal(t, []string{"Teleported alice to bob"}, queuedMessages(t, a))
+
+	srv.RunCommand(a, "give bob stone 70")
+	require.Equal(t, ItemStack{Item: "minecraft:stone", Count: 64}, b.player.Inventory.Slots[hotbarSlotStart])
+	require.Equal(t, ItemStack{Item: "minecraft:stone", Count: 6}, b.player.Inventory.Slots[hotbarSlotStart+1])
+	require.Equal(t, []string{"Gave 70 [stone] to bob"}, queuedMessages(t, a))
+	srv.RunCommand(a, "give bob nothing")
+	require.Equal(t, []string{"Unknown item 'minecraft:nothing'"}, queuedMessages(t, a))
+
+	srv.RunCommand(a, "time set noon")
+	require.Equal(t, int64(6000), srv.world.DayTime())
+	srv.RunCommand(a, "weather thunder 1d")
+	require.Equal(t, world.Thunder, srv.world.Weather())
+	require.Equal(t, []string{"Set the time to 6000", "Set the weather to rain & thunder"}, queuedMessages(t, a))
+
+	carol := access.Profile{UUID: access.OfflineUUID("carol"), Name: "carol"}
+	srv.RunCommand(a, "ban carol griefing")
+	require.Equal(t, []string{"Banned carol: griefing"}, queuedMessages(t, a))
+	reason, ok := srv.checkLogin(carol)
+	require.False(t, ok)
+	require.Equal(t, "You are banned from this server.\nReason: griefing", reason.String())
+	srv.RunCommand(a, "pardon carol")
+	require.Equal(t, []string{"Unbanned carol"}, queuedMessages(t, a))
+	_, ok = srv.checkLogin(carol)
+	require.True(t, ok)
+
+	srv.RunCommand(a, "whitelist on")
+	_, ok = srv.checkLogin(carol)
+	require.False(t, ok)
+	srv.RunCommand(a, "whitelist add carol")
+	_, ok = srv.checkLogin(carol)
+	require.True(t, ok)
+	require.Equal(t, []string{"Whitelist is now turned on", "Added carol to the whitelist"}, queuedMessages(t, a))
+
+	srv.RunCommand(a, "list")
+	require.Equal(t, []string{"There are 2 of a max of 100 players online: alice, bob"}, queuedMessages(t, a))
+
+	srv.RunCommand(a, "ban bob")
+	require.True(t, b.closing)
+	_, ok = srv.checkLogin(b.profile())
+	require.False(t, ok)
+
+	srv.RunCommand(a, "stop")
+	require.Equal(t, []string{"Banned bob: Banned by an operator.", "Stopping the server"}, queuedMessages(t, a))
+	select {
+	case <-srv.Done():
+	default:
+		t.Fatal("server wasn't stopped")
+	}
+}
+
+func TestRconSource(t *testing.T) {
+	srv := newTestServer(t)
+	src := rconSource{console: console{server: srv}, out: &strings.Builder{}}
+	srv.RunCommand(src, "seed")
+	srv.RunCommand(src, "gamemode creative")
+	require.Equal(t, "Seed: [42]\nAn entity is required to run this command here", src.out.String())
+}
@@ -0,0 +1,426 @@
+package server
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/recipe"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/rs/zerolog/log"
+)
+
+// Furnace window slots, the player main inventory and hotbar follow them
+const (
+	furnaceInput  = 0
+	furnaceFuel   = 1
+	furnaceOutput = 2
+	furnaceSize   = 3
+)
+
+// containerKind describes a block holding items
+type containerKind struct {
+	// entity is the block entity id
+	entity string
+	size   int
+	menu   int32
+	title  string
+}
+
+func (k containerKind) furnace() bool {
+	return k.menu == protocol.MenuFurnace
+}
+
+func (k containerKind) chest() bool {
+	return strings.HasSuffix(k.entity, "chest")
+}
+
+// containerKinds are the blocks players can open by name. Double chests
+// open as their clicked half.
+var containerKinds = map[string]containerKind{
+	"minecraft:chest":         {entity: "minecraft:chest", size: 27, menu: protocol.MenuGeneric9x3, title: "container.chest"},
+	"minecraft:trapped_chest": {entity: "minecraft:trapped_chest", size: 27, menu: protocol.MenuGeneric9x3, title: "container.chest"},
+	"minecraft:barrel":        {entity: "minecraft:barrel", size: 27, menu: protocol.MenuGeneric9x3, title: "container.barrel"},
+	"minecraft:furnace":       {entity: "minecraft:furnace", size: furnaceSize, menu: protocol.MenuFurnace, title: "container.furnace"},
+}
+
+// blockContainer is a container block in use, its contents are kept in
+// the block entity of its chunk
+type blockContainer struct {
+	pos     world.BlockPos
+	kind    containerKind
+	items   []ItemStack
+	viewers map[*Session]struct{}
+	// dirty is set when the block entity needs to be saved
+	dirty bool
+
+	// furnace state in ticks, cooking is the item being cooked
+	litTime     int
+	litDuration int
+	cookTime    int
+	cookTotal   int
+	cooking     string
+}
+
+// blockContainer returns the container at pos, loading it from its block
+// entity. Its chunk must be loaded.
+func (s *Server) blockContainer(pos world.BlockPos, kind containerKind) *blockContainer {
+	if c, ok := s.containers[pos]; ok {
+		return c
+	}
+	c := &blockContainer{
+		pos:     pos,
+		kind:    kind,
+		items:   make([]ItemStack, kind.size),
+		viewers: map[*Session]struct{}{},
+	}
+	if chunk := s.world.LoadedChunk(int32(pos.X>>4), int32(pos.Z>>4)); chunk != nil {
+		c.load(chunk.BlockEntity(pos))
+	}
+	s.containers[pos] = c
+	return c
+}
+
+func (c *blockContainer) load(data nbt.Compound) {
+	if data == nil {
+		return
+	}
+	itemsFromNBT(c.items, data.List("Items"))
+	if c.kind.furnace() {
+		c.litTime = int(data.Short("BurnTime"))
+		c.cookTime = int(data.Short("CookTime"))
+		c.cookTotal = int(data.Short("CookTimeTotal"))
+		c.litDuration = recipe.FuelTime(c.items[furnaceFuel].Item)
+		c.cooking = c.items[furnaceInput].Item
+	}
+}
+
+// save writes the container to its block entity, keys the server doesn't
+// know such as custom names are kept
+func (s *Server) saveContainer(c *blockContainer) {
+	chunk := s.world.LoadedChunk(int32(c.pos.X>>4), int32(c.pos.Z>>4))
+	if chunk == nil {
+		return
+	}
+	data := maps.Clone(chunk.BlockEntity(c.pos))
+	if data == nil {
+		data = nbt.Compound{}
+	}
+	data["id"] = c.kind.entity
+	data["Items"] = itemsToNBT(c.items)
+	if c.kind.furnace() {
+		data["BurnTime"] = int16(c.litTime)
+		data["CookTime"] = int16(c.cookTime)
+		data["CookTimeTotal"] = int16(c.cookTotal)
+	}
+	chunk.SetBlockEntity(c.pos, data)
+	c.dirty = false
+}
+
+// loadContainers starts ticking furnaces of chunk that were burning when it was saved
+func (s *Server) loadContainers(chunk *world.Chunk) {
+	chunk.RLock()
+	var burning []world.BlockPos
+	for pos, data := range chunk.BlockEntities {
+		if data.String("id") == "minecraft:furnace" && (data.Short("BurnTime") > 0 || data.Short("CookTime") > 0) {
+			burning = append(burning, pos)
+		}
+	}
+	chunk.RUnlock()
+	for _, pos := range burning {
+		b, ok := s.world.LoadedBlock(pos.X, pos.Y, pos.Z)
+		if kind, isContainer := containerKinds[b.Name]; ok && isContainer && kind.furnace() {
+			s.blockContainer(pos, kind)
+		}
+	}
+}
+
+// blockChanged keeps block entities in line with the block at pos
+func (s *Server) blockChanged(pos world.BlockPos, prev, b world.BlockState) {
+	if prev.Name == b.Name {
+		return
+	}
+	chunk := s.world.LoadedChunk(int32(pos.X>>4), int32(pos.Z>>4))
+	if _, ok := containerKinds[prev.Name]; ok {
+		if c, ok := s.containers[pos]; ok {
+			// items are lost as dropping items isn't supported
+			delete(s.containers, pos)
+			for viewer := range c.viewers {
+				viewer.forceCloseWindow()
+			}
+		}
+		if chunk != nil {
+			chunk.SetBlockEntity(pos, nil)
+		}
+	}
+	if kind, ok := containerKinds[b.Name]; ok && chunk != nil {
+		chunk.SetBlockEntity(pos, nbt.Compound{"id": kind.entity})
+	}
+}
+
+// tickContainers smelts in furnaces, saves changed containers and forgets
+// the ones nobody uses
+func (s *Server) tickContainers() {
+	for pos, c := range s.containers {
+		if c.kind.furnace() {
+			s.tickFurnace(c)
+		}
+		if c.dirty {
+			s.saveContainer(c)
+		}
+		if len(c.viewers) == 0 && c.litTime == 0 && c.cookTime == 0 {
+			delete(s.containers, pos)
+		}
+	}
+}
+
+// tickFurnace follows the vanilla furnace: fuel is burnt while there is
+// something to smelt and the output has room for it
+func (s *Server) tickFurnace(c *blockContainer) {
+	input, fuel, output := &c.items[furnaceInput], &c.items[furnaceFuel], &c.items[furnaceOutput]
+	if input.Item != c.cooking {
+		c.cooking = input.Item
+		c.cookTime = 0
+		c.cookTotal = 0
+		if r, ok := s.recipes.Smelt(input.Item); ok {
+			c.cookTotal = r.CookingTime
+		}
+		c.dirty = true
+	}
+
+	wasLit := c.litTime > 0
+	if wasLit {
+		c.litTime--
+		c.dirty = true
+	}
+	r, ok := s.recipes.Smelt(input.Item)
+	canSmelt := ok && !input.IsEmpty() && (output.IsEmpty() ||
+		output.Item == r.Result.Item && output.Components.IsEmpty() &&
+			output.Count+r.Result.Count <= output.MaxStack())
+
+	if c.litTime == 0 && canSmelt && !fuel.IsEmpty() {
+		c.litTime = recipe.FuelTime(fuel.Item)
+		c.litDuration = c.litTime
+		if c.litTime > 0 {
+			item := fuel.Item
+			fuel.Count--
+			clearEmpty(fuel)
+			if remainder := recipe.Remainder(item); remainder != "" && fuel.IsEmpty() {
+				*fuel = ItemStack{Item: remainder, Count: 1}
+			}
+			c.dirty = true
+		}
+	}
+
+	switch {
+	case c.litTime > 0 && canSmelt:
+		c.cookTime++
+		if c.cookTime >= c.cookTotal {
+			c.cookTime = 0
+			if output.IsEmpty() {
+				*output = ItemStack{Item: r.Result.Item, Count: r.Result.Count}
+			} else {
+				output.Count += r.Result.Count
+			}
+			input.Count--
+			clearEmpty(input)
+		}
+		c.dirty = true
+	case c.cookTime > 0:
+		c.cookTime = max(c.cookTime-2, 0)
+		c.dirty = true
+	}
+
+	if lit := c.litTime > 0; lit != wasLit {
+		s.setBlockProperty(c.pos, "lit", lit)
+	}
+}
+
+// setBlockProperty changes a boolean property of the block at pos, such as
+// a lit furnace or an open barrel
+func (s *Server) setBlockProperty(pos world.BlockPos, name string, value bool) {
+	b, ok := s.world.LoadedBlock(pos.X, pos.Y, pos.Z)
+	if !ok {
+		return
+	}
+	err := s.SetBlock(pos, b.WithProperty(name, map[bool]string{true: "true", false: "false"}[value]))
+	if err != nil {
+		log.Err(err).Str("block", b.Name).Msg("failed to update block")
+	}
+}
+
+// addViewer opens the container to a player, chest lids and barrels open
+func (s *Server) addViewer(c *blockContainer, viewer *Session) {
+	c.viewers[viewer] = struct{}{}
+	if len(c.viewers) == 1 && c.kind.entity == "minecraft:barrel" {
+		s.setBlockProperty(c.pos, "open", true)
+	}
+	s.broadcastChestViewers(c)
+}
+
+func (s *Server) removeViewer(c *blockContainer, viewer *Session) {
+	if _, ok := c.viewers[viewer]; !ok {
+		return
+	}
+	delete(c.viewers, viewer)
+	if s.containers[c.pos] != c {
+		// the block is gone
+		return
+	}
+	if len(c.viewers) == 0 && c.kind.entity == "minecraft:barrel" {
+		s.setBlockProperty(c.pos, "open", false)
+	}
+	s.broadcastChestViewers(c)
+}
+
+// broadcastChestViewers tells players seeing a chest how far to open its lid
+func (s *Server) broadcastChestViewers(c *blockContainer) {
+	if !c.kind.chest() {
+		return
+	}
+	b, ok := s.world.LoadedBlock(c.pos.X, c.pos.Y, c.pos.Z)
+	if !ok {
+		return
+	}
+	id, _ := registry.BlockID(b.Name)
+	for session := range s.sessions {
+		if session.seesBlock(c.pos) {
+			session.queue(&protocol.BlockActionPacket{
+				Location: toPosition(c.pos),
+				Action:   protocol.BlockActionChestViewers,
+				Param:    byte(min(len(c.viewers), 0xFF)),
+				BlockID:  id,
+			})
+		}
+	}
+}
+
+// useBlock opens the window of block b at pos and reports whether b has one
+func (s *Session) useBlock(pos world.BlockPos, b world.BlockState) bool {
+	if b.Name == "minecraft:crafting_table" {
+		s.openCraftingTable(pos)
+		return true
+	}
+	kind, ok := containerKinds[b.Name]
+	if !ok {
+		return false
+	}
+	if kind.chest() {
+		above, _ := s.server.world.LoadedBlock(pos.X, pos.Y+1, pos.Z)
+		if registry.Solid(above.Name) {
+			// a block on top keeps the lid shut
+			return true
+		}
+	}
+	c := s.server.blockContainer(pos, kind)
+	var m menu = &containerMenu{s: s, c: c}
+	if kind.furnace() {
+		m = &furnaceMenu{containerMenu{s: s, c: c}}
+	}
+	s.openWindow(kind.menu, text.Translated(kind.title), m)
+	s.server.addViewer(c, s)
+	return true
+}
+
+// containerMenu shows the slots of a container block above the player inventory
+type containerMenu struct {
+	s *Session
+	c *blockContainer
+}
+
+func (m *containerMenu) slots() []*ItemStack {
+	res := make([]*ItemStack, 0, len(m.c.items)+playerSlotCount)
+	for i := range m.c.items {
+		res = append(res, &m.c.items[i])
+	}
+	return append(res, m.s.playerSlots()...)
+}
+
+func (m *containerMenu) mayPlace(int, ItemStack) bool {
+	return true
+}
+
+func (m *containerMenu) slotLimit(_ int, stack ItemStack) int {
+	return stack.MaxStack()
+}
+
+// quickMove moves container items to the player, last slots first, and
+// player items into the container
+func (m *containerMenu) quickMove(i int, _ ItemStack) []int {
+	size := len(m.c.items)
+	if i < size {
+		player := slotRange(size, size+playerSlotCount)
+		slices.Reverse(player)
+		return player
+	}
+	return slotRange(0, size)
+}
+
+func (m *containerMenu) result(int) bool {
+	return false
+}
+
+func (m *containerMenu) taken(int) {}
+
+func (m *containerMenu) changed() {
+	m.c.dirty = true
+}
+
+func (m *containerMenu) closed() {
+	m.s.server.removeViewer(m.c, m.s)
+}
+
+func (m *containerMenu) valid() bool {
+	return m.s.server.containers[m.c.pos] == m.c && m.s.nearBlock(m.c.pos)
+}
+
+// furnaceMenu takes fuel in its fuel slot and only gives items out of its output
+type furnaceMenu struct {
+	containerMenu
+}
+
+func isFuel(item string) bool {
+	return recipe.FuelTime(item) > 0 || item == "minecraft:bucket"
+}
+
+func (m *furnaceMenu) mayPlace(i int, stack ItemStack) bool {
+	switch i {
+	case furnaceFuel:
+		return isFuel(stack.Item)
+	case furnaceOutput:
+		return false
+	}
+	return true
+}
+
+// quickMove moves smeltable items to the input and fuel to the fuel slot
+func (m *furnaceMenu) quickMove(i int, stack ItemStack) []int {
+	if i < furnaceSize {
+		player := slotRange(furnaceSize, furnaceSize+playerSlotCount)
+		if i == furnaceOutput {
+			slices.Reverse(player)
+		}
+		return player
+	}
+	if _, ok := m.s.server.recipes.Smelt(stack.Item); ok {
+		return []int{furnaceInput}
+	}
+	if isFuel(stack.Item) {
+		return []int{furnaceFuel}
+	}
+	return playerQuickMove(i, furnaceSize)
+}
+
+func (m *furnaceMenu) properties() []int16 {
+	c := m.c
+	return []int16{
+		protocol.FurnaceLitTime:          int16(c.litTime),
+		protocol.FurnaceLitDuration:      int16(c.litDuration),
+		protocol.FurnaceCookingProgress:  int16(c.cookTime),
+		protocol.FurnaceCookingTotalTime: int16(c.cookTotal),
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+// clickWindow sends a click on the open window predicting nothing, so every change is sent back
+func clickWindow(s *Session, slot, button int, mode int32) {
+	w := s.currentWindow()
+	s.handleClickContainer(protocol.ClickContainerPacket{
+		WindowID: w.id,
+		StateID:  w.stateID,
+		Slot:     int16(slot),
+		Button:   byte(button),
+		Mode:     mode,
+	})
+}
+
+func TestInventoryCrafting(t *testing.T) {
+	s := addTestPlayer(newTestServer(t), 1, "alice")
+	inv := &s.player.Inventory
+	inv.Slots[mainSlotsStart] = stack("oak_log", 2)
+
+	// a log in the grid crafts planks, shift clicking crafts until the logs run out
+	clickWindow(s, mainSlotsStart, 0, protocol.ClickPickup)
+	clickWindow(s, craftSlotsStart, 0, protocol.ClickPickup)
+	require.Equal(t, stack("oak_planks", 4), inv.Slots[craftResultSlot])
+	clickWindow(s, craftResultSlot, 0, protocol.ClickQuickMove)
+	require.Equal(t, stack("oak_planks", 8), inv.Slots[hotbarSlotEnd-1])
+	require.True(t, inv.Slots[craftSlotsStart].IsEmpty())
+	require.True(t, inv.Slots[craftResultSlot].IsEmpty())
+
+	// four planks make a crafting table taken into the cursor
+	for i := craftSlotsStart; i < craftSlotsEnd; i++ {
+		inv.Slots[i] = stack("oak_planks", 1)
+	}
+	s.currentWindow().menu.changed()
+	clickWindow(s, craftResultSlot, 0, protocol.ClickPickup)
+	require.Equal(t, stack("crafting_table", 1), s.carried)
+	for i := craftSlotsStart; i < craftSlotsEnd; i++ {
+		require.True(t, inv.Slots[i].IsEmpty())
+	}
+
+	// closing returns the cursor and the grid
+	inv.Slots[craftSlotsStart] = stack("stick", 3)
+	s.handleCloseContainer(protocol.CloseContainerPacket{})
+	require.True(t, s.carried.IsEmpty())
+	require.Equal(t, stack("crafting_table", 1), inv.Slots[hotbarSlotStart])
+	require.Equal(t, stack("stick", 3), inv.Slots[hotbarSlotStart+1])
+}
+
+func TestCraftingTable(t *testing.T) {
+	srv := newTestServer(t)
+	pos := world.BlockPos{X: 0, Y: 64, Z: 0}
+	require.NoError(t, srv.SetBlock(pos, world.NewBlockState("minecraft:crafting_table", nil)))
+	s := addTestPlayer(srv, 1, "alice")
+	s.player.X, s.player.Y, s.player.Z = 2.5, 64, 0.5
+	inv := &s.player.Inventory
+	inv.Slots[mainSlotsStart] = stack("cobblestone", 8)
+	queuedIDs(t, s)
+
+	s.handleUseItemOn(protocol.UseItemOnPacket{Location: datatypes.Position{X: 0, Y: 64, Z: 0}, Face: protocol.FaceTop})
+	w := s.currentWindow()
+	require.IsType(t, &craftingMenu{}, w.menu)
+	require.Equal(t, []int{protocol.PlayOpenScreenID, protocol.PlaySetContainerContentID, protocol.PlayAcknowledgeBlockChangeID}, queuedIDs(t, s))
+
+	// a ring of cobblestone is a furnace, player slots follow the grid
+	clickWindow(s, craftingGridEnd, 0, protocol.ClickPickup)
+	s.clickDrag(w, outsideSlot, dragOne<<2|dragStart)
+	for i := craftingGridStart; i < craftingGridEnd; i++ {
+		if i != 5 {
+			s.clickDrag(w, i, dragOne<<2|dragAdd)
+		}
+	}
+	clickWindow(s, outsideSlot, dragOne<<2|dragEnd, protocol.ClickDrag)
+	require.True(t, s.carried.IsEmpty())
+	require.Equal(t, stack("furnace", 1), *w.menu.slots()[craftResultSlot])
+
+	// the grid is returned when the table goes away
+	require.NoError(t, srv.SetBlock(pos, world.Air))
+	s.tickWindow()
+	require.Nil(t, s.window)
+	require.Equal(t, stack("cobblestone", 8), inv.Slots[hotbarSlotStart])
+}
+
+func TestChest(t *testing.T) {
+	srv := newTestServer(t)
+	pos := world.BlockPos{X: 0, Y: 64, Z: 0}
+	require.NoError(t, srv.SetBlock(pos, world.NewBlockState("minecraft:chest", nil)))
+	chunk := srv.world.LoadedChunk(0, 0)
+	require.Equal(t, "minecraft:chest", chunk.BlockEntity(pos).String("id"))
+
+	a := addTestPlayer(srv, 1, "alice")
+	b := addTestPlayer(srv, 2, "bob")
+	a.chunks.sent[world.ChunkPos{}] = struct{}{}
+	b.chunks.sent[world.ChunkPos{}] = struct{}{}
+	a.player.X, a.player.Y, a.player.Z = 2.5, 64, 0.5
+	b.player.X, b.player.Y, b.player.Z = 0.5, 64, 2.5
+	a.player.Inventory.Slots[hotbarSlotStart] = stack("diamond", 5)
+	queuedIDs(t, b)
+
+	require.True(t, a.useBlock(pos, world.NewBlockState("minecraft:chest", nil)))
+	require.Equal(t, []int{protocol.PlayBlockActionID}, queuedIDs(t, b))
+	clickWindow(a, 27+playerMainSlots, 0, protocol.ClickQuickMove)
+	require.Equal(t, stack("diamond", 5), srv.containers[pos].items[0])
+
+	// bob sees alice's changes as they happen
+	require.True(t, b.useBlock(pos, world.NewBlockState("minecraft:chest", nil)))
+	queuedIDs(t, b)
+	clickWindow(a, 0, 1, protocol.ClickPickup)
+	b.tickWindow()
+	require.Equal(t, []int{protocol.PlaySetContainerSlotID}, queuedIDs(t, b))
+
+	// contents are kept in the block entity once nobody looks inside
+	a.handleCloseContainer(protocol.CloseContainerPacket{WindowID: a.window.id})
+	b.handleCloseContainer(protocol.CloseContainerPacket{WindowID: b.window.id})
+	srv.tickContainers()
+	require.Empty(t, srv.containers)
+	items := chunk.BlockEntity(pos).List("Items").Compounds()
+	require.Len(t, items, 1)
+	require.Equal(t, "minecraft:diamond", items[0].String("id"))
+	require.Equal(t, int32(2), items[0].Int("count"))
+	require.True(t, a.useBlock(pos, world.NewBlockState("minecraft:chest", nil)))
+	require.Equal(t, stack("diamond", 2), srv.containers[pos].items[0])
+	a.handleCloseContainer(protocol.CloseContainerPacket{WindowID: a.window.id})
+
+	// a block on top keeps chests shut
+	require.NoError(t, srv.SetBlock(world.BlockPos{X: 0, Y: 65, Z: 0}, world.NewBlockState("minecraft:stone", nil)))
+	require.True(t, a.useBlock(pos, world.NewBlockState("minecraft:chest", nil)))
+	require.Nil(t, a.window)
+
+	require.NoError(t, srv.SetBlock(pos, world.Air))
+	require.Nil(t, chunk.BlockEntity(pos))
+}
+
+func TestFurnace(t *testing.T) {
+	srv := newTestServer(t)
+	pos := world.BlockPos{X: 0, Y: 64, Z: 0}
+	require.NoError(t, srv.SetBlock(pos, world.NewBlockState("minecraft:furnace", nil)))
+	s := addTestPlayer(srv, 1, "alice")
+	s.player.X, s.player.Y, s.player.Z = 2.5, 64, 0.5
+	inv := &s.player.Inventory
+	inv.Slots[mainSlotsStart] = stack("raw_iron", 2)
+	inv.Slots[mainSlotsStart+1] = stack("stick", 3)
+	inv.Slots[mainSlotsStart+2] = stack("dirt", 1)
+
+	require.True(t, s.useBlock(pos, world.NewBlockState("minecraft:furnace", nil)))
+	// shift clicks sort smeltable items and fuel
+	clickWindow(s, furnaceSize, 0, protocol.ClickQuickMove)
+	clickWindow(s, furnaceSize+1, 0, protocol.ClickQuickMove)
+	clickWindow(s, furnaceSize+2, 0, protocol.ClickQuickMove)
+	c := srv.containers[pos]
+	require.Equal(t, []ItemStack{stack("raw_iron", 2), stack("stick", 3), {}}, c.items)
+	require.Equal(t, stack("dirt", 1), inv.Slots[hotbarSlotStart])
+	s.handleCloseContainer(protocol.CloseContainerPacket{WindowID: s.window.id})
+
+	// a stick burns for 100 ticks, half of a smelt
+	srv.tickContainers()
+	b, _ := srv.world.LoadedBlock(0, 64, 0)
+	require.Equal(t, "true", b.Property("lit"))
+	for range 199 {
+		srv.tickContainers()
+	}
+	require.Equal(t, []ItemStack{stack("raw_iron", 1), stack("stick", 1), stack("iron_ingot", 1)}, c.items)
+
+	// progress is shown while the furnace is open, the last stick is lit
+	require.True(t, s.useBlock(pos, world.NewBlockState("minecraft:furnace", nil)))
+	queuedIDs(t, s)
+	srv.tickContainers()
+	s.tickWindow()
+	require.True(t, c.items[furnaceFuel].IsEmpty())
+	require.Equal(t, []int{protocol.PlaySetContainerSlotID, protocol.PlayContainerPropertyID, protocol.PlayContainerPropertyID}, queuedIDs(t, s))
+
+	// the output only gives items out
+	s.carried = stack("iron_ingot", 1)
+	clickWindow(s, furnaceOutput, 0, protocol.ClickPickup)
+	require.Equal(t, stack("iron_ingot", 2), s.carried)
+	require.True(t, c.items[furnaceOutput].IsEmpty())
+
+	// the furnace state is saved with its block entity
+	srv.tickContainers()
+	data := srv.world.LoadedChunk(0, 0).BlockEntity(pos)
+	require.Equal(t, int16(c.cookTime), data.Short("CookTime"))
+	require.Equal(t, int16(200), data.Short("CookTimeTotal"))
+	require.Positive(t, data.Short("BurnTime"))
+}
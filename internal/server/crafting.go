@@ -0,0 +1,145 @@
+package server
+
+import (
+	"slices"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/recipe"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+// Crafting table window slots, the player main inventory and hotbar follow the grid
+const (
+	craftingGridStart = 1
+	craftingGridEnd   = 10
+)
+
+// craftingGrid is the crafting slots of a window and their result
+type craftingGrid struct {
+	s      *Session
+	width  int
+	slots  []*ItemStack
+	result *ItemStack
+}
+
+// update sets the result to what the grid crafts
+func (g craftingGrid) update() {
+	items := make([]string, len(g.slots))
+	for i, stack := range g.slots {
+		if !stack.IsEmpty() {
+			items[i] = stack.Item
+		}
+	}
+	r, ok := g.s.server.recipes.Craft(items, g.width)
+	if !ok {
+		*g.result = ItemStack{}
+		return
+	}
+	*g.result = ItemStack{Item: r.Item, Count: r.Count}
+}
+
+// consume uses up one item of every grid slot after the result was taken,
+// containers such as buckets are left behind
+func (g craftingGrid) consume() {
+	for _, stack := range g.slots {
+		if stack.IsEmpty() {
+			continue
+		}
+		item := stack.Item
+		stack.Count--
+		clearEmpty(stack)
+		remainder := recipe.Remainder(item)
+		if remainder == "" {
+			continue
+		}
+		if stack.IsEmpty() {
+			*stack = ItemStack{Item: remainder, Count: 1}
+		} else {
+			g.s.returnToInventory(ItemStack{Item: remainder, Count: 1})
+		}
+	}
+}
+
+// clear returns the grid items to the player
+func (g craftingGrid) clear() {
+	for _, stack := range g.slots {
+		if !stack.IsEmpty() {
+			g.s.returnToInventory(*stack)
+		}
+		*stack = ItemStack{}
+	}
+	*g.result = ItemStack{}
+}
+
+// craftingMenu is the 3x3 grid of a crafting table
+type craftingMenu struct {
+	s      *Session
+	pos    world.BlockPos
+	output ItemStack
+	items  [craftingGridEnd - craftingGridStart]ItemStack
+}
+
+// openCraftingTable shows the crafting table at pos to the player
+func (s *Session) openCraftingTable(pos world.BlockPos) {
+	s.openWindow(protocol.MenuCrafting, text.Translated("container.crafting"), &craftingMenu{s: s, pos: pos})
+}
+
+func (m *craftingMenu) slots() []*ItemStack {
+	res := []*ItemStack{&m.output}
+	for i := range m.items {
+		res = append(res, &m.items[i])
+	}
+	return append(res, m.s.playerSlots()...)
+}
+
+func (m *craftingMenu) grid() craftingGrid {
+	g := craftingGrid{s: m.s, width: 3, result: &m.output}
+	for i := range m.items {
+		g.slots = append(g.slots, &m.items[i])
+	}
+	return g
+}
+
+func (m *craftingMenu) mayPlace(i int, _ ItemStack) bool {
+	return i != craftResultSlot
+}
+
+func (m *craftingMenu) slotLimit(_ int, stack ItemStack) int {
+	return stack.MaxStack()
+}
+
+// quickMove moves results and grid items to the player, player items try
+// the grid first
+func (m *craftingMenu) quickMove(i int, _ ItemStack) []int {
+	player := slotRange(craftingGridEnd, craftingGridEnd+playerSlotCount)
+	switch {
+	case i == craftResultSlot:
+		slices.Reverse(player)
+		return player
+	case i < craftingGridEnd:
+		return player
+	}
+	return append(slotRange(craftingGridStart, craftingGridEnd), playerQuickMove(i, craftingGridEnd)...)
+}
+
+func (m *craftingMenu) result(i int) bool {
+	return i == craftResultSlot
+}
+
+func (m *craftingMenu) taken(int) {
+	m.grid().consume()
+}
+
+func (m *craftingMenu) changed() {
+	m.grid().update()
+}
+
+func (m *craftingMenu) closed() {
+	m.grid().clear()
+}
+
+func (m *craftingMenu) valid() bool {
+	b, ok := m.s.server.world.LoadedBlock(m.pos.X, m.pos.Y, m.pos.Z)
+	return ok && b.Name == "minecraft:crafting_table" && m.s.nearBlock(m.pos)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+// Events returns the event bus, handlers subscribe with event.Subscribe.
+// PlayerPreLoginEvent and StatusPingEvent are fired on network goroutines,
+// the other events on the tick goroutine.
+func (s *Server) Events() *event.Bus {
+	return &s.events
+}
+
+// PlayerPreLoginEvent is fired for players that passed the ban and whitelist
+// checks, cancelling it disconnects the player with Reason
+type PlayerPreLoginEvent struct {
+	Profile   access.Profile
+	Address   string
+	Reason    text.Component
+	Cancelled bool
+}
+
+// PlayerJoinEvent is fired when a player enters play, a nil Message is not broadcast
+type PlayerJoinEvent struct {
+	Player  *Session
+	Message *text.Component
+}
+
+// PlayerQuitEvent is fired when a player in play disconnects, a nil Message is not broadcast
+type PlayerQuitEvent struct {
+	Player  *Session
+	Message *text.Component
+}
+
+// ChatEvent is fired before a player message is broadcast
+type ChatEvent struct {
+	Player  *Session
+	Message string
+	// Format is the message template, {player} and {message} are replaced
+	Format    text.Component
+	Cancelled bool
+}
+
+// BlockBreakEvent is fired before a player breaks a block, cancelling it
+// restores the block on the client
+type BlockBreakEvent struct {
+	Player    *Session
+	Pos       world.BlockPos
+	Block     world.BlockState
+	Cancelled bool
+}
+
+// BlockPlaceEvent is fired before a player places a block, handlers may change Block
+type BlockPlaceEvent struct {
+	Player    *Session
+	Pos       world.BlockPos
+	Block     world.BlockState
+	Cancelled bool
+}
+
+// Location is a player position
+type Location struct {
+	X, Y, Z float64
+}
+
+// MoveEvent is fired when a player changes position, cancelling it teleports
+// the player back to From
+type MoveEvent struct {
+	Player    *Session
+	From, To  Location
+	Cancelled bool
+}
+
+// CommandEvent is fired before a command runs, handlers may rewrite Command
+type CommandEvent struct {
+	Source command.Source
+	// Command is the input without the leading slash
+	Command   string
+	Cancelled bool
+}
+
+// StatusPingEvent is fired when a client asks for the server list status,
+// handlers may change Response and cancelling it closes the connection unanswered
+type StatusPingEvent struct {
+	Address   string
+	Response  protocol.JSONResponse
+	Cancelled bool
+}
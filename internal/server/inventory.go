@@ -0,0 +1,267 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+)
+
+// Player inventory window slots
+const (
+	craftResultSlot = 0
+	craftSlotsStart = 1
+	craftSlotsEnd   = 5
+	armorSlotsStart = 5
+	headSlot        = 5
+	chestSlot       = 6
+	legsSlot        = 7
+	feetSlot        = 8
+	armorSlotsEnd   = 9
+	inventorySize   = 46
+	mainSlotsStart  = 9
+	hotbarSlotStart = 36
+	hotbarSlotEnd   = 45
+	offHandSlot     = 45
+	hotbarSize      = hotbarSlotEnd - hotbarSlotStart
+	// outsideSlot is clicked when the cursor is outside of the window
+	outsideSlot = -999
+)
+
+const (
+	// offHandSwapButton is the swap click button of the off hand key
+	offHandSwapButton = 40
+	// maxCreativeStack is the largest stack creative players may set
+	maxCreativeStack = 99
+)
+
+// ItemStack is a number of items of one kind
+type ItemStack struct {
+	Item  string
+	Count int
+	// Components are the data components changed from the item defaults
+	Components protocol.Components
+}
+
+func (s ItemStack) IsEmpty() bool {
+	return s.Item == "" || s.Count <= 0
+}
+
+// Stacks reports whether s and o are the same item and may share a slot
+func (s ItemStack) Stacks(o ItemStack) bool {
+	return s.Item == o.Item && s.Components.Equal(o.Components)
+}
+
+// MaxStack is the most items of this kind a slot holds
+func (s ItemStack) MaxStack() int {
+	if n, ok := s.Components.VarInt(protocol.ComponentMaxStackSize); ok && n > 0 {
+		return int(n)
+	}
+	return registry.MaxStackSize(s.Item)
+}
+
+// withCount returns a copy of s holding n items
+func (s ItemStack) withCount(n int) ItemStack {
+	if n <= 0 {
+		return ItemStack{}
+	}
+	return ItemStack{Item: s.Item, Count: n, Components: s.Components.Clone()}
+}
+
+func (s ItemStack) protocol() protocol.Slot {
+	if s.IsEmpty() {
+		return protocol.Slot{}
+	}
+	id, _ := registry.ItemID(s.Item)
+	return protocol.Slot{Count: int32(s.Count), ItemID: id, Components: s.Components}
+}
+
+// stackFromProtocol converts a slot sent by the client, unknown items are rejected
+func stackFromProtocol(s protocol.Slot) (ItemStack, bool) {
+	if s.Count <= 0 {
+		return ItemStack{}, true
+	}
+	name, ok := registry.ItemName(s.ItemID)
+	if !ok {
+		return ItemStack{}, false
+	}
+	return ItemStack{Item: name, Count: int(s.Count), Components: s.Components}, true
+}
+
+// equipmentSlot returns the armor or off hand slot an item is worn in, zero
+// for items that aren't equipment
+func equipmentSlot(item string) int {
+	switch {
+	case strings.HasSuffix(item, "_helmet"), strings.HasSuffix(item, "_head"),
+		strings.HasSuffix(item, "_skull"), item == "minecraft:carved_pumpkin":
+		return headSlot
+	case strings.HasSuffix(item, "_chestplate"), item == "minecraft:elytra":
+		return chestSlot
+	case strings.HasSuffix(item, "_leggings"):
+		return legsSlot
+	case strings.HasSuffix(item, "_boots"):
+		return feetSlot
+	case item == "minecraft:shield":
+		return offHandSlot
+	}
+	return 0
+}
+
+func isArmorSlot(i int) bool {
+	return i >= armorSlotsStart && i < armorSlotsEnd
+}
+
+// Inventory holds the slots of the player inventory window
+type Inventory struct {
+	Slots [inventorySize]ItemStack
+}
+
+// storageOrder lists slots items are picked up into: the hotbar, then the main inventory
+func storageOrder() []int {
+	res := make([]int, 0, hotbarSlotEnd-mainSlotsStart)
+	for i := hotbarSlotStart; i < hotbarSlotEnd; i++ {
+		res = append(res, i)
+	}
+	for i := mainSlotsStart; i < hotbarSlotStart; i++ {
+		res = append(res, i)
+	}
+	return res
+}
+
+// Add puts stack into stacks of the same item first and then into empty
+// slots, it returns the changed slots and the count that didn't fit
+func (inv *Inventory) Add(stack ItemStack) ([]int, int) {
+	maxStack := stack.MaxStack()
+	left := stack.Count
+	var changed []int
+	for _, i := range storageOrder() {
+		slot := &inv.Slots[i]
+		if left == 0 {
+			break
+		}
+		if slot.IsEmpty() || !slot.Stacks(stack) || slot.Count >= maxStack {
+			continue
+		}
+		n := min(left, maxStack-slot.Count)
+		slot.Count += n
+		left -= n
+		changed = append(changed, i)
+	}
+	for _, i := range storageOrder() {
+		slot := &inv.Slots[i]
+		if left == 0 {
+			break
+		}
+		if !slot.IsEmpty() {
+			continue
+		}
+		n := min(left, maxStack)
+		*slot = stack.withCount(n)
+		left -= n
+		changed = append(changed, i)
+	}
+	return changed, left
+}
+
+func (p *Player) mainHandSlot() int {
+	return hotbarSlotStart + int(p.HeldSlot)
+}
+
+// MainHand returns the stack in the selected hotbar slot
+func (p *Player) MainHand() *ItemStack {
+	return &p.Inventory.Slots[p.mainHandSlot()]
+}
+
+// OffHand returns the stack in the off hand slot
+func (p *Player) OffHand() *ItemStack {
+	return &p.Inventory.Slots[offHandSlot]
+}
+
+// Armor returns the stacks worn from head to feet
+func (p *Player) Armor() []ItemStack {
+	return p.Inventory.Slots[armorSlotsStart:armorSlotsEnd]
+}
+
+// Give adds items to the player inventory and returns how many didn't fit
+func (s *Session) Give(stack ItemStack) int {
+	_, left := s.player.Inventory.Add(stack)
+	s.syncWindow()
+	return left
+}
+
+// inventoryMenu is the player inventory window with its 2x2 crafting grid
+type inventoryMenu struct {
+	s *Session
+}
+
+func (m *inventoryMenu) slots() []*ItemStack {
+	inv := &m.s.player.Inventory
+	res := make([]*ItemStack, inventorySize)
+	for i := range inv.Slots {
+		res[i] = &inv.Slots[i]
+	}
+	return res
+}
+
+func (m *inventoryMenu) grid() craftingGrid {
+	inv := &m.s.player.Inventory
+	g := craftingGrid{s: m.s, width: 2, result: &inv.Slots[craftResultSlot]}
+	for i := craftSlotsStart; i < craftSlotsEnd; i++ {
+		g.slots = append(g.slots, &inv.Slots[i])
+	}
+	return g
+}
+
+func (m *inventoryMenu) mayPlace(i int, stack ItemStack) bool {
+	switch {
+	case i == craftResultSlot:
+		return false
+	case isArmorSlot(i):
+		return equipmentSlot(stack.Item) == i
+	}
+	return true
+}
+
+func (m *inventoryMenu) slotLimit(i int, stack ItemStack) int {
+	if isArmorSlot(i) {
+		return 1
+	}
+	return stack.MaxStack()
+}
+
+// quickMove puts equipment on, trades items between the hotbar and the
+// main inventory and moves everything else to storage
+func (m *inventoryMenu) quickMove(i int, stack ItemStack) []int {
+	storage := slotRange(mainSlotsStart, hotbarSlotEnd)
+	switch {
+	case i == craftResultSlot:
+		slices.Reverse(storage)
+		return storage
+	case i < mainSlotsStart || i == offHandSlot:
+		return storage
+	}
+	if eq := equipmentSlot(stack.Item); eq != 0 && m.s.player.Inventory.Slots[eq].IsEmpty() {
+		return []int{eq}
+	}
+	if i < hotbarSlotStart {
+		return slotRange(hotbarSlotStart, hotbarSlotEnd)
+	}
+	return slotRange(mainSlotsStart, hotbarSlotStart)
+}
+
+func (m *inventoryMenu) result(i int) bool {
+	return i == craftResultSlot
+}
+
+func (m *inventoryMenu) taken(int) {
+	m.grid().consume()
+}
+
+func (m *inventoryMenu) changed() {
+	m.grid().update()
+}
+
+func (m *inventoryMenu) closed() {
+	m.grid().clear()
+}
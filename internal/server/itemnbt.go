@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/rs/zerolog/log"
+)
+
+// savedComponents are the data components stored with items in block
+// entities and player data, other components are lost when items are saved
+var savedComponents = map[int32]string{
+	protocol.ComponentCustomData:      "minecraft:custom_data",
+	protocol.ComponentMaxStackSize:    "minecraft:max_stack_size",
+	protocol.ComponentMaxDamage:       "minecraft:max_damage",
+	protocol.ComponentDamage:          "minecraft:damage",
+	protocol.ComponentRepairCost:      "minecraft:repair_cost",
+	protocol.ComponentCustomModelData: "minecraft:custom_model_data",
+}
+
+// stackToNBT encodes stack the way vanilla saves items
+func stackToNBT(stack ItemStack) nbt.Compound {
+	res := nbt.Compound{"id": stack.Item, "count": int32(stack.Count)}
+	components := nbt.Compound{}
+	for typ, data := range stack.Components.Added {
+		name, ok := savedComponents[typ]
+		if !ok {
+			log.Debug().Str("item", stack.Item).Int32("component", typ).Msg("item component can't be saved")
+			continue
+		}
+		if typ == protocol.ComponentCustomData {
+			tag, err := nbt.ReadNetwork(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			components[name] = tag
+			continue
+		}
+		v, _ := stack.Components.VarInt(typ)
+		components[name] = v
+	}
+	for typ := range stack.Components.Removed {
+		if name, ok := savedComponents[typ]; ok {
+			components["!"+name] = nbt.Compound{}
+		}
+	}
+	if len(components) > 0 {
+		res["components"] = components
+	}
+	return res
+}
+
+// stackFromNBT decodes an item saved by vanilla, unknown items are rejected
+func stackFromNBT(c nbt.Compound) (ItemStack, bool) {
+	item := c.String("id")
+	if _, ok := registry.ItemID(item); !ok {
+		return ItemStack{}, false
+	}
+	count := 1
+	if c.Has("count") {
+		count = int(c.Int("count"))
+	}
+	if count <= 0 {
+		return ItemStack{}, false
+	}
+	stack := ItemStack{Item: item, Count: count}
+	components := c.Compound("components")
+	for typ, name := range savedComponents {
+		if components.Has("!" + name) {
+			stack.Components.Remove(typ)
+			continue
+		}
+		if !components.Has(name) {
+			continue
+		}
+		if typ == protocol.ComponentCustomData {
+			var buf bytes.Buffer
+			tag := components.Compound(name)
+			if tag == nil || nbt.WriteNetwork(&buf, tag) != nil {
+				continue
+			}
+			stack.Components.Set(typ, buf.Bytes())
+			continue
+		}
+		stack.Components.SetVarInt(typ, components.Int(name))
+	}
+	return stack, true
+}
+
+// itemsToNBT encodes the non empty slots of items as a vanilla Items list
+func itemsToNBT(items []ItemStack) nbt.List {
+	list := nbt.NewList(nbt.TagCompound)
+	for i, stack := range items {
+		if stack.IsEmpty() {
+			continue
+		}
+		c := stackToNBT(stack)
+		c["Slot"] = int8(i)
+		list.Items = append(list.Items, c)
+	}
+	return list
+}
+
+// itemsFromNBT fills items from a vanilla Items list, slots out of range
+// and unknown items are skipped
+func itemsFromNBT(items []ItemStack, list nbt.List) {
+	for _, c := range list.Compounds() {
+		slot := int(c.Byte("Slot"))
+		if slot < 0 || slot >= len(items) {
+			continue
+		}
+		stack, ok := stackFromNBT(c)
+		if !ok {
+			log.Debug().Str("item", c.String("id")).Msg("skipped unknown saved item")
+			continue
+		}
+		items[slot] = stack
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/BinaryArchaism/mc-srv/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxFrameHeader is the longest packet length and ID prefix, 3 bytes of
+// length up to protocol.MaxPacketSize and a 5 byte ID
+const maxFrameHeader = 8
+
+// otherPacketLabel counts IDs no packet of the state has, IDs come from
+// clients before login and must not make up new series
+const otherPacketLabel = "other"
+
+// maxPacketIDs is the highest packet ID of either direction in every state
+var maxPacketIDs = map[State]uint64{
+	Handshake:     0x00,
+	Status:        0x01,
+	Login:         0x05,
+	Configuration: 0x10,
+	Play:          0x7B,
+}
+
+// meteredConn counts packets and bytes by packet ID. Packets are neither
+// compressed nor encrypted, so frames are parsed right off the wire and
+// packets are counted however they were written.
+type meteredConn struct {
+	net.Conn
+	state   func() State
+	in, out frameMeter
+}
+
+func newMeteredConn(conn net.Conn, state func() State) *meteredConn {
+	return &meteredConn{
+		Conn:  conn,
+		state: state,
+		in:    frameMeter{packets: metrics.PacketsIn, bytes: metrics.BytesIn},
+		out:   frameMeter{packets: metrics.PacketsOut, bytes: metrics.BytesOut},
+	}
+}
+
+func (c *meteredConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.in.observe(c.state(), p[:n])
+	return n, err
+}
+
+func (c *meteredConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.out.observe(c.state(), p[:n])
+	return n, err
+}
+
+// frameMeter follows packet frames in a byte stream split at arbitrary points
+type frameMeter struct {
+	packets, bytes *prometheus.CounterVec
+
+	mu     sync.Mutex
+	header []byte
+	// remaining is the part of the current frame left to skip
+	remaining int
+}
+
+func (m *frameMeter) observe(state State, p []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(p) > 0 {
+		if m.remaining > 0 {
+			n := min(m.remaining, len(p))
+			m.remaining -= n
+			p = p[n:]
+			continue
+		}
+		m.header = append(m.header, p[0])
+		p = p[1:]
+
+		length, n := binary.Uvarint(m.header)
+		if n < 0 || (n == 0 && len(m.header) >= maxFrameHeader) {
+			m.header = m.header[:0]
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		body := len(m.header) - n
+		id, k := binary.Uvarint(m.header[n:])
+		if k == 0 && uint64(body) < length && len(m.header) < maxFrameHeader {
+			continue
+		}
+		if k > 0 {
+			label := packetLabel(state, id)
+			m.packets.WithLabelValues(string(state), label).Inc()
+			m.bytes.WithLabelValues(string(state), label).Add(float64(uint64(n) + length))
+		}
+		m.remaining = max(int(length)-body, 0)
+		m.header = m.header[:0]
+	}
+}
+
+func packetLabel(state State, id uint64) string {
+	if maxID, ok := maxPacketIDs[state]; !ok || id > maxID {
+		return otherPacketLabel
+	}
+	return fmt.Sprintf("0x%02X", id)
+}
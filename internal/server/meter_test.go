@@ -0,0 +1,60 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type byteSink []byte
+
+func (b *byteSink) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
+
+func TestFrameMeter(t *testing.T) {
+	packets := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "packets"}, []string{"state", "id"})
+	bytes := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "bytes"}, []string{"state", "id"})
+	m := frameMeter{packets: packets, bytes: bytes}
+
+	var stream byteSink
+	require.NoError(t, protocol.WritePacket(&stream, 0x27, make([]byte, 300)))
+	require.NoError(t, protocol.WritePacket(&stream, 0x04, []byte{1, 2}))
+	require.NoError(t, protocol.WritePacket(&stream, 0x27, nil))
+
+	// the stream is split at every byte to cover partial headers
+	for i := range stream {
+		m.observe(Play, stream[i:i+1])
+	}
+	require.Equal(t, 2.0, testutil.ToFloat64(packets.WithLabelValues("play", "0x27")))
+	require.Equal(t, 1.0, testutil.ToFloat64(packets.WithLabelValues("play", "0x04")))
+	require.Equal(t, float64(2+1+300+1+1), testutil.ToFloat64(bytes.WithLabelValues("play", "0x27")))
+	require.Equal(t, 4.0, testutil.ToFloat64(bytes.WithLabelValues("play", "0x04")))
+
+	// status has no packets 0x27 and 0x04
+	m.observe(Status, stream)
+	require.Equal(t, 3.0, testutil.ToFloat64(packets.WithLabelValues("status", otherPacketLabel)))
+}
+
+func TestFrameMeter_UnknownIDs(t *testing.T) {
+	packets := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "packets"}, []string{"state", "id"})
+	bytes := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "bytes"}, []string{"state", "id"})
+	m := frameMeter{packets: packets, bytes: bytes}
+
+	// made up IDs share one series whatever their number
+	var stream byteSink
+	for id := range 1000 {
+		require.NoError(t, protocol.WritePacket(&stream, int32(0x7C+id*1000), nil))
+	}
+	require.NoError(t, protocol.WritePacket(&stream, 0x02, nil))
+	m.observe(Status, stream)
+	m.observe(Play, stream)
+	require.Equal(t, 3, testutil.CollectAndCount(packets))
+	require.Equal(t, 1000.0, testutil.ToFloat64(packets.WithLabelValues("play", otherPacketLabel)))
+	require.Equal(t, 1001.0, testutil.ToFloat64(packets.WithLabelValues("status", otherPacketLabel)))
+	require.Equal(t, 1.0, testutil.ToFloat64(packets.WithLabelValues("play", "0x02")))
+}
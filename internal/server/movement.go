@@ -0,0 +1,243 @@
+package server
+
+import (
+	"math"
+
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/registry"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	playerWidth = 0.6
+	// collisionHeight is the height of the crawling pose, the smallest one, so
+	// players are never pushed back for sneaking or swimming under blocks
+	collisionHeight = 0.6
+	// collisionEpsilon keeps boxes touching a block face from colliding with it
+	collisionEpsilon = 1e-5
+
+	// maxMoveDistanceSq is the squared distance allowed from the position at
+	// the start of the tick for every movement packet received in it. Like
+	// vanilla a burst of more than maxCountedMoves packets only counts as one.
+	maxMoveDistanceSq = 100
+	maxCountedMoves   = 5
+	// maxFloatingTicks is how long a player that can't fly may stay in the air
+	// without falling
+	maxFloatingTicks = 80
+	// maxCoordinate is the world border limit for positions sent by clients
+	maxCoordinate = 3.0e7
+)
+
+type moveViolation int
+
+const (
+	moveOK moveViolation = iota
+	moveTooFast
+	moveIntoBlock
+)
+
+func (v moveViolation) String() string {
+	switch v {
+	case moveTooFast:
+		return "moved too quickly"
+	case moveIntoBlock:
+		return "moved into a block"
+	default:
+		return "ok"
+	}
+}
+
+// moveState holds movement checks bookkeeping of a session
+type moveState struct {
+	// start is the position at the start of the tick, moves counts the
+	// movement packets received since
+	start         Location
+	moves         int
+	floating      bool
+	floatingTicks int
+	// ground is the last position the player was supported at
+	groundX, groundY, groundZ float64
+}
+
+// blockGetter returns a block at absolute coordinates
+type blockGetter func(x, y, z int) world.BlockState
+
+type aabb struct {
+	minX, minY, minZ float64
+	maxX, maxY, maxZ float64
+}
+
+func playerBox(x, y, z, height float64) aabb {
+	const half = playerWidth / 2
+	return aabb{
+		minX: x - half + collisionEpsilon, minY: y + collisionEpsilon, minZ: z - half + collisionEpsilon,
+		maxX: x + half - collisionEpsilon, maxY: y + height - collisionEpsilon, maxZ: z + half - collisionEpsilon,
+	}
+}
+
+// any reports whether a block intersecting the box matches fn
+func (b aabb) any(blockAt blockGetter, fn func(world.BlockState) bool) bool {
+	for x := int(math.Floor(b.minX)); x <= int(math.Floor(b.maxX)); x++ {
+		for y := int(math.Floor(b.minY)); y <= int(math.Floor(b.maxY)); y++ {
+			for z := int(math.Floor(b.minZ)); z <= int(math.Floor(b.maxZ)); z++ {
+				if fn(blockAt(x, y, z)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func isSolid(b world.BlockState) bool {
+	return registry.Solid(b.Name)
+}
+
+func isNotAir(b world.BlockState) bool {
+	return !b.IsAir()
+}
+
+func collides(blockAt blockGetter, x, y, z float64) bool {
+	return playerBox(x, y, z, collisionHeight).any(blockAt, isSolid)
+}
+
+// checkMove validates moving p to x, y, z with the moves-th packet since
+// the tick started at from. Moving into solid blocks is only rejected when
+// the player wasn't stuck in them already.
+func checkMove(blockAt blockGetter, p *Player, from Location, moves int, x, y, z float64) moveViolation {
+	if moves > maxCountedMoves {
+		moves = 1
+	}
+	dx, dy, dz := x-from.X, y-from.Y, z-from.Z
+	if dx*dx+dy*dy+dz*dz > maxMoveDistanceSq*float64(moves) {
+		return moveTooFast
+	}
+	if p.GameMode == Spectator {
+		return moveOK
+	}
+	if collides(blockAt, x, y, z) && !collides(blockAt, p.X, p.Y, p.Z) {
+		return moveIntoBlock
+	}
+	return moveOK
+}
+
+// isFloating reports whether a player at x, y, z that moved by dy vertically
+// hangs in the air, it is the vanilla check with blocks around the player
+// and slightly below their feet
+func isFloating(blockAt blockGetter, x, y, z, dy float64) bool {
+	if dy < -0.03125 {
+		return false
+	}
+	box := playerBox(x, y, z, collisionHeight)
+	box.minX, box.minZ = box.minX-0.0625, box.minZ-0.0625
+	box.maxX, box.maxZ = box.maxX+0.0625, box.maxZ+0.0625
+	box.minY -= 0.55
+	return !box.any(blockAt, isNotAir)
+}
+
+func validCoordinates(p *protocol.MovePlayerPacket) bool {
+	for _, v := range [...]float64{p.X, p.Y, p.Z, float64(p.Yaw), float64(p.Pitch)} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return math.Abs(p.X) <= maxCoordinate && math.Abs(p.Z) <= maxCoordinate
+}
+
+func (s *Session) blockAt(x, y, z int) world.BlockState {
+	b, _ := s.server.world.LoadedBlock(x, y, z)
+	return b
+}
+
+// handleMove applies a movement packet on the tick goroutine. Invalid moves
+// teleport the player back to where the server has them.
+func (s *Session) handleMove(move protocol.MovePlayerPacket) {
+	player := s.player
+	if !validCoordinates(&move) {
+		s.Kick("Invalid move player packet received")
+		return
+	}
+	if s.awaitingTeleport != 0 {
+		// moves sent before the client accepted the teleport start from the old position
+		return
+	}
+
+	if move.HasRotation {
+		player.Yaw = move.Yaw
+		player.Pitch = min(max(move.Pitch, -90), 90)
+	}
+	if !move.HasPosition {
+		player.OnGround = move.OnGround
+		return
+	}
+
+	s.move.moves++
+	if v := checkMove(s.blockAt, player, s.move.start, s.move.moves, move.X, move.Y, move.Z); v != moveOK {
+		log.Warn().Str("player", player.Name).Stringer("violation", v).
+			Float64("x", move.X).Float64("y", move.Y).Float64("z", move.Z).
+			Msg("rejected movement")
+		s.queue(s.teleport(player.X, player.Y, player.Z, player.Yaw, player.Pitch))
+		return
+	}
+
+	// moves are the most frequent event, it is only built when handled
+	if event.Has[MoveEvent](&s.server.events) {
+		e := &MoveEvent{
+			Player: s,
+			From:   Location{X: player.X, Y: player.Y, Z: player.Z},
+			To:     Location{X: move.X, Y: move.Y, Z: move.Z},
+		}
+		event.Fire(&s.server.events, e)
+		if e.Cancelled {
+			s.queue(s.teleport(player.X, player.Y, player.Z, player.Yaw, player.Pitch))
+			return
+		}
+	}
+
+	dy := move.Y - player.Y
+	player.X, player.Y, player.Z = move.X, move.Y, move.Z
+	player.OnGround = move.OnGround
+	s.move.floating = isFloating(s.blockAt, move.X, move.Y, move.Z, dy)
+	if !s.move.floating {
+		s.move.floatingTicks = 0
+		s.move.groundX, s.move.groundY, s.move.groundZ = move.X, move.Y, move.Z
+	}
+
+	s.updateChunkCenter()
+}
+
+// updateChunkCenter streams chunks around the player after it moved
+func (s *Session) updateChunkCenter() {
+	if pos := s.player.ChunkPos(); pos != s.chunks.Center() {
+		s.queue(&protocol.SetCenterChunkPacket{X: pos.X, Z: pos.Z})
+		s.unloadChunks(s.chunks.SetCenter(pos))
+	}
+}
+
+// Teleport moves the player keeping its rotation, it must be called from the tick goroutine
+func (s *Session) Teleport(x, y, z float64) {
+	s.queue(s.teleport(x, y, z, s.player.Yaw, s.player.Pitch))
+	s.updateChunkCenter()
+}
+
+// tickMovement starts counting moves of the next tick and pulls down
+// players that hang in the air without being allowed to fly
+func (s *Session) tickMovement() {
+	player := s.player
+	s.move.start = Location{X: player.X, Y: player.Y, Z: player.Z}
+	s.move.moves = 0
+	if !s.move.floating || player.GameMode.Abilities()&protocol.AbilityAllowFlying != 0 {
+		s.move.floatingTicks = 0
+		return
+	}
+	s.move.floatingTicks++
+	if s.move.floatingTicks <= maxFloatingTicks {
+		return
+	}
+	log.Warn().Str("player", player.Name).Msg("rejected flying")
+	s.move.floating = false
+	s.move.floatingTicks = 0
+	s.queue(s.teleport(s.move.groundX, s.move.groundY, s.move.groundZ, player.Yaw, player.Pitch))
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+// floorAt returns stone below y 0 and a stone pillar at x 5, z 0
+func floorAt(x, y, z int) world.BlockState {
+	if y < 0 || x == 5 && z == 0 {
+		return world.Stone
+	}
+	return world.Air
+}
+
+func TestCheckMove(t *testing.T) {
+	p := &Player{X: 0.5, Y: 0, Z: 0.5}
+	from := Location{X: 0.5, Y: 0, Z: 0.5}
+	require.Equal(t, moveOK, checkMove(floorAt, p, from, 1, 1.5, 0, 0.5))
+	require.Equal(t, moveOK, checkMove(floorAt, p, from, 1, 0.5, 1.2, 0.5))
+	require.Equal(t, moveTooFast, checkMove(floorAt, p, from, 1, 10.5, 1, 0.5))
+	require.Equal(t, moveIntoBlock, checkMove(floorAt, p, from, 1, 0.5, -0.5, 0.5))
+	require.Equal(t, moveIntoBlock, checkMove(floorAt, p, from, 1, 5.5, 0, 0.5))
+	// standing next to the pillar touches it
+	require.Equal(t, moveOK, checkMove(floorAt, p, from, 1, 4.7, 0, 0.5))
+	// every packet of the tick adds to the distance allowed, up to a burst
+	require.Equal(t, moveOK, checkMove(floorAt, p, from, 2, 10.5, 1, 0.5))
+	require.Equal(t, moveTooFast, checkMove(floorAt, p, from, maxCountedMoves+1, 10.5, 1, 0.5))
+
+	// a player stuck in a block may move out
+	stuck := &Player{X: 5.5, Y: 0, Z: 0.5}
+	require.Equal(t, moveOK, checkMove(floorAt, stuck, Location{X: 5.5, Y: 0, Z: 0.5}, 1, 5.5, 0, 1.5))
+
+	spectator := &Player{X: 0.5, Y: 0, Z: 0.5, GameMode: Spectator}
+	require.Equal(t, moveOK, checkMove(floorAt, spectator, from, 1, 0.5, -3, 0.5))
+}
+
+func TestHandleMove_Tick(t *testing.T) {
+	s := addTestPlayer(newTestServer(t), 1, "alice")
+	s.player.X, s.player.Y, s.player.Z = 0.5, 64, 0.5
+	s.tickMovement()
+
+	// short moves add up, a burst of them may not go further than one move
+	for i := 1; i <= 8; i++ {
+		s.handleMove(protocol.MovePlayerPacket{HasPosition: true, X: 0.5 + float64(i*4), Y: 64, Z: 0.5})
+	}
+	require.Equal(t, 20.5, s.player.X)
+	require.NotZero(t, s.awaitingTeleport)
+
+	// the next tick starts where the player was sent back to
+	s.awaitingTeleport = 0
+	s.tickMovement()
+	s.handleMove(protocol.MovePlayerPacket{HasPosition: true, X: 28.5, Y: 64, Z: 0.5})
+	require.Equal(t, 28.5, s.player.X)
+}
+
+func TestIsFloating(t *testing.T) {
+	require.False(t, isFloating(floorAt, 0.5, 0, 0.5, 0))
+	require.False(t, isFloating(floorAt, 0.5, 0.5, 0.5, 0.5))
+	require.True(t, isFloating(floorAt, 0.5, 3, 0.5, 0))
+	require.False(t, isFloating(floorAt, 0.5, 3, 0.5, -0.5))
+	// holding on to the pillar side
+	require.False(t, isFloating(floorAt, 4.65, 3, 0.5, 0))
+}
@@ -0,0 +1,654 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	// outboundQueueSize is how many flushed ticks a client may fall behind before it is dropped
+	outboundQueueSize = 256
+	// chunkLoadAhead is how many queued chunks are loaded in background ahead of sending
+	chunkLoadAhead = 64
+)
+
+func (s *Session) viewDistance() int {
+	return min(int(s.clientInfo.ViewDistance), s.server.cfg.ViewDistance)
+}
+
+func (s *Session) PlaySession() error {
+	w := s.server.world
+	player := s.player
+	dimension := datatypes.FromString(w.Dimension)
+	viewDistance := datatypes.VarInt(s.server.cfg.ViewDistance)
+	playLogin := protocol.LoginPlayPacket{
+		EntityID:            player.EntityID,
+		IsHardcore:          false,
+		DimensionCount:      1,
+		DimensionNames:      []datatypes.String{dimension},
+		MaxPlayers:          datatypes.VarInt(s.server.cfg.MaxPlayers),
+		ViewDistance:        viewDistance,
+		SimulationDistance:  viewDistance,
+		ReducedDebugInfo:    false,
+		EnableRespawnScreen: true,
+		DoLimitedCrafting:   false,
+		DimensionType:       0,
+		DimensionName:       dimension,
+		HashedSeed:          world.HashSeed(w.Seed),
+		GameMode:            byte(player.GameMode),
+		PreviousGameMode:    0xFF,
+		IsDebug:             false,
+		IsFlat:              datatypes.Boolean(w.Flat()),
+		HasDeathLocation:    false,
+		DeathDimensionName:  datatypes.String{},
+		DeathLocation:       datatypes.Position{},
+		PortalCooldown:      0,
+		EnforcesSecureChat:  datatypes.Boolean(s.server.cfg.SecureChat.Enforce),
+	}
+	err := s.send(&playLogin)
+	if err != nil {
+		return fmt.Errorf("failed to write playLogin packet: %w", err)
+	}
+
+	err = s.spawn()
+	if err != nil {
+		return err
+	}
+
+	// from now on packets go through the tick
+	s.outCh = make(chan []byte, outboundQueueSize)
+	go s.writeLoop()
+	s.server.Submit(func() {
+		s.server.sessions[s] = struct{}{}
+		s.server.updateOnline()
+		s.server.tracker.add(s)
+		s.server.addToTabList(s)
+		s.sendPermissionLevel()
+		s.sendCommands()
+		s.sendTime()
+		if s.server.weather != world.Clear {
+			s.sendWeather(s.server.weather)
+		}
+		msg := formatMessage(s.server.cfg.Chat.JoinMessage, s.player.Name, "")
+		join := &PlayerJoinEvent{Player: s, Message: &msg}
+		event.Fire(&s.server.events, join)
+		if join.Message != nil {
+			s.server.BroadcastMessage(*join.Message)
+		}
+	})
+	defer s.server.Submit(func() {
+		s.server.tracker.remove(s)
+		s.server.removeFromTabList(s)
+		delete(s.server.sessions, s)
+		s.server.updateOnline()
+		s.closeWindow()
+		s.server.savePlayer(s)
+		s.close()
+		msg := formatMessage(s.server.cfg.Chat.LeaveMessage, s.player.Name, "")
+		quit := &PlayerQuitEvent{Player: s, Message: &msg}
+		event.Fire(&s.server.events, quit)
+		if quit.Message != nil {
+			s.server.BroadcastMessage(*quit.Message)
+		}
+	})
+
+	return s.playLoop()
+}
+
+// spawn sends the player state that follows Login (play) and places the
+// player where it was saved or at the world spawn
+func (s *Session) spawn() error {
+	player := s.player
+	err := s.send(&protocol.PlayerAbilitiesPacket{
+		Flags:       player.GameMode.Abilities(),
+		FlyingSpeed: defaultFlyingSpeed,
+		FOVModifier: defaultWalkingSpeed,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write playerAbilities packet: %w", err)
+	}
+
+	err = s.send(&protocol.SetHealthPacket{Health: player.Health, Food: defaultFood, Saturation: defaultSaturation})
+	if err != nil {
+		return fmt.Errorf("failed to write setHealth packet: %w", err)
+	}
+
+	err = s.send(&protocol.SetExperiencePacket{Progress: player.XPProgress, Level: player.XPLevel, Total: player.XPTotal})
+	if err != nil {
+		return fmt.Errorf("failed to write setExperience packet: %w", err)
+	}
+
+	err = s.send(&protocol.SetHeldItemPacket{Slot: player.HeldSlot})
+	if err != nil {
+		return fmt.Errorf("failed to write setHeldItem packet: %w", err)
+	}
+
+	err = s.send(s.windowContent(s.inventoryWindow()))
+	if err != nil {
+		return fmt.Errorf("failed to write setContainerContent packet: %w", err)
+	}
+
+	spawn := s.server.world.Spawn()
+	err = s.send(&protocol.SetDefaultSpawnPositionPacket{
+		Location: datatypes.Position{X: spawn.X, Y: spawn.Y, Z: spawn.Z},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write setDefaultSpawnPosition packet: %w", err)
+	}
+
+	x, y, z := float64(spawn.X)+0.5, float64(spawn.Y), float64(spawn.Z)+0.5
+	var yaw, pitch float32
+	if player.positioned {
+		x, y, z, yaw, pitch = player.X, player.Y, player.Z, player.Yaw, player.Pitch
+	}
+	err = s.send(s.teleport(x, y, z, yaw, pitch))
+	if err != nil {
+		return fmt.Errorf("failed to write synchronizePlayerPosition packet: %w", err)
+	}
+
+	err = s.send(&protocol.GameEventPacket{Event: protocol.GameEventStartWaitingForChunks})
+	if err != nil {
+		return fmt.Errorf("failed to write gameEvent packet: %w", err)
+	}
+
+	s.chunks = newChunkStreamer(player.ChunkPos(), s.viewDistance())
+	center := s.chunks.Center()
+	err = s.send(&protocol.SetCenterChunkPacket{X: center.X, Z: center.Z})
+	if err != nil {
+		return fmt.Errorf("failed to write setCenterChunk packet: %w", err)
+	}
+	return nil
+}
+
+// teleport moves the player and returns the packet to send, the client
+// confirms it with the teleport ID
+func (s *Session) teleport(x, y, z float64, yaw, pitch float32) *protocol.SynchronizePlayerPositionPacket {
+	s.teleportID++
+	s.awaitingTeleport = s.teleportID
+	s.player.X, s.player.Y, s.player.Z = x, y, z
+	s.player.Yaw, s.player.Pitch = yaw, pitch
+	s.move = moveState{start: Location{X: x, Y: y, Z: z}, groundX: x, groundY: y, groundZ: z}
+	return &protocol.SynchronizePlayerPositionPacket{
+		X:          x,
+		Y:          y,
+		Z:          z,
+		Yaw:        yaw,
+		Pitch:      pitch,
+		TeleportID: s.teleportID,
+	}
+}
+
+// SetGameMode changes the game mode of the player, it must be called from the tick goroutine
+func (s *Session) SetGameMode(mode GameMode) {
+	s.player.GameMode = mode
+	s.queue(&protocol.GameEventPacket{Event: protocol.GameEventChangeGameMode, Value: float32(mode)})
+	s.queue(&protocol.PlayerAbilitiesPacket{
+		Flags:       mode.Abilities(),
+		FlyingSpeed: defaultFlyingSpeed,
+		FOVModifier: defaultWalkingSpeed,
+	})
+	s.server.updateTabList(s, protocol.PlayerInfoUpdateGameMode)
+}
+
+// SetDisplayName changes the name shown in the tab list, nil shows the player name
+func (s *Session) SetDisplayName(name *text.Component) {
+	s.player.DisplayName = name
+	s.server.updateTabList(s, protocol.PlayerInfoUpdateDisplayName)
+}
+
+// queue encodes p into the outbound buffer, it is written after the current tick
+func (s *Session) queue(p packetWriter) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	if s.closing {
+		return
+	}
+	err := p.Write(&s.outbound)
+	if err != nil {
+		log.Err(err).Str("player", s.player.Name).Msg("failed to encode packet")
+	}
+}
+
+// flush hands packets queued during the tick to the writer goroutine, a
+// client that doesn't read fast enough is dropped
+func (s *Session) flush() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.flushLocked()
+}
+
+func (s *Session) flushLocked() {
+	if s.closing || s.outbound.Len() == 0 {
+		return
+	}
+	data := bytes.Clone(s.outbound.Bytes())
+	s.outbound.Reset()
+	select {
+	case s.outCh <- data:
+	default:
+		log.Warn().Str("player", s.player.Name).Msg("client can't keep up, dropping connection")
+		s.closeLocked()
+	}
+}
+
+// close stops the writer after it writes everything already flushed
+func (s *Session) close() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Session) closeLocked() {
+	if s.closing {
+		return
+	}
+	s.closing = true
+	s.outbound.Reset()
+	close(s.outCh)
+}
+
+func (s *Session) writeLoop() {
+	defer s.closeConn()
+	for data := range s.outCh {
+		s.writeMu.Lock()
+		_, err := s.UserConn.Write(data)
+		s.writeMu.Unlock()
+		if err != nil {
+			log.Err(err).Str("player", s.player.Name).Msg("failed to write packets")
+			return
+		}
+	}
+}
+
+func (s *Session) closeConn() {
+	if c, ok := s.UserConn.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// Kick disconnects the player with reason
+func (s *Session) Kick(reason string) {
+	if s.outCh == nil {
+		// not in the tick yet
+		err := s.send(&protocol.PlayDisconnectPacket{Reason: reason})
+		if err != nil {
+			log.Err(err).Str("player", s.player.Name).Msg("failed to write disconnect packet")
+		}
+		s.closeConn()
+		return
+	}
+	s.queue(&protocol.PlayDisconnectPacket{Reason: reason})
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.flushLocked()
+	s.closeLocked()
+}
+
+// Player returns the entity controlled by the session, it must only be used on the tick goroutine
+func (s *Session) Player() *Player {
+	return s.player
+}
+
+// Latency returns the round trip time of the last answered keep alive
+func (s *Session) Latency() time.Duration {
+	return s.latency
+}
+
+// tick runs on the tick goroutine once per tick for every player in play
+func (s *Session) tick(tick int64) {
+	s.tickKeepAlive()
+	s.tickMovement()
+	s.tickDigging(tick)
+	s.tickChat()
+	s.tickChunks()
+	s.tickWindow()
+	if tick%timeSyncInterval == 0 {
+		s.sendTime()
+	}
+}
+
+// tickKeepAlive pings the client and kicks it when a ping is not answered in time
+func (s *Session) tickKeepAlive() {
+	now := time.Now()
+	if now.Sub(s.keepAliveSent) < keepAliveInterval {
+		return
+	}
+	if s.keepAlivePending {
+		log.Info().Str("player", s.player.Name).Msg("keep alive timed out")
+		s.Kick("Timed out")
+		return
+	}
+	s.keepAliveID = now.UnixMilli()
+	s.keepAliveSent = now
+	s.keepAlivePending = true
+	s.queue(&protocol.KeepAlivePacket{ID: s.keepAliveID})
+}
+
+func (s *Session) tickChunks() {
+	w := s.server.world
+	for _, pos := range s.chunks.Upcoming(chunkLoadAhead) {
+		if w.LoadedChunk(pos.X, pos.Z) == nil {
+			s.server.loadChunk(pos)
+		}
+	}
+	batch := s.chunks.NextBatch(func(pos world.ChunkPos) bool {
+		return w.LoadedChunk(pos.X, pos.Z) != nil
+	})
+	if len(batch) == 0 {
+		return
+	}
+	s.queue(&protocol.ChunkBatchStartPacket{})
+	for _, pos := range batch {
+		s.queue(protocol.NewChunkPacket(w.LoadedChunk(pos.X, pos.Z)))
+	}
+	s.queue(&protocol.ChunkBatchFinishedPacket{BatchSize: int32(len(batch))})
+}
+
+// unloadChunks tells the client to forget chunks
+func (s *Session) unloadChunks(positions []world.ChunkPos) {
+	for _, pos := range positions {
+		s.queue(&protocol.UnloadChunkPacket{X: pos.X, Z: pos.Z})
+	}
+}
+
+func (s *Session) sendTime() {
+	w := s.server.world
+	dayTime := w.DayTime()
+	if !w.DaylightCycle() {
+		dayTime = -dayTime
+		if dayTime == 0 {
+			dayTime = -1
+		}
+	}
+	s.queue(&protocol.SetTimePacket{WorldAge: w.Time(), TimeOfDay: dayTime})
+}
+
+// sendWeather starts or stops rain, thunder is sent as the thunder level
+func (s *Session) sendWeather(weather world.Weather) {
+	if weather == world.Clear {
+		s.queue(&protocol.GameEventPacket{Event: protocol.GameEventEndRaining})
+		s.queue(&protocol.GameEventPacket{Event: protocol.GameEventRainLevelChange, Value: 0})
+		s.queue(&protocol.GameEventPacket{Event: protocol.GameEventThunderLevelChange, Value: 0})
+		return
+	}
+	var thunder float32
+	if weather == world.Thunder {
+		thunder = 1
+	}
+	s.queue(&protocol.GameEventPacket{Event: protocol.GameEventBeginRaining})
+	s.queue(&protocol.GameEventPacket{Event: protocol.GameEventRainLevelChange, Value: 1})
+	s.queue(&protocol.GameEventPacket{Event: protocol.GameEventThunderLevelChange, Value: thunder})
+}
+
+func (s *Session) playLoop() error {
+	for {
+		p, err := protocol.ReadPacket(s.reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to read play packet: %w", err)
+		}
+		err = s.handlePlayPacket(p)
+		if err != nil {
+			return fmt.Errorf("failed to handle play packet 0x%02X: %w", p.ID, err)
+		}
+	}
+}
+
+// handlePlayPacket decodes p on the network goroutine and submits the
+// resulting action to the tick
+func (s *Session) handlePlayPacket(p protocol.PacketWithData) error {
+	switch p.ID {
+	case protocol.PlayConfirmTeleportationID:
+		var confirm protocol.ConfirmTeleportationPacket
+		err := confirm.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			if confirm.TeleportID == s.awaitingTeleport {
+				s.awaitingTeleport = 0
+			}
+		})
+
+	case protocol.PlayServerKeepAliveID:
+		var keepAlive protocol.KeepAlivePacket
+		err := keepAlive.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		received := time.Now()
+		s.server.Submit(func() {
+			if s.keepAlivePending && keepAlive.ID == s.keepAliveID {
+				s.keepAlivePending = false
+				s.latency = received.Sub(s.keepAliveSent)
+			}
+		})
+
+	case protocol.PlayMovePlayerPosID, protocol.PlayMovePlayerPosRotID,
+		protocol.PlayMovePlayerRotID, protocol.PlayMovePlayerStatusOnlyID:
+		var move protocol.MovePlayerPacket
+		err := move.Decode(p.ID, p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleMove(move)
+		})
+
+	case protocol.PlayChatMessageID:
+		var chat protocol.ChatMessagePacket
+		err := chat.Decode(p.Data)
+		if errors.Is(err, protocol.ErrStringTooLong) {
+			s.server.Submit(func() {
+				s.Kick("Chat message too long")
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleChat(chat)
+		})
+
+	case protocol.PlayChatCommandID:
+		var cmd protocol.ChatCommandPacket
+		err := cmd.Decode(p.Data)
+		if errors.Is(err, protocol.ErrStringTooLong) {
+			s.server.Submit(func() {
+				s.Kick("Chat message too long")
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleCommand(cmd.Command)
+		})
+
+	case protocol.PlaySignedChatCommandID:
+		var cmd protocol.SignedChatCommandPacket
+		err := cmd.Decode(p.Data)
+		if errors.Is(err, protocol.ErrStringTooLong) {
+			s.server.Submit(func() {
+				s.Kick("Chat message too long")
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleSignedCommand(cmd)
+		})
+
+	case protocol.PlayCommandSuggestionsRequestID:
+		var request protocol.CommandSuggestionsRequestPacket
+		err := request.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleCommandSuggestions(request)
+		})
+
+	case protocol.PlayChatAckID:
+		var ack protocol.ChatAckPacket
+		err := ack.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleChatAck(ack)
+		})
+
+	case protocol.PlayChatSessionUpdateID:
+		var session protocol.ChatSessionUpdatePacket
+		err := session.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleChatSession(session)
+		})
+
+	case protocol.PlayChunkBatchReceivedID:
+		var ack protocol.ChunkBatchReceivedPacket
+		err := ack.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.chunks.Acknowledge(ack.ChunksPerTick)
+		})
+
+	case protocol.PlayClientInformationID:
+		var info protocol.ClientInformationPacket
+		err := info.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.clientInfo = info
+			s.unloadChunks(s.chunks.SetViewDistance(s.viewDistance()))
+		})
+
+	case protocol.PlayServerSetHeldItemID:
+		var held protocol.SetHeldItemPacket
+		err := held.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			if held.Slot >= hotbarSize {
+				log.Warn().Str("player", s.player.Name).Uint8("slot", held.Slot).Msg("invalid held slot")
+				return
+			}
+			s.player.HeldSlot = held.Slot
+		})
+
+	case protocol.PlayPlayerCommandID:
+		var cmd protocol.PlayerCommandPacket
+		err := cmd.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			switch cmd.Action {
+			case protocol.CommandStartSneaking:
+				s.sneaking = true
+			case protocol.CommandStopSneaking:
+				s.sneaking = false
+			}
+		})
+
+	case protocol.PlayPlayerActionID:
+		var action protocol.PlayerActionPacket
+		err := action.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handlePlayerAction(action)
+		})
+
+	case protocol.PlayUseItemOnID:
+		var use protocol.UseItemOnPacket
+		err := use.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleUseItemOn(use)
+		})
+
+	case protocol.PlayServerClickContainerID:
+		var click protocol.ClickContainerPacket
+		err := click.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleClickContainer(click)
+		})
+
+	case protocol.PlayServerCloseContainerID:
+		var closeContainer protocol.CloseContainerPacket
+		err := closeContainer.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleCloseContainer(closeContainer)
+		})
+
+	case protocol.PlayServerSetCreativeSlotID:
+		var set protocol.SetCreativeSlotPacket
+		err := set.Decode(p.Data)
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			s.handleSetCreativeSlot(set)
+		})
+
+	case protocol.PlayServerPluginMessageID:
+		var msg protocol.PluginMessagePacket
+		err := msg.Decode(p.Data)
+		if errors.Is(err, protocol.ErrPacketTooLarge) {
+			s.server.Submit(func() {
+				s.Kick("Payload too large")
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.server.Submit(func() {
+			err := s.handlePluginMessage(msg)
+			if err != nil {
+				log.Err(err).Str("player", s.player.Name).Msg("bad plugin message")
+				s.Kick("Invalid plugin message")
+			}
+		})
+
+	default:
+		log.Trace().Int("id", p.ID).Int("length", p.Length).Msg("unhandled play packet")
+	}
+	return nil
+}
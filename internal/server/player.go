@@ -0,0 +1,93 @@
+package server
+
+import (
+	"math"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/google/uuid"
+)
+
+type GameMode byte
+
+const (
+	Survival GameMode = iota
+	Creative
+	Adventure
+	Spectator
+)
+
+var gameModeNames = [...]string{"survival", "creative", "adventure", "spectator"}
+
+func (m GameMode) String() string {
+	if int(m) < len(gameModeNames) {
+		return gameModeNames[m]
+	}
+	return "unknown"
+}
+
+// ParseGameMode accepts game mode names and their numeric ids
+func ParseGameMode(s string) (GameMode, bool) {
+	for i, name := range gameModeNames {
+		if s == name || len(s) == 1 && s[0] == byte('0'+i) {
+			return GameMode(i), true
+		}
+	}
+	return Survival, false
+}
+
+// Abilities returns Player Abilities flags of the game mode
+func (m GameMode) Abilities() byte {
+	switch m {
+	case Creative:
+		return protocol.AbilityInvulnerable | protocol.AbilityAllowFlying | protocol.AbilityInstantBreak
+	case Spectator:
+		return protocol.AbilityInvulnerable | protocol.AbilityAllowFlying | protocol.AbilityFlying
+	default:
+		return 0
+	}
+}
+
+const (
+	defaultFlyingSpeed  = 0.05
+	defaultWalkingSpeed = 0.1
+
+	maxHealth         = 20
+	defaultFood       = 20
+	defaultSaturation = 5
+)
+
+// Player is the entity controlled by a session
+type Player struct {
+	UUID     uuid.UUID
+	Name     string
+	EntityID int32
+
+	X, Y, Z    float64
+	Yaw, Pitch float32
+	OnGround   bool
+
+	GameMode  GameMode
+	HeldSlot  byte
+	Inventory Inventory
+	Health    float32
+	// XPLevel, XPProgress and XPTotal are shown in the experience bar
+	XPLevel    int32
+	XPProgress float32
+	XPTotal    int32
+	// PermissionLevel limits the commands the player may run, see command.LevelAll and others
+	PermissionLevel int
+	// DisplayName is shown in the tab list instead of Name when set
+	DisplayName *text.Component
+
+	// data is the saved player data, keys the server doesn't use are saved
+	// back unchanged. positioned is set when it held the player position.
+	data       nbt.Compound
+	positioned bool
+}
+
+func (p *Player) ChunkPos() world.ChunkPos {
+	return world.ChunkPos{X: int32(math.Floor(p.X)) >> 4, Z: int32(math.Floor(p.Z)) >> 4}
+}
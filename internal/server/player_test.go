@@ -0,0 +1,21 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGameMode(t *testing.T) {
+	for _, s := range []string{"creative", "1"} {
+		m, ok := ParseGameMode(s)
+		require.True(t, ok)
+		require.Equal(t, Creative, m)
+	}
+	_, ok := ParseGameMode("hardcore")
+	require.False(t, ok)
+	require.Equal(t, "spectator", Spectator.String())
+	require.Zero(t, Survival.Abilities())
+	require.NotZero(t, Creative.Abilities()&protocol.AbilityInstantBreak)
+}
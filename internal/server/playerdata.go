@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"maps"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/world/anvil"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// autosaveInterval is how often online players and changed chunks are saved, in ticks
+const autosaveInterval = 5 * 60 * TicksPerSecond
+
+// Player data inventory slots differ from window slots, armor is stored
+// from feet to head
+const (
+	savedArmorStart = 100
+	savedOffHand    = -106
+)
+
+// loadPlayer fills p from its saved data, players without data keep the defaults
+func (s *Server) loadPlayer(p *Player) {
+	if s.playerData == nil {
+		return
+	}
+	c, err := s.playerData.Load(p.UUID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Err(err).Str("player", p.Name).Msg("failed to load player data")
+		return
+	}
+	playerFromNBT(p, c, s.world.Dimension)
+}
+
+// savePlayer writes the player data of session
+func (s *Server) savePlayer(session *Session) {
+	if s.playerData == nil {
+		return
+	}
+	p := session.player
+	err := s.playerData.Save(p.UUID, playerToNBT(p, s.world.Dimension))
+	if err != nil {
+		log.Err(err).Str("player", p.Name).Msg("failed to save player data")
+	}
+}
+
+// savePlayers writes the player data of everyone online
+func (s *Server) savePlayers() {
+	for session := range s.sessions {
+		s.savePlayer(session)
+	}
+}
+
+// playerFromNBT decodes vanilla player data. The position is only used
+// when the player was in dimension.
+func playerFromNBT(p *Player, c nbt.Compound, dimension string) {
+	p.data = c
+	if c.Has("playerGameType") {
+		if mode := GameMode(c.Int("playerGameType")); mode <= Spectator {
+			p.GameMode = mode
+		}
+	}
+	if health := c.Float("Health"); health > 0 {
+		p.Health = min(health, maxHealth)
+	}
+	p.XPLevel = c.Int("XpLevel")
+	p.XPProgress = c.Float("XpP")
+	p.XPTotal = c.Int("XpTotal")
+	if slot := c.Int("SelectedItemSlot"); slot >= 0 && slot < hotbarSize {
+		p.HeldSlot = byte(slot)
+	}
+	for _, item := range c.List("Inventory").Compounds() {
+		i, ok := inventorySlot(item.Byte("Slot"))
+		if !ok {
+			continue
+		}
+		stack, ok := stackFromNBT(item)
+		if !ok {
+			log.Debug().Str("item", item.String("id")).Msg("skipped unknown saved item")
+			continue
+		}
+		p.Inventory.Slots[i] = stack
+	}
+
+	pos, rot := c.List("Pos").Items, c.List("Rotation").Items
+	if c.String("Dimension") != dimension || len(pos) != 3 || len(rot) != 2 {
+		return
+	}
+	x, okX := pos[0].(float64)
+	y, okY := pos[1].(float64)
+	z, okZ := pos[2].(float64)
+	yaw, okYaw := rot[0].(float32)
+	pitch, okPitch := rot[1].(float32)
+	if !okX || !okY || !okZ || !okYaw || !okPitch {
+		return
+	}
+	p.X, p.Y, p.Z = x, y, z
+	p.Yaw, p.Pitch = yaw, pitch
+	p.OnGround = c.Bool("OnGround")
+	p.positioned = true
+}
+
+// playerToNBT encodes p the way vanilla saves players in dimension
+func playerToNBT(p *Player, dimension string) nbt.Compound {
+	c := maps.Clone(p.data)
+	if c == nil {
+		c = nbt.Compound{}
+	}
+	c["DataVersion"] = int32(anvil.DataVersion)
+	c["UUID"] = uuidToNBT(p.UUID)
+	c["Dimension"] = dimension
+	c["Pos"] = nbt.NewList(nbt.TagDouble, p.X, p.Y, p.Z)
+	c["Rotation"] = nbt.NewList(nbt.TagFloat, p.Yaw, p.Pitch)
+	c["OnGround"] = p.OnGround
+	c["playerGameType"] = int32(p.GameMode)
+	c["Health"] = p.Health
+	c["XpLevel"] = p.XPLevel
+	c["XpP"] = p.XPProgress
+	c["XpTotal"] = p.XPTotal
+	c["SelectedItemSlot"] = int32(p.HeldSlot)
+
+	inventory := nbt.NewList(nbt.TagCompound)
+	for i, stack := range p.Inventory.Slots {
+		slot, ok := savedSlot(i)
+		if !ok || stack.IsEmpty() {
+			continue
+		}
+		item := stackToNBT(stack)
+		item["Slot"] = slot
+		inventory.Items = append(inventory.Items, item)
+	}
+	c["Inventory"] = inventory
+	return c
+}
+
+// uuidToNBT encodes id as four ints, most significant first
+func uuidToNBT(id uuid.UUID) []int32 {
+	res := make([]int32, 4)
+	for i := range res {
+		res[i] = int32(binary.BigEndian.Uint32(id[i*4:]))
+	}
+	return res
+}
+
+// savedSlot maps an inventory window slot to its player data slot, the
+// crafting grid isn't saved
+func savedSlot(i int) (int8, bool) {
+	switch {
+	case i >= hotbarSlotStart && i < hotbarSlotEnd:
+		return int8(i - hotbarSlotStart), true
+	case i >= mainSlotsStart && i < hotbarSlotStart:
+		return int8(i), true
+	case i >= armorSlotsStart && i < armorSlotsEnd:
+		return int8(savedArmorStart + feetSlot - i), true
+	case i == offHandSlot:
+		return savedOffHand, true
+	}
+	return 0, false
+}
+
+// inventorySlot is the inverse of savedSlot
+func inventorySlot(slot int8) (int, bool) {
+	switch {
+	case slot >= 0 && slot < hotbarSize:
+		return hotbarSlotStart + int(slot), true
+	case slot >= mainSlotsStart && slot < hotbarSlotStart:
+		return int(slot), true
+	case slot >= savedArmorStart && slot < savedArmorStart+armorSlotsEnd-armorSlotsStart:
+		return feetSlot - int(slot-savedArmorStart), true
+	case slot == savedOffHand:
+		return offHandSlot, true
+	}
+	return 0, false
+}
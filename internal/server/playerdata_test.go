@@ -0,0 +1,68 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/world/anvil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerData(t *testing.T) {
+	srv := newTestServer(t)
+	srv.playerData = anvil.NewPlayerData(t.TempDir())
+	s := addTestPlayer(srv, 1, "alice")
+	p := s.player
+	p.X, p.Y, p.Z = 10.5, 70, -3.25
+	p.Yaw, p.Pitch = 90, 15
+	p.GameMode = Creative
+	p.Health = 12.5
+	p.XPLevel, p.XPProgress, p.XPTotal = 3, 0.5, 40
+	p.HeldSlot = 4
+	p.Inventory.Slots[hotbarSlotStart] = stack("diamond", 5)
+	p.Inventory.Slots[mainSlotsStart] = stack("stick", 1)
+	p.Inventory.Slots[headSlot] = stack("iron_helmet", 1)
+	p.Inventory.Slots[offHandSlot] = stack("torch", 16)
+	p.Inventory.Slots[craftSlotsStart] = stack("dirt", 1)
+	p.data = nbt.Compound{"foodLevel": int32(17)}
+	srv.savePlayer(s)
+
+	// the file uses vanilla slots and keeps what the server doesn't know
+	c, err := srv.playerData.Load(p.UUID)
+	require.NoError(t, err)
+	require.Equal(t, int32(17), c.Int("foodLevel"))
+	require.Equal(t, "minecraft:overworld", c.String("Dimension"))
+	slots := map[int8]string{}
+	for _, item := range c.List("Inventory").Compounds() {
+		slots[item.Byte("Slot")] = item.String("id")
+	}
+	require.Equal(t, map[int8]string{
+		0: "minecraft:diamond", 9: "minecraft:stick", 103: "minecraft:iron_helmet", -106: "minecraft:torch",
+	}, slots)
+
+	loaded := &Player{UUID: p.UUID, Name: p.Name, Health: maxHealth}
+	srv.loadPlayer(loaded)
+	require.True(t, loaded.positioned)
+	require.Equal(t, [3]float64{10.5, 70, -3.25}, [3]float64{loaded.X, loaded.Y, loaded.Z})
+	require.Equal(t, [2]float32{90, 15}, [2]float32{loaded.Yaw, loaded.Pitch})
+	require.Equal(t, Creative, loaded.GameMode)
+	require.Equal(t, float32(12.5), loaded.Health)
+	require.Equal(t, int32(3), loaded.XPLevel)
+	require.Equal(t, float32(0.5), loaded.XPProgress)
+	require.Equal(t, int32(40), loaded.XPTotal)
+	require.Equal(t, byte(4), loaded.HeldSlot)
+	p.Inventory.Slots[craftSlotsStart] = ItemStack{}
+	require.Equal(t, p.Inventory.Slots, loaded.Inventory.Slots)
+
+	// players from another dimension start at the spawn
+	srv.world.Dimension = "minecraft:the_nether"
+	other := &Player{UUID: p.UUID}
+	srv.loadPlayer(other)
+	require.False(t, other.positioned)
+	require.Equal(t, Creative, other.GameMode)
+
+	// new players keep the defaults
+	fresh := addTestPlayer(srv, 2, "bob").player
+	srv.loadPlayer(fresh)
+	require.Nil(t, fresh.data)
+}
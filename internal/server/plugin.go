@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Plugin extends the server without changing it. Plugins register in an
+// init function of their package,
+//
+//	func init() {
+//		server.RegisterPlugin(&myPlugin{})
+//	}
+//
+// and are built in with a blank import of that package in cmd/server.
+type Plugin interface {
+	Name() string
+	// OnEnable is called before the server starts ticking, event handlers
+	// and commands are registered here
+	OnEnable(s *Server) error
+	// OnDisable is called after the server stopped ticking
+	OnDisable()
+}
+
+var (
+	pluginsMu sync.Mutex
+	plugins   []Plugin
+)
+
+// RegisterPlugin makes p enabled by every server created after it, it panics
+// if a plugin with the same name is already registered
+func RegisterPlugin(p Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	for _, registered := range plugins {
+		if registered.Name() == p.Name() {
+			panic(fmt.Sprintf("plugin %q registered twice", p.Name()))
+		}
+	}
+	plugins = append(plugins, p)
+}
+
+// enablePlugins enables registered plugins, plugins failing to enable are skipped
+func (s *Server) enablePlugins() {
+	pluginsMu.Lock()
+	registered := plugins
+	pluginsMu.Unlock()
+	for _, p := range registered {
+		err := p.OnEnable(s)
+		if err != nil {
+			log.Err(err).Str("plugin", p.Name()).Msg("failed to enable plugin")
+			continue
+		}
+		s.plugins = append(s.plugins, p)
+		log.Info().Str("plugin", p.Name()).Msg("plugin enabled")
+	}
+}
+
+func (s *Server) disablePlugins() {
+	for i := len(s.plugins) - 1; i >= 0; i-- {
+		s.plugins[i].OnDisable()
+		log.Info().Str("plugin", s.plugins[i].Name()).Msg("plugin disabled")
+	}
+}
+
+// Plugins returns names of enabled plugins
+func (s *Server) Plugins() []string {
+	names := make([]string, len(s.plugins))
+	for i, p := range s.plugins {
+		names[i] = p.Name()
+	}
+	return names
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/stretchr/testify/require"
+)
+
+type testPlugin struct {
+	name     string
+	err      error
+	enabled  bool
+	commands []string
+	disabled bool
+}
+
+func (p *testPlugin) Name() string {
+	return p.name
+}
+
+func (p *testPlugin) OnEnable(s *Server) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.enabled = true
+	event.Subscribe(s.Events(), event.Normal, func(e *CommandEvent) {
+		p.commands = append(p.commands, e.Command)
+		if e.Command == "stop" {
+			e.Cancelled = true
+		}
+	})
+	return nil
+}
+
+func (p *testPlugin) OnDisable() {
+	p.disabled = true
+}
+
+func TestPlugins(t *testing.T) {
+	t.Cleanup(func() {
+		plugins = nil
+	})
+	good := &testPlugin{name: "good"}
+	RegisterPlugin(good)
+	RegisterPlugin(&testPlugin{name: "broken", err: errors.New("broken")})
+	require.Panics(t, func() {
+		RegisterPlugin(&testPlugin{name: "good"})
+	})
+
+	srv := newTestServer(t)
+	srv.enablePlugins()
+	require.True(t, good.enabled)
+	require.Equal(t, []string{"good"}, srv.Plugins())
+	require.Equal(t, "mc-srv on 1.21: good", srv.QueryStats().Plugins)
+
+	srv.RunCommand(srv.Console(), "stop")
+	srv.RunCommand(srv.Console(), "seed")
+	require.Equal(t, []string{"stop", "seed"}, good.commands)
+	select {
+	case <-srv.Done():
+		t.Fatal("cancelled command ran")
+	default:
+	}
+
+	srv.disablePlugins()
+	require.True(t, good.disabled)
+}
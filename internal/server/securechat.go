@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/signing"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var errOutOfOrderChat = errors.New("out-of-order chat message")
+
+// vanillaChatFormat is how clients decorate signed messages themselves, other
+// formats are sent as unsigned content next to the signed message
+var vanillaChatFormat = text.Plain("<{player}> {message}")
+
+// chatSession is the signed message chain of a player
+type chatSession struct {
+	id            uuid.UUID
+	key           *signing.ProfileKey
+	nextIndex     int32
+	lastTimestamp time.Time
+}
+
+func (cs *chatSession) protocol() *protocol.ChatSession {
+	return &protocol.ChatSession{
+		ID:           cs.id,
+		ExpiresAt:    cs.key.ExpiresAt.UnixMilli(),
+		PublicKey:    cs.key.Encoded,
+		KeySignature: cs.key.Signature,
+	}
+}
+
+// handleChatSession validates the profile key the player will sign messages with
+func (s *Session) handleChatSession(p protocol.ChatSessionUpdatePacket) {
+	if s.server.trustRoot == nil {
+		log.Debug().Str("player", s.player.Name).Msg("ignoring chat session, no trusted keys")
+		return
+	}
+	key, err := signing.ValidateProfileKey(s.server.trustRoot, s.player.UUID, p.ExpiresAt, p.PublicKey, p.KeySignature, time.Now())
+	if err != nil {
+		log.Warn().Err(err).Str("player", s.player.Name).Msg("rejected chat session")
+		if errors.Is(err, signing.ErrKeyExpired) {
+			s.Kick("Expired profile public key")
+		} else {
+			s.Kick("Invalid profile public key")
+		}
+		return
+	}
+	s.chatSession = &chatSession{id: p.SessionID, key: key}
+	s.server.updateTabList(s, protocol.PlayerInfoInitializeChat)
+}
+
+// verifyChat checks the signature of p, unsigned messages give nil
+func (s *Session) verifyChat(p *protocol.ChatMessagePacket, lastSeen [][]byte) (*signing.Message, error) {
+	cs := s.chatSession
+	if cs == nil || p.Signature == nil {
+		return nil, nil
+	}
+	if !time.Now().Before(cs.key.ExpiresAt) {
+		return nil, signing.ErrKeyExpired
+	}
+	timestamp := time.UnixMilli(p.Timestamp)
+	if timestamp.Before(cs.lastTimestamp) {
+		return nil, errOutOfOrderChat
+	}
+	m := &signing.Message{
+		Sender:    s.player.UUID,
+		SessionID: cs.id,
+		Index:     cs.nextIndex,
+		Salt:      p.Salt,
+		Timestamp: timestamp,
+		Content:   p.Message,
+		LastSeen:  lastSeen,
+	}
+	err := signing.VerifyMessage(cs.key.Key, m, p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	cs.nextIndex++
+	cs.lastTimestamp = timestamp
+	return m, nil
+}
+
+// broadcastSigned sends a signed message of sender to everyone
+func (s *Server) broadcastSigned(sender *Session, m *signing.Message, signature []byte, format text.Component) {
+	formatted := formatMessage(format, sender.player.Name, m.Content)
+	log.Info().Str("message", formatted.String()).Msg("chat")
+	var unsigned *text.Component
+	if !reflect.DeepEqual(format, vanillaChatFormat) {
+		unsigned = &formatted
+	}
+	senderName := text.Plain(sender.player.Name)
+	if sender.player.DisplayName != nil {
+		senderName = *sender.player.DisplayName
+	}
+	for session := range s.sessions {
+		session.lastSeen.AddPending(signature)
+		session.queue(&protocol.PlayerChatPacket{
+			Sender:           m.Sender,
+			Index:            m.Index,
+			Signature:        signature,
+			Message:          m.Content,
+			Timestamp:        m.Timestamp.UnixMilli(),
+			Salt:             m.Salt,
+			PreviousMessages: m.LastSeen,
+			UnsignedContent:  unsigned,
+			ChatType:         protocol.ChatTypeChat,
+			SenderName:       senderName,
+		})
+	}
+}
+
+func (s *Session) handleChatAck(p protocol.ChatAckPacket) {
+	err := s.lastSeen.ApplyOffset(int(p.MessageCount))
+	if err != nil {
+		log.Warn().Err(err).Str("player", s.player.Name).Msg("rejected chat acknowledgement")
+		s.Kick("Chat message validation failure")
+	}
+}
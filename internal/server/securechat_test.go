@@ -0,0 +1,83 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/signing"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureChat(t *testing.T) {
+	root, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	player, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	srv := &Server{cfg: config.Default(), sessions: map[*Session]struct{}{}}
+	srv.SetTrustRoot(signing.RSATrustRoot{&root.PublicKey})
+	a := newTestSession(1, 0, 0)
+	a.server = srv
+	a.outCh = make(chan []byte, 1)
+	a.player.Name = "alice"
+	a.player.UUID = uuid.New()
+	b := newTestSession(2, 0, 0)
+	b.server = srv
+	srv.sessions[a] = struct{}{}
+	srv.sessions[b] = struct{}{}
+
+	encoded, err := x509.MarshalPKIXPublicKey(&player.PublicKey)
+	require.NoError(t, err)
+	expires := time.Now().Add(time.Hour).UnixMilli()
+	hash := sha1.Sum(signing.ProfileKeyPayload(a.player.UUID, expires, encoded))
+	keySig, err := rsa.SignPKCS1v15(rand.Reader, root, crypto.SHA1, hash[:])
+	require.NoError(t, err)
+	sessionID := uuid.New()
+	a.handleChatSession(protocol.ChatSessionUpdatePacket{
+		SessionID: sessionID, ExpiresAt: expires, PublicKey: encoded, KeySignature: keySig,
+	})
+	require.NotNil(t, a.chatSession)
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID}, queuedIDs(t, b))
+	queuedIDs(t, a)
+
+	sign := func(index int32, msg string) protocol.ChatMessagePacket {
+		now := time.Now()
+		m := signing.Message{
+			Sender: a.player.UUID, SessionID: sessionID, Index: index, Salt: 42, Timestamp: now, Content: msg,
+		}
+		hash := sha256.Sum256(m.Payload())
+		sig, err := rsa.SignPKCS1v15(rand.Reader, player, crypto.SHA256, hash[:])
+		require.NoError(t, err)
+		return protocol.ChatMessagePacket{Message: msg, Timestamp: now.UnixMilli(), Salt: 42, Signature: sig}
+	}
+
+	a.handleChat(sign(0, "hello"))
+	require.Equal(t, []int{protocol.PlayPlayerChatID}, queuedIDs(t, b))
+	require.False(t, a.closing)
+
+	// reusing the index breaks the chain
+	a.handleChat(sign(0, "again"))
+	require.True(t, a.closing)
+	require.Empty(t, queuedIDs(t, b))
+}
+
+func TestSecureChat_Enforce(t *testing.T) {
+	srv := &Server{cfg: config.Default(), sessions: map[*Session]struct{}{}}
+	srv.cfg.SecureChat.Enforce = true
+	a := newTestSession(1, 0, 0)
+	a.server = srv
+	a.outCh = make(chan []byte, 1)
+	srv.sessions[a] = struct{}{}
+
+	a.handleChat(protocol.ChatMessagePacket{Message: "unsigned"})
+	require.True(t, a.closing)
+}
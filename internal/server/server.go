@@ -3,27 +3,281 @@ package server
 import (
 	"context"
 	"fmt"
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/config"
 	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/metrics"
 	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/recipe"
+	"github.com/BinaryArchaism/mc-srv/internal/signing"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/BinaryArchaism/mc-srv/internal/world/anvil"
+	"github.com/BinaryArchaism/mc-srv/internal/world/generator"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"io"
+	"math/rand/v2"
 	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Server struct {
-	srv net.Listener
+	srv   net.Listener
+	cfg   config.Config
+	world *world.World
+
+	gameMode     GameMode
+	nextEntityID atomic.Int32
+
+	actionsMu sync.Mutex
+	actions   []func()
+
+	statsMu     sync.Mutex
+	stats       tickStats
+	currentTick atomic.Int64
+
+	// sessions holds players in play, it is used from the tick goroutine only
+	sessions map[*Session]struct{}
+	tracker  *entityTracker
+
+	// events is the bus plugins subscribe to, plugins are the enabled ones
+	events  event.Bus
+	plugins []Plugin
+
+	// channels maps plugin channels to their handlers
+	channelsMu sync.RWMutex
+	channels   map[string]ChannelHandler
+
+	trustRoot signing.TrustRoot
+	commands  *command.Dispatcher
+
+	// ops, bans and whitelist are safe for concurrent use, logins are
+	// checked against them on network goroutines
+	ops              *access.List[access.Op]
+	bans             *access.List[access.Ban]
+	whitelist        *access.List[access.WhitelistEntry]
+	whitelistEnabled atomic.Bool
+
+	// online is a copy of players in play for use outside the tick goroutine
+	onlineMu sync.RWMutex
+	online   []access.Profile
+	// favicon is the server list icon as a data URI
+	favicon string
+
+	// recipes are the crafting and smelting recipes, containers are the
+	// container blocks in use
+	recipes    *recipe.Book
+	containers map[world.BlockPos]*blockContainer
+	// playerData stores players between sessions, it is nil without a world directory
+	playerData *anvil.PlayerData
+
+	// weather is the weather players were last told about
+	weather world.Weather
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+
+	// loading holds chunks being loaded in background, loadSem limits how
+	// many are loaded at once
+	loadingMu sync.Mutex
+	loading   map[world.ChunkPos]struct{}
+	loadSem   chan struct{}
 }
 
-func New() (*Server, error) {
-	listener, err := net.Listen("tcp", "0.0.0.0:8080")
+func New(cfg config.Config) (*Server, error) {
+	gameMode, ok := ParseGameMode(cfg.GameMode)
+	if !ok {
+		return nil, fmt.Errorf("unknown game mode %q", cfg.GameMode)
+	}
+	var trustRoot signing.TrustRoot
+	if len(cfg.SecureChat.TrustedKeys) > 0 {
+		keys, err := signing.LoadTrustRoot(cfg.SecureChat.TrustedKeys...)
+		if err != nil {
+			return nil, err
+		}
+		trustRoot = keys
+	} else if cfg.SecureChat.Enforce {
+		log.Warn().Msg("secure chat is enforced without trusted keys, players won't be able to chat")
+	}
+	lists, err := loadAccessLists(cfg.Access)
+	if err != nil {
+		return nil, err
+	}
+	favicon, err := loadFavicon(cfg.Icon)
+	if err != nil {
+		return nil, err
+	}
+	recipes, err := loadRecipes(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWorld(cfg.Level)
+	if err != nil {
+		log.Err(err).Msg("Error creating world")
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", cfg.Address)
 	if err != nil {
 		log.Err(err).Msg("Error starting TCP server")
 		return nil, err
 	}
-	return &Server{
-		srv: listener,
-	}, nil
+	s := &Server{
+		srv:        listener,
+		cfg:        cfg,
+		world:      w,
+		gameMode:   gameMode,
+		trustRoot:  trustRoot,
+		favicon:    favicon,
+		recipes:    recipes,
+		containers: map[world.BlockPos]*blockContainer{},
+		commands:   command.NewDispatcher(),
+		ops:        lists.ops,
+		bans:       lists.bans,
+		whitelist:  lists.whitelist,
+		stopped:    make(chan struct{}),
+		sessions:   map[*Session]struct{}{},
+		tracker:    newEntityTracker(),
+		loading:    map[world.ChunkPos]struct{}{},
+		loadSem:    make(chan struct{}, generationWorkers(cfg.Level)),
+	}
+	if cfg.Level.Dir != "" {
+		s.playerData = anvil.NewPlayerData(cfg.Level.Dir)
+	}
+	s.whitelistEnabled.Store(cfg.Access.Whitelist)
+	s.weather = w.Weather()
+	for _, pos := range w.LoadedChunks() {
+		s.loadContainers(w.LoadedChunk(pos.X, pos.Z))
+	}
+	s.registerBuiltinCommands()
+	s.enablePlugins()
+	return s, nil
+}
+
+// loadRecipes reads the recipes of the vanilla data pack directory when one
+// is configured, otherwise the built in ones are used
+func loadRecipes(cfg config.Level) (*recipe.Book, error) {
+	if cfg.DataPack == "" {
+		return recipe.Vanilla(), nil
+	}
+	recipes, err := recipe.Load(os.DirFS(cfg.DataPack))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recipes: %w", err)
+	}
+	log.Info().Int("recipes", recipes.Len()).Msg("loaded recipes")
+	return recipes, nil
+}
+
+func generationWorkers(cfg config.Level) int {
+	if cfg.GenerationWorkers <= 0 {
+		return runtime.NumCPU()
+	}
+	return cfg.GenerationWorkers
+}
+
+func newWorld(cfg config.Level) (*world.World, error) {
+	var gen world.Generator
+	switch cfg.Type {
+	case config.LevelTypeDefault:
+		if cfg.Seed == 0 {
+			cfg.Seed = rand.Int64()
+		}
+		log.Info().Int64("seed", cfg.Seed).Msg("using noise terrain generator")
+		gen = generator.NewTerrain(cfg.Seed, world.OverworldMinY, world.OverworldHeight)
+	case config.LevelTypeVoid:
+		gen = generator.NewVoid()
+	case config.LevelTypeFlat:
+		layers := cfg.FlatLayers
+		if layers == "" {
+			layers = generator.DefaultFlatLayers
+		}
+		flat, err := generator.NewFlat(layers, world.OverworldMinY)
+		if err != nil {
+			return nil, err
+		}
+		gen = flat
+	default:
+		return nil, fmt.Errorf("unknown level type %q", cfg.Type)
+	}
+
+	opts := world.Options{
+		Seed:      cfg.Seed,
+		Generator: gen,
+	}
+	if cfg.Dir != "" {
+		opts.Storage = anvil.NewStorage(cfg.Dir, world.OverworldMinY, world.OverworldHeight)
+	}
+	w := world.New(opts)
+
+	workers := generationWorkers(cfg)
+	spawn := world.ChunkPosOf(w.Spawn().X, w.Spawn().Z)
+	var positions []world.ChunkPos
+	for x := -cfg.PreloadRadius; x <= cfg.PreloadRadius; x++ {
+		for z := -cfg.PreloadRadius; z <= cfg.PreloadRadius; z++ {
+			positions = append(positions, world.ChunkPos{X: spawn.X + int32(x), Z: spawn.Z + int32(z)})
+		}
+	}
+	started := time.Now()
+	err := w.Preload(context.Background(), positions, workers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to preload spawn chunks: %w", err)
+	}
+	log.Info().Int("chunks", len(positions)).Dur("took", time.Since(started)).Msg("spawn area prepared")
+	return w, nil
+}
+
+func (s *Server) World() *world.World {
+	return s.world
+}
+
+// SetTrustRoot replaces the keys profile keys of players are checked with, it must be called before Run
+func (s *Server) SetTrustRoot(root signing.TrustRoot) {
+	s.trustRoot = root
+}
+
+// Stop asks the server to shut down, it is safe to call from any goroutine
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+}
+
+// Done is closed once Stop is called or Run returns
+func (s *Server) Done() <-chan struct{} {
+	return s.stopped
+}
+
+// loadChunk loads or generates the chunk at pos in background so the tick
+// never waits for it
+func (s *Server) loadChunk(pos world.ChunkPos) {
+	s.loadingMu.Lock()
+	if _, ok := s.loading[pos]; ok {
+		s.loadingMu.Unlock()
+		return
+	}
+	s.loading[pos] = struct{}{}
+	s.loadingMu.Unlock()
+
+	go func() {
+		s.loadSem <- struct{}{}
+		chunk, err := s.world.Chunk(pos.X, pos.Z)
+		<-s.loadSem
+		if err != nil {
+			log.Err(err).Int32("x", pos.X).Int32("z", pos.Z).Msg("failed to load chunk")
+		} else {
+			s.Submit(func() {
+				s.loadContainers(chunk)
+			})
+		}
+		s.loadingMu.Lock()
+		delete(s.loading, pos)
+		s.loadingMu.Unlock()
+	}()
 }
 
 func (s *Server) Accept(ctx context.Context) error {
@@ -39,8 +293,10 @@ func (s *Server) Accept(ctx context.Context) error {
 		default:
 			conn, err := s.srv.Accept()
 			if err != nil {
-				fmt.Println("Error accepting connection:", err)
+				log.Err(err).Msg("Error accepting connection")
+				continue
 			}
+			metrics.ConnectionsAccepted.Inc()
 			log.Info().Str("accepting conn on address", conn.LocalAddr().String()).Msg("accept")
 			go s.Handle(conn)
 		}
@@ -50,7 +306,7 @@ func (s *Server) Accept(ctx context.Context) error {
 func (s *Server) Handle(conn net.Conn) {
 	defer func() {
 		if err := recover(); err != nil {
-			fmt.Println(err)
+			log.Error().Any("panic", err).Msg("Connection handler panicked")
 		}
 	}()
 	defer func(conn net.Conn) {
@@ -61,7 +317,8 @@ func (s *Server) Handle(conn net.Conn) {
 		log.Trace().Str("client", conn.RemoteAddr().String()).Msg("Connection closed")
 	}(conn)
 
-	session := NewSession(conn)
+	session := NewSession(s, conn)
+	session.UserConn = newMeteredConn(conn, func() State { return session.State })
 
 	err := session.Execute()
 	if err != nil {
@@ -74,7 +331,7 @@ func (s *Server) Handle(conn net.Conn) {
 func (s *Server) HandleConnection(conn net.Conn) {
 	defer func() {
 		if err := recover(); err != nil {
-			fmt.Println(err)
+			log.Error().Any("panic", err).Msg("Connection handler panicked")
 		}
 	}()
 	defer func(conn net.Conn) {
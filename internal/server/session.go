@@ -1,13 +1,22 @@
 package server
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"github.com/BinaryArchaism/mc-srv/internal/access"
 	"github.com/BinaryArchaism/mc-srv/internal/datatypes"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/metrics"
 	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/signing"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
 	"github.com/rs/zerolog/log"
 	"io"
 	"net"
+	"sync"
+	"time"
 )
 
 var (
@@ -33,16 +42,89 @@ const (
 type Session struct {
 	State    State
 	UserConn io.ReadWriter
+	// reader buffers UserConn once packets are read whole, from configuration on
+	reader *bufio.Reader
+
+	server *Server
+
+	clientInfo protocol.ClientInformationPacket
+	player     *Player
+
+	// clientBrand and clientChannels are what the client announced on plugin channels
+	clientBrand    string
+	clientChannels map[string]struct{}
+
+	// teleportID is the last teleport sent, awaitingTeleport is non zero until
+	// the client confirms it
+	teleportID       int32
+	awaitingTeleport int32
+
+	keepAliveID      int64
+	keepAliveSent    time.Time
+	keepAlivePending bool
+	latency          time.Duration
+
+	chunks   *chunkStreamer
+	move     moveState
+	dig      digState
+	chatSpam int
+
+	// inventory is the player inventory window, window is the container
+	// opened over it
+	inventory    *window
+	window       *window
+	lastWindowID byte
+	// carried is the stack held by the cursor in an open window
+	carried  ItemStack
+	drag     dragState
+	sneaking bool
+
+	chatSession *chatSession
+	lastSeen    *signing.LastSeenValidator
+
+	// writeMu keeps packets written from different goroutines whole
+	writeMu sync.Mutex
+
+	// outbound collects packets queued during a tick, flush hands them to
+	// the writer goroutine through outCh
+	outMu    sync.Mutex
+	outbound bytes.Buffer
+	outCh    chan []byte
+	closing  bool
 }
 
-func NewSession(userConn net.Conn) *Session {
+type packetWriter interface {
+	Write(w io.Writer) error
+}
+
+func (s *Session) send(p packetWriter) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return p.Write(s.UserConn)
+}
+
+func NewSession(server *Server, userConn net.Conn) *Session {
 	return &Session{
 		UserConn: userConn,
+		server:   server,
+		lastSeen: signing.NewLastSeenValidator(),
+	}
+}
+
+// setState moves the session to state, State is only changed by the session goroutine
+func (s *Session) setState(state State) {
+	if s.State != "" {
+		metrics.Sessions.WithLabelValues(string(s.State)).Dec()
 	}
+	s.State = state
+	metrics.Sessions.WithLabelValues(string(state)).Inc()
 }
 
 func (s *Session) Execute() error {
-	s.State = Handshake
+	s.setState(Handshake)
+	defer func() {
+		metrics.Sessions.WithLabelValues(string(s.State)).Dec()
+	}()
 	var hsPack protocol.HandshakePacket
 	err := hsPack.Read(s.UserConn)
 	if err != nil {
@@ -50,7 +132,7 @@ func (s *Session) Execute() error {
 	}
 	switch hsPack.NextState {
 	case statusState:
-		s.State = Status
+		s.setState(Status)
 		err := s.ProcessPingPongSession()
 		if err != nil {
 			log.Err(err).Msg("failed to process ping pong")
@@ -58,19 +140,22 @@ func (s *Session) Execute() error {
 		}
 
 	case loginStatus:
-		s.State = Login
+		s.setState(Login)
 		err := s.LoginSession()
+		if errors.Is(err, ErrLoginRejected) {
+			metrics.ConnectionsRejected.WithLabelValues("login").Inc()
+		}
 		if err != nil {
 			log.Err(err).Msg("failed to login")
 			return err
 		}
-		s.State = Configuration
+		s.setState(Configuration)
 		err = s.ConfigurationSession()
 		if err != nil {
 			log.Err(err).Msg("failed to configuration")
 			return err
 		}
-		s.State = Play
+		s.setState(Play)
 		err = s.PlaySession()
 		if err != nil {
 			log.Err(err).Msg("failed to play")
@@ -78,6 +163,7 @@ func (s *Session) Execute() error {
 		}
 
 	default:
+		metrics.ConnectionsRejected.WithLabelValues("handshake").Inc()
 		return ErrInvalidNextState
 	}
 	return nil
@@ -90,7 +176,12 @@ func (s *Session) ProcessPingPongSession() error {
 		return fmt.Errorf("failed to read statusRequest packet: %w", err)
 	}
 
-	var statusResponse protocol.StatusResponsePacket
+	ping := &StatusPingEvent{Address: s.remoteAddr(), Response: s.server.statusResponse()}
+	event.Fire(&s.server.events, ping)
+	if ping.Cancelled {
+		return nil
+	}
+	statusResponse := protocol.StatusResponsePacket{Response: ping.Response}
 	err = statusResponse.Write(s.UserConn)
 	if err != nil {
 		return fmt.Errorf("failed to write statusResponse packet: %w", err)
@@ -123,8 +214,21 @@ func (s *Session) LoginSession() error {
 	// TODO set compression
 	// compression skipped
 
-	// TODO check player availability to login
-	// player wont be disconnected
+	profile := access.Profile{UUID: loginPacket.PlayerUUID, Name: loginPacket.Name.Data}
+	reason, ok := s.server.checkLogin(profile)
+	if ok {
+		pre := &PlayerPreLoginEvent{Profile: profile, Address: s.remoteAddr(), Reason: text.Plain("You are not allowed to join this server")}
+		event.Fire(&s.server.events, pre)
+		reason, ok = pre.Reason, !pre.Cancelled
+	}
+	if !ok {
+		disconnect := protocol.LoginDisconnectPacket{Reason: reason}
+		err = disconnect.Write(s.UserConn)
+		if err != nil {
+			return fmt.Errorf("failed to write loginDisconnect packet: %w", err)
+		}
+		return fmt.Errorf("%w: %s: %s", ErrLoginRejected, profile.Name, reason.String())
+	}
 
 	loginSuccess := protocol.LoginSuccessPacket{
 		UUID:     loginPacket.PlayerUUID,
@@ -135,9 +239,11 @@ func (s *Session) LoginSession() error {
 		return fmt.Errorf("failed to write loginSuccess packet: %w", err)
 	}
 
+	// the client may send configuration packets right after the ack, they
+	// must stay buffered for ConfigurationSession
+	s.reader = bufio.NewReader(s.UserConn)
 	const loginProtocolID = 3
-	var loginAck protocol.Packet
-	err = loginAck.Read(s.UserConn)
+	loginAck, err := protocol.ReadPacket(s.reader)
 	if err != nil {
 		return fmt.Errorf("failed to read loginAck packet: %w", err)
 	}
@@ -146,27 +252,33 @@ func (s *Session) LoginSession() error {
 		return ErrFailedLogin
 	}
 
+	s.player = &Player{
+		UUID:     loginPacket.PlayerUUID,
+		Name:     loginPacket.Name.Data,
+		EntityID: s.server.nextEntityID.Add(1),
+		GameMode: s.server.gameMode,
+		Health:   maxHealth,
+
+		PermissionLevel: s.server.opLevel(loginPacket.PlayerUUID),
+	}
+	s.server.loadPlayer(s.player)
+
 	return nil
 }
 
 func (s *Session) ConfigurationSession() error {
-	var serverBoundPlugin protocol.ServerboundPluginPacket
-	err := serverBoundPlugin.Read(s.UserConn)
+	err := s.sendChannels()
 	if err != nil {
-		return fmt.Errorf("failed to read serverboundPligin packet: %w", err)
+		return err
 	}
 
-	serverBoundPlugin.Data = nil
-	serverBoundPlugin.Channel = datatypes.FromString("")
-	err = serverBoundPlugin.Write(s.UserConn)
+	p, err := s.awaitConfigPacket(protocol.ConfigServerClientInformationID)
 	if err != nil {
-		return fmt.Errorf("failed to write serverboundPligin packet: %w", err)
+		return fmt.Errorf("failed to read clientInfo packet: %w", err)
 	}
-
-	var clientInfo protocol.ClientInformationPacket
-	err = clientInfo.Read(s.UserConn)
+	err = s.clientInfo.Decode(p.Data)
 	if err != nil {
-		return fmt.Errorf("failed to read clientInfo packet: %w", err)
+		return fmt.Errorf("failed to decode clientInfo packet: %w", err)
 	}
 
 	featureFlag := protocol.FeatureFlagPacket{
@@ -193,8 +305,7 @@ func (s *Session) ConfigurationSession() error {
 		return fmt.Errorf("failed to write clientBoundKnownPacksPacket: %w", err)
 	}
 
-	var serverBoundKnownPacksPacket protocol.KnownPacksPacket
-	err = serverBoundKnownPacksPacket.Read(s.UserConn)
+	_, err = s.awaitConfigPacket(protocol.ConfigServerKnownPacksID)
 	if err != nil {
 		return fmt.Errorf("failed to read serverBoundKnownPacksPacket: %w", err)
 	}
@@ -211,8 +322,7 @@ func (s *Session) ConfigurationSession() error {
 		return fmt.Errorf("failed to write finishCfgPacker packet: %w", err)
 	}
 
-	var clientAckFinishCfgPacket protocol.Packet
-	err = clientAckFinishCfgPacket.Read(s.UserConn)
+	_, err = s.awaitConfigPacket(protocol.ConfigServerFinishAckID)
 	if err != nil {
 		return fmt.Errorf("failed to read clientAckFinishCfgPacket: %w", err)
 	}
@@ -220,34 +330,33 @@ func (s *Session) ConfigurationSession() error {
 	return nil
 }
 
-func (s *Session) PlaySession() error {
-	playLogin := protocol.LoginPlayPacket{
-		EntityID:            0,
-		IsHardcore:          false,
-		DimensionCount:      0,
-		DimensionNames:      nil,
-		MaxPlayers:          0,
-		ViewDistance:        0,
-		SimulationDistance:  0,
-		ReducedDebugInfo:    false,
-		EnableRespawnScreen: false,
-		DoLimitedCrafting:   false,
-		DimensionType:       0,
-		DimensionName:       datatypes.String{},
-		HashedSeed:          0,
-		GameMode:            0,
-		PreviousGameMode:    0,
-		IsDebug:             false,
-		IsFlat:              false,
-		HasDeathLocation:    false,
-		DeathDimensionName:  datatypes.String{},
-		DeathLocation:       datatypes.Position{},
-		PortalCooldown:      0,
-		EnforcesSecureChat:  false,
-	}
-	err := playLogin.Write(s.UserConn)
-	if err != nil {
-		return fmt.Errorf("failed to write playLogin packet: %w", err)
+// awaitConfigPacket reads configuration packets until one with id arrives,
+// plugin messages sent meanwhile are handled on the tick goroutine
+func (s *Session) awaitConfigPacket(id int) (protocol.PacketWithData, error) {
+	for {
+		p, err := protocol.ReadPacket(s.reader)
+		if err != nil {
+			return p, err
+		}
+		switch p.ID {
+		case id:
+			return p, nil
+
+		case protocol.ConfigServerPluginMessageID:
+			var msg protocol.PluginMessagePacket
+			err = msg.Decode(p.Data)
+			if err != nil {
+				return p, fmt.Errorf("failed to decode plugin message: %w", err)
+			}
+			if !s.server.call(func() { err = s.handlePluginMessage(msg) }) {
+				return p, ErrServerStopped
+			}
+			if err != nil {
+				return p, err
+			}
+
+		default:
+			log.Trace().Int("id", p.ID).Str("player", s.player.Name).Msg("unhandled configuration packet")
+		}
 	}
-	return nil
 }
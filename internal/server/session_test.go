@@ -0,0 +1,45 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginSession(t *testing.T) {
+	srv := newTestServer(t)
+	conn, client := net.Pipe()
+	defer client.Close()
+	s := NewSession(srv, conn)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.LoginSession() }()
+
+	var start protocol.Encoder
+	start.String("alice")
+	start.UUID(access.OfflineUUID("alice"))
+	require.NoError(t, protocol.WritePacket(client, 0x00, start.Data()))
+	success, err := protocol.ReadPacket(bufio.NewReader(client))
+	require.NoError(t, err)
+	require.Equal(t, 0x02, success.ID)
+
+	// the brand arrives in the same segment as the ack
+	var brand protocol.Encoder
+	brand.String(brandChannel)
+	brand.String("vanilla")
+	var segment byteSink
+	require.NoError(t, protocol.WritePacket(&segment, 0x03, nil))
+	require.NoError(t, protocol.WritePacket(&segment, protocol.ConfigServerPluginMessageID, brand.Data()))
+	_, err = client.Write(segment)
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+	require.Equal(t, "alice", s.player.Name)
+
+	p, err := protocol.ReadPacket(s.reader)
+	require.NoError(t, err)
+	require.Equal(t, protocol.ConfigServerPluginMessageID, p.ID)
+}
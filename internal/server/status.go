@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/query"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// serverBrand names the server software to query clients
+	serverBrand = "mc-srv"
+	// maxStatusSample is how many players the server list shows, as in vanilla
+	maxStatusSample = 12
+)
+
+// StatusInfo is what the server list ping and the query protocol report
+type StatusInfo struct {
+	MOTD       text.Component
+	MaxPlayers int
+	Players    []access.Profile
+	// Plugins are names of loaded plugins
+	Plugins []string
+}
+
+// loadFavicon reads the server list icon, a missing file gives no icon
+func loadFavicon(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warn().Str("path", path).Msg("server icon not found")
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read server icon: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// updateOnline must be called from the tick goroutine when players join or leave
+func (s *Server) updateOnline() {
+	players := s.players()
+	online := make([]access.Profile, len(players))
+	for i, p := range players {
+		online[i] = p.profile()
+	}
+	s.onlineMu.Lock()
+	s.online = online
+	s.onlineMu.Unlock()
+}
+
+// onlinePlayers returns players in play sorted by name, it is safe for concurrent use
+func (s *Server) onlinePlayers() []access.Profile {
+	s.onlineMu.RLock()
+	defer s.onlineMu.RUnlock()
+	return slices.Clone(s.online)
+}
+
+// StatusInfo returns the current status, it is safe for concurrent use
+func (s *Server) StatusInfo() StatusInfo {
+	return StatusInfo{
+		MOTD:       s.cfg.MOTD,
+		MaxPlayers: s.cfg.MaxPlayers,
+		Players:    s.onlinePlayers(),
+		Plugins:    s.Plugins(),
+	}
+}
+
+func (s *Server) statusResponse() protocol.JSONResponse {
+	st := s.StatusInfo()
+	sample := st.Players
+	if len(sample) > maxStatusSample {
+		rand.Shuffle(len(sample), func(i, j int) {
+			sample[i], sample[j] = sample[j], sample[i]
+		})
+		sample = sample[:maxStatusSample]
+	}
+	res := protocol.JSONResponse{
+		Version:            protocol.StatusVersion{Name: protocol.VersionName, Protocol: protocol.ProtocolVersion},
+		Players:            protocol.StatusPlayers{Max: st.MaxPlayers, Online: len(st.Players)},
+		Description:        st.MOTD,
+		Favicon:            s.favicon,
+		EnforcesSecureChat: s.cfg.SecureChat.Enforce,
+	}
+	for _, p := range sample {
+		res.Players.Sample = append(res.Players.Sample, protocol.StatusPlayer{Name: p.Name, ID: p.UUID.String()})
+	}
+	return res
+}
+
+// levelName is the map name reported to query clients
+func (s *Server) levelName() string {
+	if s.cfg.Level.Dir == "" {
+		return "world"
+	}
+	return filepath.Base(s.cfg.Level.Dir)
+}
+
+// QueryStats returns the status for the query protocol, it is safe for concurrent use
+func (s *Server) QueryStats() query.Stats {
+	st := s.StatusInfo()
+	host, portStr, _ := net.SplitHostPort(s.cfg.Address)
+	port, _ := strconv.Atoi(portStr)
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	plugins := serverBrand + " on " + protocol.VersionName
+	if len(st.Plugins) > 0 {
+		plugins += ": " + strings.Join(st.Plugins, "; ")
+	}
+	names := make([]string, len(st.Players))
+	for i, p := range st.Players {
+		names[i] = p.Name
+	}
+	return query.Stats{
+		MOTD:       st.MOTD.String(),
+		GameType:   "SMP",
+		GameID:     "MINECRAFT",
+		Version:    protocol.VersionName,
+		Plugins:    plugins,
+		Map:        s.levelName(),
+		NumPlayers: len(names),
+		MaxPlayers: st.MaxPlayers,
+		HostPort:   port,
+		HostIP:     host,
+		Players:    names,
+	}
+}
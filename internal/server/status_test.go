@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/access"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	srv := newTestServer(t)
+	addTestPlayer(srv, 1, "bob")
+	addTestPlayer(srv, 2, "alice")
+	srv.updateOnline()
+
+	res := srv.statusResponse()
+	require.Equal(t, protocol.ProtocolVersion, res.Version.Protocol)
+	require.Equal(t, 2, res.Players.Online)
+	require.Equal(t, []protocol.StatusPlayer{
+		{Name: "alice", ID: access.OfflineUUID("alice").String()},
+		{Name: "bob", ID: access.OfflineUUID("bob").String()},
+	}, res.Players.Sample)
+
+	stats := srv.QueryStats()
+	require.Equal(t, "Davai rabotai", stats.MOTD)
+	require.Equal(t, []string{"alice", "bob"}, stats.Players)
+	require.Equal(t, srv.cfg.MaxPlayers, stats.MaxPlayers)
+	require.Equal(t, "mc-srv on "+protocol.VersionName, stats.Plugins)
+}
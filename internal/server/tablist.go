@@ -0,0 +1,123 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/google/uuid"
+)
+
+const (
+	// latencyUpdateInterval is how often the tab list latency is updated, in ticks, as in vanilla
+	latencyUpdateInterval = 30 * TicksPerSecond
+
+	tabListJoinActions = protocol.PlayerInfoAddPlayer | protocol.PlayerInfoInitializeChat | protocol.PlayerInfoUpdateGameMode |
+		protocol.PlayerInfoUpdateListed | protocol.PlayerInfoUpdateLatency | protocol.PlayerInfoUpdateDisplayName
+)
+
+// broadcast queues p to every player in play
+func (s *Server) broadcast(p packetWriter) {
+	for session := range s.sessions {
+		session.queue(p)
+	}
+}
+
+func (s *Session) tabListEntry() protocol.PlayerInfoEntry {
+	var session *protocol.ChatSession
+	if s.chatSession != nil {
+		session = s.chatSession.protocol()
+	}
+	return protocol.PlayerInfoEntry{
+		UUID:        s.player.UUID,
+		ChatSession: session,
+		Name:        s.player.Name,
+		GameMode:    int32(s.player.GameMode),
+		Listed:      true,
+		Latency:     int32(s.latency.Milliseconds()),
+		DisplayName: s.player.DisplayName,
+	}
+}
+
+// addToTabList sends the tab list to the joining session s and adds s to the
+// tab list of everyone else, s must already be in the sessions
+func (s *Server) addToTabList(joined *Session) {
+	entries := make([]protocol.PlayerInfoEntry, 0, len(s.sessions))
+	for session := range s.sessions {
+		entries = append(entries, session.tabListEntry())
+	}
+	joined.queue(&protocol.PlayerInfoUpdatePacket{Actions: tabListJoinActions, Entries: entries})
+
+	update := &protocol.PlayerInfoUpdatePacket{
+		Actions: tabListJoinActions,
+		Entries: []protocol.PlayerInfoEntry{joined.tabListEntry()},
+	}
+	for session := range s.sessions {
+		if session != joined {
+			session.queue(update)
+		}
+	}
+	s.sendTabListHeader(joined)
+}
+
+// removeFromTabList removes the quitting session from the tab list of everyone else
+func (s *Server) removeFromTabList(quit *Session) {
+	remove := &protocol.PlayerInfoRemovePacket{UUIDs: []uuid.UUID{quit.player.UUID}}
+	for session := range s.sessions {
+		if session != quit {
+			session.queue(remove)
+		}
+	}
+}
+
+// updateTabList sends the given actions of session to everyone
+func (s *Server) updateTabList(session *Session, actions byte) {
+	s.broadcast(&protocol.PlayerInfoUpdatePacket{
+		Actions: actions,
+		Entries: []protocol.PlayerInfoEntry{session.tabListEntry()},
+	})
+}
+
+func (s *Server) tickTabList(tick int64) {
+	if tick%latencyUpdateInterval == 0 && len(s.sessions) > 0 {
+		entries := make([]protocol.PlayerInfoEntry, 0, len(s.sessions))
+		for session := range s.sessions {
+			entries = append(entries, session.tabListEntry())
+		}
+		s.broadcast(&protocol.PlayerInfoUpdatePacket{Actions: protocol.PlayerInfoUpdateLatency, Entries: entries})
+	}
+
+	refresh := int64(s.cfg.TabList.RefreshSeconds) * TicksPerSecond
+	if refresh > 0 && tick%refresh == 0 {
+		for session := range s.sessions {
+			s.sendTabListHeader(session)
+		}
+	}
+}
+
+func (s *Server) sendTabListHeader(session *Session) {
+	cfg := s.cfg.TabList
+	if cfg.Header.IsEmpty() && cfg.Footer.IsEmpty() {
+		return
+	}
+	session.queue(&protocol.TabListPacket{
+		Header: s.fillPlaceholders(cfg.Header, session),
+		Footer: s.fillPlaceholders(cfg.Footer, session),
+	})
+}
+
+// fillPlaceholders replaces tab list placeholders in c as seen by session
+func (s *Server) fillPlaceholders(c text.Component, session *Session) text.Component {
+	tps, mspt := s.TickStats()
+	for _, r := range [...][2]string{
+		{"{online}", strconv.Itoa(len(s.sessions))},
+		{"{max}", strconv.Itoa(s.cfg.MaxPlayers)},
+		{"{tps}", strconv.FormatFloat(tps, 'f', 1, 64)},
+		{"{mspt}", strconv.FormatFloat(mspt, 'f', 1, 64)},
+		{"{player}", session.player.Name},
+		{"{ping}", strconv.FormatInt(session.latency.Milliseconds(), 10)},
+	} {
+		c = c.Replace(r[0], r[1])
+	}
+	return c
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTabList(t *testing.T) {
+	cfg := config.Default()
+	cfg.TabList.Footer = text.Plain("{online}/{max} {player}")
+	srv := &Server{cfg: cfg, sessions: map[*Session]struct{}{}}
+
+	a := newTestSession(1, 0, 0)
+	a.server = srv
+	a.player.Name = "alice"
+	srv.sessions[a] = struct{}{}
+	srv.addToTabList(a)
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID, protocol.PlayTabListID}, queuedIDs(t, a))
+
+	b := newTestSession(2, 0, 0)
+	b.server = srv
+	b.player.Name = "bob"
+	srv.sessions[b] = struct{}{}
+	srv.addToTabList(b)
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID}, queuedIDs(t, a))
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID, protocol.PlayTabListID}, queuedIDs(t, b))
+	require.Equal(t, text.Plain("2/100 bob"), srv.fillPlaceholders(cfg.TabList.Footer, b))
+
+	b.SetDisplayName(&text.Component{Text: "Bob", Color: "red"})
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID}, queuedIDs(t, a))
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID}, queuedIDs(t, b))
+
+	srv.tickTabList(latencyUpdateInterval)
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID, protocol.PlayTabListID}, queuedIDs(t, a))
+	require.Equal(t, []int{protocol.PlayPlayerInfoUpdateID, protocol.PlayTabListID}, queuedIDs(t, b))
+
+	srv.removeFromTabList(b)
+	delete(srv.sessions, b)
+	require.Equal(t, []int{protocol.PlayPlayerInfoRemoveID}, queuedIDs(t, a))
+	require.Empty(t, queuedIDs(t, b))
+}
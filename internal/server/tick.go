@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TicksPerSecond = 20
+	tickDuration   = time.Second / TicksPerSecond
+
+	// maxTickLag is how far behind the loop may fall before ticks are skipped
+	// instead of being caught up
+	maxTickLag = 2 * time.Second
+	// tickStatsWindow is the number of ticks TPS and MSPT are averaged over
+	tickStatsWindow = 100
+	// timeSyncInterval is how often clients get the world time, in ticks
+	timeSyncInterval = TicksPerSecond
+)
+
+// tickStats keeps start times and durations of the last ticks
+type tickStats struct {
+	starts    [tickStatsWindow]time.Time
+	durations [tickStatsWindow]time.Duration
+	count     int
+}
+
+func (ts *tickStats) add(start time.Time, took time.Duration) {
+	i := ts.count % tickStatsWindow
+	ts.starts[i] = start
+	ts.durations[i] = took
+	ts.count++
+}
+
+// tps returns ticks per second over the window, capped at TicksPerSecond
+func (ts *tickStats) tps() float64 {
+	n := min(ts.count, tickStatsWindow)
+	if n < 2 {
+		return TicksPerSecond
+	}
+	first := ts.starts[(ts.count-n)%tickStatsWindow]
+	last := ts.starts[(ts.count-1)%tickStatsWindow]
+	elapsed := last.Sub(first).Seconds()
+	if elapsed <= 0 {
+		return TicksPerSecond
+	}
+	return min(float64(n-1)/elapsed, TicksPerSecond)
+}
+
+// mspt returns average tick duration in milliseconds
+func (ts *tickStats) mspt() float64 {
+	n := min(ts.count, tickStatsWindow)
+	if n == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for i := range n {
+		sum += ts.durations[i]
+	}
+	return float64(sum) / float64(n) / float64(time.Millisecond)
+}
+
+// Submit queues fn to run on the tick goroutine at the start of the next tick.
+// Game state is only changed from there, network goroutines submit actions.
+func (s *Server) Submit(fn func()) {
+	s.actionsMu.Lock()
+	s.actions = append(s.actions, fn)
+	s.actionsMu.Unlock()
+}
+
+// TickStats returns ticks per second and milliseconds per tick averaged over the last ticks
+func (s *Server) TickStats() (tps, mspt float64) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return s.stats.tps(), s.stats.mspt()
+}
+
+// CurrentTick returns the number of ticks run since start
+func (s *Server) CurrentTick() int64 {
+	return s.currentTick.Load()
+}
+
+// Run ticks the server at TicksPerSecond until ctx is done. When a tick
+// overruns the following ones run back to back to catch up, if the loop
+// falls more than maxTickLag behind the missed ticks are skipped. Players
+// are saved and disconnected and plugins disabled when it returns, then
+// Done is closed so that nothing waits for the tick anymore.
+func (s *Server) Run(ctx context.Context) {
+	defer s.Stop()
+	defer s.disablePlugins()
+	defer s.disconnectAll()
+	next := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		if wait := time.Until(next); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		if lag := start.Sub(next); lag > maxTickLag {
+			skipped := int64(lag / tickDuration)
+			log.Warn().Dur("behind", lag).Int64("skipped", skipped).Msg("can't keep up, skipping ticks")
+			next = start
+		}
+
+		s.tick()
+
+		took := time.Since(start)
+		s.statsMu.Lock()
+		s.stats.add(start, took)
+		s.statsMu.Unlock()
+		next = next.Add(tickDuration)
+	}
+}
+
+func (s *Server) tick() {
+	tick := s.currentTick.Add(1)
+
+	s.actionsMu.Lock()
+	actions := s.actions
+	s.actions = nil
+	s.actionsMu.Unlock()
+	for _, fn := range actions {
+		fn()
+	}
+
+	s.world.Tick()
+	s.tickWeather()
+	s.tickContainers()
+
+	for session := range s.sessions {
+		session.tick(tick)
+	}
+	s.tracker.tick(s.sessions)
+	s.tickTabList(tick)
+	if tick%chunkUnloadInterval == 0 {
+		s.unloadChunks()
+	}
+	if tick%autosaveInterval == 0 {
+		s.savePlayers()
+		s.saveWorld()
+	}
+
+	for session := range s.sessions {
+		session.flush()
+	}
+}
+
+// tickWeather tells players when the world weather changes
+func (s *Server) tickWeather() {
+	weather := s.world.Weather()
+	if weather == s.weather {
+		return
+	}
+	s.weather = weather
+	for session := range s.sessions {
+		session.sendWeather(weather)
+	}
+}
+
+// disconnectAll saves and kicks everyone when the server stops
+func (s *Server) disconnectAll() {
+	for session := range s.sessions {
+		session.closeWindow()
+		s.savePlayer(session)
+		session.Kick("Server closed")
+	}
+}
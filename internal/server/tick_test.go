@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTickStats(t *testing.T) {
+	var ts tickStats
+	require.Equal(t, float64(TicksPerSecond), ts.tps())
+	require.Zero(t, ts.mspt())
+
+	start := time.Now()
+	// a tick every 100ms is half speed
+	for i := range tickStatsWindow + 10 {
+		ts.add(start.Add(time.Duration(i)*100*time.Millisecond), 10*time.Millisecond)
+	}
+	require.InDelta(t, 10, ts.tps(), 0.001)
+	require.InDelta(t, 10, ts.mspt(), 0.001)
+
+	// ticks faster than the target don't report more than TicksPerSecond
+	ts = tickStats{}
+	for i := range 10 {
+		ts.add(start.Add(time.Duration(i)*time.Millisecond), time.Millisecond)
+	}
+	require.Equal(t, float64(TicksPerSecond), ts.tps())
+}
+
+func TestRunStops(t *testing.T) {
+	srv := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	srv.Run(ctx)
+
+	// nothing waits for a tick that won't come
+	require.False(t, srv.call(func() {}))
+}
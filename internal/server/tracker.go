@@ -0,0 +1,182 @@
+package server
+
+import (
+	"math"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+)
+
+const (
+	// playerTrackingRange is the vanilla tracking range of players in blocks,
+	// it is further limited by the view distance of the viewer
+	playerTrackingRange = 512
+	// teleportInterval forces an absolute position every so many ticks so
+	// rounding errors of relative moves don't add up
+	teleportInterval = 400
+)
+
+// trackedEntity is an entity with the state its viewers last received
+type trackedEntity struct {
+	session *Session
+	viewers map[*Session]struct{}
+
+	// position in protocol.DeltaScale units and angles as last sent
+	x, y, z             int64
+	yaw, pitch, headYaw byte
+	onGround            bool
+	sinceTeleport       int
+}
+
+// entityTracker decides which sessions see which entities and keeps their
+// view in sync. Only players are entities for now, their tab list entries
+// must be sent before they are shown. It is used from the tick goroutine only.
+type entityTracker struct {
+	entities map[int32]*trackedEntity
+}
+
+func newEntityTracker() *entityTracker {
+	return &entityTracker{entities: map[int32]*trackedEntity{}}
+}
+
+func encodePosition(v float64) int64 {
+	return int64(math.Round(v * protocol.DeltaScale))
+}
+
+// add starts tracking the player of session s
+func (t *entityTracker) add(s *Session) {
+	e := &trackedEntity{session: s, viewers: map[*Session]struct{}{}}
+	e.sync()
+	t.entities[s.player.EntityID] = e
+}
+
+// remove stops tracking the player of session s and hides it from everyone,
+// s also stops seeing other entities
+func (t *entityTracker) remove(s *Session) {
+	if e, ok := t.entities[s.player.EntityID]; ok {
+		for viewer := range e.viewers {
+			e.hide(viewer)
+		}
+		delete(t.entities, s.player.EntityID)
+	}
+	for _, e := range t.entities {
+		delete(e.viewers, s)
+	}
+}
+
+// sync stores the current state of the entity as sent
+func (e *trackedEntity) sync() {
+	p := e.session.player
+	e.x, e.y, e.z = encodePosition(p.X), encodePosition(p.Y), encodePosition(p.Z)
+	e.yaw, e.pitch, e.headYaw = protocol.Angle(p.Yaw), protocol.Angle(p.Pitch), protocol.Angle(p.Yaw)
+	e.onGround = p.OnGround
+	e.sinceTeleport = 0
+}
+
+// visibleTo reports whether viewer is close enough to see the entity
+func (e *trackedEntity) visibleTo(viewer *Session) bool {
+	if viewer == e.session {
+		return false
+	}
+	p, v := e.session.player, viewer.player
+	r := float64(min(playerTrackingRange, viewer.chunks.ViewDistance()*16))
+	return math.Abs(p.X-v.X) <= r && math.Abs(p.Z-v.Z) <= r
+}
+
+func (e *trackedEntity) show(viewer *Session) {
+	p := e.session.player
+	e.viewers[viewer] = struct{}{}
+	viewer.queue(&protocol.AddEntityPacket{
+		EntityID: p.EntityID,
+		UUID:     p.UUID,
+		Type:     protocol.EntityTypePlayer,
+		X:        float64(e.x) / protocol.DeltaScale,
+		Y:        float64(e.y) / protocol.DeltaScale,
+		Z:        float64(e.z) / protocol.DeltaScale,
+		Pitch:    e.pitch,
+		Yaw:      e.yaw,
+		HeadYaw:  e.headYaw,
+	})
+	viewer.queue(&protocol.RotateHeadPacket{EntityID: p.EntityID, HeadYaw: e.headYaw})
+}
+
+func (e *trackedEntity) hide(viewer *Session) {
+	p := e.session.player
+	delete(e.viewers, viewer)
+	viewer.queue(&protocol.RemoveEntitiesPacket{EntityIDs: []int32{p.EntityID}})
+}
+
+// tick updates viewers of every entity and sends them what changed
+func (t *entityTracker) tick(sessions map[*Session]struct{}) {
+	for _, e := range t.entities {
+		e.sendChanges()
+		for viewer := range sessions {
+			_, seen := e.viewers[viewer]
+			visible := e.visibleTo(viewer)
+			switch {
+			case visible && !seen:
+				e.show(viewer)
+			case !visible && seen:
+				e.hide(viewer)
+			}
+		}
+	}
+}
+
+// sendChanges sends position and rotation changes since the last tick to viewers
+func (e *trackedEntity) sendChanges() {
+	p := e.session.player
+	x, y, z := encodePosition(p.X), encodePosition(p.Y), encodePosition(p.Z)
+	yaw, pitch := protocol.Angle(p.Yaw), protocol.Angle(p.Pitch)
+	headYaw := yaw
+	e.sinceTeleport++
+
+	moved := x != e.x || y != e.y || z != e.z
+	rotated := yaw != e.yaw || pitch != e.pitch
+	dx, dy, dz := x-e.x, y-e.y, z-e.z
+
+	var packets []packetWriter
+	switch {
+	case moved && (!fitsShort(dx) || !fitsShort(dy) || !fitsShort(dz) || e.sinceTeleport >= teleportInterval):
+		packets = append(packets, &protocol.TeleportEntityPacket{
+			EntityID: p.EntityID,
+			X:        p.X,
+			Y:        p.Y,
+			Z:        p.Z,
+			Yaw:      yaw,
+			Pitch:    pitch,
+			OnGround: p.OnGround,
+		})
+		e.sinceTeleport = 0
+	case moved || rotated || p.OnGround != e.onGround:
+		packets = append(packets, &protocol.MoveEntityPacket{
+			EntityID:    p.EntityID,
+			DeltaX:      int16(dx),
+			DeltaY:      int16(dy),
+			DeltaZ:      int16(dz),
+			Yaw:         yaw,
+			Pitch:       pitch,
+			OnGround:    p.OnGround,
+			HasPosition: moved,
+			HasRotation: rotated || !moved,
+		})
+	}
+	if headYaw != e.headYaw {
+		packets = append(packets, &protocol.RotateHeadPacket{EntityID: p.EntityID, HeadYaw: headYaw})
+	}
+
+	e.x, e.y, e.z = x, y, z
+	e.yaw, e.pitch, e.headYaw = yaw, pitch, headYaw
+	e.onGround = p.OnGround
+	if len(packets) == 0 {
+		return
+	}
+	for viewer := range e.viewers {
+		for _, packet := range packets {
+			viewer.queue(packet)
+		}
+	}
+}
+
+func fitsShort(v int64) bool {
+	return v >= math.MinInt16 && v <= math.MaxInt16
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/signing"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSession(id int32, x, z float64) *Session {
+	p := &Player{EntityID: id, X: x, Z: z}
+	return &Session{player: p, chunks: newChunkStreamer(p.ChunkPos(), 2), lastSeen: signing.NewLastSeenValidator()}
+}
+
+// queuedIDs returns ids of packets queued to s and clears its queue
+func queuedIDs(t *testing.T, s *Session) []int {
+	t.Helper()
+	r := bytes.NewReader(bytes.Clone(s.outbound.Bytes()))
+	s.outbound.Reset()
+	var ids []int
+	for r.Len() > 0 {
+		p, err := protocol.ReadPacket(r)
+		require.NoError(t, err)
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestEntityTracker(t *testing.T) {
+	a := newTestSession(1, 0, 0)
+	b := newTestSession(2, 10, 0)
+	sessions := map[*Session]struct{}{a: {}, b: {}}
+	tracker := newEntityTracker()
+	tracker.add(a)
+	tracker.add(b)
+
+	tracker.tick(sessions)
+	spawn := []int{protocol.PlayAddEntityID, protocol.PlayRotateHeadID}
+	require.Equal(t, spawn, queuedIDs(t, a))
+	require.Equal(t, spawn, queuedIDs(t, b))
+
+	tracker.tick(sessions)
+	require.Empty(t, queuedIDs(t, a))
+
+	b.player.X = 11
+	tracker.tick(sessions)
+	require.Equal(t, []int{protocol.PlayMoveEntityPosID}, queuedIDs(t, a))
+
+	b.player.Yaw = 90
+	tracker.tick(sessions)
+	require.Equal(t, []int{protocol.PlayMoveEntityRotID, protocol.PlayRotateHeadID}, queuedIDs(t, a))
+
+	b.player.X = 20
+	tracker.tick(sessions)
+	require.Equal(t, []int{protocol.PlayTeleportEntityID}, queuedIDs(t, a))
+
+	// view distance 2 sees 32 blocks away
+	b.player.X = 100
+	tracker.tick(sessions)
+	despawn := []int{protocol.PlayRemoveEntitiesID}
+	require.Equal(t, append([]int{protocol.PlayTeleportEntityID}, despawn...), queuedIDs(t, a))
+	require.Equal(t, despawn, queuedIDs(t, b))
+
+	b.player.X = 5
+	tracker.tick(sessions)
+	require.Equal(t, spawn, queuedIDs(t, a))
+	queuedIDs(t, b)
+
+	tracker.remove(b)
+	delete(sessions, b)
+	require.Equal(t, despawn, queuedIDs(t, a))
+	tracker.tick(sessions)
+	require.Empty(t, queuedIDs(t, a))
+	require.Empty(t, queuedIDs(t, b))
+}
+
+func TestEncodePosition(t *testing.T) {
+	require.Equal(t, int64(4096), encodePosition(1))
+	require.Equal(t, int64(-2048), encodePosition(-0.5))
+	require.True(t, fitsShort(encodePosition(7.9)))
+	require.False(t, fitsShort(encodePosition(8)))
+}
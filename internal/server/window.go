@@ -0,0 +1,324 @@
+package server
+
+import (
+	"github.com/BinaryArchaism/mc-srv/internal/protocol"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxWindowID is the highest id of opened windows, ids wrap around as in vanilla
+	maxWindowID = 100
+	// maxWindowDistance is how far players may move away from an open container
+	maxWindowDistance = 8
+)
+
+// menu gives the slots of a window their rules. Slots are numbered the way
+// the client numbers them.
+type menu interface {
+	slots() []*ItemStack
+	mayPlace(i int, stack ItemStack) bool
+	slotLimit(i int, stack ItemStack) int
+	// quickMove returns the slots a shift clicked stack from slot i moves to in order
+	quickMove(i int, stack ItemStack) []int
+	// result reports whether slot i is a crafting result, it is taken whole
+	// and taking it calls taken
+	result(i int) bool
+	taken(i int)
+	// changed is called after slots were changed by a click
+	changed()
+	// closed returns items that don't stay in the window to the player
+	closed()
+}
+
+// propertyMenu is a menu with properties such as furnace progress
+type propertyMenu interface {
+	properties() []int16
+}
+
+// validMenu is a menu that closes once the player can't use it anymore
+type validMenu interface {
+	valid() bool
+}
+
+// window is an open menu, it keeps what the client shows so changes can be
+// sent as they happen
+type window struct {
+	id      byte
+	menu    menu
+	stateID int32
+	// remote is what the client shows
+	remote        []ItemStack
+	remoteCarried ItemStack
+	remoteProps   []int16
+}
+
+func newWindow(id byte, m menu) *window {
+	return &window{id: id, menu: m, remote: make([]ItemStack, len(m.slots()))}
+}
+
+// inventoryWindow returns the player inventory window, it is always open
+// underneath other windows
+func (s *Session) inventoryWindow() *window {
+	if s.inventory == nil {
+		s.inventory = newWindow(protocol.PlayerInventoryWindow, &inventoryMenu{s: s})
+	}
+	return s.inventory
+}
+
+// currentWindow returns the open window or the player inventory
+func (s *Session) currentWindow() *window {
+	if s.window != nil {
+		return s.window
+	}
+	return s.inventoryWindow()
+}
+
+// Window slots of the player inventory shown below container slots
+const (
+	playerMainSlots = hotbarSlotStart - mainSlotsStart
+	playerSlotCount = hotbarSlotEnd - mainSlotsStart
+)
+
+// playerSlots returns the main inventory and the hotbar in the order
+// container windows show them
+func (s *Session) playerSlots() []*ItemStack {
+	res := make([]*ItemStack, 0, playerSlotCount)
+	for i := mainSlotsStart; i < hotbarSlotEnd; i++ {
+		res = append(res, &s.player.Inventory.Slots[i])
+	}
+	return res
+}
+
+// playerQuickMove returns where a shift click moves slot i of the player
+// slots starting at window slot start: main inventory and hotbar trade items
+func playerQuickMove(i, start int) []int {
+	if i < start+playerMainSlots {
+		return slotRange(start+playerMainSlots, start+playerSlotCount)
+	}
+	return slotRange(start, start+playerMainSlots)
+}
+
+// nearBlock reports whether the player is close enough to pos to keep its window open
+func (s *Session) nearBlock(pos world.BlockPos) bool {
+	p := s.player
+	dx := p.X - (float64(pos.X) + 0.5)
+	dy := p.Y - (float64(pos.Y) + 0.5)
+	dz := p.Z - (float64(pos.Z) + 0.5)
+	return dx*dx+dy*dy+dz*dz <= maxWindowDistance*maxWindowDistance
+}
+
+func (s *Session) nextWindowID() byte {
+	s.lastWindowID = s.lastWindowID%maxWindowID + 1
+	return s.lastWindowID
+}
+
+// openWindow closes the open window and shows m to the player
+func (s *Session) openWindow(menuType int32, title text.Component, m menu) *window {
+	s.forceCloseWindow()
+	w := newWindow(s.nextWindowID(), m)
+	s.window = w
+	s.queue(&protocol.OpenScreenPacket{WindowID: int32(w.id), Menu: menuType, Title: title})
+	s.sendWindow(w)
+	return w
+}
+
+// closeWindow closes the open window without telling the client, items on
+// the cursor go back to the inventory
+func (s *Session) closeWindow() {
+	s.drag = dragState{}
+	if !s.carried.IsEmpty() {
+		s.returnToInventory(s.carried)
+		s.carried = ItemStack{}
+	}
+	s.currentWindow().menu.closed()
+	s.window = nil
+	s.syncWindow()
+}
+
+// forceCloseWindow closes the open window on the server request
+func (s *Session) forceCloseWindow() {
+	if s.window == nil {
+		return
+	}
+	id := s.window.id
+	s.closeWindow()
+	s.queue(&protocol.CloseContainerPacket{WindowID: id})
+}
+
+// returnToInventory adds stack to the inventory, items that don't fit are
+// lost as dropping items isn't supported
+func (s *Session) returnToInventory(stack ItemStack) {
+	_, left := s.player.Inventory.Add(stack)
+	if left > 0 {
+		log.Debug().Str("player", s.player.Name).Str("item", stack.Item).Int("count", left).Msg("inventory full, items lost")
+	}
+}
+
+// sendWindow sends every slot of w and the carried stack
+func (s *Session) sendWindow(w *window) {
+	s.queue(s.windowContent(w))
+	s.syncProperties(w)
+}
+
+// windowContent returns every slot of w and the carried stack, the client
+// is assumed to show them from now on
+func (s *Session) windowContent(w *window) *protocol.SetContainerContentPacket {
+	w.stateID++
+	slots := w.menu.slots()
+	p := &protocol.SetContainerContentPacket{
+		WindowID: w.id,
+		StateID:  w.stateID,
+		Slots:    make([]protocol.Slot, len(slots)),
+		Carried:  s.carried.protocol(),
+	}
+	for i, stack := range slots {
+		p.Slots[i] = stack.protocol()
+		w.remote[i] = stack.withCount(stack.Count)
+	}
+	w.remoteCarried = s.carried.withCount(s.carried.Count)
+	w.remoteProps = nil
+	return p
+}
+
+// syncWindow sends the slots of the open window that changed since the
+// client last saw them
+func (s *Session) syncWindow() {
+	w := s.currentWindow()
+	for i, stack := range w.menu.slots() {
+		if sameStack(*stack, w.remote[i]) {
+			continue
+		}
+		w.remote[i] = stack.withCount(stack.Count)
+		w.stateID++
+		s.queue(&protocol.SetContainerSlotPacket{
+			WindowID: w.id,
+			StateID:  w.stateID,
+			Slot:     int16(i),
+			Item:     stack.protocol(),
+		})
+	}
+	if !sameStack(s.carried, w.remoteCarried) {
+		w.remoteCarried = s.carried.withCount(s.carried.Count)
+		w.stateID++
+		s.queue(&protocol.SetContainerSlotPacket{
+			WindowID: protocol.CarriedWindow,
+			StateID:  w.stateID,
+			Slot:     protocol.CarriedSlot,
+			Item:     s.carried.protocol(),
+		})
+	}
+	s.syncProperties(w)
+}
+
+func (s *Session) syncProperties(w *window) {
+	m, ok := w.menu.(propertyMenu)
+	if !ok {
+		return
+	}
+	props := m.properties()
+	if w.remoteProps == nil {
+		w.remoteProps = make([]int16, len(props))
+		for i := range w.remoteProps {
+			w.remoteProps[i] = -1
+		}
+	}
+	for i, v := range props {
+		if w.remoteProps[i] == v {
+			continue
+		}
+		w.remoteProps[i] = v
+		s.queue(&protocol.ContainerPropertyPacket{WindowID: w.id, Property: int16(i), Value: v})
+	}
+}
+
+// tickWindow closes windows the player can't use anymore and sends changes
+// made by others, such as furnace progress
+func (s *Session) tickWindow() {
+	if s.window != nil {
+		if v, ok := s.window.menu.(validMenu); ok && !v.valid() {
+			s.forceCloseWindow()
+		}
+	}
+	s.syncWindow()
+}
+
+func sameStack(a, b ItemStack) bool {
+	if a.IsEmpty() || b.IsEmpty() {
+		return a.IsEmpty() == b.IsEmpty()
+	}
+	return a.Count == b.Count && a.Stacks(b)
+}
+
+// handleClickContainer applies a click and sends the client every slot it
+// predicted differently
+func (s *Session) handleClickContainer(p protocol.ClickContainerPacket) {
+	w := s.currentWindow()
+	if p.WindowID != w.id {
+		return
+	}
+	n := len(w.menu.slots())
+	slot := int(p.Slot)
+	if slot != outsideSlot && (slot < 0 || slot >= n) {
+		log.Debug().Str("player", s.player.Name).Int("slot", slot).Msg("click on an invalid slot")
+		return
+	}
+
+	s.click(w, slot, int(p.Button), p.Mode)
+	w.menu.changed()
+
+	// the client already shows its prediction
+	resync := p.StateID != w.stateID
+	for _, c := range p.Changed {
+		stack, ok := stackFromProtocol(c.Item)
+		if !ok || c.Slot < 0 || int(c.Slot) >= n {
+			resync = true
+			continue
+		}
+		w.remote[c.Slot] = stack
+	}
+	carried, ok := stackFromProtocol(p.Carried)
+	if !ok {
+		resync = true
+	}
+	w.remoteCarried = carried
+
+	if resync {
+		s.sendWindow(w)
+		return
+	}
+	s.syncWindow()
+}
+
+// handleCloseContainer runs when the player closes a window
+func (s *Session) handleCloseContainer(p protocol.CloseContainerPacket) {
+	if p.WindowID != s.currentWindow().id {
+		return
+	}
+	s.closeWindow()
+}
+
+// handleSetCreativeSlot sets a player inventory slot picked from the
+// creative inventory
+func (s *Session) handleSetCreativeSlot(p protocol.SetCreativeSlotPacket) {
+	if s.player.GameMode != Creative {
+		log.Warn().Str("player", s.player.Name).Msg("creative slot set outside of creative mode")
+		s.sendWindow(s.inventoryWindow())
+		return
+	}
+	slot := int(p.Slot)
+	if slot < 0 {
+		// dropping items is not supported
+		return
+	}
+	w := s.inventoryWindow()
+	stack, ok := stackFromProtocol(p.Item)
+	if !ok || slot == craftResultSlot || slot >= inventorySize || stack.Count > maxCreativeStack {
+		s.sendWindow(w)
+		return
+	}
+	s.player.Inventory.Slots[slot] = stack
+	w.remote[slot] = stack
+	w.menu.changed()
+}
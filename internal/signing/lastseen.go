@@ -0,0 +1,82 @@
+package signing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// LastSeenCount is the number of messages a client acknowledges at once
+const LastSeenCount = 20
+
+var ErrLastSeen = errors.New("invalid last seen messages")
+
+type trackedMessage struct {
+	signature []byte
+	pending   bool
+}
+
+// LastSeenValidator tracks signed messages sent to a player and resolves the
+// acknowledgements the player sends with its own messages, the same way as
+// vanilla does
+type LastSeenValidator struct {
+	// tracked starts with LastSeenCount empty entries, acknowledged bits
+	// refer to its first LastSeenCount entries
+	tracked     []*trackedMessage
+	lastPending []byte
+}
+
+func NewLastSeenValidator() *LastSeenValidator {
+	return &LastSeenValidator{tracked: make([]*trackedMessage, LastSeenCount)}
+}
+
+// AddPending tracks a signed message sent to the player
+func (v *LastSeenValidator) AddPending(signature []byte) {
+	if bytes.Equal(signature, v.lastPending) {
+		return
+	}
+	v.tracked = append(v.tracked, &trackedMessage{signature: signature, pending: true})
+	v.lastPending = signature
+}
+
+// ApplyOffset drops offset messages the player won't refer to anymore
+func (v *LastSeenValidator) ApplyOffset(offset int) error {
+	limit := len(v.tracked) - LastSeenCount
+	if offset < 0 || offset > limit {
+		return fmt.Errorf("%w: advanced by %d messages, expected at most %d", ErrLastSeen, offset, limit)
+	}
+	v.tracked = append(v.tracked[:0], v.tracked[offset:]...)
+	return nil
+}
+
+// ApplyUpdate applies offset and returns signatures of acknowledged messages,
+// acknowledged is a little endian bitset of LastSeenCount bits
+func (v *LastSeenValidator) ApplyUpdate(offset int, acknowledged []byte) ([][]byte, error) {
+	err := v.ApplyOffset(offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := LastSeenCount; i < len(acknowledged)*8; i++ {
+		if acknowledged[i/8]&(1<<(i%8)) != 0 {
+			return nil, fmt.Errorf("%w: more than %d entries", ErrLastSeen, LastSeenCount)
+		}
+	}
+	var res [][]byte
+	for i := range LastSeenCount {
+		ack := i/8 < len(acknowledged) && acknowledged[i/8]&(1<<(i%8)) != 0
+		m := v.tracked[i]
+		if ack {
+			if m == nil {
+				return nil, fmt.Errorf("%w: acknowledged unknown message at %d", ErrLastSeen, i)
+			}
+			v.tracked[i] = &trackedMessage{signature: m.signature}
+			res = append(res, m.signature)
+			continue
+		}
+		if m != nil && !m.pending {
+			return nil, fmt.Errorf("%w: ignored acknowledged message at %d", ErrLastSeen, i)
+		}
+		v.tracked[i] = nil
+	}
+	return res, nil
+}
@@ -0,0 +1,153 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidKey       = errors.New("invalid profile public key")
+	ErrKeyExpired       = errors.New("profile public key expired")
+	ErrUntrustedKey     = errors.New("profile public key is not signed by a trusted key")
+	ErrInvalidSignature = errors.New("invalid message signature")
+)
+
+// TrustRoot verifies signatures of profile public keys, Mojang signs the
+// keys of authenticated players
+type TrustRoot interface {
+	Verify(payload, signature []byte) error
+}
+
+// RSATrustRoot trusts profile keys signed with SHA1withRSA by any of its keys
+type RSATrustRoot []*rsa.PublicKey
+
+func (r RSATrustRoot) Verify(payload, signature []byte) error {
+	hash := sha1.Sum(payload)
+	for _, key := range r {
+		if rsa.VerifyPKCS1v15(key, crypto.SHA1, hash[:], signature) == nil {
+			return nil
+		}
+	}
+	return ErrUntrustedKey
+}
+
+// LoadTrustRoot reads RSA public keys from PEM or DER files. Mojang player
+// certificate keys are listed at https://api.minecraftservices.com/publickeys.
+func LoadTrustRoot(paths ...string) (RSATrustRoot, error) {
+	res := make(RSATrustRoot, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted key: %w", err)
+		}
+		if block, _ := pem.Decode(data); block != nil {
+			data = block.Bytes
+		}
+		key, err := parsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trusted key %s: %w", path, err)
+		}
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+func parsePublicKey(der []byte) (*rsa.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return rsaKey, nil
+}
+
+// ProfileKey is the public key a client signs its chat messages with
+type ProfileKey struct {
+	ExpiresAt time.Time
+	Key       *rsa.PublicKey
+	// Encoded is the key in X.509 form as sent by the client
+	Encoded   []byte
+	Signature []byte
+}
+
+// ProfileKeyPayload returns the bytes the trust root signs for a profile key of owner
+func ProfileKeyPayload(owner uuid.UUID, expiresAt int64, encoded []byte) []byte {
+	res := make([]byte, 0, 24+len(encoded))
+	res = append(res, owner[:]...)
+	res = binary.BigEndian.AppendUint64(res, uint64(expiresAt))
+	return append(res, encoded...)
+}
+
+// ValidateProfileKey checks the profile key of owner, expiresAt is in unix milliseconds
+func ValidateProfileKey(root TrustRoot, owner uuid.UUID, expiresAt int64, encoded, signature []byte, now time.Time) (*ProfileKey, error) {
+	expires := time.UnixMilli(expiresAt)
+	if !now.Before(expires) {
+		return nil, ErrKeyExpired
+	}
+	key, err := parsePublicKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+	if root == nil {
+		return nil, ErrUntrustedKey
+	}
+	err = root.Verify(ProfileKeyPayload(owner, expiresAt, encoded), signature)
+	if err != nil {
+		return nil, err
+	}
+	return &ProfileKey{ExpiresAt: expires, Key: key, Encoded: encoded, Signature: signature}, nil
+}
+
+// Message is the signed part of a chat message. Sender, SessionID and Index
+// link the message to the chain of messages of the sender.
+type Message struct {
+	Sender    uuid.UUID
+	SessionID uuid.UUID
+	Index     int32
+	Salt      int64
+	Timestamp time.Time
+	Content   string
+	// LastSeen holds signatures of messages the sender acknowledged
+	LastSeen [][]byte
+}
+
+// Payload returns the bytes the sender signs
+func (m *Message) Payload() []byte {
+	var b []byte
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = append(b, m.Sender[:]...)
+	b = append(b, m.SessionID[:]...)
+	b = binary.BigEndian.AppendUint32(b, uint32(m.Index))
+	b = binary.BigEndian.AppendUint64(b, uint64(m.Salt))
+	b = binary.BigEndian.AppendUint64(b, uint64(m.Timestamp.Unix()))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(m.Content)))
+	b = append(b, m.Content...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(m.LastSeen)))
+	for _, sig := range m.LastSeen {
+		b = append(b, sig...)
+	}
+	return b
+}
+
+// VerifyMessage checks a SHA256withRSA signature of m
+func VerifyMessage(key *rsa.PublicKey, m *Message, signature []byte) error {
+	hash := sha256.Sum256(m.Payload())
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	return key
+}
+
+func TestValidateProfileKey(t *testing.T) {
+	root, player := generateKey(t), generateKey(t)
+	owner := uuid.New()
+	now := time.Now()
+	expires := now.Add(time.Hour).UnixMilli()
+	encoded, err := x509.MarshalPKIXPublicKey(&player.PublicKey)
+	require.NoError(t, err)
+	hash := sha1.Sum(ProfileKeyPayload(owner, expires, encoded))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, root, crypto.SHA1, hash[:])
+	require.NoError(t, err)
+
+	// the trust root is loaded from a PEM file
+	path := filepath.Join(t.TempDir(), "root.pem")
+	rootDER, err := x509.MarshalPKIXPublicKey(&root.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rootDER}), 0o600))
+	trust, err := LoadTrustRoot(path)
+	require.NoError(t, err)
+
+	key, err := ValidateProfileKey(trust, owner, expires, encoded, sig, now)
+	require.NoError(t, err)
+	require.True(t, key.Key.Equal(&player.PublicKey))
+
+	_, err = ValidateProfileKey(trust, uuid.New(), expires, encoded, sig, now)
+	require.ErrorIs(t, err, ErrUntrustedKey)
+	_, err = ValidateProfileKey(trust, owner, expires, encoded, sig, now.Add(2*time.Hour))
+	require.ErrorIs(t, err, ErrKeyExpired)
+	_, err = ValidateProfileKey(trust, owner, expires, []byte{1, 2, 3}, sig, now)
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = ValidateProfileKey(RSATrustRoot{&player.PublicKey}, owner, expires, encoded, sig, now)
+	require.ErrorIs(t, err, ErrUntrustedKey)
+}
+
+func TestVerifyMessage(t *testing.T) {
+	key := generateKey(t)
+	m := &Message{
+		Sender:    uuid.New(),
+		SessionID: uuid.New(),
+		Index:     3,
+		Salt:      42,
+		Timestamp: time.Now(),
+		Content:   "hello",
+		LastSeen:  [][]byte{make([]byte, 256)},
+	}
+	hash := sha256.Sum256(m.Payload())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	require.NoError(t, err)
+	require.NoError(t, VerifyMessage(&key.PublicKey, m, sig))
+
+	m.Index++
+	require.ErrorIs(t, VerifyMessage(&key.PublicKey, m, sig), ErrInvalidSignature)
+}
+
+func TestLastSeenValidator(t *testing.T) {
+	v := NewLastSeenValidator()
+	a, b, c := []byte{1}, []byte{2}, []byte{3}
+	v.AddPending(a)
+	v.AddPending(a)
+	v.AddPending(b)
+
+	// nothing acknowledged yet, the window can't move
+	require.ErrorIs(t, v.ApplyOffset(3), ErrLastSeen)
+	// the client saw both messages, they are the last two of the window
+	seen, err := v.ApplyUpdate(2, []byte{0, 0, 0b1100})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{a, b}, seen)
+
+	// acknowledged messages can't be ignored later
+	_, err = v.ApplyUpdate(0, []byte{0, 0, 0b0100})
+	require.ErrorIs(t, err, ErrLastSeen)
+
+	v = NewLastSeenValidator()
+	v.AddPending(c)
+	_, err = v.ApplyUpdate(0, []byte{1})
+	require.ErrorIs(t, err, ErrLastSeen)
+	_, err = v.ApplyUpdate(1, []byte{0, 0, 0x10})
+	require.ErrorIs(t, err, ErrLastSeen)
+}
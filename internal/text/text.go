@@ -0,0 +1,148 @@
+package text
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+)
+
+// Component is a chat text component. In JSON a plain string is a component
+// with only Text set.
+type Component struct {
+	Text          string      `json:"text,omitempty"`
+	Translate     string      `json:"translate,omitempty"`
+	With          []Component `json:"with,omitempty"`
+	Color         string      `json:"color,omitempty"`
+	Bold          bool        `json:"bold,omitempty"`
+	Italic        bool        `json:"italic,omitempty"`
+	Underlined    bool        `json:"underlined,omitempty"`
+	Strikethrough bool        `json:"strikethrough,omitempty"`
+	Obfuscated    bool        `json:"obfuscated,omitempty"`
+	Extra         []Component `json:"extra,omitempty"`
+}
+
+// Plain returns a component of unstyled text
+func Plain(s string) Component {
+	return Component{Text: s}
+}
+
+// Colored returns a component of text in color
+func Colored(s, color string) Component {
+	return Component{Text: s, Color: color}
+}
+
+// Translated returns a component the client translates with key and args
+func Translated(key string, args ...Component) Component {
+	return Component{Translate: key, With: args}
+}
+
+// Append returns c with children added to its extra components
+func (c Component) Append(children ...Component) Component {
+	c.Extra = append(append([]Component(nil), c.Extra...), children...)
+	return c
+}
+
+func (c *Component) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		*c = Component{}
+		return json.Unmarshal(data, &c.Text)
+	}
+	if len(data) > 0 && data[0] == '[' {
+		var parts []Component
+		err := json.Unmarshal(data, &parts)
+		if err != nil {
+			return err
+		}
+		*c = Component{}
+		if len(parts) > 0 {
+			*c = parts[0].Append(parts[1:]...)
+		}
+		return nil
+	}
+	type plain Component
+	*c = Component{}
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// String returns the text without styles, translated components show their key
+func (c Component) String() string {
+	var sb strings.Builder
+	c.writePlain(&sb)
+	return sb.String()
+}
+
+func (c Component) writePlain(sb *strings.Builder) {
+	if c.Translate != "" {
+		sb.WriteString(c.Translate)
+	}
+	sb.WriteString(c.Text)
+	for _, e := range c.Extra {
+		e.writePlain(sb)
+	}
+}
+
+// IsEmpty reports whether c has no content
+func (c Component) IsEmpty() bool {
+	return c.Text == "" && c.Translate == "" && len(c.Extra) == 0
+}
+
+// Replace returns c with old replaced by new in text of every component
+func (c Component) Replace(old, new string) Component {
+	c.Text = strings.ReplaceAll(c.Text, old, new)
+	c.With = replaceAll(c.With, old, new)
+	c.Extra = replaceAll(c.Extra, old, new)
+	return c
+}
+
+func replaceAll(components []Component, old, new string) []Component {
+	if len(components) == 0 {
+		return components
+	}
+	res := make([]Component, len(components))
+	for i, c := range components {
+		res[i] = c.Replace(old, new)
+	}
+	return res
+}
+
+// NBT returns c in the form sent to clients since 1.20.3
+func (c Component) NBT() nbt.Compound {
+	res := nbt.Compound{}
+	if c.Translate != "" {
+		res["translate"] = c.Translate
+		if len(c.With) > 0 {
+			res["with"] = list(c.With)
+		}
+	} else {
+		res["text"] = c.Text
+	}
+	if c.Color != "" {
+		res["color"] = c.Color
+	}
+	for key, set := range map[string]bool{
+		"bold":          c.Bold,
+		"italic":        c.Italic,
+		"underlined":    c.Underlined,
+		"strikethrough": c.Strikethrough,
+		"obfuscated":    c.Obfuscated,
+	} {
+		if set {
+			res[key] = true
+		}
+	}
+	if len(c.Extra) > 0 {
+		res["extra"] = list(c.Extra)
+	}
+	return res
+}
+
+func list(components []Component) nbt.List {
+	items := make([]any, len(components))
+	for i, c := range components {
+		items[i] = c.NBT()
+	}
+	return nbt.NewList(nbt.TagCompound, items...)
+}
@@ -0,0 +1,39 @@
+package text
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponent_UnmarshalJSON(t *testing.T) {
+	var c Component
+	require.NoError(t, json.Unmarshal([]byte(`"hello"`), &c))
+	require.Equal(t, Plain("hello"), c)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"text":"a","color":"gold","bold":true,"extra":["b"]}`), &c))
+	require.Equal(t, Component{Text: "a", Color: "gold", Bold: true, Extra: []Component{Plain("b")}}, c)
+
+	require.NoError(t, json.Unmarshal([]byte(`["a", {"text":"b","color":"red"}]`), &c))
+	require.Equal(t, Plain("a").Append(Colored("b", "red")), c)
+	require.Equal(t, "ab", c.String())
+}
+
+func TestComponent_NBT(t *testing.T) {
+	c := Colored("Players: {online}", "gold").Append(Component{Text: "!", Bold: true})
+	c = c.Replace("{online}", "3")
+	require.Equal(t, nbt.Compound{
+		"text":  "Players: 3",
+		"color": "gold",
+		"extra": nbt.NewList(nbt.TagCompound, nbt.Compound{"text": "!", "bold": true}),
+	}, c.NBT())
+
+	tr := Translated("multiplayer.player.joined", Plain("Steve"))
+	require.Equal(t, nbt.Compound{
+		"translate": "multiplayer.player.joined",
+		"with":      nbt.NewList(nbt.TagCompound, nbt.Compound{"text": "Steve"}),
+	}, tr.NBT())
+	require.True(t, Component{}.IsEmpty())
+}
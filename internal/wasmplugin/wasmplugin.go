@@ -0,0 +1,424 @@
+// Package wasmplugin runs sandboxed server plugins compiled to WebAssembly.
+//
+// Every module gets its own runtime with a memory limit, and every call into
+// it has a time limit. A module that runs out of time or traps is closed and
+// its plugin stays disabled until the server restarts.
+//
+// Modules import host functions from the "mcsrv" module, strings are passed
+// as a pointer into the module memory and a length:
+//
+//	log(ptr, len)
+//	broadcast(ptr, len)
+//	send_message(player_ptr, player_len, msg_ptr, msg_len)
+//	reply(ptr, len)                                answers the source of the running command
+//	register_command(name_ptr, name_len, level) i32 command id, or -1 outside of on_enable
+//	subscribe(kind, priority) i32                  0, or -1 for unknown event kinds
+//
+// and may export:
+//
+//	alloc(size) i32                          memory for strings passed to the module
+//	on_enable()
+//	on_disable()
+//	on_command(id, args_ptr, args_len)
+//	on_event(kind, ptr, len) i32             the event as JSON, returns 1 to cancel it
+//
+// WASI is available without file system access, so modules built by common
+// toolchains run as long as they are reactors.
+package wasmplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/server"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/rs/zerolog/log"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Event kinds passed to subscribe and on_event
+const (
+	EventPreLogin = 1 + iota
+	EventJoin
+	EventQuit
+	EventChat
+	EventCommand
+	EventMove
+	EventBlockBreak
+	EventBlockPlace
+	EventStatusPing
+)
+
+const (
+	hostModule = "mcsrv"
+	// pageSize is the WebAssembly memory page size
+	pageSize = 64 << 10
+)
+
+var ErrDisabled = errors.New("plugin is disabled")
+
+type Options struct {
+	// MaxMemory caps the linear memory of a module in bytes
+	MaxMemory int
+	// CallTimeout caps every call into a module, DefaultCallTimeout is used
+	// when it is not positive
+	CallTimeout time.Duration
+}
+
+const DefaultCallTimeout = 20 * time.Millisecond
+
+// Plugin is a WebAssembly module run as a server plugin
+type Plugin struct {
+	name string
+	code []byte
+	opts Options
+
+	// mu serializes calls into the module, events are fired from network
+	// goroutines as well as from the tick
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	mod      api.Module
+	server   *server.Server
+	enabling bool
+	// commands counts registered commands, ids passed to on_command are their indexes
+	commands int32
+	// source is the sender of the command being run
+	source command.Source
+}
+
+func New(name string, code []byte, opts Options) *Plugin {
+	if opts.CallTimeout <= 0 {
+		opts.CallTimeout = DefaultCallTimeout
+	}
+	return &Plugin{name: name, code: code, opts: opts}
+}
+
+// Load reads every .wasm module in dir, plugins are named after their files
+func Load(dir string, opts Options) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+	var res []*Plugin
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".wasm" {
+			continue
+		}
+		code, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin: %w", err)
+		}
+		res = append(res, New(strings.TrimSuffix(e.Name(), ".wasm"), code, opts))
+	}
+	return res, nil
+}
+
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+func (p *Plugin) OnEnable(s *server.Server) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.server = s
+	err := p.instantiate()
+	if err != nil {
+		p.close()
+		return err
+	}
+	p.enabling = true
+	_, err = p.call("on_enable")
+	p.enabling = false
+	return err
+}
+
+func (p *Plugin) OnDisable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mod == nil {
+		return
+	}
+	_, _ = p.call("on_disable")
+	p.close()
+}
+
+func (p *Plugin) instantiate() error {
+	ctx := context.Background()
+	cfg := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(max(p.opts.MaxMemory/pageSize, 1))).
+		WithCloseOnContextDone(true)
+	p.runtime = wazero.NewRuntimeWithConfig(ctx, cfg)
+
+	_, err := wasi_snapshot_preview1.Instantiate(ctx, p.runtime)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate wasi: %w", err)
+	}
+	_, err = p.runtime.NewHostModuleBuilder(hostModule).
+		NewFunctionBuilder().WithFunc(p.hostLog).Export("log").
+		NewFunctionBuilder().WithFunc(p.hostBroadcast).Export("broadcast").
+		NewFunctionBuilder().WithFunc(p.hostSendMessage).Export("send_message").
+		NewFunctionBuilder().WithFunc(p.hostReply).Export("reply").
+		NewFunctionBuilder().WithFunc(p.hostRegisterCommand).Export("register_command").
+		NewFunctionBuilder().WithFunc(p.hostSubscribe).Export("subscribe").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate host module: %w", err)
+	}
+
+	compiled, err := p.runtime.CompileModule(ctx, p.code)
+	if err != nil {
+		return fmt.Errorf("failed to compile plugin: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.opts.CallTimeout)
+	defer cancel()
+	p.mod, err = p.runtime.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().
+		WithName(p.name).
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return fmt.Errorf("failed to instantiate plugin: %w", err)
+	}
+	return nil
+}
+
+// close releases the runtime, p.mu must be held
+func (p *Plugin) close() {
+	if p.runtime != nil {
+		_ = p.runtime.Close(context.Background())
+	}
+	p.runtime = nil
+	p.mod = nil
+}
+
+// call runs an exported function within the call timeout, missing exports
+// are skipped. A failing module is closed. p.mu must be held.
+func (p *Plugin) call(name string, params ...uint64) ([]uint64, error) {
+	if p.mod == nil {
+		return nil, ErrDisabled
+	}
+	fn := p.mod.ExportedFunction(name)
+	if fn == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.CallTimeout)
+	defer cancel()
+	res, err := fn.Call(ctx, params...)
+	if err != nil {
+		log.Err(err).Str("plugin", p.name).Str("function", name).Msg("wasm plugin failed, disabling it")
+		p.close()
+		return nil, fmt.Errorf("failed to call %s: %w", name, err)
+	}
+	return res, nil
+}
+
+// write copies data into memory returned by alloc, modules without alloc get nothing
+func (p *Plugin) write(data []byte) (ptr, n uint32, err error) {
+	if len(data) == 0 || p.mod == nil || p.mod.Memory() == nil || p.mod.ExportedFunction("alloc") == nil {
+		return 0, 0, nil
+	}
+	res, err := p.call("alloc", uint64(len(data)))
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(res) != 1 || !p.mod.Memory().Write(uint32(res[0]), data) {
+		return 0, 0, nil
+	}
+	return uint32(res[0]), uint32(len(data)), nil
+}
+
+func readString(m api.Module, ptr, n uint32) string {
+	if m.Memory() == nil {
+		return ""
+	}
+	b, ok := m.Memory().Read(ptr, n)
+	if !ok {
+		return ""
+	}
+	return string(b)
+}
+
+func (p *Plugin) hostLog(_ context.Context, m api.Module, ptr, n uint32) {
+	log.Info().Str("plugin", p.name).Msg(readString(m, ptr, n))
+}
+
+// hostBroadcast and hostSendMessage may be called off the tick, the message is sent on the next one
+func (p *Plugin) hostBroadcast(_ context.Context, m api.Module, ptr, n uint32) {
+	msg := text.Plain(readString(m, ptr, n))
+	s := p.server
+	s.Submit(func() {
+		s.BroadcastMessage(msg)
+	})
+}
+
+func (p *Plugin) hostSendMessage(_ context.Context, m api.Module, namePtr, nameLen, msgPtr, msgLen uint32) {
+	name := readString(m, namePtr, nameLen)
+	msg := text.Plain(readString(m, msgPtr, msgLen))
+	s := p.server
+	s.Submit(func() {
+		if player := s.PlayerByName(name); player != nil {
+			player.SendMessage(msg)
+		}
+	})
+}
+
+func (p *Plugin) hostReply(_ context.Context, m api.Module, ptr, n uint32) {
+	if p.source != nil {
+		p.source.SendMessage(text.Plain(readString(m, ptr, n)))
+	}
+}
+
+func (p *Plugin) hostRegisterCommand(_ context.Context, m api.Module, namePtr, nameLen uint32, level int32) int32 {
+	name := readString(m, namePtr, nameLen)
+	commands := p.server.Commands()
+	// commands of the server and other plugins can't be replaced
+	if !p.enabling || name == "" || strings.ContainsRune(name, ' ') || commands.Get(name) != nil {
+		return -1
+	}
+	level = min(max(level, command.LevelAll), command.LevelOwner)
+	id := p.commands
+	p.commands++
+	run := func(ctx *command.Context) error {
+		return p.runCommand(id, ctx)
+	}
+	commands.Register(command.Literal(name).Requires(int(level)).Executes(run).Then(
+		command.Argument("args", command.Message()).Executes(run),
+	))
+	return id
+}
+
+func (p *Plugin) runCommand(id int32, ctx *command.Context) error {
+	var args string
+	if ctx.Has("args") {
+		args = ctx.String("args")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mod == nil {
+		return fmt.Errorf("Plugin %s is disabled", p.name)
+	}
+	ptr, n, err := p.write([]byte(args))
+	if err == nil {
+		p.source = ctx.Source
+		_, err = p.call("on_command", uint64(id), uint64(ptr), uint64(n))
+		p.source = nil
+	}
+	if err != nil {
+		return fmt.Errorf("Plugin %s failed to run the command", p.name)
+	}
+	return nil
+}
+
+// fire passes an event to on_event and reports whether the module cancelled it
+func (p *Plugin) fire(kind int32, payload any) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Err(err).Str("plugin", p.name).Msg("failed to encode event")
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ptr, n, err := p.write(data)
+	if err != nil {
+		return false
+	}
+	res, err := p.call("on_event", uint64(kind), uint64(ptr), uint64(n))
+	return err == nil && len(res) == 1 && uint32(res[0]) == 1
+}
+
+type position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+type blockPayload struct {
+	Player     string `json:"player"`
+	X          int    `json:"x"`
+	Y          int    `json:"y"`
+	Z          int    `json:"z"`
+	Block      string `json:"block"`
+	Properties string `json:"properties,omitempty"`
+}
+
+func (p *Plugin) hostSubscribe(_ context.Context, kind, priority int32) int32 {
+	if !p.enabling {
+		return -1
+	}
+	bus := p.server.Events()
+	prio := event.Priority(min(max(priority, int32(event.Lowest)), int32(event.Monitor)))
+	switch kind {
+	case EventPreLogin:
+		event.Subscribe(bus, prio, func(e *server.PlayerPreLoginEvent) {
+			if p.fire(kind, map[string]string{"player": e.Profile.Name, "uuid": e.Profile.UUID.String(), "address": e.Address}) {
+				e.Cancelled = true
+			}
+		})
+	case EventJoin:
+		event.Subscribe(bus, prio, func(e *server.PlayerJoinEvent) {
+			p.fire(kind, map[string]string{"player": e.Player.Name()})
+		})
+	case EventQuit:
+		event.Subscribe(bus, prio, func(e *server.PlayerQuitEvent) {
+			p.fire(kind, map[string]string{"player": e.Player.Name()})
+		})
+	case EventChat:
+		event.Subscribe(bus, prio, func(e *server.ChatEvent) {
+			if p.fire(kind, map[string]string{"player": e.Player.Name(), "message": e.Message}) {
+				e.Cancelled = true
+			}
+		})
+	case EventCommand:
+		event.Subscribe(bus, prio, func(e *server.CommandEvent) {
+			if p.fire(kind, map[string]string{"source": e.Source.Name(), "command": e.Command}) {
+				e.Cancelled = true
+			}
+		})
+	case EventMove:
+		event.Subscribe(bus, prio, func(e *server.MoveEvent) {
+			payload := map[string]any{
+				"player": e.Player.Name(),
+				"from":   position(e.From),
+				"to":     position(e.To),
+			}
+			if p.fire(kind, payload) {
+				e.Cancelled = true
+			}
+		})
+	case EventBlockBreak:
+		event.Subscribe(bus, prio, func(e *server.BlockBreakEvent) {
+			payload := blockPayload{e.Player.Name(), e.Pos.X, e.Pos.Y, e.Pos.Z, e.Block.Name, e.Block.Properties}
+			if p.fire(kind, payload) {
+				e.Cancelled = true
+			}
+		})
+	case EventBlockPlace:
+		event.Subscribe(bus, prio, func(e *server.BlockPlaceEvent) {
+			payload := blockPayload{e.Player.Name(), e.Pos.X, e.Pos.Y, e.Pos.Z, e.Block.Name, e.Block.Properties}
+			if p.fire(kind, payload) {
+				e.Cancelled = true
+			}
+		})
+	case EventStatusPing:
+		event.Subscribe(bus, prio, func(e *server.StatusPingEvent) {
+			if p.fire(kind, map[string]string{"address": e.Address}) {
+				e.Cancelled = true
+			}
+		})
+	default:
+		return -1
+	}
+	return 0
+}
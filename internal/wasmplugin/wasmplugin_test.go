@@ -0,0 +1,199 @@
+package wasmplugin
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/command"
+	"github.com/BinaryArchaism/mc-srv/internal/config"
+	"github.com/BinaryArchaism/mc-srv/internal/event"
+	"github.com/BinaryArchaism/mc-srv/internal/server"
+	"github.com/BinaryArchaism/mc-srv/internal/text"
+	"github.com/stretchr/testify/require"
+)
+
+func uleb(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	b := uleb(uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func name(s string) []byte {
+	return append(uleb(uint32(len(s))), s...)
+}
+
+func section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, uleb(uint32(len(content)))...), content...)
+}
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func funcType(params, results int) []byte {
+	i32 := func(n int) []byte {
+		b := uleb(uint32(n))
+		for range n {
+			b = append(b, 0x7F)
+		}
+		return b
+	}
+	return cat([]byte{0x60}, i32(params), i32(results))
+}
+
+func body(code ...byte) []byte {
+	b := append([]byte{0}, code...)
+	return append(uleb(uint32(len(b))), b...)
+}
+
+// testModule registers the command "hello" answering "Hello!", arguments
+// make it loop forever. It subscribes to status pings and cancels them.
+func testModule(memoryPages byte) []byte {
+	const (
+		i32Const = 0x41
+		call     = 0x10
+		drop     = 0x1A
+		localGet = 0x20
+		end      = 0x0B
+	)
+	return cat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		section(1, vec(
+			funcType(2, 0), // 0: reply
+			funcType(3, 1), // 1: register_command
+			funcType(2, 1), // 2: subscribe
+			funcType(0, 0), // 3: on_enable
+			funcType(3, 0), // 4: on_command
+			funcType(3, 1), // 5: on_event
+			funcType(1, 1), // 6: alloc
+		)),
+		section(2, vec(
+			cat(name("mcsrv"), name("reply"), []byte{0, 0}),
+			cat(name("mcsrv"), name("register_command"), []byte{0, 1}),
+			cat(name("mcsrv"), name("subscribe"), []byte{0, 2}),
+		)),
+		section(3, vec([]byte{3}, []byte{4}, []byte{5}, []byte{6})),
+		section(5, vec([]byte{0, memoryPages})),
+		section(7, vec(
+			cat(name("on_enable"), []byte{0, 3}),
+			cat(name("on_command"), []byte{0, 4}),
+			cat(name("on_event"), []byte{0, 5}),
+			cat(name("alloc"), []byte{0, 6}),
+			cat(name("memory"), []byte{2, 0}),
+		)),
+		section(10, vec(
+			body(i32Const, 0, i32Const, 5, i32Const, 0, call, 1, drop,
+				i32Const, EventStatusPing, i32Const, byte(event.Normal), call, 2, drop, end),
+			body(localGet, 2, 0x04, 0x40, 0x03, 0x40, 0x0C, 0, end, end,
+				i32Const, 16, i32Const, 6, call, 0, end),
+			body(i32Const, 1, end),
+			body(i32Const, 0x80, 0x08, end),
+		)),
+		section(11, vec(
+			cat([]byte{0, i32Const, 0, end}, name("hello")),
+			cat([]byte{0, i32Const, 16, end}, name("Hello!")),
+		)),
+	)
+}
+
+type testSource struct {
+	messages []string
+}
+
+func (s *testSource) Name() string {
+	return "test"
+}
+
+func (s *testSource) HasPermission(int) bool {
+	return true
+}
+
+func (s *testSource) SendMessage(c text.Component) {
+	s.messages = append(s.messages, c.String())
+}
+
+func (s *testSource) Position() (x, y, z float64) {
+	return 0, 0, 0
+}
+
+func TestPlugin(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Address = "127.0.0.1:0"
+	cfg.Icon = ""
+	cfg.Level.Type = config.LevelTypeVoid
+	cfg.Access.OpsFile = filepath.Join(dir, "ops.json")
+	cfg.Access.BansFile = filepath.Join(dir, "banned-players.json")
+	cfg.Access.WhitelistFile = filepath.Join(dir, "whitelist.json")
+	srv, err := server.New(cfg)
+	require.NoError(t, err)
+
+	p := New("hello", testModule(1), Options{MaxMemory: 1 << 20, CallTimeout: 100 * time.Millisecond})
+	require.NoError(t, p.OnEnable(srv))
+	defer p.OnDisable()
+
+	ping := &server.StatusPingEvent{Address: "127.0.0.1:1234"}
+	event.Fire(srv.Events(), ping)
+	require.True(t, ping.Cancelled)
+
+	// handlers and commands are only added while enabling, built in commands stay
+	require.Equal(t, int32(-1), p.hostSubscribe(context.Background(), EventJoin, 0))
+	stop := srv.Commands().Get("stop")
+	p.enabling = true
+	ptr, n, err := p.write([]byte("stop"))
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), p.hostRegisterCommand(context.Background(), p.mod, ptr, n, command.LevelAll))
+	p.enabling = false
+	require.Same(t, stop, srv.Commands().Get("stop"))
+
+	src := &testSource{}
+	srv.RunCommand(src, "hello")
+	require.Equal(t, []string{"Hello!"}, src.messages)
+
+	// a module running out of time is disabled
+	src.messages = nil
+	start := time.Now()
+	srv.RunCommand(src, "hello spin")
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Equal(t, []string{"Plugin hello failed to run the command"}, src.messages)
+
+	src.messages = nil
+	srv.RunCommand(src, "hello")
+	require.Equal(t, []string{"Plugin hello is disabled"}, src.messages)
+	ping = &server.StatusPingEvent{}
+	event.Fire(srv.Events(), ping)
+	require.False(t, ping.Cancelled)
+}
+
+func TestPlugin_MemoryLimit(t *testing.T) {
+	p := New("big", testModule(32), Options{MaxMemory: 1 << 20, CallTimeout: time.Second})
+	require.Error(t, p.instantiate())
+	p.close()
+}
+
+func TestPlugin_DefaultCallTimeout(t *testing.T) {
+	p := New("hello", testModule(1), Options{MaxMemory: 1 << 20})
+	require.Equal(t, DefaultCallTimeout, p.opts.CallTimeout)
+	require.NoError(t, p.instantiate())
+	p.close()
+}
@@ -0,0 +1,98 @@
+package anvil
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+)
+
+const DefaultMaxOpenRegions = 256
+
+type regionKey struct {
+	x, z int
+}
+
+// RegionCache keeps at most max region files open and closes the least
+// recently used one when another region is requested
+type RegionCache struct {
+	mu      sync.Mutex
+	dir     string
+	max     int
+	lru     *list.List
+	regions map[regionKey]*list.Element
+
+	// Compression is applied to regions opened after it is set
+	Compression Compression
+}
+
+func NewRegionCache(dir string, max int) *RegionCache {
+	if max <= 0 {
+		max = DefaultMaxOpenRegions
+	}
+	return &RegionCache{
+		dir:         dir,
+		max:         max,
+		lru:         list.New(),
+		regions:     map[regionKey]*list.Element{},
+		Compression: CompressionZlib,
+	}
+}
+
+// Region returns open region x, z, opening and caching it if needed
+func (c *RegionCache) Region(x, z int) (*Region, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := regionKey{x: x, z: z}
+	if e, ok := c.regions[key]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*Region), nil
+	}
+
+	for c.lru.Len() >= c.max {
+		err := c.evict()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := OpenRegion(c.dir, x, z)
+	if err != nil {
+		return nil, err
+	}
+	r.Compression = c.Compression
+	c.regions[key] = c.lru.PushFront(r)
+	return r, nil
+}
+
+// RegionOf returns region holding chunk x, z
+func (c *RegionCache) RegionOf(chunkX, chunkZ int32) (*Region, error) {
+	return c.Region(int(chunkX>>5), int(chunkZ>>5))
+}
+
+func (c *RegionCache) evict() error {
+	e := c.lru.Back()
+	if e == nil {
+		return nil
+	}
+	r := c.lru.Remove(e).(*Region)
+	delete(c.regions, regionKey{x: r.X, z: r.Z})
+	return r.Close()
+}
+
+// Len returns number of open regions
+func (c *RegionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *RegionCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for c.lru.Len() > 0 {
+		errs = append(errs, c.evict())
+	}
+	return errors.Join(errs...)
+}
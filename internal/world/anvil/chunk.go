@@ -0,0 +1,202 @@
+package anvil
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+// DataVersion is the data version of 1.21
+const DataVersion = 3953
+
+// EncodeChunk converts c to the chunk NBT layout used since 1.18
+func EncodeChunk(c *world.Chunk) nbt.Compound {
+	c.RLock()
+	defer c.RUnlock()
+
+	minSection := int32(c.MinY >> 4)
+	sections := make([]any, 0, len(c.Sections))
+	for i, s := range c.Sections {
+		sections = append(sections, encodeSection(s, int8(minSection+int32(i))))
+	}
+
+	blockEntities := make([]any, 0, len(c.BlockEntities))
+	for pos, data := range c.BlockEntities {
+		be := nbt.Compound{}
+		for k, v := range data {
+			be[k] = v
+		}
+		be["x"], be["y"], be["z"] = int32(pos.X), int32(pos.Y), int32(pos.Z)
+		blockEntities = append(blockEntities, be)
+	}
+
+	return nbt.Compound{
+		"DataVersion":    int32(DataVersion),
+		"xPos":           c.X,
+		"zPos":           c.Z,
+		"yPos":           minSection,
+		"Status":         c.Status,
+		"LastUpdate":     c.LastUpdate,
+		"InhabitedTime":  c.InhabitedTime,
+		"sections":       nbt.NewList(nbt.TagCompound, sections...),
+		"block_entities": nbt.NewList(nbt.TagCompound, blockEntities...),
+		"Heightmaps":     nbt.Compound{},
+		"block_ticks":    nbt.NewList(nbt.TagCompound),
+		"fluid_ticks":    nbt.NewList(nbt.TagCompound),
+		"PostProcessing": nbt.NewList(nbt.TagList),
+		"structures":     nbt.Compound{},
+	}
+}
+
+func encodeSection(s *world.Section, y int8) nbt.Compound {
+	palette, idx := s.BlockData()
+	blockPalette := make([]any, 0, len(palette))
+	for _, b := range palette {
+		entry := nbt.Compound{"Name": b.Name}
+		if props := b.PropertyMap(); props != nil {
+			p := nbt.Compound{}
+			for k, v := range props {
+				p[k] = v
+			}
+			entry["Properties"] = p
+		}
+		blockPalette = append(blockPalette, entry)
+	}
+	blockStates := nbt.Compound{"palette": nbt.NewList(nbt.TagCompound, blockPalette...)}
+	if len(palette) > 1 {
+		blockStates["data"] = world.PackIndexes(idx, world.BitsFor(len(palette), 4))
+	}
+
+	biomes, biomeIdx := s.BiomeData()
+	biomePalette := make([]any, 0, len(biomes))
+	for _, b := range biomes {
+		biomePalette = append(biomePalette, b)
+	}
+	biomeStates := nbt.Compound{"palette": nbt.NewList(nbt.TagString, biomePalette...)}
+	if len(biomes) > 1 {
+		biomeStates["data"] = world.PackIndexes(biomeIdx, world.BitsFor(len(biomes), 1))
+	}
+
+	res := nbt.Compound{
+		"Y":            y,
+		"block_states": blockStates,
+		"biomes":       biomeStates,
+	}
+	if s.BlockLight != nil {
+		res["BlockLight"] = append([]byte(nil), s.BlockLight...)
+	}
+	if s.SkyLight != nil {
+		res["SkyLight"] = append([]byte(nil), s.SkyLight...)
+	}
+	return res
+}
+
+// DecodeChunk converts chunk NBT to a chunk of the given dimension height.
+// Pre 1.18 chunks with a Level compound are not supported.
+func DecodeChunk(root nbt.Compound, minY, height int) (*world.Chunk, error) {
+	if root.Has("Level") {
+		return nil, fmt.Errorf("%w: pre 1.18 chunk format", ErrCorruptedData)
+	}
+	c := world.NewChunk(root.Int("xPos"), root.Int("zPos"), minY, height)
+	c.Status = root.String("Status")
+	c.LastUpdate = root.Long("LastUpdate")
+	c.InhabitedTime = root.Long("InhabitedTime")
+
+	minSection := minY >> 4
+	for _, sec := range root.List("sections").Compounds() {
+		i := int(sec.Byte("Y")) - minSection
+		if i < 0 || i >= len(c.Sections) {
+			// light only sections above and below the world
+			continue
+		}
+		err := decodeSection(c.Sections[i], sec)
+		if err != nil {
+			return nil, fmt.Errorf("section %d: %w", sec.Byte("Y"), err)
+		}
+	}
+
+	for _, be := range root.List("block_entities").Compounds() {
+		pos := world.BlockPos{X: int(be.Int("x")), Y: int(be.Int("y")), Z: int(be.Int("z"))}
+		data := nbt.Compound{}
+		for k, v := range be {
+			if k != "x" && k != "y" && k != "z" {
+				data[k] = v
+			}
+		}
+		c.BlockEntities[pos] = data
+	}
+	return c, nil
+}
+
+func decodeSection(s *world.Section, sec nbt.Compound) error {
+	blockStates := sec.Compound("block_states")
+	if blockStates != nil {
+		entries := blockStates.List("palette").Compounds()
+		palette := make([]world.BlockState, 0, len(entries))
+		for _, e := range entries {
+			var props map[string]string
+			if p := e.Compound("Properties"); p != nil {
+				props = make(map[string]string, len(p))
+				for k := range p {
+					props[k] = p.String(k)
+				}
+			}
+			palette = append(palette, world.NewBlockState(e.String("Name"), props))
+		}
+		idx := make([]uint16, world.SectionVolume)
+		if len(palette) > 1 {
+			ok := world.UnpackIndexes(blockStates.LongArray("data"), world.BitsFor(len(palette), 4), idx)
+			if !ok {
+				return fmt.Errorf("%w: short block data", ErrCorruptedData)
+			}
+		}
+		s.SetBlockData(palette, idx)
+	}
+
+	biomes := sec.Compound("biomes")
+	if biomes != nil {
+		palette := biomes.List("palette").Strings()
+		idx := make([]uint16, world.BiomeVolume)
+		if len(palette) > 1 {
+			ok := world.UnpackIndexes(biomes.LongArray("data"), world.BitsFor(len(palette), 1), idx)
+			if !ok {
+				return fmt.Errorf("%w: short biome data", ErrCorruptedData)
+			}
+		}
+		s.SetBiomeData(palette, idx)
+	}
+
+	s.BlockLight = lightArray(sec.ByteArray("BlockLight"))
+	s.SkyLight = lightArray(sec.ByteArray("SkyLight"))
+	return nil
+}
+
+func lightArray(v []int8) []byte {
+	if len(v) != world.LightLength {
+		return nil
+	}
+	res := make([]byte, len(v))
+	for i, b := range v {
+		res[i] = byte(b)
+	}
+	return res
+}
+
+func marshalChunk(c *world.Chunk) ([]byte, error) {
+	var buf bytes.Buffer
+	err := nbt.Write(&buf, "", EncodeChunk(c))
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalChunk(data []byte, minY, height int) (*world.Chunk, error) {
+	_, root, err := nbt.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeChunk(root, minY, height)
+}
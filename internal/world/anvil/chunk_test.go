@@ -0,0 +1,55 @@
+package anvil
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_RoundTrip(t *testing.T) {
+	s := NewStorage(t.TempDir(), world.OverworldMinY, world.OverworldHeight)
+	defer s.Close()
+
+	c := world.NewChunk(-3, 40, world.OverworldMinY, world.OverworldHeight)
+	for x := range 16 {
+		for z := range 16 {
+			c.SetBlock(x, -64, z, world.Bedrock)
+			c.SetBlock(x, -63, z, world.Dirt)
+			c.SetBlock(x, -62, z, world.Grass)
+		}
+	}
+	c.SetBlock(3, 70, 9, world.OakLog)
+	c.SetBlock(3, 71, 9, world.OakLeaves)
+	c.Sections[1].SetBiome(1, 2, 3, "minecraft:desert")
+	c.Sections[0].SkyLight = make([]byte, world.LightLength)
+	world.SetLight(c.Sections[0].SkyLight, 1, 1, 1, 15)
+	c.SetBlockEntity(world.BlockPos{X: -45, Y: 70, Z: 649}, nbt.Compound{"id": "minecraft:chest"})
+
+	err := s.SaveChunk(c)
+	require.NoError(t, err)
+	require.False(t, c.Dirty())
+
+	_, err = s.LoadChunk(0, 0)
+	require.ErrorIs(t, err, world.ErrChunkNotFound)
+
+	res, err := s.LoadChunk(-3, 40)
+	require.NoError(t, err)
+	require.Equal(t, int32(-3), res.X)
+	require.Equal(t, int32(40), res.Z)
+	require.Equal(t, world.Bedrock, res.Block(15, -64, 15))
+	require.Equal(t, world.Grass, res.Block(0, -62, 0))
+	require.Equal(t, world.OakLog, res.Block(3, 70, 9))
+	require.Equal(t, world.OakLeaves, res.Block(3, 71, 9))
+	require.Equal(t, world.Air, res.Block(3, 72, 9))
+	require.Equal(t, 71, res.HighestBlock(3, 9))
+	require.Equal(t, "minecraft:desert", res.Sections[1].Biome(1, 2, 3))
+	require.Equal(t, byte(15), world.Light(res.Sections[0].SkyLight, 1, 1, 1))
+	require.Equal(t, "minecraft:chest", res.BlockEntity(world.BlockPos{X: -45, Y: 70, Z: 649}).String("id"))
+}
+
+func TestDecodeChunk_Legacy(t *testing.T) {
+	_, err := DecodeChunk(nbt.Compound{"Level": nbt.Compound{}}, world.OverworldMinY, world.OverworldHeight)
+	require.ErrorIs(t, err, ErrCorruptedData)
+}
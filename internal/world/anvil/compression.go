@@ -0,0 +1,244 @@
+package anvil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+var ErrUnknownCompression = errors.New("unknown chunk compression")
+
+// Compression is the scheme byte stored before every chunk payload
+type Compression byte
+
+const (
+	CompressionGZip Compression = 1
+	CompressionZlib Compression = 2
+	CompressionNone Compression = 3
+	CompressionLZ4  Compression = 4
+
+	// externalFlag marks chunks stored in a separate c.<x>.<z>.mcc file
+	externalFlag = 0x80
+)
+
+func compress(c Compression, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch c {
+	case CompressionGZip:
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+	case CompressionZlib:
+		w := zlib.NewWriter(&buf)
+		_, err := w.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+	case CompressionNone:
+		return data, nil
+	case CompressionLZ4:
+		err := lz4BlockWrite(&buf, data)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCompression, c)
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionGZip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case CompressionZlib:
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case CompressionNone:
+		return data, nil
+	case CompressionLZ4:
+		return lz4BlockRead(data)
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownCompression, c)
+}
+
+// Vanilla uses LZ4BlockOutputStream from lz4-java, which is not the LZ4 frame
+// format: every block has a 21 byte header of magic, token, compressed
+// length, decompressed length and a masked xxhash32 checksum.
+const (
+	lz4BlockSize    = 64 * 1024
+	lz4HeaderLength = 21
+	lz4MethodRaw    = 0x10
+	lz4MethodLZ4    = 0x20
+	lz4ChecksumSeed = 0x9747b28c
+)
+
+var (
+	lz4Magic = []byte("LZ4Block")
+
+	errLZ4Corrupted = errors.New("corrupted lz4 block stream")
+)
+
+func lz4Level() byte {
+	return byte(max(0, bits.Len(uint(lz4BlockSize-1))-10))
+}
+
+func lz4BlockWrite(w io.Writer, data []byte) error {
+	header := make([]byte, lz4HeaderLength)
+	copy(header, lz4Magic)
+	compressed := make([]byte, lz4.CompressBlockBound(lz4BlockSize))
+	var c lz4.Compressor
+
+	for len(data) > 0 {
+		block := data[:min(len(data), lz4BlockSize)]
+		data = data[len(block):]
+
+		n, err := c.CompressBlock(block, compressed)
+		if err != nil {
+			return err
+		}
+		method, payload := byte(lz4MethodLZ4), compressed[:n]
+		if n == 0 || n >= len(block) {
+			method, payload = lz4MethodRaw, block
+		}
+
+		header[8] = method | lz4Level()
+		binary.LittleEndian.PutUint32(header[9:], uint32(len(payload)))
+		binary.LittleEndian.PutUint32(header[13:], uint32(len(block)))
+		binary.LittleEndian.PutUint32(header[17:], xxh32(block, lz4ChecksumSeed)&0xFFFFFFF)
+		_, err = w.Write(header)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	// end of stream marker
+	header[8] = lz4MethodRaw | lz4Level()
+	clear(header[9:])
+	_, err := w.Write(header)
+	return err
+}
+
+func lz4BlockRead(data []byte) ([]byte, error) {
+	var res []byte
+	for len(data) > 0 {
+		if len(data) < lz4HeaderLength || !bytes.Equal(data[:8], lz4Magic) {
+			return nil, errLZ4Corrupted
+		}
+		method := data[8] & 0xF0
+		compressedLen := int(binary.LittleEndian.Uint32(data[9:]))
+		originalLen := int(binary.LittleEndian.Uint32(data[13:]))
+		checksum := binary.LittleEndian.Uint32(data[17:])
+		data = data[lz4HeaderLength:]
+
+		if originalLen == 0 {
+			return res, nil
+		}
+		if compressedLen > len(data) || originalLen > 1<<25 {
+			return nil, errLZ4Corrupted
+		}
+
+		var block []byte
+		switch method {
+		case lz4MethodRaw:
+			block = data[:compressedLen]
+		case lz4MethodLZ4:
+			block = make([]byte, originalLen)
+			n, err := lz4.UncompressBlock(data[:compressedLen], block)
+			if err != nil {
+				return nil, err
+			}
+			if n != originalLen {
+				return nil, errLZ4Corrupted
+			}
+		default:
+			return nil, errLZ4Corrupted
+		}
+		if xxh32(block, lz4ChecksumSeed)&0xFFFFFFF != checksum {
+			return nil, errLZ4Corrupted
+		}
+		res = append(res, block...)
+		data = data[compressedLen:]
+	}
+	return res, nil
+}
+
+const (
+	xxhPrime1 uint32 = 2654435761
+	xxhPrime2 uint32 = 2246822519
+	xxhPrime3 uint32 = 3266489917
+	xxhPrime4 uint32 = 668265263
+	xxhPrime5 uint32 = 374761393
+)
+
+func xxhRound(acc, in uint32) uint32 {
+	return bits.RotateLeft32(acc+in*xxhPrime2, 13) * xxhPrime1
+}
+
+// xxh32 is XXH32 as used by lz4-java block checksums
+func xxh32(b []byte, seed uint32) uint32 {
+	n := len(b)
+	var h uint32
+	if n >= 16 {
+		v1 := seed + xxhPrime1 + xxhPrime2
+		v2 := seed + xxhPrime2
+		v3 := seed
+		v4 := seed - xxhPrime1
+		for len(b) >= 16 {
+			v1 = xxhRound(v1, binary.LittleEndian.Uint32(b[0:]))
+			v2 = xxhRound(v2, binary.LittleEndian.Uint32(b[4:]))
+			v3 = xxhRound(v3, binary.LittleEndian.Uint32(b[8:]))
+			v4 = xxhRound(v4, binary.LittleEndian.Uint32(b[12:]))
+			b = b[16:]
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) + bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxhPrime5
+	}
+	h += uint32(n)
+	for len(b) >= 4 {
+		h += binary.LittleEndian.Uint32(b) * xxhPrime3
+		h = bits.RotateLeft32(h, 17) * xxhPrime4
+		b = b[4:]
+	}
+	for _, c := range b {
+		h += uint32(c) * xxhPrime5
+		h = bits.RotateLeft32(h, 11) * xxhPrime1
+	}
+	h ^= h >> 15
+	h *= xxhPrime2
+	h ^= h >> 13
+	h *= xxhPrime3
+	h ^= h >> 16
+	return h
+}
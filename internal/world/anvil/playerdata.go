@@ -0,0 +1,66 @@
+package anvil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+	"github.com/google/uuid"
+)
+
+// PlayerData is the playerdata directory of a vanilla world, every player
+// is a gzipped NBT file named after its UUID
+type PlayerData struct {
+	dir string
+}
+
+// NewPlayerData uses <worldDir>/playerdata, it is created on the first save
+func NewPlayerData(worldDir string) *PlayerData {
+	return &PlayerData{dir: filepath.Join(worldDir, "playerdata")}
+}
+
+func (p *PlayerData) path(id uuid.UUID) string {
+	return filepath.Join(p.dir, id.String()+".dat")
+}
+
+// Load reads the data of player id, an error matching fs.ErrNotExist is
+// returned for players that never joined
+func (p *PlayerData) Load(id uuid.UUID) (nbt.Compound, error) {
+	f, err := os.Open(p.path(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read player data: %w", err)
+	}
+	_, c, err := nbt.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read player data: %w", err)
+	}
+	return c, nil
+}
+
+// Save replaces the data of player id
+func (p *PlayerData) Save(id uuid.UUID, c nbt.Compound) error {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	err := nbt.Write(w, "", c)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode player data: %w", err)
+	}
+
+	err = os.MkdirAll(p.dir, 0o755)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path(id), buf.Bytes())
+}
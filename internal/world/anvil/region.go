@@ -0,0 +1,349 @@
+package anvil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/metrics"
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+var (
+	ErrRegionClosed  = errors.New("region file closed")
+	ErrCorruptedData = errors.New("corrupted chunk data")
+)
+
+const (
+	SectorSize = 4096
+
+	regionWidth   = 32
+	chunksCount   = regionWidth * regionWidth
+	headerSectors = 2
+	// maxInlineSectors is the largest sector count the location table can hold,
+	// bigger chunks go to external .mcc files
+	maxInlineSectors = 255
+	chunkHeaderSize  = 5
+)
+
+// Region is a single r.<x>.<z>.mca file holding up to 32x32 chunks
+type Region struct {
+	mu sync.Mutex
+
+	X, Z int
+	dir  string
+	f    *os.File
+
+	locations  [chunksCount]uint32
+	timestamps [chunksCount]uint32
+	// used tracks allocated sectors, header sectors are always used
+	used []bool
+
+	// Compression is used for chunks written to this region
+	Compression Compression
+}
+
+func RegionFileName(x, z int) string {
+	return fmt.Sprintf("r.%d.%d.mca", x, z)
+}
+
+// OpenRegion opens region x, z in dir and creates it if it doesn't exist
+func OpenRegion(dir string, x, z int) (*Region, error) {
+	path := filepath.Join(dir, RegionFileName(x, z))
+	r := &Region{
+		X:           x,
+		Z:           z,
+		dir:         dir,
+		Compression: CompressionZlib,
+	}
+
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = createRegionFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.f, err = os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	err = r.readHeader()
+	if err != nil {
+		_ = r.f.Close()
+		return nil, fmt.Errorf("failed to read region %s header: %w", path, err)
+	}
+	return r, nil
+}
+
+// createRegionFile writes an empty header to a temp file and renames it so a
+// crash never leaves a region file with a partial header behind
+func createRegionFile(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, make([]byte, headerSectors*SectorSize))
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (r *Region) readHeader() error {
+	header := make([]byte, headerSectors*SectorSize)
+	_, err := io.ReadFull(r.f, header)
+	if err != nil {
+		return err
+	}
+	info, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+	fileSectors := int((info.Size() + SectorSize - 1) / SectorSize)
+	r.used = make([]bool, max(fileSectors, headerSectors))
+	r.used[0], r.used[1] = true, true
+
+	for i := range chunksCount {
+		loc := binary.BigEndian.Uint32(header[i*4:])
+		r.timestamps[i] = binary.BigEndian.Uint32(header[SectorSize+i*4:])
+		offset, count := int(loc>>8), int(loc&0xFF)
+		// entries pointing into the header or past the end of file are dropped
+		if loc == 0 || offset < headerSectors || count == 0 || offset+count > fileSectors {
+			continue
+		}
+		r.locations[i] = loc
+		for s := offset; s < offset+count; s++ {
+			r.used[s] = true
+		}
+	}
+	return nil
+}
+
+func chunkIndex(x, z int) int {
+	return (x & (regionWidth - 1)) + (z&(regionWidth-1))*regionWidth
+}
+
+func (r *Region) externalPath(x, z int) string {
+	return filepath.Join(r.dir, fmt.Sprintf("c.%d.%d.mcc", r.X*regionWidth+(x&(regionWidth-1)), r.Z*regionWidth+(z&(regionWidth-1))))
+}
+
+// HasChunk reports whether chunk x, z is stored, x and z are taken modulo 32
+func (r *Region) HasChunk(x, z int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.locations[chunkIndex(x, z)] != 0
+}
+
+// Timestamp returns last modification time of chunk x, z
+func (r *Region) Timestamp(x, z int) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Unix(int64(r.timestamps[chunkIndex(x, z)]), 0)
+}
+
+// ReadChunk returns uncompressed chunk NBT or world.ErrChunkNotFound
+func (r *Region) ReadChunk(x, z int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil, ErrRegionClosed
+	}
+
+	loc := r.locations[chunkIndex(x, z)]
+	if loc == 0 {
+		return nil, world.ErrChunkNotFound
+	}
+	offset, count := int64(loc>>8), int(loc&0xFF)
+
+	raw := make([]byte, count*SectorSize)
+	_, err := r.f.ReadAt(raw, offset*SectorSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(raw))
+	if length == 0 || length+4 > len(raw) {
+		return nil, fmt.Errorf("%w: chunk %d %d length %d", ErrCorruptedData, x, z, length)
+	}
+	scheme := raw[4]
+	payload := raw[chunkHeaderSize : length+4]
+
+	if scheme&externalFlag != 0 {
+		payload, err = os.ReadFile(r.externalPath(x, z))
+		if err != nil {
+			return nil, err
+		}
+		scheme &^= externalFlag
+	}
+	return decompress(Compression(scheme), payload)
+}
+
+// WriteChunk compresses and stores chunk NBT. New data is always written to
+// free sectors and synced before the location table is updated, so a crash
+// leaves either the old or the new chunk readable.
+func (r *Region) WriteChunk(x, z int, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return ErrRegionClosed
+	}
+
+	payload, err := compress(r.Compression, data)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		metrics.ChunkCompressionRatio.Observe(float64(len(payload)) / float64(len(data)))
+	}
+
+	scheme := byte(r.Compression)
+	external := chunkHeaderSize+len(payload) > maxInlineSectors*SectorSize
+	if external {
+		err = writeFileAtomic(r.externalPath(x, z), payload)
+		if err != nil {
+			return err
+		}
+		scheme |= externalFlag
+		payload = nil
+	}
+
+	buf := make([]byte, chunkHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)+1))
+	buf[4] = scheme
+	copy(buf[chunkHeaderSize:], payload)
+
+	count := (len(buf) + SectorSize - 1) / SectorSize
+	offset := r.allocate(count)
+	padded := make([]byte, count*SectorSize)
+	copy(padded, buf)
+	_, err = r.f.WriteAt(padded, int64(offset)*SectorSize)
+	if err != nil {
+		r.release(offset, count)
+		return err
+	}
+	err = r.f.Sync()
+	if err != nil {
+		r.release(offset, count)
+		return err
+	}
+
+	i := chunkIndex(x, z)
+	old := r.locations[i]
+	r.locations[i] = uint32(offset)<<8 | uint32(count)
+	r.timestamps[i] = uint32(time.Now().Unix())
+	err = r.writeHeaderEntry(i)
+	if err != nil {
+		return err
+	}
+	if old != 0 {
+		r.release(int(old>>8), int(old&0xFF))
+	}
+	if !external {
+		_ = os.Remove(r.externalPath(x, z))
+	}
+	return nil
+}
+
+// DeleteChunk removes chunk x, z from the location table
+func (r *Region) DeleteChunk(x, z int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return ErrRegionClosed
+	}
+	i := chunkIndex(x, z)
+	old := r.locations[i]
+	if old == 0 {
+		return nil
+	}
+	r.locations[i] = 0
+	r.timestamps[i] = 0
+	err := r.writeHeaderEntry(i)
+	if err != nil {
+		return err
+	}
+	r.release(int(old>>8), int(old&0xFF))
+	_ = os.Remove(r.externalPath(x, z))
+	return nil
+}
+
+func (r *Region) writeHeaderEntry(i int) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], r.locations[i])
+	_, err := r.f.WriteAt(b[:], int64(i*4))
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(b[:], r.timestamps[i])
+	_, err = r.f.WriteAt(b[:], int64(SectorSize+i*4))
+	if err != nil {
+		return err
+	}
+	return r.f.Sync()
+}
+
+// allocate finds the first run of count free sectors, growing the file if needed
+func (r *Region) allocate(count int) int {
+	run := 0
+	for i, u := range r.used {
+		if u {
+			run = 0
+			continue
+		}
+		run++
+		if run == count {
+			start := i - count + 1
+			r.mark(start, count, true)
+			return start
+		}
+	}
+	start := len(r.used) - run
+	r.used = append(r.used, make([]bool, count-run)...)
+	r.mark(start, count, true)
+	return start
+}
+
+func (r *Region) release(offset, count int) {
+	r.mark(offset, count, false)
+}
+
+func (r *Region) mark(offset, count int, v bool) {
+	for s := offset; s < offset+count && s < len(r.used); s++ {
+		if s >= headerSectors {
+			r.used[s] = v
+		}
+	}
+}
+
+func (r *Region) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
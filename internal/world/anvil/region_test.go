@@ -0,0 +1,120 @@
+package anvil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXXH32(t *testing.T) {
+	require.Equal(t, uint32(0x02CC5D05), xxh32(nil, 0))
+	require.Equal(t, uint32(0x32D153FF), xxh32([]byte("abc"), 0))
+}
+
+func TestCompression(t *testing.T) {
+	data := bytes.Repeat([]byte("minecraft:stone "), 10000)
+	for _, c := range []Compression{CompressionGZip, CompressionZlib, CompressionNone, CompressionLZ4} {
+		compressed, err := compress(c, data)
+		require.NoError(t, err, c)
+
+		res, err := decompress(c, compressed)
+		require.NoError(t, err, c)
+		require.Equal(t, data, res, c)
+	}
+
+	_, err := decompress(Compression(9), data)
+	require.ErrorIs(t, err, ErrUnknownCompression)
+}
+
+func TestRegion_ReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	r, err := OpenRegion(dir, -1, 2)
+	require.NoError(t, err)
+
+	_, err = r.ReadChunk(0, 0)
+	require.ErrorIs(t, err, world.ErrChunkNotFound)
+
+	small := []byte("small chunk")
+	err = r.WriteChunk(-32, 64, small)
+	require.NoError(t, err)
+
+	r.Compression = CompressionLZ4
+	medium := bytes.Repeat([]byte{1, 2, 3, 4}, 5000)
+	err = r.WriteChunk(5, 7, medium)
+	require.NoError(t, err)
+
+	// rewriting a chunk with bigger data must not corrupt neighbours
+	bigger := bytes.Repeat([]byte{9}, 3*SectorSize)
+	r.Compression = CompressionNone
+	err = r.WriteChunk(-32, 64, bigger)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	r, err = OpenRegion(dir, -1, 2)
+	require.NoError(t, err)
+	defer r.Close()
+
+	res, err := r.ReadChunk(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, bigger, res)
+
+	res, err = r.ReadChunk(5, 7)
+	require.NoError(t, err)
+	require.Equal(t, medium, res)
+	require.False(t, r.Timestamp(5, 7).IsZero())
+
+	require.NoError(t, r.DeleteChunk(5, 7))
+	require.False(t, r.HasChunk(5, 7))
+}
+
+func TestRegion_External(t *testing.T) {
+	dir := t.TempDir()
+	r, err := OpenRegion(dir, 0, 0)
+	require.NoError(t, err)
+	defer r.Close()
+	r.Compression = CompressionNone
+
+	huge := make([]byte, (maxInlineSectors+1)*SectorSize)
+	_, err = rand.Read(huge)
+	require.NoError(t, err)
+
+	err = r.WriteChunk(3, 4, huge)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, "c.3.4.mcc"))
+
+	res, err := r.ReadChunk(3, 4)
+	require.NoError(t, err)
+	require.Equal(t, huge, res)
+
+	// shrinking the chunk brings it back inline
+	err = r.WriteChunk(3, 4, []byte("tiny"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "c.3.4.mcc"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRegionCache_Evict(t *testing.T) {
+	c := NewRegionCache(t.TempDir(), 2)
+	defer c.Close()
+
+	r0, err := c.Region(0, 0)
+	require.NoError(t, err)
+	r1, err := c.Region(1, 0)
+	require.NoError(t, err)
+	_, err = c.Region(0, 0)
+	require.NoError(t, err)
+	_, err = c.Region(2, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Len())
+
+	// 1,0 was least recently used
+	require.ErrorIs(t, r1.WriteChunk(0, 0, nil), ErrRegionClosed)
+	r, err := c.Region(0, 0)
+	require.NoError(t, err)
+	require.Same(t, r0, r)
+}
@@ -0,0 +1,78 @@
+package anvil
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+// Storage implements world.Storage on top of the region directory of a vanilla world
+type Storage struct {
+	regions *RegionCache
+	minY    int
+	height  int
+}
+
+// NewStorage opens <worldDir>/region, minY and height describe the dimension
+func NewStorage(worldDir string, minY, height int) *Storage {
+	return &Storage{
+		regions: NewRegionCache(filepath.Join(worldDir, "region"), DefaultMaxOpenRegions),
+		minY:    minY,
+		height:  height,
+	}
+}
+
+// Regions exposes the region cache, e.g. to change compression
+func (s *Storage) Regions() *RegionCache {
+	return s.regions
+}
+
+func (s *Storage) LoadChunk(x, z int32) (*world.Chunk, error) {
+	data, err := s.withRegion(x, z, func(r *Region) ([]byte, error) {
+		return r.ReadChunk(int(x), int(z))
+	})
+	if err != nil {
+		return nil, err
+	}
+	c, err := unmarshalChunk(data, s.minY, s.height)
+	if err != nil {
+		return nil, err
+	}
+	c.X, c.Z = x, z
+	return c, nil
+}
+
+func (s *Storage) SaveChunk(c *world.Chunk) error {
+	data, err := marshalChunk(c)
+	if err != nil {
+		return err
+	}
+	_, err = s.withRegion(c.X, c.Z, func(r *Region) ([]byte, error) {
+		return nil, r.WriteChunk(int(c.X), int(c.Z), data)
+	})
+	if err != nil {
+		return err
+	}
+	c.MarkSaved()
+	return nil
+}
+
+// withRegion retries once if the region was evicted from the cache between lookup and use
+func (s *Storage) withRegion(x, z int32, fn func(r *Region) ([]byte, error)) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		r, err := s.regions.RegionOf(x, z)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fn(r)
+		if errors.Is(err, ErrRegionClosed) && attempt == 0 {
+			continue
+		}
+		return data, err
+	}
+}
+
+func (s *Storage) Close() error {
+	return s.regions.Close()
+}
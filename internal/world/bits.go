@@ -0,0 +1,41 @@
+package world
+
+import "math/bits"
+
+// BitsFor returns the number of bits needed to index n palette entries,
+// but not less than minBits
+func BitsFor(n int, minBits int) int {
+	if n <= 1 {
+		return minBits
+	}
+	return max(bits.Len(uint(n-1)), minBits)
+}
+
+// PackIndexes packs idx into longs without spanning entries across longs,
+// the layout used by both region files and the network since 1.16
+func PackIndexes(idx []uint16, bitsPerEntry int) []int64 {
+	perLong := 64 / bitsPerEntry
+	res := make([]int64, (len(idx)+perLong-1)/perLong)
+	for i, v := range idx {
+		res[i/perLong] |= int64(uint64(v) << (uint(i%perLong) * uint(bitsPerEntry)))
+	}
+	return res
+}
+
+// UnpackIndexes is the inverse of PackIndexes, it fills out and reports false
+// if data is too short for len(out) entries
+func UnpackIndexes(data []int64, bitsPerEntry int, out []uint16) bool {
+	if bitsPerEntry == 0 {
+		clear(out)
+		return true
+	}
+	perLong := 64 / bitsPerEntry
+	if len(data) < (len(out)+perLong-1)/perLong {
+		return false
+	}
+	mask := uint64(1)<<uint(bitsPerEntry) - 1
+	for i := range out {
+		out[i] = uint16(uint64(data[i/perLong]) >> (uint(i%perLong) * uint(bitsPerEntry)) & mask)
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package world
+
+import (
+	"sort"
+	"strings"
+)
+
+// BlockState is a block name with its canonical properties string,
+// e.g. {"minecraft:oak_log", "axis=y"}. Properties are sorted by key so
+// BlockState is comparable and can be used as a map key.
+type BlockState struct {
+	Name       string
+	Properties string
+}
+
+var (
+	Air       = BlockState{Name: "minecraft:air"}
+	CaveAir   = BlockState{Name: "minecraft:cave_air"}
+	VoidAir   = BlockState{Name: "minecraft:void_air"}
+	Stone     = BlockState{Name: "minecraft:stone"}
+	Bedrock   = BlockState{Name: "minecraft:bedrock"}
+	Dirt      = BlockState{Name: "minecraft:dirt"}
+	Grass     = BlockState{Name: "minecraft:grass_block", Properties: "snowy=false"}
+	Water     = BlockState{Name: "minecraft:water", Properties: "level=0"}
+	Sand      = BlockState{Name: "minecraft:sand"}
+	Gravel    = BlockState{Name: "minecraft:gravel"}
+	OakLog    = BlockState{Name: "minecraft:oak_log", Properties: "axis=y"}
+	OakLeaves = BlockState{Name: "minecraft:oak_leaves", Properties: "distance=7,persistent=false,waterlogged=false"}
+)
+
+const DefaultBiome = "minecraft:plains"
+
+func NewBlockState(name string, props map[string]string) BlockState {
+	if !strings.Contains(name, ":") {
+		name = "minecraft:" + name
+	}
+	if len(props) == 0 {
+		return BlockState{Name: name}
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(props[k])
+	}
+	return BlockState{Name: name, Properties: sb.String()}
+}
+
+// ParseBlockState parses the "name[key=value,...]" notation used by commands and flat presets
+func ParseBlockState(s string) BlockState {
+	name, rest, ok := strings.Cut(strings.TrimSpace(s), "[")
+	if !ok {
+		return NewBlockState(name, nil)
+	}
+	rest = strings.TrimSuffix(rest, "]")
+	props := map[string]string{}
+	for _, kv := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok {
+			props[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return NewBlockState(name, props)
+}
+
+func (b BlockState) String() string {
+	if b.Properties == "" {
+		return b.Name
+	}
+	return b.Name + "[" + b.Properties + "]"
+}
+
+func (b BlockState) Property(key string) string {
+	for _, kv := range strings.Split(b.Properties, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+func (b BlockState) PropertyMap() map[string]string {
+	if b.Properties == "" {
+		return nil
+	}
+	m := map[string]string{}
+	for _, kv := range strings.Split(b.Properties, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+func (b BlockState) WithProperty(key, value string) BlockState {
+	m := b.PropertyMap()
+	if m == nil {
+		m = map[string]string{}
+	}
+	m[key] = value
+	return NewBlockState(b.Name, m)
+}
+
+func (b BlockState) IsAir() bool {
+	return b.Name == Air.Name || b.Name == CaveAir.Name || b.Name == VoidAir.Name || b.Name == ""
+}
@@ -0,0 +1,194 @@
+package world
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/BinaryArchaism/mc-srv/internal/nbt"
+)
+
+var ErrChunkNotFound = errors.New("chunk not found")
+
+const (
+	// OverworldMinY and OverworldHeight describe the vanilla overworld dimension type
+	OverworldMinY   = -64
+	OverworldHeight = 384
+
+	StatusFull = "minecraft:full"
+)
+
+type ChunkPos struct {
+	X, Z int32
+}
+
+// ChunkPosOf returns position of the chunk containing block x, z
+func ChunkPosOf(x, z int) ChunkPos {
+	return ChunkPos{X: int32(x >> 4), Z: int32(z >> 4)}
+}
+
+type BlockPos struct {
+	X, Y, Z int
+}
+
+// Storage persists chunks, LoadChunk returns ErrChunkNotFound for chunks that were never saved
+type Storage interface {
+	LoadChunk(x, z int32) (*Chunk, error)
+	SaveChunk(c *Chunk) error
+	Close() error
+}
+
+// Chunk is a 16 blocks wide column of sections. Block coordinates passed to
+// Chunk methods are chunk local for x and z and absolute for y.
+type Chunk struct {
+	mu sync.RWMutex
+
+	X, Z     int32
+	MinY     int
+	Sections []*Section
+
+	// BlockEntities holds block entity data keyed by absolute block position
+	BlockEntities map[BlockPos]nbt.Compound
+
+	Status        string
+	LastUpdate    int64
+	InhabitedTime int64
+
+	dirty bool
+}
+
+func NewChunk(x, z int32, minY, height int) *Chunk {
+	c := &Chunk{
+		X:             x,
+		Z:             z,
+		MinY:          minY,
+		Sections:      make([]*Section, height/SectionWidth),
+		BlockEntities: map[BlockPos]nbt.Compound{},
+		Status:        StatusFull,
+	}
+	for i := range c.Sections {
+		c.Sections[i] = NewSection(DefaultBiome)
+	}
+	return c
+}
+
+func (c *Chunk) Pos() ChunkPos {
+	return ChunkPos{X: c.X, Z: c.Z}
+}
+
+func (c *Chunk) Height() int {
+	return len(c.Sections) * SectionWidth
+}
+
+func (c *Chunk) MaxY() int {
+	return c.MinY + c.Height() - 1
+}
+
+func (c *Chunk) section(y int) *Section {
+	i := (y - c.MinY) >> 4
+	if i < 0 || i >= len(c.Sections) {
+		return nil
+	}
+	return c.Sections[i]
+}
+
+func (c *Chunk) Lock()    { c.mu.Lock() }
+func (c *Chunk) Unlock()  { c.mu.Unlock() }
+func (c *Chunk) RLock()   { c.mu.RLock() }
+func (c *Chunk) RUnlock() { c.mu.RUnlock() }
+
+// Block returns air for y outside of the chunk
+func (c *Chunk) Block(x, y, z int) BlockState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := c.section(y)
+	if s == nil {
+		return Air
+	}
+	return s.Block(x, y, z)
+}
+
+// SetBlock sets a block and returns the previous one, y outside of the chunk is ignored
+func (c *Chunk) SetBlock(x, y, z int, b BlockState) BlockState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.SetBlockLocked(x, y, z, b)
+}
+
+// SetBlockLocked is SetBlock for callers that already hold the chunk lock
+func (c *Chunk) SetBlockLocked(x, y, z int, b BlockState) BlockState {
+	s := c.section(y)
+	if s == nil {
+		return Air
+	}
+	prev := s.SetBlock(x, y, z, b)
+	if prev != b {
+		c.dirty = true
+	}
+	return prev
+}
+
+// Biome returns biome at chunk local block coordinates
+func (c *Chunk) Biome(x, y, z int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := c.section(y)
+	if s == nil {
+		return DefaultBiome
+	}
+	return s.Biome((x&0xF)>>2, (y&0xF)>>2, (z&0xF)>>2)
+}
+
+// HighestBlock returns y of the highest non air block in column x, z or MinY-1 for empty columns
+func (c *Chunk) HighestBlock(x, z int) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for i := len(c.Sections) - 1; i >= 0; i-- {
+		s := c.Sections[i]
+		if s.IsEmpty() {
+			continue
+		}
+		for y := SectionWidth - 1; y >= 0; y-- {
+			if !s.Block(x, y, z).IsAir() {
+				return c.MinY + i*SectionWidth + y
+			}
+		}
+	}
+	return c.MinY - 1
+}
+
+func (c *Chunk) BlockEntity(pos BlockPos) nbt.Compound {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.BlockEntities[pos]
+}
+
+// SetBlockEntity stores block entity data, nil removes it
+func (c *Chunk) SetBlockEntity(pos BlockPos, data nbt.Compound) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data == nil {
+		delete(c.BlockEntities, pos)
+	} else {
+		c.BlockEntities[pos] = data
+	}
+	c.dirty = true
+}
+
+// Dirty reports whether chunk changed since it was loaded or last saved
+func (c *Chunk) Dirty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dirty
+}
+
+func (c *Chunk) MarkDirty() {
+	c.mu.Lock()
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+func (c *Chunk) MarkSaved() {
+	c.mu.Lock()
+	c.dirty = false
+	c.mu.Unlock()
+}
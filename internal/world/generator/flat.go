@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+var ErrInvalidLayers = errors.New("invalid superflat layers")
+
+// DefaultFlatLayers is the vanilla "Classic Flat" preset
+const DefaultFlatLayers = "minecraft:bedrock,2*minecraft:dirt,minecraft:grass_block;minecraft:plains"
+
+type Layer struct {
+	Block  world.BlockState
+	Height int
+}
+
+// Flat generates the same column of layers everywhere starting at the bottom of the world
+type Flat struct {
+	Layers []Layer
+	Biome  string
+
+	minY int
+}
+
+// NewFlat parses a vanilla layers string "layer,layer,...;biome" where a layer
+// is "block" or "count*block", listed from the bottom up. A leading numeric
+// version and trailing structure options of the legacy preset format are ignored.
+func NewFlat(layers string, minY int) (*Flat, error) {
+	parts := strings.Split(strings.TrimSpace(layers), ";")
+	if len(parts) > 1 {
+		if _, err := strconv.Atoi(parts[0]); err == nil {
+			parts = parts[1:]
+		}
+	}
+	f := &Flat{Biome: world.DefaultBiome, minY: minY}
+	if len(parts) > 1 && parts[1] != "" {
+		f.Biome = namespaced(parts[1])
+	}
+
+	total := 0
+	for _, l := range strings.Split(parts[0], ",") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		layer, err := parseLayer(l)
+		if err != nil {
+			return nil, err
+		}
+		total += layer.Height
+		if total > world.OverworldHeight {
+			return nil, fmt.Errorf("%w: layers are higher than the world", ErrInvalidLayers)
+		}
+		f.Layers = append(f.Layers, layer)
+	}
+	return f, nil
+}
+
+func parseLayer(s string) (Layer, error) {
+	count, block := 1, s
+	if n, b, ok := strings.Cut(s, "*"); ok {
+		c, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || c <= 0 {
+			return Layer{}, fmt.Errorf("%w: %q", ErrInvalidLayers, s)
+		}
+		count, block = c, b
+	} else if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && s[i] == 'x' {
+		// legacy "3xminecraft:stone" notation
+		c, _ := strconv.Atoi(s[:i])
+		count, block = c, s[i+1:]
+	}
+	if strings.TrimSpace(block) == "" {
+		return Layer{}, fmt.Errorf("%w: %q", ErrInvalidLayers, s)
+	}
+	return Layer{Block: world.ParseBlockState(block), Height: count}, nil
+}
+
+func namespaced(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, ":") {
+		return "minecraft:" + s
+	}
+	return s
+}
+
+func (f *Flat) Generate(c *world.Chunk) {
+	c.Lock()
+	defer c.Unlock()
+	y := c.MinY
+	for _, l := range f.Layers {
+		for range l.Height {
+			if !l.Block.IsAir() {
+				for x := range world.SectionWidth {
+					for z := range world.SectionWidth {
+						c.SetBlockLocked(x, y, z, l.Block)
+					}
+				}
+			}
+			y++
+		}
+	}
+	if f.Biome != world.DefaultBiome {
+		fillBiome(c, f.Biome)
+	}
+}
+
+func (f *Flat) Spawn() world.BlockPos {
+	y := f.minY
+	for _, l := range f.Layers {
+		y += l.Height
+	}
+	return world.BlockPos{X: 0, Y: y, Z: 0}
+}
+
+func (f *Flat) Flat() bool {
+	return true
+}
+
+func fillBiome(c *world.Chunk, biome string) {
+	for _, s := range c.Sections {
+		s.SetBiomeData([]string{biome}, nil)
+	}
+}
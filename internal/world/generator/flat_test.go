@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFlat(t *testing.T) {
+	testCases := []struct {
+		name   string
+		layers string
+		exp    []Layer
+		biome  string
+	}{
+		{
+			name:   "classic",
+			layers: DefaultFlatLayers,
+			exp: []Layer{
+				{Block: world.Bedrock, Height: 1},
+				{Block: world.Dirt, Height: 2},
+				{Block: world.BlockState{Name: "minecraft:grass_block"}, Height: 1},
+			},
+			biome: "minecraft:plains",
+		},
+		{
+			name:   "legacy version and x notation",
+			layers: "3;minecraft:bedrock,3xminecraft:stone;desert;village",
+			exp: []Layer{
+				{Block: world.Bedrock, Height: 1},
+				{Block: world.Stone, Height: 3},
+			},
+			biome: "minecraft:desert",
+		},
+		{
+			name:   "properties and no biome",
+			layers: "minecraft:bedrock,minecraft:grass_block[snowy=false]",
+			exp: []Layer{
+				{Block: world.Bedrock, Height: 1},
+				{Block: world.Grass, Height: 1},
+			},
+			biome: world.DefaultBiome,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFlat(tc.layers, world.OverworldMinY)
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, f.Layers)
+			require.Equal(t, tc.biome, f.Biome)
+		})
+	}
+
+	_, err := NewFlat("0*minecraft:stone", world.OverworldMinY)
+	require.ErrorIs(t, err, ErrInvalidLayers)
+	_, err = NewFlat("500*minecraft:stone", world.OverworldMinY)
+	require.ErrorIs(t, err, ErrInvalidLayers)
+}
+
+func TestFlat_Generate(t *testing.T) {
+	f, err := NewFlat(DefaultFlatLayers, world.OverworldMinY)
+	require.NoError(t, err)
+	w := world.New(world.Options{Generator: f})
+	require.True(t, w.Flat())
+	require.Equal(t, world.BlockPos{X: 0, Y: -60, Z: 0}, w.Spawn())
+
+	c, err := w.Chunk(5, -7)
+	require.NoError(t, err)
+	require.Equal(t, world.Bedrock, c.Block(0, -64, 0))
+	require.Equal(t, world.Dirt, c.Block(7, -63, 7))
+	require.Equal(t, world.Dirt, c.Block(7, -62, 7))
+	require.Equal(t, "minecraft:grass_block", c.Block(15, -61, 15).Name)
+	require.Equal(t, world.Air, c.Block(15, -60, 15))
+	require.Same(t, c, w.LoadedChunk(5, -7))
+}
+
+func TestVoid_Generate(t *testing.T) {
+	w := world.New(world.Options{Generator: NewVoid()})
+	spawn := w.Spawn()
+
+	b, err := w.Block(spawn.X, spawn.Y-1, spawn.Z)
+	require.NoError(t, err)
+	require.Equal(t, "minecraft:cobblestone", b.Name)
+
+	b, err = w.Block(spawn.X+DefaultPlatformRadius, spawn.Y-1, spawn.Z-DefaultPlatformRadius)
+	require.NoError(t, err)
+	require.Equal(t, world.Stone, b)
+
+	b, err = w.Block(spawn.X+DefaultPlatformRadius+1, spawn.Y-1, spawn.Z)
+	require.NoError(t, err)
+	require.Equal(t, world.Air, b)
+
+	c, err := w.Chunk(10, 10)
+	require.NoError(t, err)
+	require.Equal(t, c.MinY-1, c.HighestBlock(0, 0))
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Perlin is Ken Perlin's improved noise with a seeded permutation table.
+// It is immutable after creation and safe for concurrent use.
+type Perlin struct {
+	perm [512]uint8
+	// offsets shift sampling so that octaves sharing a seed don't line up
+	ox, oy, oz float64
+}
+
+func NewPerlin(rng *rand.Rand) *Perlin {
+	p := &Perlin{
+		ox: rng.Float64() * 256,
+		oy: rng.Float64() * 256,
+		oz: rng.Float64() * 256,
+	}
+	for i := range 256 {
+		p.perm[i] = uint8(i)
+	}
+	for i := 255; i > 0; i-- {
+		j := rng.IntN(i + 1)
+		p.perm[i], p.perm[j] = p.perm[j], p.perm[i]
+	}
+	copy(p.perm[256:], p.perm[:256])
+	return p
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash uint8, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+// Noise3 returns noise in about [-1, 1]
+func (p *Perlin) Noise3(x, y, z float64) float64 {
+	x, y, z = x+p.ox, y+p.oy, z+p.oz
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	a := int(p.perm[xi]) + yi
+	aa := int(p.perm[a]) + zi
+	ab := int(p.perm[a+1]) + zi
+	b := int(p.perm[xi+1]) + yi
+	ba := int(p.perm[b]) + zi
+	bb := int(p.perm[b+1]) + zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p.perm[aa], x, y, z), grad(p.perm[ba], x-1, y, z)),
+			lerp(u, grad(p.perm[ab], x, y-1, z), grad(p.perm[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p.perm[aa+1], x, y, z-1), grad(p.perm[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p.perm[ab+1], x, y-1, z-1), grad(p.perm[bb+1], x-1, y-1, z-1))))
+}
+
+func (p *Perlin) Noise2(x, z float64) float64 {
+	return p.Noise3(x, 0, z)
+}
+
+// Octaves sums several Perlin layers, every next one with double frequency
+// and half amplitude. The result is normalized to about [-1, 1].
+type Octaves struct {
+	layers []*Perlin
+	scale  float64
+	norm   float64
+}
+
+// NewOctaves creates count octaves, scale is the size in blocks of the lowest frequency feature
+func NewOctaves(rng *rand.Rand, count int, scale float64) *Octaves {
+	o := &Octaves{scale: scale}
+	amp := 1.0
+	for range count {
+		o.layers = append(o.layers, NewPerlin(rng))
+		o.norm += amp
+		amp /= 2
+	}
+	return o
+}
+
+func (o *Octaves) Noise3(x, y, z float64) float64 {
+	var sum float64
+	freq, amp := 1/o.scale, 1.0
+	for _, l := range o.layers {
+		sum += l.Noise3(x*freq, y*freq, z*freq) * amp
+		freq *= 2
+		amp /= 2
+	}
+	return sum / o.norm
+}
+
+func (o *Octaves) Noise2(x, z float64) float64 {
+	return o.Noise3(x, 0, z)
+}
@@ -0,0 +1,535 @@
+package generator
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+)
+
+const (
+	SeaLevel = 63
+
+	// bedrockLayers is how many layers above the bottom may contain bedrock
+	bedrockLayers = 5
+	lavaLevel     = -55
+	deepslateY    = 0
+)
+
+var (
+	deepslate    = world.BlockState{Name: "minecraft:deepslate", Properties: "axis=y"}
+	lava         = world.BlockState{Name: "minecraft:lava", Properties: "level=0"}
+	sandstone    = world.BlockState{Name: "minecraft:sandstone"}
+	snowBlock    = world.BlockState{Name: "minecraft:snow_block"}
+	snowLayer    = world.BlockState{Name: "minecraft:snow", Properties: "layers=1"}
+	snowyGrass   = world.BlockState{Name: "minecraft:grass_block", Properties: "snowy=true"}
+	shortGrass   = world.BlockState{Name: "minecraft:short_grass"}
+	deadBush     = world.BlockState{Name: "minecraft:dead_bush"}
+	birchLog     = world.BlockState{Name: "minecraft:birch_log", Properties: "axis=y"}
+	birchLeaves  = world.BlockState{Name: "minecraft:birch_leaves", Properties: "distance=7,persistent=false,waterlogged=false"}
+	spruceLog    = world.BlockState{Name: "minecraft:spruce_log", Properties: "axis=y"}
+	spruceLeaves = world.BlockState{Name: "minecraft:spruce_leaves", Properties: "distance=7,persistent=false,waterlogged=false"}
+)
+
+// terrainStream selects the PCG stream for noise layers so they differ from per chunk streams
+const terrainStream = 0x6d632d737276
+
+const (
+	BiomePlains        = "minecraft:plains"
+	BiomeForest        = "minecraft:forest"
+	BiomeDesert        = "minecraft:desert"
+	BiomeSnowyPlains   = "minecraft:snowy_plains"
+	BiomeTaiga         = "minecraft:taiga"
+	BiomeBeach         = "minecraft:beach"
+	BiomeSnowyBeach    = "minecraft:snowy_beach"
+	BiomeOcean         = "minecraft:ocean"
+	BiomeFrozenOcean   = "minecraft:frozen_ocean"
+	BiomeWindswept     = "minecraft:windswept_hills"
+	BiomeSnowySlopes   = "minecraft:snowy_slopes"
+	mountainHeight     = 110
+	oceanDepthFromSea  = 4
+	beachHeightFromSea = 1
+)
+
+type ore struct {
+	block     world.BlockState
+	deepslate world.BlockState
+	attempts  int
+	minY      int
+	maxY      int
+	size      int
+}
+
+var ores = []ore{
+	{block: block("coal_ore"), deepslate: block("deepslate_coal_ore"), attempts: 16, minY: 0, maxY: 128, size: 10},
+	{block: block("iron_ore"), deepslate: block("deepslate_iron_ore"), attempts: 10, minY: -24, maxY: 56, size: 7},
+	{block: block("copper_ore"), deepslate: block("deepslate_copper_ore"), attempts: 6, minY: -16, maxY: 112, size: 8},
+	{block: block("gold_ore"), deepslate: block("deepslate_gold_ore"), attempts: 4, minY: -64, maxY: 32, size: 6},
+	{block: block("redstone_ore"), deepslate: block("deepslate_redstone_ore"), attempts: 6, minY: -64, maxY: 16, size: 6},
+	{block: block("lapis_ore"), deepslate: block("deepslate_lapis_ore"), attempts: 2, minY: -32, maxY: 32, size: 5},
+	{block: block("diamond_ore"), deepslate: block("deepslate_diamond_ore"), attempts: 2, minY: -64, maxY: 16, size: 4},
+}
+
+func block(name string) world.BlockState {
+	return world.BlockState{Name: "minecraft:" + name}
+}
+
+// Terrain is a seeded noise generator with biomes, surface rules, caves, ores
+// and trees. Every chunk depends only on the seed and its position, so
+// Terrain is safe to use from many goroutines and chunks can be generated in
+// any order.
+type Terrain struct {
+	seed   int64
+	minY   int
+	height int
+
+	continents  *Octaves
+	hills       *Octaves
+	detail      *Octaves
+	temperature *Octaves
+	humidity    *Octaves
+	caveA       *Octaves
+	caveB       *Octaves
+	cheese      *Octaves
+
+	spawn world.BlockPos
+}
+
+func NewTerrain(seed int64, minY, height int) *Terrain {
+	rng := rand.New(rand.NewPCG(uint64(seed), terrainStream))
+	t := &Terrain{
+		seed:        seed,
+		minY:        minY,
+		height:      height,
+		continents:  NewOctaves(rng, 4, 1024),
+		hills:       NewOctaves(rng, 4, 256),
+		detail:      NewOctaves(rng, 3, 48),
+		temperature: NewOctaves(rng, 3, 768),
+		humidity:    NewOctaves(rng, 3, 512),
+		caveA:       NewOctaves(rng, 2, 64),
+		caveB:       NewOctaves(rng, 2, 64),
+		cheese:      NewOctaves(rng, 2, 96),
+	}
+	t.spawn = t.findSpawn()
+	return t
+}
+
+func (t *Terrain) Seed() int64 {
+	return t.seed
+}
+
+// HeightAt returns y of the topmost solid block of column x, z before caves are carved
+func (t *Terrain) HeightAt(x, z int) int {
+	fx, fz := float64(x), float64(z)
+	c := t.continents.Noise2(fx, fz)
+	h := t.hills.Noise2(fx, fz)
+	d := t.detail.Noise2(fx, fz)
+
+	height := 70 + c*80 + d*6
+	// mountains only rise on land
+	if h > 0 && c > -0.1 {
+		height += h * h * 220 * math.Min(1, (c+0.1)*4)
+	}
+	maxY := t.minY + t.height - 16
+	return int(math.Max(float64(t.minY+8), math.Min(float64(maxY), height)))
+}
+
+// BiomeAt returns biome of column x, z with surface height
+func (t *Terrain) BiomeAt(x, z, height int) string {
+	temp := t.temperature.Noise2(float64(x), float64(z))
+	hum := t.humidity.Noise2(float64(x), float64(z))
+	cold := temp < -0.2
+
+	switch {
+	case height < SeaLevel-oceanDepthFromSea:
+		if cold {
+			return BiomeFrozenOcean
+		}
+		return BiomeOcean
+	case height <= SeaLevel+beachHeightFromSea:
+		if cold {
+			return BiomeSnowyBeach
+		}
+		return BiomeBeach
+	case height > mountainHeight:
+		if cold || height > mountainHeight+40 {
+			return BiomeSnowySlopes
+		}
+		return BiomeWindswept
+	case temp > 0.15 && hum < 0:
+		return BiomeDesert
+	case cold && hum > 0.1:
+		return BiomeTaiga
+	case cold:
+		return BiomeSnowyPlains
+	case hum > 0.1:
+		return BiomeForest
+	}
+	return BiomePlains
+}
+
+// isCave reports whether block is carved by spaghetti or cheese caves
+func (t *Terrain) isCave(x, y, z, surface int) bool {
+	if y <= t.minY+bedrockLayers {
+		return false
+	}
+	// keep a roof under oceans and rivers so water doesn't flood caves
+	if surface < SeaLevel && y > surface-6 {
+		return false
+	}
+	fx, fy, fz := float64(x), float64(y)*2, float64(z)
+	if math.Abs(t.caveA.Noise3(fx, fy, fz)) < 0.045 && math.Abs(t.caveB.Noise3(fx, fy, fz)) < 0.045 {
+		return true
+	}
+	return y < 40 && y < surface-8 && t.cheese.Noise3(fx, fy, fz) > 0.48
+}
+
+// positional returns a stable pseudo random value for a block position
+func (t *Terrain) positional(x, y, z int, salt uint64) uint64 {
+	h := uint64(t.seed) ^ salt
+	h ^= uint64(int64(x)) * 0x9E3779B97F4A7C15
+	h ^= uint64(int64(y)) * 0xC2B2AE3D27D4EB4F
+	h ^= uint64(int64(z)) * 0x165667B19E3779F9
+	h ^= h >> 33
+	h *= 0xFF51AFD7ED558CCD
+	h ^= h >> 33
+	h *= 0xC4CEB9FE1A85EC53
+	h ^= h >> 33
+	return h
+}
+
+func (t *Terrain) chunkRand(cx, cz int32, salt uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(t.positional(int(cx), 0, int(cz), salt), uint64(t.seed)))
+}
+
+func (t *Terrain) Generate(c *world.Chunk) {
+	baseX, baseZ := int(c.X)*world.SectionWidth, int(c.Z)*world.SectionWidth
+
+	var heights, surfaces [world.SectionWidth][world.SectionWidth]int
+	var biomes [world.SectionWidth][world.SectionWidth]string
+	for x := range world.SectionWidth {
+		for z := range world.SectionWidth {
+			h := t.HeightAt(baseX+x, baseZ+z)
+			heights[x][z] = h
+			biomes[x][z] = t.BiomeAt(baseX+x, baseZ+z, h)
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for x := range world.SectionWidth {
+		for z := range world.SectionWidth {
+			surfaces[x][z] = t.fillColumn(c, baseX+x, baseZ+z, x, z, heights[x][z], biomes[x][z])
+		}
+	}
+
+	// biomes are stored per 4x4x4 cell, sampled at the cell center column
+	for _, s := range c.Sections {
+		for cx := range 4 {
+			for cz := range 4 {
+				b := biomes[cx*4+2][cz*4+2]
+				for cy := range 4 {
+					s.SetBiome(cx, cy, cz, b)
+				}
+			}
+		}
+	}
+
+	t.placeOres(c)
+	t.placeTrees(c)
+	t.placeVegetation(c, surfaces, biomes)
+}
+
+// fillColumn writes one column and returns y of its surface block after carving
+func (t *Terrain) fillColumn(c *world.Chunk, wx, wz, x, z, height int, biome string) int {
+	top, filler, fillerDepth := surfaceBlocks(biome, height)
+	surface := c.MinY - 1
+	for y := c.MinY; y <= max(height, SeaLevel-1); y++ {
+		var b world.BlockState
+		switch {
+		case y == c.MinY:
+			b = world.Bedrock
+		case y < c.MinY+bedrockLayers && t.positional(wx, y, wz, 1)%uint64(bedrockLayers) >= uint64(y-c.MinY):
+			b = world.Bedrock
+		case y > height:
+			b = world.Water
+			if y == SeaLevel-1 && (biome == BiomeFrozenOcean || biome == BiomeSnowyBeach) {
+				b = block("ice")
+			}
+		case t.isCave(wx, y, wz, height):
+			if y <= lavaLevel {
+				b = lava
+			} else {
+				b = world.CaveAir
+			}
+		case y == height:
+			b = top
+		case y > height-fillerDepth:
+			b = filler
+		case y < deepslateY-int(t.positional(wx, y, wz, 2)%8):
+			b = deepslate
+		default:
+			b = world.Stone
+		}
+		if !b.IsAir() {
+			c.SetBlockLocked(x, y, z, b)
+			if b != world.Water && b != lava {
+				surface = y
+			}
+		}
+	}
+	if surface == height && height >= SeaLevel && (biome == BiomeSnowyPlains || biome == BiomeSnowySlopes || biome == BiomeTaiga && height > 90) {
+		c.SetBlockLocked(x, height+1, z, snowLayer)
+	}
+	return surface
+}
+
+// surfaceBlocks returns top block, filler block and filler depth for a biome
+func surfaceBlocks(biome string, height int) (world.BlockState, world.BlockState, int) {
+	underwater := height < SeaLevel-1
+	switch biome {
+	case BiomeDesert:
+		return world.Sand, sandstone, 6
+	case BiomeBeach, BiomeSnowyBeach:
+		return world.Sand, world.Sand, 4
+	case BiomeOcean, BiomeFrozenOcean:
+		if height < SeaLevel-15 {
+			return world.Gravel, world.Gravel, 3
+		}
+		return world.Sand, world.Sand, 3
+	case BiomeWindswept:
+		return world.Stone, world.Stone, 1
+	case BiomeSnowySlopes:
+		return snowBlock, snowBlock, 3
+	case BiomeSnowyPlains, BiomeTaiga:
+		if underwater {
+			return world.Dirt, world.Dirt, 4
+		}
+		return snowyGrass, world.Dirt, 4
+	}
+	if underwater {
+		return world.Dirt, world.Dirt, 4
+	}
+	return world.Grass, world.Dirt, 4
+}
+
+func (t *Terrain) placeOres(c *world.Chunk) {
+	rng := t.chunkRand(c.X, c.Z, 3)
+	for _, o := range ores {
+		for range o.attempts {
+			x, z := rng.IntN(world.SectionWidth), rng.IntN(world.SectionWidth)
+			y := o.minY + rng.IntN(o.maxY-o.minY+1)
+			for range o.size {
+				if x >= 0 && x < world.SectionWidth && z >= 0 && z < world.SectionWidth {
+					t.replaceOre(c, x, y, z, o)
+				}
+				x += rng.IntN(3) - 1
+				y += rng.IntN(3) - 1
+				z += rng.IntN(3) - 1
+			}
+		}
+	}
+}
+
+func (t *Terrain) replaceOre(c *world.Chunk, x, y, z int, o ore) {
+	if y < c.MinY || y > c.MaxY() {
+		return
+	}
+	s := c.Sections[(y-c.MinY)>>4]
+	switch s.Block(x, y, z) {
+	case world.Stone:
+		c.SetBlockLocked(x, y, z, o.block)
+	case deepslate:
+		c.SetBlockLocked(x, y, z, o.deepslate)
+	}
+}
+
+type tree struct {
+	x, y, z int
+	height  int
+	log     world.BlockState
+	leaves  world.BlockState
+	spruce  bool
+}
+
+// treesOf returns trees rooted in chunk cx, cz in world coordinates. It only
+// uses noise, so neighbouring chunks can compute the same trees to draw the
+// parts that overhang into them.
+func (t *Terrain) treesOf(cx, cz int32) []tree {
+	rng := t.chunkRand(cx, cz, 4)
+	baseX, baseZ := int(cx)*world.SectionWidth, int(cz)*world.SectionWidth
+
+	probe := t.BiomeAt(baseX+8, baseZ+8, t.HeightAt(baseX+8, baseZ+8))
+	count := 0
+	switch probe {
+	case BiomeForest:
+		count = 6 + rng.IntN(4)
+	case BiomeTaiga:
+		count = 4 + rng.IntN(3)
+	case BiomePlains:
+		if rng.IntN(4) == 0 {
+			count = 1
+		}
+	case BiomeSnowyPlains:
+		if rng.IntN(8) == 0 {
+			count = 1
+		}
+	}
+
+	res := make([]tree, 0, count)
+	for range count {
+		x, z := baseX+rng.IntN(world.SectionWidth), baseZ+rng.IntN(world.SectionWidth)
+		h := t.HeightAt(x, z)
+		b := t.BiomeAt(x, z, h)
+		tr := tree{x: x, y: h + 1, z: z, height: 4 + rng.IntN(3), log: world.OakLog, leaves: world.OakLeaves}
+		switch b {
+		case BiomeForest:
+			if rng.IntN(5) == 0 {
+				tr.log, tr.leaves = birchLog, birchLeaves
+			}
+		case BiomeTaiga, BiomeSnowyPlains:
+			tr.log, tr.leaves, tr.spruce = spruceLog, spruceLeaves, true
+			tr.height += 2
+		case BiomePlains:
+		default:
+			continue
+		}
+		if h < SeaLevel || h+tr.height+2 > t.minY+t.height-1 || t.isCave(x, h, z, h) {
+			continue
+		}
+		res = append(res, tr)
+	}
+	return res
+}
+
+func (t *Terrain) placeTrees(c *world.Chunk) {
+	baseX, baseZ := int(c.X)*world.SectionWidth, int(c.Z)*world.SectionWidth
+	set := func(x, y, z int, b world.BlockState, log bool) {
+		lx, lz := x-baseX, z-baseZ
+		if lx < 0 || lx >= world.SectionWidth || lz < 0 || lz >= world.SectionWidth || y > c.MaxY() {
+			return
+		}
+		cur := c.Sections[(y-c.MinY)>>4].Block(lx, y, lz)
+		// logs replace leaves so the result doesn't depend on tree order
+		if cur.IsAir() || cur == snowLayer || log && (cur == world.OakLeaves || cur == birchLeaves || cur == spruceLeaves) {
+			c.SetBlockLocked(lx, y, lz, b)
+		}
+	}
+
+	for dx := int32(-1); dx <= 1; dx++ {
+		for dz := int32(-1); dz <= 1; dz++ {
+			for _, tr := range t.treesOf(c.X+dx, c.Z+dz) {
+				top := tr.y + tr.height - 1
+				if tr.spruce {
+					for y := tr.y + 2; y <= top+1; y++ {
+						r := 1
+						if (top-y)%2 == 1 && y < top {
+							r = 2
+						}
+						if y > top {
+							r = 0
+						}
+						t.leafLayer(tr, y, r, set)
+					}
+				} else {
+					for y := top - 2; y <= top+1; y++ {
+						r := 2
+						if y >= top {
+							r = 1
+						}
+						t.leafLayer(tr, y, r, set)
+					}
+				}
+				for y := tr.y; y <= top; y++ {
+					set(tr.x, y, tr.z, tr.log, true)
+				}
+				if lx, lz := tr.x-baseX, tr.z-baseZ; lx >= 0 && lx < world.SectionWidth && lz >= 0 && lz < world.SectionWidth {
+					c.SetBlockLocked(lx, tr.y-1, lz, world.Dirt)
+				}
+			}
+		}
+	}
+}
+
+func (t *Terrain) leafLayer(tr tree, y, r int, set func(x, y, z int, b world.BlockState, log bool)) {
+	for x := tr.x - r; x <= tr.x+r; x++ {
+		for z := tr.z - r; z <= tr.z+r; z++ {
+			corner := abs(x-tr.x) == r && abs(z-tr.z) == r && r > 0
+			if corner && t.positional(x, y, z, 5)%2 == 0 {
+				continue
+			}
+			set(x, y, z, tr.leaves, false)
+		}
+	}
+}
+
+func (t *Terrain) placeVegetation(c *world.Chunk, surfaces [world.SectionWidth][world.SectionWidth]int, biomes [world.SectionWidth][world.SectionWidth]string) {
+	baseX, baseZ := int(c.X)*world.SectionWidth, int(c.Z)*world.SectionWidth
+	for x := range world.SectionWidth {
+		for z := range world.SectionWidth {
+			y := surfaces[x][z] + 1
+			if y <= SeaLevel-1 || y > c.MaxY() {
+				continue
+			}
+			roll := t.positional(baseX+x, y, baseZ+z, 6) % 100
+			var plant world.BlockState
+			switch biomes[x][z] {
+			case BiomePlains:
+				if roll < 20 {
+					plant = shortGrass
+				}
+			case BiomeForest:
+				if roll < 8 {
+					plant = shortGrass
+				}
+			case BiomeDesert:
+				if roll < 1 {
+					plant = deadBush
+				}
+			}
+			if plant.Name == "" {
+				continue
+			}
+			below := c.Sections[(y-1-c.MinY)>>4].Block(x, y-1, z)
+			above := c.Sections[(y-c.MinY)>>4].Block(x, y, z)
+			if above.IsAir() && (below == world.Grass || below == world.Sand) {
+				c.SetBlockLocked(x, y, z, plant)
+			}
+		}
+	}
+}
+
+// findSpawn searches outwards from 0, 0 for dry land
+func (t *Terrain) findSpawn() world.BlockPos {
+	for r := 0; r <= 512; r += 16 {
+		for x := -r; x <= r; x += 16 {
+			for _, z := range []int{-r, r} {
+				if pos, ok := t.spawnAt(x, z); ok {
+					return pos
+				}
+				if pos, ok := t.spawnAt(z, x); ok {
+					return pos
+				}
+			}
+		}
+	}
+	return world.BlockPos{X: 0, Y: t.HeightAt(0, 0) + 1, Z: 0}
+}
+
+func (t *Terrain) spawnAt(x, z int) (world.BlockPos, bool) {
+	h := t.HeightAt(x, z)
+	b := t.BiomeAt(x, z, h)
+	if h < SeaLevel || b == BiomeOcean || b == BiomeFrozenOcean || h > mountainHeight {
+		return world.BlockPos{}, false
+	}
+	return world.BlockPos{X: x, Y: h + 1, Z: z}, true
+}
+
+func (t *Terrain) Spawn() world.BlockPos {
+	return t.spawn
+}
+
+func (t *Terrain) Flat() bool {
+	return false
+}
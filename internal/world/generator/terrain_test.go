@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BinaryArchaism/mc-srv/internal/world"
+	"github.com/stretchr/testify/require"
+)
+
+func generate(seed int64, x, z int32) *world.Chunk {
+	c := world.NewChunk(x, z, world.OverworldMinY, world.OverworldHeight)
+	NewTerrain(seed, world.OverworldMinY, world.OverworldHeight).Generate(c)
+	return c
+}
+
+func requireSameChunk(t *testing.T, exp, act *world.Chunk) {
+	t.Helper()
+	for i := range exp.Sections {
+		expPalette, expIdx := exp.Sections[i].BlockData()
+		actPalette, actIdx := act.Sections[i].BlockData()
+		require.Equal(t, expPalette, actPalette, "section %d", i)
+		require.Equal(t, expIdx, actIdx, "section %d", i)
+	}
+}
+
+func TestTerrain_Reproducible(t *testing.T) {
+	const seed = 20240613
+	requireSameChunk(t, generate(seed, 3, -2), generate(seed, 3, -2))
+
+	a, b := generate(seed, 0, 0), generate(seed+1, 0, 0)
+	same := true
+	for x := 0; x < 16 && same; x++ {
+		for z := 0; z < 16 && same; z++ {
+			same = a.HighestBlock(x, z) == b.HighestBlock(x, z)
+		}
+	}
+	require.False(t, same, "different seeds must produce different terrain")
+}
+
+func TestTerrain_Generate(t *testing.T) {
+	c := generate(42, 1, 1)
+	for x := range 16 {
+		for z := range 16 {
+			require.Equal(t, world.Bedrock, c.Block(x, world.OverworldMinY, z))
+			require.Greater(t, c.HighestBlock(x, z), world.OverworldMinY+8)
+		}
+	}
+
+	terrain := NewTerrain(42, world.OverworldMinY, world.OverworldHeight)
+	spawn := terrain.Spawn()
+	require.GreaterOrEqual(t, spawn.Y, SeaLevel)
+	require.False(t, terrain.Flat())
+}
+
+func TestTerrain_ParallelMatchesSequential(t *testing.T) {
+	const seed = 7
+	var positions []world.ChunkPos
+	for x := int32(-2); x <= 2; x++ {
+		for z := int32(-2); z <= 2; z++ {
+			positions = append(positions, world.ChunkPos{X: x, Z: z})
+		}
+	}
+
+	w := world.New(world.Options{Seed: seed, Generator: NewTerrain(seed, world.OverworldMinY, world.OverworldHeight)})
+	err := w.Preload(context.Background(), positions, 4)
+	require.NoError(t, err)
+	require.Len(t, w.LoadedChunks(), len(positions))
+
+	for _, pos := range positions {
+		requireSameChunk(t, generate(seed, pos.X, pos.Z), w.LoadedChunk(pos.X, pos.Z))
+	}
+}
+
+func TestPerlin_Range(t *testing.T) {
+	terrain := NewTerrain(1, world.OverworldMinY, world.OverworldHeight)
+	for i := range 1000 {
+		v := terrain.detail.Noise3(float64(i)*1.37, float64(i)*0.73, float64(i)*2.11)
+		require.True(t, v >= -1.1 && v <= 1.1, v)
+	}
+}
+
+func TestHashSeed(t *testing.T) {
+	require.Equal(t, world.HashSeed(12345), world.HashSeed(12345))
+	require.NotEqual(t, world.HashSeed(12345), world.HashSeed(12346))
+}
@@ -0,0 +1,63 @@
+package generator
+
+import "github.com/BinaryArchaism/mc-srv/internal/world"
+
+const (
+	DefaultPlatformY      = 64
+	DefaultPlatformRadius = 16
+)
+
+// Void generates empty chunks except for a square stone platform centered
+// at block 8, 8 like the vanilla void start platform
+type Void struct {
+	Biome          string
+	PlatformY      int
+	PlatformRadius int
+}
+
+func NewVoid() *Void {
+	return &Void{
+		Biome:          "minecraft:the_void",
+		PlatformY:      DefaultPlatformY,
+		PlatformRadius: DefaultPlatformRadius,
+	}
+}
+
+const platformCenter = 8
+
+func (v *Void) Generate(c *world.Chunk) {
+	fillBiome(c, v.Biome)
+
+	c.Lock()
+	defer c.Unlock()
+	baseX, baseZ := int(c.X)*world.SectionWidth, int(c.Z)*world.SectionWidth
+	for x := range world.SectionWidth {
+		for z := range world.SectionWidth {
+			dx, dz := baseX+x-platformCenter, baseZ+z-platformCenter
+			if abs(dx) > v.PlatformRadius || abs(dz) > v.PlatformRadius {
+				continue
+			}
+			block := world.Stone
+			if dx == 0 && dz == 0 {
+				block = world.BlockState{Name: "minecraft:cobblestone"}
+			}
+			c.SetBlockLocked(x, v.PlatformY, z, block)
+		}
+	}
+}
+
+func (v *Void) Spawn() world.BlockPos {
+	return world.BlockPos{X: platformCenter, Y: v.PlatformY + 1, Z: platformCenter}
+}
+
+// Flat is true because vanilla void is a superflat preset
+func (v *Void) Flat() bool {
+	return true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,193 @@
+package world
+
+const (
+	SectionWidth  = 16
+	SectionVolume = SectionWidth * SectionWidth * SectionWidth
+	BiomeVolume   = 4 * 4 * 4
+	LightLength   = SectionVolume / 2
+)
+
+// Section is a 16x16x16 cube of a chunk. Blocks and biomes are stored as
+// indexes into section local palettes, the same way region files and the
+// network represent them.
+type Section struct {
+	palette []BlockState
+	blocks  [SectionVolume]uint16
+	nonAir  int
+
+	biomePalette []string
+	biomes       [BiomeVolume]uint8
+
+	// BlockLight and SkyLight are nibble arrays of LightLength bytes or nil if unknown
+	BlockLight []byte
+	SkyLight   []byte
+}
+
+func NewSection(biome string) *Section {
+	return &Section{
+		palette:      []BlockState{Air},
+		biomePalette: []string{biome},
+	}
+}
+
+func blockIndex(x, y, z int) int {
+	return (y&0xF)<<8 | (z&0xF)<<4 | x&0xF
+}
+
+func biomeIndex(x, y, z int) int {
+	return (y&0x3)<<4 | (z&0x3)<<2 | x&0x3
+}
+
+func (s *Section) Block(x, y, z int) BlockState {
+	return s.palette[s.blocks[blockIndex(x, y, z)]]
+}
+
+// SetBlock sets block at section local coordinates and returns the previous one
+func (s *Section) SetBlock(x, y, z int, b BlockState) BlockState {
+	i := blockIndex(x, y, z)
+	prev := s.palette[s.blocks[i]]
+	if prev == b {
+		return prev
+	}
+	s.blocks[i] = s.paletteIndex(b)
+	if prev.IsAir() && !b.IsAir() {
+		s.nonAir++
+	} else if !prev.IsAir() && b.IsAir() {
+		s.nonAir--
+	}
+	return prev
+}
+
+// Fill sets every block of the section to b
+func (s *Section) Fill(b BlockState) {
+	s.palette = []BlockState{b}
+	clear(s.blocks[:])
+	s.nonAir = 0
+	if !b.IsAir() {
+		s.nonAir = SectionVolume
+	}
+}
+
+func (s *Section) paletteIndex(b BlockState) uint16 {
+	for i, p := range s.palette {
+		if p == b {
+			return uint16(i)
+		}
+	}
+	if len(s.palette) >= SectionVolume {
+		// the block being replaced still holds its entry, so the palette
+		// may end up one entry over the volume
+		s.Compact()
+	}
+	s.palette = append(s.palette, b)
+	return uint16(len(s.palette) - 1)
+}
+
+// Compact drops palette entries that are no longer referenced
+func (s *Section) Compact() {
+	used := make([]bool, len(s.palette))
+	for _, i := range s.blocks {
+		used[i] = true
+	}
+	remap := make([]uint16, len(s.palette))
+	palette := s.palette[:0:0]
+	for i, b := range s.palette {
+		if used[i] {
+			remap[i] = uint16(len(palette))
+			palette = append(palette, b)
+		}
+	}
+	for i, v := range s.blocks {
+		s.blocks[i] = remap[v]
+	}
+	s.palette = palette
+}
+
+// BlockData returns a compacted palette and per block indexes into it in YZX order
+func (s *Section) BlockData() ([]BlockState, []uint16) {
+	s.Compact()
+	idx := make([]uint16, SectionVolume)
+	copy(idx, s.blocks[:])
+	return append([]BlockState(nil), s.palette...), idx
+}
+
+// SetBlockData replaces section blocks, idx entries must be valid palette indexes
+func (s *Section) SetBlockData(palette []BlockState, idx []uint16) {
+	s.palette = append(s.palette[:0:0], palette...)
+	if len(s.palette) == 0 {
+		s.palette = []BlockState{Air}
+	}
+	s.nonAir = 0
+	for i := range s.blocks {
+		var v uint16
+		if i < len(idx) && int(idx[i]) < len(s.palette) {
+			v = idx[i]
+		}
+		s.blocks[i] = v
+		if !s.palette[v].IsAir() {
+			s.nonAir++
+		}
+	}
+}
+
+func (s *Section) NonAirCount() int {
+	return s.nonAir
+}
+
+func (s *Section) IsEmpty() bool {
+	return s.nonAir == 0
+}
+
+// Biome returns biome of the 4x4x4 cell at cell coordinates 0..3
+func (s *Section) Biome(x, y, z int) string {
+	return s.biomePalette[s.biomes[biomeIndex(x, y, z)]]
+}
+
+func (s *Section) SetBiome(x, y, z int, biome string) {
+	for i, b := range s.biomePalette {
+		if b == biome {
+			s.biomes[biomeIndex(x, y, z)] = uint8(i)
+			return
+		}
+	}
+	s.biomePalette = append(s.biomePalette, biome)
+	s.biomes[biomeIndex(x, y, z)] = uint8(len(s.biomePalette) - 1)
+}
+
+// BiomeData returns biome palette and per cell indexes into it
+func (s *Section) BiomeData() ([]string, []uint16) {
+	idx := make([]uint16, BiomeVolume)
+	for i, v := range s.biomes {
+		idx[i] = uint16(v)
+	}
+	return append([]string(nil), s.biomePalette...), idx
+}
+
+func (s *Section) SetBiomeData(palette []string, idx []uint16) {
+	s.biomePalette = append(s.biomePalette[:0:0], palette...)
+	if len(s.biomePalette) == 0 {
+		s.biomePalette = []string{DefaultBiome}
+	}
+	for i := range s.biomes {
+		var v uint8
+		if i < len(idx) && int(idx[i]) < len(s.biomePalette) {
+			v = uint8(idx[i])
+		}
+		s.biomes[i] = v
+	}
+}
+
+// Light returns nibble value from a light array, nil array is treated as zero light
+func Light(arr []byte, x, y, z int) byte {
+	if arr == nil {
+		return 0
+	}
+	i := blockIndex(x, y, z)
+	return (arr[i>>1] >> ((i & 1) * 4)) & 0xF
+}
+
+func SetLight(arr []byte, x, y, z int, v byte) {
+	i := blockIndex(x, y, z)
+	shift := (i & 1) * 4
+	arr[i>>1] = arr[i>>1]&^(0xF<<shift) | (v&0xF)<<shift
+}
@@ -0,0 +1,55 @@
+package world
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackIndexes(t *testing.T) {
+	for _, bitsPerEntry := range []int{1, 4, 5, 9, 15} {
+		idx := make([]uint16, SectionVolume)
+		for i := range idx {
+			idx[i] = uint16(rand.Intn(1 << bitsPerEntry))
+		}
+		packed := PackIndexes(idx, bitsPerEntry)
+		require.Len(t, packed, (SectionVolume+64/bitsPerEntry-1)/(64/bitsPerEntry))
+
+		res := make([]uint16, SectionVolume)
+		require.True(t, UnpackIndexes(packed, bitsPerEntry, res))
+		require.Equal(t, idx, res)
+	}
+}
+
+func TestBitsFor(t *testing.T) {
+	require.Equal(t, 4, BitsFor(1, 4))
+	require.Equal(t, 4, BitsFor(16, 4))
+	require.Equal(t, 5, BitsFor(17, 4))
+	require.Equal(t, 1, BitsFor(2, 1))
+	require.Equal(t, 2, BitsFor(3, 1))
+}
+
+func TestSection_SetBlock(t *testing.T) {
+	s := NewSection(DefaultBiome)
+	require.True(t, s.IsEmpty())
+
+	prev := s.SetBlock(1, 2, 3, Stone)
+	require.Equal(t, Air, prev)
+	require.Equal(t, Stone, s.Block(1, 2, 3))
+	require.Equal(t, 1, s.NonAirCount())
+
+	s.SetBlock(1, 2, 3, Air)
+	require.True(t, s.IsEmpty())
+
+	palette, _ := s.BlockData()
+	require.Equal(t, []BlockState{Air}, palette)
+}
+
+func TestParseBlockState(t *testing.T) {
+	b := ParseBlockState("oak_log[axis=x]")
+	require.Equal(t, BlockState{Name: "minecraft:oak_log", Properties: "axis=x"}, b)
+	require.Equal(t, "x", b.Property("axis"))
+	require.Equal(t, "minecraft:oak_log[axis=z]", b.WithProperty("axis", "z").String())
+	require.Equal(t, Grass, NewBlockState("minecraft:grass_block", map[string]string{"snowy": "false"}))
+}
@@ -0,0 +1,16 @@
+package world
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// HashSeed returns the seed hash sent to clients in Login (play) and Respawn,
+// the first 8 bytes of SHA-256 of the little endian seed as vanilla does with
+// Guava's Hashing.sha256().hashLong(seed).asLong()
+func HashSeed(seed int64) int64 {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(seed))
+	sum := sha256.Sum256(b[:])
+	return int64(binary.LittleEndian.Uint64(sum[:8]))
+}
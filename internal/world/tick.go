@@ -0,0 +1,103 @@
+package world
+
+import (
+	"container/heap"
+)
+
+// DayLength is the number of ticks in a day
+const DayLength = 24000
+
+// BlockTickFunc runs when a scheduled block tick is due, it is called from
+// the tick goroutine
+type BlockTickFunc func(w *World, pos BlockPos)
+
+type blockTick struct {
+	due int64
+	// seq keeps ticks scheduled for the same game tick in order
+	seq int64
+	pos BlockPos
+	fn  BlockTickFunc
+}
+
+type blockTickQueue []blockTick
+
+func (q blockTickQueue) Len() int { return len(q) }
+func (q blockTickQueue) Less(i, j int) bool {
+	if q[i].due != q[j].due {
+		return q[i].due < q[j].due
+	}
+	return q[i].seq < q[j].seq
+}
+func (q blockTickQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *blockTickQueue) Push(x any)   { *q = append(*q, x.(blockTick)) }
+func (q *blockTickQueue) Pop() any {
+	old := *q
+	t := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return t
+}
+
+// Time returns the world age in ticks
+func (w *World) Time() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.time
+}
+
+// DayTime returns the time of day in ticks, it grows past DayLength as days pass
+func (w *World) DayTime() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dayTime
+}
+
+func (w *World) SetDayTime(t int64) {
+	w.mu.Lock()
+	w.dayTime = t
+	w.mu.Unlock()
+}
+
+// DaylightCycle reports whether the time of day advances
+func (w *World) DaylightCycle() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.daylightCycle
+}
+
+func (w *World) SetDaylightCycle(enabled bool) {
+	w.mu.Lock()
+	w.daylightCycle = enabled
+	w.mu.Unlock()
+}
+
+// ScheduleBlockTick runs fn at pos after delay ticks
+func (w *World) ScheduleBlockTick(pos BlockPos, delay int64, fn BlockTickFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tickSeq++
+	heap.Push(&w.blockTicks, blockTick{due: w.time + max(delay, 1), seq: w.tickSeq, pos: pos, fn: fn})
+}
+
+// Tick advances the world by one tick and runs due block ticks
+func (w *World) Tick() {
+	w.mu.Lock()
+	w.time++
+	if w.daylightCycle {
+		w.dayTime++
+	}
+	if w.weatherTime > 0 {
+		w.weatherTime--
+		if w.weatherTime == 0 {
+			w.weather = Clear
+		}
+	}
+	var due []blockTick
+	for len(w.blockTicks) > 0 && w.blockTicks[0].due <= w.time {
+		due = append(due, heap.Pop(&w.blockTicks).(blockTick))
+	}
+	w.mu.Unlock()
+
+	for _, t := range due {
+		t.fn(w, t.pos)
+	}
+}
@@ -0,0 +1,82 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type emptyGenerator struct{}
+
+func (emptyGenerator) Generate(*Chunk) {}
+func (emptyGenerator) Spawn() BlockPos { return BlockPos{} }
+func (emptyGenerator) Flat() bool      { return false }
+
+func TestTickAdvancesTime(t *testing.T) {
+	w := New(Options{Generator: emptyGenerator{}})
+	for range 3 {
+		w.Tick()
+	}
+	require.Equal(t, int64(3), w.Time())
+	require.Equal(t, int64(3), w.DayTime())
+
+	w.SetDaylightCycle(false)
+	w.Tick()
+	require.Equal(t, int64(4), w.Time())
+	require.Equal(t, int64(3), w.DayTime())
+
+	w.SetDayTime(DayLength / 2)
+	require.Equal(t, int64(DayLength/2), w.DayTime())
+}
+
+func TestScheduleBlockTick(t *testing.T) {
+	w := New(Options{Generator: emptyGenerator{}})
+	var ran []int
+	schedule := func(id int, delay int64) {
+		w.ScheduleBlockTick(BlockPos{X: id}, delay, func(w *World, pos BlockPos) {
+			ran = append(ran, pos.X)
+		})
+	}
+	schedule(1, 2)
+	schedule(2, 1)
+	schedule(3, 2)
+	schedule(4, 0)
+
+	w.Tick()
+	require.Equal(t, []int{2, 4}, ran)
+	w.Tick()
+	require.Equal(t, []int{2, 4, 1, 3}, ran)
+	w.Tick()
+	require.Len(t, ran, 4)
+}
+
+func TestScheduleBlockTickFromTick(t *testing.T) {
+	w := New(Options{Generator: emptyGenerator{}})
+	count := 0
+	var fn BlockTickFunc
+	fn = func(w *World, pos BlockPos) {
+		count++
+		w.ScheduleBlockTick(pos, 1, fn)
+	}
+	w.ScheduleBlockTick(BlockPos{}, 1, fn)
+	for range 5 {
+		w.Tick()
+	}
+	require.Equal(t, 5, count)
+}
+
+func TestWeather(t *testing.T) {
+	w := New(Options{Generator: emptyGenerator{}})
+	require.Equal(t, Clear, w.Weather())
+	w.SetWeather(Thunder, 2)
+	w.Tick()
+	require.Equal(t, Thunder, w.Weather())
+	w.Tick()
+	require.Equal(t, Clear, w.Weather())
+
+	w.SetWeather(Rain, 0)
+	for range weatherDurations[Rain][0] - 1 {
+		w.Tick()
+	}
+	require.Equal(t, Rain, w.Weather())
+}
@@ -0,0 +1,52 @@
+package world
+
+import (
+	"math/rand/v2"
+)
+
+type Weather byte
+
+const (
+	Clear Weather = iota
+	Rain
+	Thunder
+)
+
+var weatherNames = [...]string{"clear", "rain", "thunder"}
+
+func (w Weather) String() string {
+	if int(w) < len(weatherNames) {
+		return weatherNames[w]
+	}
+	return "unknown"
+}
+
+// weatherDurations are the vanilla ranges of weather set without a duration, in ticks
+var weatherDurations = [...][2]int64{
+	Clear:   {12000, 180000},
+	Rain:    {12000, 24000},
+	Thunder: {3600, 15600},
+}
+
+// Weather returns the current weather
+func (w *World) Weather() Weather {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.weather
+}
+
+// SetWeather changes the weather for duration ticks, after that it clears up.
+// A zero duration picks a random one as vanilla does.
+func (w *World) SetWeather(weather Weather, duration int64) {
+	if duration <= 0 {
+		r := weatherDurations[weather]
+		duration = r[0] + rand.Int64N(r[1]-r[0]+1)
+	}
+	w.mu.Lock()
+	w.weather = weather
+	w.weatherTime = duration
+	if weather == Clear {
+		w.weatherTime = 0
+	}
+	w.mu.Unlock()
+}
@@ -0,0 +1,279 @@
+package world
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/BinaryArchaism/mc-srv/internal/metrics"
+)
+
+// Generator fills chunks that were never saved
+type Generator interface {
+	Generate(c *Chunk)
+	// Spawn returns the default spawn position of the generated world
+	Spawn() BlockPos
+	// Flat reports whether clients should render the world as superflat (horizon at y 0)
+	Flat() bool
+}
+
+type Options struct {
+	Dimension string
+	MinY      int
+	Height    int
+	Seed      int64
+	Generator Generator
+	// Storage is optional, without it chunks live only in memory
+	Storage Storage
+}
+
+// World is a single dimension. Chunks are loaded from storage or generated
+// lazily the first time they are requested.
+type World struct {
+	Dimension string
+	MinY      int
+	Height    int
+	Seed      int64
+
+	generator Generator
+	storage   Storage
+
+	mu      sync.Mutex
+	chunks  map[ChunkPos]*Chunk
+	pending map[ChunkPos]*pendingChunk
+	spawn   BlockPos
+
+	time          int64
+	dayTime       int64
+	daylightCycle bool
+	blockTicks    blockTickQueue
+	tickSeq       int64
+
+	weather     Weather
+	weatherTime int64
+}
+
+type pendingChunk struct {
+	done  chan struct{}
+	chunk *Chunk
+	err   error
+}
+
+func New(opts Options) *World {
+	if opts.Dimension == "" {
+		opts.Dimension = "minecraft:overworld"
+	}
+	if opts.Height == 0 {
+		opts.MinY, opts.Height = OverworldMinY, OverworldHeight
+	}
+	return &World{
+		Dimension: opts.Dimension,
+		MinY:      opts.MinY,
+		Height:    opts.Height,
+		Seed:      opts.Seed,
+		generator: opts.Generator,
+		storage:   opts.Storage,
+		chunks:    map[ChunkPos]*Chunk{},
+		pending:   map[ChunkPos]*pendingChunk{},
+		spawn:     opts.Generator.Spawn(),
+
+		daylightCycle: true,
+	}
+}
+
+func (w *World) Flat() bool {
+	return w.generator.Flat()
+}
+
+func (w *World) Spawn() BlockPos {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.spawn
+}
+
+func (w *World) SetSpawn(pos BlockPos) {
+	w.mu.Lock()
+	w.spawn = pos
+	w.mu.Unlock()
+}
+
+// LoadedChunk returns chunk only if it is already in memory
+func (w *World) LoadedChunk(x, z int32) *Chunk {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.chunks[ChunkPos{X: x, Z: z}]
+}
+
+// LoadedChunks returns positions of all chunks in memory
+func (w *World) LoadedChunks() []ChunkPos {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	res := make([]ChunkPos, 0, len(w.chunks))
+	for pos := range w.chunks {
+		res = append(res, pos)
+	}
+	return res
+}
+
+// Chunk returns chunk x, z loading or generating it if needed. Concurrent
+// requests for the same chunk wait for a single load.
+func (w *World) Chunk(x, z int32) (*Chunk, error) {
+	pos := ChunkPos{X: x, Z: z}
+	w.mu.Lock()
+	if c, ok := w.chunks[pos]; ok {
+		w.mu.Unlock()
+		return c, nil
+	}
+	if p, ok := w.pending[pos]; ok {
+		w.mu.Unlock()
+		<-p.done
+		return p.chunk, p.err
+	}
+	p := &pendingChunk{done: make(chan struct{})}
+	w.pending[pos] = p
+	w.mu.Unlock()
+
+	p.chunk, p.err = w.load(x, z)
+
+	w.mu.Lock()
+	delete(w.pending, pos)
+	if p.err == nil {
+		w.chunks[pos] = p.chunk
+	}
+	w.mu.Unlock()
+	close(p.done)
+	return p.chunk, p.err
+}
+
+func (w *World) load(x, z int32) (*Chunk, error) {
+	if w.storage != nil {
+		start := time.Now()
+		c, err := w.storage.LoadChunk(x, z)
+		if err == nil {
+			metrics.ChunkLoadSeconds.Observe(time.Since(start).Seconds())
+			return c, nil
+		}
+		if !errors.Is(err, ErrChunkNotFound) {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	c := NewChunk(x, z, w.MinY, w.Height)
+	w.generator.Generate(c)
+	metrics.ChunkGenerateSeconds.Observe(time.Since(start).Seconds())
+	c.MarkDirty()
+	return c, nil
+}
+
+// Block returns block at absolute coordinates, loading its chunk if needed
+func (w *World) Block(x, y, z int) (BlockState, error) {
+	c, err := w.Chunk(int32(x>>4), int32(z>>4))
+	if err != nil {
+		return Air, err
+	}
+	return c.Block(x&0xF, y, z&0xF), nil
+}
+
+// LoadedBlock returns block at absolute coordinates without loading its
+// chunk, false means the chunk is not loaded
+func (w *World) LoadedBlock(x, y, z int) (BlockState, bool) {
+	c := w.LoadedChunk(int32(x>>4), int32(z>>4))
+	if c == nil {
+		return Air, false
+	}
+	return c.Block(x&0xF, y, z&0xF), true
+}
+
+// SetBlock sets block at absolute coordinates and returns the previous one
+func (w *World) SetBlock(x, y, z int, b BlockState) (BlockState, error) {
+	c, err := w.Chunk(int32(x>>4), int32(z>>4))
+	if err != nil {
+		return Air, err
+	}
+	return c.SetBlock(x&0xF, y, z&0xF, b), nil
+}
+
+// Unload saves chunk if it's dirty and drops it from memory
+func (w *World) Unload(x, z int32) error {
+	pos := ChunkPos{X: x, Z: z}
+	w.mu.Lock()
+	c, ok := w.chunks[pos]
+	delete(w.chunks, pos)
+	w.mu.Unlock()
+	if !ok || w.storage == nil || !c.Dirty() {
+		return nil
+	}
+	return w.storage.SaveChunk(c)
+}
+
+// Save writes all dirty chunks to storage
+func (w *World) Save() error {
+	if w.storage == nil {
+		return nil
+	}
+	w.mu.Lock()
+	chunks := make([]*Chunk, 0, len(w.chunks))
+	for _, c := range w.chunks {
+		chunks = append(chunks, c)
+	}
+	w.mu.Unlock()
+
+	var errs []error
+	for _, c := range chunks {
+		if c.Dirty() {
+			errs = append(errs, w.storage.SaveChunk(c))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close saves the world and closes its storage
+func (w *World) Close() error {
+	err := w.Save()
+	if w.storage != nil {
+		err = errors.Join(err, w.storage.Close())
+	}
+	return err
+}
+
+// Preload loads or generates chunks using workers goroutines and returns the first error
+func (w *World) Preload(ctx context.Context, positions []ChunkPos, workers int) error {
+	workers = max(workers, 1)
+	jobs := make(chan ChunkPos)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pos := range jobs {
+				_, err := w.Chunk(pos.X, pos.Z)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+loop:
+	for _, pos := range positions {
+		select {
+		case jobs <- pos:
+		case err = <-errs:
+			break loop
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if err != nil {
+		return err
+	}
+	return <-errs
+}